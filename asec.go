@@ -30,10 +30,13 @@ import (
 	"asec/models"
 	"asec/settings"
 	"asec/utils"
+
+	"golang.org/x/crypto/acme"
 )
 
 func main() {
 	ver := flag.Bool("version", false, "Display Version Information")
+	rotateInstanceKey := flag.Bool("rotate-instance-key", false, "Re-encrypt stored secrets with a new instance key and exit (stop the service first)")
 	flag.Parse()
 	if *ver {
 		fmt.Println(data.Version)
@@ -55,16 +58,35 @@ func main() {
 		backend.InitDatabase()
 		settings.InitDefaultSettings() // instanceKey & nodesKey
 	}
+	if *rotateInstanceKey {
+		if data.IsPrimary == false {
+			fmt.Println("Error: the instance key can only be rotated on the primary node.")
+			os.Exit(1)
+		}
+		count, err := backend.RotateInstanceKey()
+		if err != nil {
+			fmt.Println("Rotate instance key failed, the old instance key is still in use:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Rotate instance key OK, re-encrypted secrets:", count)
+		os.Exit(0)
+	}
 	backend.LoadAppConfiguration()
 	firewall.InitFirewall()
 	settings.LoadSettings()
+	backend.InitACME()
+	go backend.RoutineCertMonitorTick()
+	go backend.RoutineOCSPTick()
 
 	tlsconfig := &tls.Config{
 		GetCertificate: func(helloInfo *tls.ClientHelloInfo) (*tls.Certificate, error) {
-			cert, err := backend.GetCertificateByDomain(helloInfo.ServerName)
-			return cert, err
+			if backend.IsACMETLSALPNHello(helloInfo) {
+				// ACME tls-alpn-01 validation
+				return backend.GetACMEChallengeCertificate(helloInfo.ServerName)
+			}
+			return backend.GetCertificateByHello(helloInfo)
 		},
-		NextProtos: []string{"h2", "http/1.1"},
+		NextProtos: []string{"h2", "http/1.1", acme.ALPNProto},
 		MaxVersion: tls.VersionTLS13,
 		MinVersion: tls.VersionTLS11,
 		CipherSuites: []uint16{
@@ -82,6 +104,10 @@ func main() {
 			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
 			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256},
 	}
+	tlsconfig.GetConfigForClient = func(helloInfo *tls.ClientHelloInfo) (*tls.Config, error) {
+		// TLS profile of application or domain
+		return backend.GetConfigForClient(helloInfo, tlsconfig)
+	}
 	gateMux := http.NewServeMux()
 	if data.IsPrimary {
 		admin := data.CFG.PrimaryNode.Admin
@@ -91,6 +117,7 @@ func main() {
 			adminMux.HandleFunc("/asec-admin/", gateway.AdminHandlerFunc)
 			adminMux.HandleFunc("/asec-admin/webssh", gateway.WebSSHHandlerFunc)
 			adminMux.HandleFunc("/asec-admin/oauth/get", gateway.OAuthGetHandleFunc)
+			adminMux.HandleFunc("/asec-admin/ca.crt", gateway.InternalCAHandlerFunc)
 			if len(admin.ListenHTTP) > 0 {
 				go func() {
 					listen, err := net.Listen("tcp", admin.ListenHTTP)
@@ -127,6 +154,9 @@ func main() {
 	gateMux.HandleFunc("/captcha/validate", gateway.ValidateCaptchaHandlerFunc)
 	gateMux.Handle("/captcha/png/", gateway.ShowCaptchaImage())
 
+	// ACME http-01 challenge
+	gateMux.HandleFunc("/.well-known/acme-challenge/", gateway.ACMEChallengeHandlerFunc)
+
 	// Reverse Proxy
 	gateMux.HandleFunc("/", gateway.ReverseHandlerFunc)
 	ctxGateMux := AddContextHandler(gateMux)
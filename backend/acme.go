@@ -0,0 +1,484 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:05:31
+ * @Last Modified: thonsun, 2026-10-19 14:05:31
+ */
+
+package backend
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+
+	"golang.org/x/crypto/acme"
+)
+
+var (
+	acmeClient     *acme.Client
+	acmeClientLock sync.Mutex
+
+	// acmeChallenges map[string]*models.ACMEChallenge, key: http-01 token or tls-alpn-01 domain
+	acmeChallenges sync.Map
+	// acmeIssuing map[string]bool, domains which are being issued
+	acmeIssuing sync.Map
+)
+
+// IsACMEEnabled ...
+func IsACMEEnabled() bool {
+	return data.IsPrimary && data.CFG.PrimaryNode.ACME.Enabled
+}
+
+// InitACME start the renewal routine of ACME certificates
+func InitACME() {
+	if IsACMEEnabled() == false {
+		return
+	}
+	go RoutineACMERenewTick()
+}
+
+// GetACMEClient return the registered ACME client, the account key is saved in settings
+func GetACMEClient(ctx context.Context) (*acme.Client, error) {
+	acmeClientLock.Lock()
+	defer acmeClientLock.Unlock()
+	if acmeClient != nil {
+		return acmeClient, nil
+	}
+	acmeConfig := data.CFG.PrimaryNode.ACME
+	accountKey, err := loadACMEAccountKey()
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := newACMEHTTPClient(acmeConfig.CACertFile)
+	if err != nil {
+		return nil, err
+	}
+	client := &acme.Client{
+		Key:          accountKey,
+		DirectoryURL: acmeConfig.DirectoryURL,
+		HTTPClient:   httpClient,
+		UserAgent:    "asec/" + data.Version,
+	}
+	account := &acme.Account{}
+	if len(acmeConfig.Email) > 0 {
+		account.Contact = []string{"mailto:" + acmeConfig.Email}
+	}
+	_, err = client.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, err
+	}
+	acmeClient = client
+	return acmeClient, nil
+}
+
+func loadACMEAccountKey() (crypto.Signer, error) {
+	if data.DAL.ExistsSetting("acme_account_key") {
+		hexEncryptedKey, err := data.DAL.SelectStringSetting("acme_account_key")
+		if err != nil {
+			return nil, err
+		}
+		encryptedKey, err := hex.DecodeString(hexEncryptedKey)
+		if err != nil {
+			return nil, err
+		}
+		keyDER, err := data.AES256Decrypt(encryptedKey, false)
+		if err != nil {
+			return nil, err
+		}
+		return x509.ParseECPrivateKey(keyDER)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	err = data.WithInstanceKey(func(encrypt func(plaintext []byte) []byte) error {
+		return data.DAL.SaveStringSetting("acme_account_key", hex.EncodeToString(encrypt(keyDER)))
+	})
+	return key, err
+}
+
+func newACMEHTTPClient(caCertFile string) (*http.Client, error) {
+	if len(caCertFile) == 0 {
+		return http.DefaultClient, nil
+	}
+	caCertPEM, err := ioutil.ReadFile(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+	rootCAs := x509.NewCertPool()
+	if rootCAs.AppendCertsFromPEM(caCertPEM) == false {
+		return nil, errors.New("no certificate found in " + caCertFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+	return &http.Client{Transport: transport, Timeout: 60 * time.Second}, nil
+}
+
+// ObtainACMECertificate finish an ACME order for the domains, return PEM certificate chain and private key
+func ObtainACMECertificate(ctx context.Context, client *acme.Client, domains []string, challengeType string) (certContent string, privKeyContent string, err error) {
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domains...))
+	if err != nil {
+		return "", "", err
+	}
+	var challengeKeys []string
+	defer func() {
+		for _, key := range challengeKeys {
+			acmeChallenges.Delete(key)
+		}
+	}()
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return "", "", err
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		challenge := selectACMEChallenge(authz.Challenges, challengeType)
+		if challenge == nil {
+			return "", "", errors.New("no supported challenge for " + authz.Identifier.Value)
+		}
+		domain := authz.Identifier.Value
+		switch challenge.Type {
+		case "http-01":
+			keyAuth, err := client.HTTP01ChallengeResponse(challenge.Token)
+			if err != nil {
+				return "", "", err
+			}
+			acmeChallenges.Store(challenge.Token, &models.ACMEChallenge{Token: challenge.Token, Domain: domain, KeyAuth: keyAuth})
+			challengeKeys = append(challengeKeys, challenge.Token)
+		case "tls-alpn-01":
+			tlsCert, err := client.TLSALPN01ChallengeCert(challenge.Token, domain)
+			if err != nil {
+				return "", "", err
+			}
+			acmeChallenge := &models.ACMEChallenge{Token: challenge.Token, Domain: domain, TlsCert: &tlsCert}
+			acmeChallenge.CertContent, acmeChallenge.PrivKeyContent, err = EncodeTLSCertificate(&tlsCert)
+			if err != nil {
+				return "", "", err
+			}
+			acmeChallenges.Store(domain, acmeChallenge)
+			challengeKeys = append(challengeKeys, domain)
+		}
+		if _, err = client.Accept(ctx, challenge); err != nil {
+			return "", "", err
+		}
+		if _, err = client.WaitAuthorization(ctx, authz.URI); err != nil {
+			return "", "", err
+		}
+	}
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return "", "", err
+	}
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+	csrTemplate := &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: domains[0]},
+		DNSNames: domains,
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, csrTemplate, privKey)
+	if err != nil {
+		return "", "", err
+	}
+	derChain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return "", "", err
+	}
+	var certPEM []byte
+	for _, der := range derChain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	privKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privKey)})
+	return string(certPEM), string(privKeyPEM), nil
+}
+
+func selectACMEChallenge(challenges []*acme.Challenge, challengeType string) *acme.Challenge {
+	if len(challengeType) == 0 {
+		challengeType = "http-01"
+	}
+	var supported *acme.Challenge
+	for _, challenge := range challenges {
+		if challenge.Type == challengeType {
+			return challenge
+		}
+		if supported == nil && (challenge.Type == "http-01" || challenge.Type == "tls-alpn-01") {
+			supported = challenge
+		}
+	}
+	return supported
+}
+
+// EncodeTLSCertificate convert tls.Certificate to PEM certificate chain and PKCS#8 private key
+func EncodeTLSCertificate(tlsCert *tls.Certificate) (certContent string, privKeyContent string, err error) {
+	var certPEM []byte
+	for _, der := range tlsCert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(tlsCert.PrivateKey)
+	if err != nil {
+		return "", "", err
+	}
+	privKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return string(certPEM), string(privKeyPEM), nil
+}
+
+// IssueACMECertificate obtain a certificate for domains and save it,
+// the certificate certItem (may be nil for new certificate) is replaced if renewed.
+// A new certificate is added after all fields are set, and a renewed one is replaced under certsMutex,
+// the handshakes in progress keep the previous TLS certificate.
+func IssueACMECertificate(domains []string, certItem *models.CertItem) (*models.CertItem, error) {
+	if IsACMEEnabled() == false {
+		return nil, errors.New("ACME is not enabled, please check config.json")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	client, err := GetACMEClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	certContent, privKeyContent, err := ObtainACMECertificate(ctx, client, domains, data.CFG.PrimaryNode.ACME.ChallengeType)
+	if err != nil {
+		return nil, err
+	}
+	tlsCert, err := tls.X509KeyPair([]byte(certContent), []byte(privKeyContent))
+	if err != nil {
+		return nil, err
+	}
+	commonName := domains[0]
+	expireTime := data.GetCertificateExpiryTime(certContent)
+	description := "ACME: " + strings.Join(domains, ",")
+	if certItem == nil {
+		var newID int64
+		data.WithInstanceKey(func(encrypt func(plaintext []byte) []byte) error {
+			newID = data.DAL.InsertCertificate(commonName, certContent, encrypt([]byte(privKeyContent)), expireTime, description, true)
+			return nil
+		})
+		certItem = &models.CertItem{
+			ID:             newID,
+			CommonName:     commonName,
+			CertContent:    certContent,
+			PrivKeyContent: privKeyContent,
+			TlsCert:        tlsCert,
+			ExpireTime:     expireTime,
+			Description:    description,
+			ACME:           true,
+			DNSNames:       domains,
+		}
+		addCertItem(certItem)
+		data.UpdateBackendLastModified()
+		return certItem, nil
+	}
+	err = data.WithInstanceKey(func(encrypt func(plaintext []byte) []byte) error {
+		return data.DAL.UpdateCertificate(commonName, certContent, encrypt([]byte(privKeyContent)), expireTime, description, true, certItem.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	certsMutex.Lock()
+	certItem.CommonName = commonName
+	certItem.CertContent = certContent
+	certItem.PrivKeyContent = privKeyContent
+	setTLSCertificate(certItem, tlsCert)
+	certItem.ExpireTime = expireTime
+	certItem.Description = description
+	certItem.ACME = true
+	certItem.DNSNames = domains
+	// The status of the previous certificate, refreshed by RoutineOCSPTick
+	certItem.OCSP = nil
+	certsMutex.Unlock()
+	data.UpdateBackendLastModified()
+	return certItem, nil
+}
+
+// IssueACMECertificateAPI used by administrators, object: {"domains": ["www.example.com"]}
+func IssueACMECertificateAPI(param map[string]interface{}, authUser *models.AuthUser) (*models.CertItem, error) {
+	if authUser.IsCertAdmin == false {
+		return nil, errors.New("Only certificate administrators can issue certificates.")
+	}
+	obj := param["object"].(map[string]interface{})
+	domainsI := obj["domains"].([]interface{})
+	var domains []string
+	for _, domainI := range domainsI {
+		domain := strings.TrimSpace(domainI.(string))
+		if strings.HasPrefix(domain, "*.") {
+			return nil, errors.New("Wildcard domain is not supported by http-01 and tls-alpn-01: " + domain)
+		}
+		if len(domain) > 0 {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) == 0 {
+		return nil, errors.New("No domain designated.")
+	}
+	return IssueACMECertificate(domains, nil)
+}
+
+// AutoIssueACMECertificate issue certificate for the new domain which has no certificate
+func AutoIssueACMECertificate(domain *models.Domain) {
+	if IsACMEEnabled() == false || domain.CertID > 0 || strings.HasPrefix(domain.Name, "*.") {
+		return
+	}
+	if _, issuing := acmeIssuing.LoadOrStore(domain.Name, true); issuing {
+		return
+	}
+	defer acmeIssuing.Delete(domain.Name)
+	domainName := domain.Name
+	certItem, err := IssueACMECertificate([]string{domainName}, nil)
+	if err != nil {
+		utils.DebugPrintln("AutoIssueACMECertificate", domainName, err)
+		return
+	}
+	// The domain may be modified or deleted during the issuance, which takes up to 5 minutes
+	domain = GetDomainByID(domain.ID)
+	if domain == nil || domain.Name != domainName || domain.CertID > 0 {
+		utils.DebugPrintln("AutoIssueACMECertificate domain changed during issuance", domainName)
+		return
+	}
+	err = data.DAL.UpdateDomain(domain.Name, domain.AppID, certItem.ID, domain.Redirect, domain.Location, domain.AltCertID, domain.TLSProfileID, domain.ID)
+	if err != nil {
+		utils.DebugPrintln("AutoIssueACMECertificate UpdateDomain", domain.Name, err)
+		return
+	}
+	domain.CertID = certItem.ID
+	domain.Cert = certItem
+	DomainsMap.Store(domain.Name, models.DomainRelation{App: domain.App, Cert: certItem, AltCert: domain.AltCert, Redirect: domain.Redirect, Location: domain.Location, TLSProfileID: domain.TLSProfileID})
+	data.UpdateBackendLastModified()
+}
+
+// RoutineACMERenewTick renew the ACME certificates before expiration
+func RoutineACMERenewTick() {
+	RenewACMECertificates()
+	routineTicker := time.NewTicker(12 * time.Hour)
+	for range routineTicker.C {
+		RenewACMECertificates()
+	}
+}
+
+// RenewACMECertificates ...
+func RenewACMECertificates() {
+	renewBeforeDays := data.CFG.PrimaryNode.ACME.RenewBeforeDays
+	if renewBeforeDays <= 0 {
+		renewBeforeDays = 30
+	}
+	renewTime := time.Now().Unix() + renewBeforeDays*86400
+	for _, certItem := range GetCertItems() {
+		if certItem.ACME == false || certItem.ExpireTime > renewTime {
+			continue
+		}
+		domains := GetCertificateDNSNames(certItem)
+		if len(domains) == 0 {
+			continue
+		}
+		_, err := IssueACMECertificate(domains, certItem)
+		if err != nil {
+			utils.DebugPrintln("RenewACMECertificates", certItem.CommonName, err)
+		}
+	}
+}
+
+// GetCertificateDNSNames return the DNS names of the leaf certificate
+func GetCertificateDNSNames(certItem *models.CertItem) []string {
+	block, _ := pem.Decode([]byte(certItem.CertContent))
+	if block == nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames
+	}
+	if len(cert.Subject.CommonName) > 0 {
+		return []string{cert.Subject.CommonName}
+	}
+	return nil
+}
+
+// GetACMEChallenge return pending challenge by http-01 token or tls-alpn-01 domain,
+// the replica nodes get it from the primary node.
+func GetACMEChallenge(key string) (*models.ACMEChallenge, error) {
+	if acmeChallenge, ok := acmeChallenges.Load(key); ok {
+		return acmeChallenge.(*models.ACMEChallenge), nil
+	}
+	if data.IsPrimary {
+		return nil, errors.New("ACME challenge not found")
+	}
+	acmeChallenge := RPCGetACMEChallenge(key)
+	if acmeChallenge == nil {
+		return nil, errors.New("ACME challenge not found")
+	}
+	if len(acmeChallenge.CertContent) > 0 {
+		tlsCert, err := tls.X509KeyPair([]byte(acmeChallenge.CertContent), []byte(acmeChallenge.PrivKeyContent))
+		if err != nil {
+			return nil, err
+		}
+		acmeChallenge.TlsCert = &tlsCert
+	}
+	return acmeChallenge, nil
+}
+
+// GetACMEChallengeAPI used by replica nodes only (authenticated with auth_key), object: {"key": "token or domain"},
+// the response contains the private key of tls-alpn-01 certificate.
+func GetACMEChallengeAPI(param map[string]interface{}, isNode bool) (*models.ACMEChallenge, error) {
+	if isNode == false {
+		return nil, errors.New("Only replica nodes can get ACME challenges.")
+	}
+	obj, ok := param["object"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("object is required")
+	}
+	key, ok := obj["key"].(string)
+	if !ok || len(key) == 0 {
+		return nil, errors.New("key is required")
+	}
+	return GetACMEChallenge(key)
+}
+
+// IsACMETLSALPNHello check whether the ClientHello is a tls-alpn-01 validation
+func IsACMETLSALPNHello(helloInfo *tls.ClientHelloInfo) bool {
+	for _, proto := range helloInfo.SupportedProtos {
+		if proto == acme.ALPNProto {
+			return true
+		}
+	}
+	return false
+}
+
+// GetACMEChallengeCertificate return the tls-alpn-01 certificate of the domain
+func GetACMEChallengeCertificate(domain string) (*tls.Certificate, error) {
+	acmeChallenge, err := GetACMEChallenge(domain)
+	if err != nil {
+		return nil, err
+	}
+	if acmeChallenge.TlsCert == nil {
+		return nil, errors.New("ACME tls-alpn-01 challenge not found: " + domain)
+	}
+	return acmeChallenge.TlsCert, nil
+}
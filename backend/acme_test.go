@@ -0,0 +1,97 @@
+package backend
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"asec/data"
+	"asec/models"
+
+	"golang.org/x/crypto/acme"
+)
+
+// TestObtainACMECertificatePebble requires a local Pebble, see README.md
+func TestObtainACMECertificatePebble(t *testing.T) {
+	directoryURL := os.Getenv("ASEC_TEST_ACME_DIRECTORY")
+	if directoryURL == "" {
+		t.Skip("ASEC_TEST_ACME_DIRECTORY not set")
+	}
+	data.IsPrimary = true
+	httpClient, err := newACMEHTTPClient(os.Getenv("ASEC_TEST_ACME_CA"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	client := &acme.Client{Key: accountKey, DirectoryURL: directoryURL, HTTPClient: httpClient}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if _, err = client.Register(ctx, &acme.Account{}, acme.AcceptTOS); err != nil {
+		t.Fatal(err)
+	}
+
+	// http-01 responder, Pebble validates on port 5002 by default
+	httpAddr := os.Getenv("ASEC_TEST_ACME_HTTP_ADDR")
+	if httpAddr == "" {
+		httpAddr = ":5002"
+	}
+	listener, err := net.Listen("tcp", httpAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acmeChallenge, err := GetACMEChallenge(path.Base(r.URL.Path))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(acmeChallenge.KeyAuth))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	domain := os.Getenv("ASEC_TEST_ACME_DOMAIN")
+	if domain == "" {
+		domain = "www.asec.test"
+	}
+	certContent, privKeyContent, err := ObtainACMECertificate(ctx, client, []string{domain}, "http-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsCert, err := tls.X509KeyPair([]byte(certContent), []byte(privKeyContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tlsCert.Certificate) < 2 {
+		t.Error("certificate chain not bundled")
+	}
+	certItem := &models.CertItem{CertContent: certContent}
+	if names := GetCertificateDNSNames(certItem); len(names) != 1 || names[0] != domain {
+		t.Error("unexpected DNS names", names)
+	}
+}
+
+func TestGetACMEChallengeAPI(t *testing.T) {
+	acmeChallenges.Store("token1", &models.ACMEChallenge{Token: "token1", KeyAuth: "token1.thumbprint"})
+	defer acmeChallenges.Delete("token1")
+	param := map[string]interface{}{"object": map[string]interface{}{"key": "token1"}}
+	if _, err := GetACMEChallengeAPI(param, false); err == nil {
+		t.Error("ACME challenge returned without auth_key")
+	}
+	if acmeChallenge, err := GetACMEChallengeAPI(param, true); err != nil || acmeChallenge.KeyAuth != "token1.thumbprint" {
+		t.Error("unexpected ACME challenge", acmeChallenge, err)
+	}
+	for _, invalid := range []map[string]interface{}{{}, {"object": "token1"}, {"object": map[string]interface{}{"key": 1.0}}} {
+		if _, err := GetACMEChallengeAPI(invalid, true); err == nil {
+			t.Error("invalid param accepted", invalid)
+		}
+	}
+}
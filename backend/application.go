@@ -118,7 +118,7 @@ func GetApplicationByDomain(domain string) *models.Application {
 	if domainRelation, ok := DomainsMap.Load(wildDomain); ok {
 		domainRelation2 := domainRelation.(models.DomainRelation)
 		app := domainRelation2.App //DomainsMap[domain].App
-		DomainsMap.Store(domain, models.DomainRelation{App: app, Cert: domainRelation2.Cert, Redirect: false, Location: ""})
+		DomainsMap.Store(domain, models.DomainRelation{App: app, Cert: domainRelation2.Cert, AltCert: domainRelation2.AltCert, Redirect: false, Location: "", TLSProfileID: domainRelation2.TLSProfileID})
 		return app
 	}
 	return nil
@@ -130,18 +130,24 @@ func LoadApps() {
 		dbApps := data.DAL.SelectApplications()
 		for _, dbApp := range dbApps {
 			app := &models.Application{ID: dbApp.ID,
-				Name:           dbApp.Name,
-				InternalScheme: dbApp.InternalScheme,
-				RedirectHTTPS:  dbApp.RedirectHTTPS,
-				HSTSEnabled:    dbApp.HSTSEnabled,
-				WAFEnabled:     dbApp.WAFEnabled,
-				ClientIPMethod: dbApp.ClientIPMethod,
-				Description:    dbApp.Description,
-				Destinations:   []*models.Destination{},
-				Route:          sync.Map{},
-				OAuthRequired:  dbApp.OAuthRequired,
-				SessionSeconds: dbApp.SessionSeconds,
-				Owner:          dbApp.Owner}
+				Name:              dbApp.Name,
+				InternalScheme:    dbApp.InternalScheme,
+				RedirectHTTPS:     dbApp.RedirectHTTPS,
+				HSTSEnabled:       dbApp.HSTSEnabled,
+				WAFEnabled:        dbApp.WAFEnabled,
+				ClientIPMethod:    dbApp.ClientIPMethod,
+				Description:       dbApp.Description,
+				Destinations:      []*models.Destination{},
+				Route:             sync.Map{},
+				OAuthRequired:     dbApp.OAuthRequired,
+				SessionSeconds:    dbApp.SessionSeconds,
+				Owner:             dbApp.Owner,
+				TLSProfileID:      dbApp.TLSProfileID,
+				ScoringEnabled:    dbApp.ScoringEnabled,
+				InboundThreshold:  dbApp.InboundThreshold,
+				OutboundThreshold: dbApp.OutboundThreshold,
+				MonitorMode:       dbApp.MonitorMode,
+				ResponseInspectKB: dbApp.ResponseInspectKB}
 			Apps = append(Apps, app)
 		}
 	} else {
@@ -308,29 +314,67 @@ func UpdateApplication(param map[string]interface{}) (*models.Application, error
 	oauthRequired := application["oauth_required"].(bool)
 	sessionSeconds := int64(application["session_seconds"].(float64))
 	owner := application["owner"].(string)
+	var tlsProfileID int64
+	if tlsProfileIDFloat, ok := application["tls_profile_id"].(float64); ok {
+		tlsProfileID = int64(tlsProfileIDFloat)
+	}
+	if err := CheckTLSProfileID(tlsProfileID); err != nil {
+		return nil, err
+	}
+	appDomains, _ := application["domains"].([]interface{})
+	for _, domainInterface := range appDomains {
+		domainMap, _ := domainInterface.(map[string]interface{})
+		if domainTLSProfileID, ok := domainMap["tls_profile_id"].(float64); ok {
+			if err := CheckTLSProfileID(int64(domainTLSProfileID)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	scoringEnabled, _ := application["scoring_enabled"].(bool)
+	inboundThreshold, outboundThreshold := int64(models.DefaultInboundThreshold), int64(models.DefaultOutboundThreshold)
+	if threshold, ok := application["inbound_threshold"].(float64); ok && threshold > 0 {
+		inboundThreshold = int64(threshold)
+	}
+	if threshold, ok := application["outbound_threshold"].(float64); ok && threshold > 0 {
+		outboundThreshold = int64(threshold)
+	}
+	monitorMode, _ := application["monitor_mode"].(bool)
+	responseInspectKB := int64(models.DefaultResponseInspectKB)
+	if inspectKB, ok := application["response_inspect_kb"].(float64); ok && inspectKB > 0 {
+		responseInspectKB = int64(inspectKB)
+		if responseInspectKB > models.MaxResponseInspectKB {
+			responseInspectKB = models.MaxResponseInspectKB
+		}
+	}
 	var app *models.Application
 	if appID == 0 {
 		// new application
-		newID := data.DAL.InsertApplication(appName, internalScheme, redirectHttps, hstsEnabled, wafEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner)
+		newID := data.DAL.InsertApplication(appName, internalScheme, redirectHttps, hstsEnabled, wafEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner, tlsProfileID, scoringEnabled, inboundThreshold, outboundThreshold, monitorMode, responseInspectKB)
 		app = &models.Application{
 			ID: newID, Name: appName,
 			InternalScheme: internalScheme,
 			//Destinations:   []*models.Destination{},
-			Route:          sync.Map{},
-			Domains:        []*models.Domain{},
-			RedirectHTTPS:  redirectHttps,
-			HSTSEnabled:    hstsEnabled,
-			WAFEnabled:     wafEnabled,
-			ClientIPMethod: ipMethod,
-			Description:    description,
-			OAuthRequired:  oauthRequired,
-			SessionSeconds: sessionSeconds,
-			Owner:          owner}
+			Route:             sync.Map{},
+			Domains:           []*models.Domain{},
+			RedirectHTTPS:     redirectHttps,
+			HSTSEnabled:       hstsEnabled,
+			WAFEnabled:        wafEnabled,
+			ClientIPMethod:    ipMethod,
+			Description:       description,
+			OAuthRequired:     oauthRequired,
+			SessionSeconds:    sessionSeconds,
+			Owner:             owner,
+			TLSProfileID:      tlsProfileID,
+			ScoringEnabled:    scoringEnabled,
+			InboundThreshold:  inboundThreshold,
+			OutboundThreshold: outboundThreshold,
+			MonitorMode:       monitorMode,
+			ResponseInspectKB: responseInspectKB}
 		Apps = append(Apps, app)
 	} else {
 		app, _ = GetApplicationByID(appID)
 		if app != nil {
-			data.DAL.UpdateApplication(appName, internalScheme, redirectHttps, hstsEnabled, wafEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner, appID)
+			data.DAL.UpdateApplication(appName, internalScheme, redirectHttps, hstsEnabled, wafEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner, tlsProfileID, scoringEnabled, inboundThreshold, outboundThreshold, monitorMode, responseInspectKB, appID)
 			app.Name = appName
 			app.InternalScheme = internalScheme
 			app.RedirectHTTPS = redirectHttps
@@ -341,13 +385,18 @@ func UpdateApplication(param map[string]interface{}) (*models.Application, error
 			app.OAuthRequired = oauthRequired
 			app.SessionSeconds = sessionSeconds
 			app.Owner = owner
+			app.TLSProfileID = tlsProfileID
+			app.ScoringEnabled = scoringEnabled
+			app.InboundThreshold = inboundThreshold
+			app.OutboundThreshold = outboundThreshold
+			app.MonitorMode = monitorMode
+			app.ResponseInspectKB = responseInspectKB
 		} else {
 			return nil, errors.New("Application not found.")
 		}
 	}
 	destinations := application["destinations"].([]interface{})
 	UpdateDestinations(app, destinations)
-	appDomains := application["domains"].([]interface{})
 	UpdateAppDomains(app, appDomains)
 	data.UpdateBackendLastModified()
 	return app, nil
@@ -0,0 +1,43 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:15:14
+ * @Last Modified: thonsun, 2026-10-19 14:15:14
+ */
+
+package backend
+
+import (
+	"errors"
+	"time"
+
+	"asec/data"
+	"asec/models"
+)
+
+// WriteAuditLog record the sensitive operation of administrator, the audit logs are not expired
+func WriteAuditLog(authUser *models.AuthUser, srcIP string, action string, object string) error {
+	if len(object) > 1024 {
+		object = object[:1024]
+	}
+	auditLog := &models.AuditLog{
+		Username:    authUser.Username,
+		Action:      action,
+		Object:      object,
+		SrcIP:       srcIP,
+		RequestTime: time.Now().Unix(),
+	}
+	return data.DAL.InsertAuditLog(auditLog)
+}
+
+// GetAuditLogs API, param: start_time, end_time, request_count, offset
+func GetAuditLogs(param map[string]interface{}, authUser *models.AuthUser) ([]*models.AuditLog, error) {
+	if authUser.IsSuperAdmin == false {
+		return nil, errors.New("Only super administrators can view the audit logs.")
+	}
+	startTime := int64(param["start_time"].(float64))
+	endTime := int64(param["end_time"].(float64))
+	requestCount := int64(param["request_count"].(float64))
+	offset := int64(param["offset"].(float64))
+	return data.DAL.SelectAuditLogs(startTime, endTime, requestCount, offset)
+}
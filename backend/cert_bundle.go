@@ -0,0 +1,275 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:15:14
+ * @Last Modified: thonsun, 2026-10-19 14:15:14
+ */
+
+package backend
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"strconv"
+	"strings"
+
+	"asec/models"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ParsePKCS12Bundle convert PFX/PKCS#12 to PEM certificate chain and private key
+func ParsePKCS12Bundle(pfxData []byte, password string) (certContent string, privKeyContent string, err error) {
+	privateKey, leaf, caCerts, err := pkcs12.DecodeChain(pfxData, password)
+	if err != nil {
+		return "", "", err
+	}
+	privKeyDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", "", err
+	}
+	chain := orderCertificateChain(leaf, caCerts)
+	privKeyContent = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privKeyDER}))
+	return encodeCertificateChain(chain), privKeyContent, nil
+}
+
+// ParsePEMBundle split the combined PEM into certificate chain and private key,
+// the chain is reordered from the leaf matching the private key, encrypted private key is decrypted by password.
+func ParsePEMBundle(bundle string, password string) (certContent string, privKeyContent string, err error) {
+	var certs []*x509.Certificate
+	var privKeyBlock *pem.Block
+	rest := []byte(bundle)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return "", "", err
+			}
+			certs = append(certs, cert)
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if privKeyBlock != nil {
+				return "", "", errors.New("More than one private key found in PEM bundle")
+			}
+			privKeyBlock = block
+			// Legacy encrypted PEM, still exported by many tools
+			if x509.IsEncryptedPEMBlock(block) {
+				der, err := x509.DecryptPEMBlock(block, []byte(password))
+				if err != nil {
+					return "", "", err
+				}
+				privKeyBlock = &pem.Block{Type: block.Type, Bytes: der}
+			}
+		}
+	}
+	if len(certs) == 0 || privKeyBlock == nil {
+		return "", "", errors.New("PEM bundle should contain certificates and a private key")
+	}
+	privKey, err := parsePrivateKey(privKeyBlock)
+	if err != nil {
+		return "", "", err
+	}
+	var leaf *x509.Certificate
+	var others []*x509.Certificate
+	for _, cert := range certs {
+		if leaf == nil && isPublicKeyMatched(cert, privKey) {
+			leaf = cert
+			continue
+		}
+		others = append(others, cert)
+	}
+	if leaf == nil {
+		return "", "", errors.New("No certificate matches the private key")
+	}
+	chain := orderCertificateChain(leaf, others)
+	return encodeCertificateChain(chain), string(pem.EncodeToMemory(privKeyBlock)), nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("Unsupported private key type")
+	}
+	return signer, nil
+}
+
+func isPublicKeyMatched(cert *x509.Certificate, privKey crypto.Signer) bool {
+	pubKey, ok := privKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pubKey.Equal(cert.PublicKey)
+}
+
+// orderCertificateChain return leaf followed by its issuers, unrelated certificates are dropped
+func orderCertificateChain(leaf *x509.Certificate, others []*x509.Certificate) []*x509.Certificate {
+	chain := []*x509.Certificate{leaf}
+	used := make([]bool, len(others))
+	current := leaf
+	for !isSelfSigned(current) {
+		found := false
+		for i, cert := range others {
+			if !used[i] && bytes.Equal(current.RawIssuer, cert.RawSubject) && current.CheckSignatureFrom(cert) == nil {
+				used[i] = true
+				chain = append(chain, cert)
+				current = cert
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return chain
+}
+
+func encodeCertificateChain(chain []*x509.Certificate) string {
+	var buf bytes.Buffer
+	for _, cert := range chain {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.String()
+}
+
+// ImportCertificateBundle import PKCS#12 (base64 content) or combined PEM bundle, object:
+// {"id": 0, "format": "pkcs12", "content": "...", "password": "", "description": "", "skip_chain_check": false, "app_id": 0}
+func ImportCertificateBundle(param map[string]interface{}, authUser *models.AuthUser, srcIP string) (*models.CertItem, error) {
+	if authUser.IsCertAdmin == false {
+		return nil, errors.New("You have no privilege to import certificates.")
+	}
+	obj := param["object"].(map[string]interface{})
+	format, _ := obj["format"].(string)
+	content, _ := obj["content"].(string)
+	password, _ := obj["password"].(string)
+	var certContent, privKeyContent string
+	var err error
+	switch strings.ToLower(format) {
+	case "pkcs12", "pfx", "p12":
+		pfxData, err := base64.StdEncoding.DecodeString(content)
+		if err != nil {
+			return nil, err
+		}
+		certContent, privKeyContent, err = ParsePKCS12Bundle(pfxData, password)
+		if err != nil {
+			return nil, err
+		}
+	case "pem":
+		certContent, privKeyContent, err = ParsePEMBundle(content, password)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("Unknown format " + format)
+	}
+	certificate := map[string]interface{}{
+		"id":               obj["id"],
+		"cert_content":     certContent,
+		"priv_key_content": privKeyContent,
+		"description":      obj["description"],
+		"skip_chain_check": obj["skip_chain_check"],
+		"app_id":           obj["app_id"],
+	}
+	if _, ok := certificate["id"].(float64); !ok {
+		certificate["id"] = float64(0)
+	}
+	certItem, err := UpdateCertificate(map[string]interface{}{"object": certificate}, authUser)
+	if err != nil {
+		return nil, err
+	}
+	WriteAuditLog(authUser, srcIP, "importcert", "id="+strconv.FormatInt(certItem.ID, 10)+" common_name="+certItem.CommonName+" format="+format)
+	return certItem, nil
+}
+
+// ExportCertificateBundle export the certificate with private key as PKCS#12 or PEM chain, object:
+// {"id": 1, "format": "pkcs12", "password": "..."}, the export is written to the audit logs.
+func ExportCertificateBundle(param map[string]interface{}, authUser *models.AuthUser, srcIP string) (*models.CertBundle, error) {
+	if authUser.IsCertAdmin == false {
+		return nil, errors.New("You have no privilege to export certificates.")
+	}
+	obj := param["object"].(map[string]interface{})
+	certID := int64(obj["id"].(float64))
+	format, _ := obj["format"].(string)
+	password, _ := obj["password"].(string)
+	certItem, err := SysCallGetCertByID(certID)
+	if err != nil {
+		return nil, err
+	}
+	certBundle, err := EncodeCertificateBundle(certItem, format, password)
+	if err != nil {
+		return nil, err
+	}
+	err = WriteAuditLog(authUser, srcIP, "exportcert", "id="+strconv.FormatInt(certItem.ID, 10)+" common_name="+certItem.CommonName+" format="+certBundle.Format)
+	if err != nil {
+		// No export without audit trail
+		return nil, err
+	}
+	return certBundle, nil
+}
+
+// EncodeCertificateBundle encode the certificate with private key as PKCS#12 (password required) or PEM chain,
+// the private key of PEM is not encrypted, so a password is rejected for PEM.
+func EncodeCertificateBundle(certItem *models.CertItem, format string, password string) (*models.CertBundle, error) {
+	filename := strings.Replace(certItem.CommonName, "*", "_", -1)
+	certBundle := &models.CertBundle{Format: strings.ToLower(format)}
+	switch certBundle.Format {
+	case "pkcs12", "pfx", "p12":
+		if len(password) == 0 {
+			return nil, errors.New("Password is required for PKCS#12 export")
+		}
+		tlsCert := GetTLSCertificate(certItem)
+		var chain []*x509.Certificate
+		for _, der := range tlsCert.Certificate {
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, cert)
+		}
+		if len(chain) == 0 {
+			return nil, errors.New("Empty certificate " + certItem.CommonName)
+		}
+		// The legacy encryption (3DES and RC2 with SHA-1) of this go-pkcs12 version, pkcs12.Modern (AES-256-CBC with PBKDF2)
+		// requires go-pkcs12 v0.3.0+ and golang.org/x/crypto v0.11.0+.
+		pfxData, err := pkcs12.Encode(rand.Reader, tlsCert.PrivateKey, chain[0], chain[1:], password)
+		if err != nil {
+			return nil, err
+		}
+		certBundle.Format = "pkcs12"
+		certBundle.Filename = filename + ".pfx"
+		certBundle.Content = base64.StdEncoding.EncodeToString(pfxData)
+	case "pem":
+		if len(password) > 0 {
+			return nil, errors.New("Password is not supported for PEM export, the private key is not encrypted, use PKCS#12 instead")
+		}
+		certBundle.Filename = filename + ".pem"
+		certBundle.Content = certItem.CertContent
+		if !strings.HasSuffix(certBundle.Content, "\n") {
+			certBundle.Content += "\n"
+		}
+		certBundle.Content += certItem.PrivKeyContent
+	default:
+		return nil, errors.New("Unknown format " + format)
+	}
+	return certBundle, nil
+}
@@ -0,0 +1,117 @@
+package backend
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"asec/models"
+)
+
+func TestParsePEMBundle(t *testing.T) {
+	now := time.Now()
+	chain := newTestChainPEM(t, now.Add(-time.Hour), now.Add(time.Hour), "www.asec.test")
+	other := newTestChainPEM(t, now.Add(-time.Hour), now.Add(time.Hour), "www.asec.test")
+	keyBlock, _ := pem.Decode([]byte(chain.leafKey))
+	encryptedBlock, err := x509.EncryptPEMBlock(rand.Reader, keyBlock.Type, keyBlock.Bytes, []byte("secret"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedKey := string(pem.EncodeToMemory(encryptedBlock))
+	orderedChain := chain.leaf + chain.intermediate + chain.root
+	cases := []struct {
+		name     string
+		bundle   string
+		password string
+		// errPrefix is empty if the bundle is accepted
+		errPrefix string
+	}{
+		{"ordered", orderedChain + chain.leafKey, "", ""},
+		// Reordered from the leaf, the certificates of other chains are dropped
+		{"shuffled", chain.root + chain.leafKey + other.intermediate + chain.intermediate + chain.leaf, "", ""},
+		{"encrypted key", orderedChain + encryptedKey, "secret", ""},
+		{"wrong password", orderedChain + encryptedKey, "wrong", "x509:"},
+		{"two keys", orderedChain + chain.leafKey + chain.otherKey, "", "More than one private key"},
+		{"no key", orderedChain, "", "PEM bundle should contain"},
+		{"no certificate", chain.leafKey, "", "PEM bundle should contain"},
+		{"unmatched key", orderedChain + chain.otherKey, "", "No certificate matches"},
+	}
+	for _, c := range cases {
+		certContent, privKeyContent, err := ParsePEMBundle(c.bundle, c.password)
+		if c.errPrefix == "" {
+			if err != nil || certContent != orderedChain {
+				t.Errorf("%s: unexpected chain, %v", c.name, err)
+				continue
+			}
+			if _, err = tls.X509KeyPair([]byte(certContent), []byte(privKeyContent)); err != nil {
+				t.Errorf("%s: unexpected private key, %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), c.errPrefix) {
+			t.Errorf("%s: error %v, expected %q", c.name, err, c.errPrefix)
+		}
+	}
+}
+
+func TestEncodeCertificateBundle(t *testing.T) {
+	now := time.Now()
+	chain := newTestChainPEM(t, now.Add(-time.Hour), now.Add(time.Hour), "*.asec.test")
+	certContent := chain.leaf + chain.intermediate + chain.root
+	tlsCert, err := tls.X509KeyPair([]byte(certContent), []byte(chain.leafKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certItem := &models.CertItem{ID: 1, CommonName: "*.asec.test", CertContent: certContent, PrivKeyContent: chain.leafKey, TlsCert: tlsCert}
+
+	// PKCS#12 export is imported back with the same chain
+	certBundle, err := EncodeCertificateBundle(certItem, "PFX", "secret")
+	if err != nil || certBundle.Format != "pkcs12" || certBundle.Filename != "_.asec.test.pfx" {
+		t.Fatalf("unexpected bundle %+v, %v", certBundle, err)
+	}
+	pfxData, _ := base64.StdEncoding.DecodeString(certBundle.Content)
+	importedCert, importedKey, err := ParsePKCS12Bundle(pfxData, "secret")
+	if err != nil || importedCert != certContent {
+		t.Fatalf("unexpected PKCS#12 chain, %v", err)
+	}
+	if _, err = tls.X509KeyPair([]byte(importedCert), []byte(importedKey)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = ParsePKCS12Bundle(pfxData, "wrong"); err == nil {
+		t.Error("PKCS#12 decoded with wrong password")
+	}
+
+	certBundle, err = EncodeCertificateBundle(certItem, "pem", "")
+	if err != nil || certBundle.Filename != "_.asec.test.pem" {
+		t.Fatalf("unexpected bundle %+v, %v", certBundle, err)
+	}
+	if importedCert, _, err = ParsePEMBundle(certBundle.Content, ""); err != nil || importedCert != certContent {
+		t.Fatalf("unexpected PEM chain, %v", err)
+	}
+
+	for _, format := range []string{"pkcs12", "der"} {
+		if _, err = EncodeCertificateBundle(certItem, format, ""); err == nil {
+			t.Errorf("%s exported without password", format)
+		}
+	}
+	// The private key of PEM is not encrypted
+	if _, err = EncodeCertificateBundle(certItem, "pem", "secret"); err == nil {
+		t.Error("pem exported with password")
+	}
+}
+
+func TestCertificateBundlePrivilege(t *testing.T) {
+	appAdmin := &models.AuthUser{Username: "app", IsAppAdmin: true}
+	param := map[string]interface{}{"object": map[string]interface{}{"id": 1.0, "format": "pem", "content": ""}}
+	if _, err := ImportCertificateBundle(param, appAdmin, "127.0.0.1"); err == nil {
+		t.Error("certificate imported without IsCertAdmin")
+	}
+	if _, err := ExportCertificateBundle(param, appAdmin, "127.0.0.1"); err == nil {
+		t.Error("certificate exported without IsCertAdmin")
+	}
+}
@@ -0,0 +1,152 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:12:04
+ * @Last Modified: thonsun, 2026-10-19 14:12:04
+ */
+
+package backend
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
+
+	"asec/models"
+)
+
+// ParseCertificateChain parse the PEM chain in order: leaf, intermediates ..., and check the private key,
+// validity period, order and completeness. The chain is complete if it ends with a self-signed
+// certificate or an intermediate trusted by the system roots or internal CA, skipChainCheck is used for private CAs.
+func ParseCertificateChain(certContent string, privKeyContent string, skipChainCheck bool) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	rest := []byte(certContent)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("No certificate found in PEM content")
+	}
+	if _, err := tls.X509KeyPair([]byte(certContent), []byte(privKeyContent)); err != nil {
+		return nil, err
+	}
+	leaf := chain[0]
+	now := time.Now()
+	if now.Before(leaf.NotBefore) {
+		return nil, errors.New("Certificate is not valid before " + leaf.NotBefore.Format("2006-01-02 15:04:05"))
+	}
+	if now.After(leaf.NotAfter) {
+		return nil, errors.New("Certificate expired at " + leaf.NotAfter.Format("2006-01-02 15:04:05"))
+	}
+	for i := 0; i < len(chain)-1; i++ {
+		if !bytes.Equal(chain[i].RawIssuer, chain[i+1].RawSubject) || chain[i].CheckSignatureFrom(chain[i+1]) != nil {
+			return nil, errors.New("Certificate chain is out of order or contains unrelated certificate: " + chain[i+1].Subject.CommonName)
+		}
+	}
+	if skipChainCheck || isSelfSigned(chain[len(chain)-1]) {
+		return chain, nil
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{Intermediates: intermediates, CurrentTime: now})
+	if _, ok := err.(x509.UnknownAuthorityError); ok {
+		if internalRoots := getInternalCARoots(); internalRoots != nil {
+			// Issued by the internal CA
+			_, err = leaf.Verify(x509.VerifyOptions{Intermediates: intermediates, Roots: internalRoots, CurrentTime: now})
+		}
+	}
+	if err != nil {
+		if _, ok := err.(x509.UnknownAuthorityError); ok {
+			return nil, errors.New("Certificate chain is incomplete, please append the intermediate certificates of " + chain[len(chain)-1].Issuer.CommonName)
+		}
+		return nil, err
+	}
+	return chain, nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// GetCertificateNames return the common name and SANs of the leaf certificate
+func GetCertificateNames(leaf *x509.Certificate) (commonName string, dnsNames []string) {
+	dnsNames = leaf.DNSNames
+	commonName = leaf.Subject.CommonName
+	if len(commonName) == 0 && len(dnsNames) > 0 {
+		commonName = dnsNames[0]
+	}
+	if len(dnsNames) == 0 && len(commonName) > 0 {
+		dnsNames = []string{commonName}
+	}
+	return commonName, dnsNames
+}
+
+// GetSuggestedDomains return the SAN names which are not configured as domains yet
+func GetSuggestedDomains(dnsNames []string) []string {
+	suggestedDomains := []string{}
+	for _, dnsName := range dnsNames {
+		if GetDomainByName(strings.ToLower(dnsName)) == nil && GetDomainByName(dnsName) == nil {
+			suggestedDomains = append(suggestedDomains, dnsName)
+		}
+	}
+	return suggestedDomains
+}
+
+// CheckCertificate API, validate the certificate before import
+func CheckCertificate(param map[string]interface{}) (*models.CertItem, error) {
+	certificate := param["object"].(map[string]interface{})
+	certContent := certificate["cert_content"].(string)
+	privKeyContent := certificate["priv_key_content"].(string)
+	skipChainCheck, _ := certificate["skip_chain_check"].(bool)
+	chain, err := ParseCertificateChain(certContent, privKeyContent, skipChainCheck)
+	if err != nil {
+		return nil, err
+	}
+	commonName, dnsNames := GetCertificateNames(chain[0])
+	certItem := &models.CertItem{
+		CommonName:       commonName,
+		ExpireTime:       chain[0].NotAfter.Unix(),
+		DNSNames:         dnsNames,
+		SuggestedDomains: GetSuggestedDomains(dnsNames),
+	}
+	return certItem, nil
+}
+
+// CreateSuggestedDomains add the suggested domains to the application with the certificate
+func CreateSuggestedDomains(appID int64, certID int64, suggestedDomains []string) error {
+	app, err := GetApplicationByID(appID)
+	if err != nil {
+		return err
+	}
+	for _, domainName := range suggestedDomains {
+		domainMap := map[string]interface{}{
+			"id":       float64(0),
+			"name":     domainName,
+			"cert_id":  float64(certID),
+			"redirect": false,
+			"location": "",
+		}
+		domain := UpdateDomain(app, domainMap)
+		app.Domains = append(app.Domains, domain)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"asec/models"
+)
+
+// testChainPEM is the PEM of a chain: leaf, intermediate CA and root CA, with the leaf key and an unrelated key
+type testChainPEM struct {
+	leaf, intermediate, root, leafKey, otherKey string
+}
+
+func newTestCertificatePEM(t *testing.T, template *x509.Certificate, parent *x509.Certificate, publicKey crypto.PublicKey, signer crypto.Signer) (*x509.Certificate, string) {
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(certDER)
+	return cert, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+}
+
+func newTestPrivateKeyPEM(t *testing.T, privKey *ecdsa.PrivateKey) string {
+	keyDER, err := x509.MarshalECPrivateKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+// newTestChainPEM return a chain with the leaf valid in [notBefore, notAfter]
+func newTestChainPEM(t *testing.T, notBefore time.Time, notAfter time.Time, dnsNames ...string) *testChainPEM {
+	caTemplate := func(serial int64, commonName string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber:          big.NewInt(serial),
+			Subject:               pkix.Name{CommonName: commonName},
+			NotBefore:             time.Now().Add(-48 * time.Hour),
+			NotAfter:              time.Now().Add(48 * time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+	}
+	rootKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rootTemplate := caTemplate(1, "asec test root CA")
+	root, rootPEM := newTestCertificatePEM(t, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	intermediateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	intermediate, intermediatePEM := newTestCertificatePEM(t, caTemplate(2, "asec test intermediate CA"), root, &intermediateKey.PublicKey, rootKey)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	_, leafPEM := newTestCertificatePEM(t, leafTemplate, intermediate, &leafKey.PublicKey, intermediateKey)
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return &testChainPEM{
+		leaf:         leafPEM,
+		intermediate: intermediatePEM,
+		root:         rootPEM,
+		leafKey:      newTestPrivateKeyPEM(t, leafKey),
+		otherKey:     newTestPrivateKeyPEM(t, otherKey),
+	}
+}
+
+func TestParseCertificateChain(t *testing.T) {
+	now := time.Now()
+	valid := newTestChainPEM(t, now.Add(-time.Hour), now.Add(24*time.Hour), "www.asec.test")
+	expired := newTestChainPEM(t, now.Add(-24*time.Hour), now.Add(-time.Hour), "www.asec.test")
+	notYetValid := newTestChainPEM(t, now.Add(time.Hour), now.Add(24*time.Hour), "www.asec.test")
+	cases := []struct {
+		name           string
+		certContent    string
+		privKeyContent string
+		skipChainCheck bool
+		// errPrefix is empty if the chain is accepted
+		errPrefix string
+	}{
+		{"complete", valid.leaf + valid.intermediate + valid.root, valid.leafKey, false, ""},
+		{"incomplete", valid.leaf + valid.intermediate, valid.leafKey, false, "Certificate chain is incomplete"},
+		{"private CA", valid.leaf + valid.intermediate, valid.leafKey, true, ""},
+		{"out of order", valid.leaf + valid.root + valid.intermediate, valid.leafKey, true, "Certificate chain is out of order"},
+		{"unrelated", valid.leaf + expired.intermediate, valid.leafKey, true, "Certificate chain is out of order"},
+		{"key mismatch", valid.leaf + valid.intermediate + valid.root, valid.otherKey, false, "tls:"},
+		{"expired", expired.leaf + expired.intermediate + expired.root, expired.leafKey, false, "Certificate expired"},
+		{"not yet valid", notYetValid.leaf + notYetValid.intermediate + notYetValid.root, notYetValid.leafKey, false, "Certificate is not valid before"},
+		{"empty", valid.leafKey, valid.leafKey, false, "No certificate found"},
+	}
+	for _, c := range cases {
+		chain, err := ParseCertificateChain(c.certContent, c.privKeyContent, c.skipChainCheck)
+		if c.errPrefix == "" {
+			if err != nil || chain[0].Subject.CommonName != "www.asec.test" {
+				t.Errorf("%s: chain rejected, %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), c.errPrefix) {
+			t.Errorf("%s: error %v, expected %q", c.name, err, c.errPrefix)
+		}
+	}
+}
+
+func TestGetSuggestedDomains(t *testing.T) {
+	oldDomains := Domains
+	defer func() { Domains = oldDomains }()
+	Domains = []*models.Domain{{Name: "www.asec.test"}}
+	now := time.Now()
+	chain := newTestChainPEM(t, now.Add(-time.Hour), now.Add(time.Hour), "WWW.asec.test", "api.asec.test", "*.img.asec.test")
+	parsedChain, err := ParseCertificateChain(chain.leaf+chain.intermediate+chain.root, chain.leafKey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commonName, dnsNames := GetCertificateNames(parsedChain[0])
+	if commonName != "WWW.asec.test" || len(dnsNames) != 3 {
+		t.Fatalf("unexpected names %s %v", commonName, dnsNames)
+	}
+	suggestedDomains := GetSuggestedDomains(dnsNames)
+	if strings.Join(suggestedDomains, ",") != "api.asec.test,*.img.asec.test" {
+		t.Errorf("unexpected suggested domains %v", suggestedDomains)
+	}
+}
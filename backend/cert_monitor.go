@@ -0,0 +1,124 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:06:37
+ * @Last Modified: thonsun, 2026-10-19 14:06:37
+ */
+
+package backend
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"asec/data"
+	"asec/models"
+)
+
+var (
+	// certAlertsNotified (string, int64) the last notified time of each alert
+	certAlertsNotified sync.Map
+)
+
+// RoutineCertMonitorTick check the certificates and notify the alerting channels
+func RoutineCertMonitorTick() {
+	if data.IsPrimary == false {
+		return
+	}
+	NotifyCertificateAlerts(CheckCertificates())
+	routineTicker := time.NewTicker(time.Hour)
+	for range routineTicker.C {
+		NotifyCertificateAlerts(CheckCertificates())
+	}
+}
+
+// CheckCertificates return the certificates expired or expiring within Cert_Expiry_Alert_Days,
+// and the domains not covered by the SANs of their certificates.
+func CheckCertificates() []*models.CertificateAlert {
+	certAlerts := []*models.CertificateAlert{}
+	now := time.Now().Unix()
+	alertTime := now + data.GetCertExpiryAlertDays()*86400
+	for _, certItem := range GetCertItems() {
+		certAlert := &models.CertificateAlert{
+			CertID:     certItem.ID,
+			CommonName: certItem.CommonName,
+			ExpireTime: certItem.ExpireTime,
+		}
+		expireDate := time.Unix(certItem.ExpireTime, 0).Format("2006-01-02 15:04:05")
+		if ocspStatus := GetOCSPStatus(certItem); ocspStatus != nil && ocspStatus.Status == "revoked" {
+			certAlert.Type = "revoked"
+			certAlert.Message = "Certificate " + certItem.CommonName + " is revoked by " + ocspStatus.Responder
+		} else if certItem.ExpireTime <= now {
+			certAlert.Type = "expired"
+			certAlert.Message = "Certificate " + certItem.CommonName + " expired at " + expireDate
+		} else if certItem.ExpireTime <= alertTime {
+			days := (certItem.ExpireTime - now) / 86400
+			certAlert.Type = "expiring"
+			certAlert.Message = fmt.Sprintf("Certificate %s will expire in %d days at %s", certItem.CommonName, days, expireDate)
+		} else {
+			continue
+		}
+		certAlerts = append(certAlerts, certAlert)
+	}
+	for _, domain := range Domains {
+		for _, certItem := range []*models.CertItem{domain.Cert, domain.AltCert} {
+			if certItem == nil {
+				continue
+			}
+			leaf, err := GetLeafCertificate(certItem)
+			if err == nil {
+				err = leaf.VerifyHostname(domain.Name)
+			}
+			if err == nil {
+				continue
+			}
+			certAlert := &models.CertificateAlert{
+				CertID:     certItem.ID,
+				CommonName: certItem.CommonName,
+				Domain:     domain.Name,
+				ExpireTime: certItem.ExpireTime,
+				Type:       "name_mismatch",
+				Message:    "Domain " + domain.Name + " is not covered by certificate " + certItem.CommonName + ": " + err.Error(),
+			}
+			certAlerts = append(certAlerts, certAlert)
+		}
+	}
+	return certAlerts
+}
+
+// NotifyCertificateAlerts send the alerts, each alert is repeated once a day at most
+func NotifyCertificateAlerts(certAlerts []*models.CertificateAlert) {
+	now := time.Now().Unix()
+	for _, certAlert := range certAlerts {
+		key := certAlert.Type + ":" + strconv.FormatInt(certAlert.CertID, 10) + ":" + certAlert.Domain
+		if lastTime, ok := certAlertsNotified.Load(key); ok && now-lastTime.(int64) < 86400 {
+			continue
+		}
+		certAlertsNotified.Store(key, now)
+		data.SendAlert("certificate", "Certificate "+certAlert.Type, certAlert.Message)
+	}
+}
+
+// GetCertificateAlerts API for the certificate monitoring results
+func GetCertificateAlerts(authUser *models.AuthUser) ([]*models.CertificateAlert, error) {
+	if authUser.IsCertAdmin == false && authUser.IsAppAdmin == false {
+		return nil, errors.New("You have no privilege to view the certificates.")
+	}
+	return CheckCertificates(), nil
+}
+
+// GetLeafCertificate return the parsed leaf certificate of certItem
+func GetLeafCertificate(certItem *models.CertItem) (*x509.Certificate, error) {
+	tlsCert := GetTLSCertificate(certItem)
+	if tlsCert.Leaf != nil {
+		return tlsCert.Leaf, nil
+	}
+	if len(tlsCert.Certificate) == 0 {
+		return nil, errors.New("Empty certificate " + certItem.CommonName)
+	}
+	return x509.ParseCertificate(tlsCert.Certificate[0])
+}
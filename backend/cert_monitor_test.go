@@ -0,0 +1,99 @@
+package backend
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"asec/models"
+)
+
+// newSelfSignedCertItem return a self-signed ECDSA or RSA certificate of the names
+func newSelfSignedCertItem(t *testing.T, id int64, isECDSA bool, names ...string) *models.CertItem {
+	var privKey crypto.Signer
+	if isECDSA {
+		privKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	} else {
+		privKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(id),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, privKey.Public(), privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.CertItem{
+		ID:         id,
+		CommonName: names[0],
+		DNSNames:   names,
+		TlsCert:    tls.Certificate{Certificate: [][]byte{certDER}, PrivateKey: privKey},
+	}
+}
+
+func TestCheckCertificates(t *testing.T) {
+	now := time.Now().Unix()
+	leafCert := newSelfSignedCertItem(t, 1, true, "www.asec.test")
+	cases := []struct {
+		name       string
+		expireTime int64
+		ocsp       *models.OCSPStatus
+		alertType  string
+	}{
+		{"valid", now + 90*86400, nil, ""},
+		{"expiring", now + 10*86400, nil, "expiring"},
+		{"expired", now - 86400, nil, "expired"},
+		{"revoked", now + 90*86400, &models.OCSPStatus{Status: "revoked", Responder: "http://ocsp.asec.test"}, "revoked"},
+		{"good", now + 10*86400, &models.OCSPStatus{Status: "good"}, "expiring"},
+	}
+	certs := []*models.CertItem{}
+	for i, c := range cases {
+		certs = append(certs, &models.CertItem{ID: int64(i + 1), CommonName: c.name, ExpireTime: c.expireTime, OCSP: c.ocsp, TlsCert: leafCert.TlsCert})
+	}
+	oldDomains := Domains
+	defer func() { Certs, Domains = nil, oldDomains }()
+	Certs = certs
+	Domains = []*models.Domain{
+		{Name: "www.asec.test", Cert: certs[0]},
+		{Name: "api.asec.test", Cert: certs[0], AltCert: certs[1]},
+		{Name: "www.asec.test", Cert: nil},
+	}
+	certAlerts := CheckCertificates()
+	alerts := map[int64]string{}
+	mismatches := []*models.CertificateAlert{}
+	for _, certAlert := range certAlerts {
+		if certAlert.Type == "name_mismatch" {
+			mismatches = append(mismatches, certAlert)
+			continue
+		}
+		alerts[certAlert.CertID] = certAlert.Type
+	}
+	for i, c := range cases {
+		if alerts[int64(i+1)] != c.alertType {
+			t.Errorf("%s: alert %q, expected %q", c.name, alerts[int64(i+1)], c.alertType)
+		}
+	}
+	// api.asec.test is not covered by both certificates
+	if len(mismatches) != 2 {
+		t.Fatalf("%d name mismatches, expected 2", len(mismatches))
+	}
+	for _, certAlert := range mismatches {
+		if certAlert.Domain != "api.asec.test" {
+			t.Errorf("unexpected name mismatch %+v", certAlert)
+		}
+	}
+}
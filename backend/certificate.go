@@ -8,9 +8,12 @@
 package backend
 
 import (
+	"crypto/ecdsa"
 	"crypto/tls"
 	"errors"
 	"log"
+	"strings"
+	"sync"
 
 	"asec/data"
 	"asec/models"
@@ -18,13 +21,43 @@ import (
 )
 
 var (
-	Certs []*models.CertItem
+	// Certs is changed by the API, ACME and internal CA routines, use GetCertItems to range it
+	Certs      []*models.CertItem
+	certsMutex sync.RWMutex
 )
 
+// GetCertItems return a snapshot of Certs
+func GetCertItems() []*models.CertItem {
+	certsMutex.RLock()
+	defer certsMutex.RUnlock()
+	certItems := make([]*models.CertItem, len(Certs))
+	copy(certItems, Certs)
+	return certItems
+}
+
+// GetTLSCertificate return the certificate of certItem used in handshakes, which should not be modified
+func GetTLSCertificate(certItem *models.CertItem) *tls.Certificate {
+	if tlsCert, ok := certItem.ActiveCert.Load().(*tls.Certificate); ok {
+		return tlsCert
+	}
+	return &(certItem.TlsCert)
+}
+
+// setTLSCertificate replace the certificate of certItem used in the new handshakes, certsMutex should be locked
+func setTLSCertificate(certItem *models.CertItem, tlsCert tls.Certificate) {
+	certItem.ActiveCert.Store(&tlsCert)
+}
+
+func addCertItem(certItem *models.CertItem) {
+	certsMutex.Lock()
+	defer certsMutex.Unlock()
+	Certs = append(Certs, certItem)
+}
+
 func LoadCerts() {
 	//fmt.Println("LoadCerts")
 	if data.IsPrimary {
-		Certs = Certs[0:0]
+		var certItems []*models.CertItem
 		dbCerts := data.DAL.SelectCertificates()
 		for _, dbCert := range dbCerts {
 			cert := new(models.CertItem)
@@ -39,43 +72,108 @@ func LoadCerts() {
 			cert.PrivKeyContent = string(privKey)
 			cert.TlsCert = tlsCert
 			cert.ExpireTime = dbCert.ExpireTime
+			cert.ACME = dbCert.ACME
+			cert.DNSNames = GetCertificateDNSNames(cert)
 			if dbCert.Description.Valid == true {
 				cert.Description = dbCert.Description.String
 			} else {
 				cert.Description = ""
 			}
-			Certs = append(Certs, cert)
+			certItems = append(certItems, cert)
 		}
+		certsMutex.Lock()
+		Certs = certItems
+		certsMutex.Unlock()
 	} else {
 		// Replica
 		rpcCerts := RPCSelectCertificates()
 		if rpcCerts != nil {
+			certsMutex.Lock()
 			Certs = rpcCerts
+			certsMutex.Unlock()
 		}
 		//fmt.Println("LoadCerts Replica:", Certs)
 	}
 }
 
+// GetCertificateByDomain return the certificate by SNI name: exact name, wildcard, then the default certificate
 func GetCertificateByDomain(domain string) (*tls.Certificate, error) {
-	if domain_relation, ok := DomainsMap.Load(domain); ok == true {
-		certItem := domain_relation.(models.DomainRelation).Cert
-		if certItem == nil {
-			return nil, errors.New("GetCertificateByDomain Null CertItem: " + domain)
-		}
-		cert := &(certItem.TlsCert)
-		return cert, nil
-	} else {
+	certItems := GetCertItemsByDomain(domain)
+	if len(certItems) == 0 {
 		return nil, errors.New("Unknown Host: " + domain)
 	}
+	return GetTLSCertificate(certItems[0]), nil
+}
+
+// GetCertificateByHello select the certificate for TLS handshake,
+// the ECDSA one is preferred if the domain has both ECDSA and RSA certificates and the client supports it.
+func GetCertificateByHello(helloInfo *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certItems := GetCertItemsByDomain(helloInfo.ServerName)
+	if len(certItems) == 0 {
+		return nil, errors.New("Unknown Host: " + helloInfo.ServerName)
+	}
+	if len(certItems) == 1 {
+		return GetTLSCertificate(certItems[0]), nil
+	}
+	var supported *models.CertItem
+	for _, certItem := range certItems {
+		tlsCert := GetTLSCertificate(certItem)
+		if helloInfo.SupportsCertificate(tlsCert) != nil {
+			continue
+		}
+		if _, isECDSA := tlsCert.PrivateKey.(*ecdsa.PrivateKey); isECDSA {
+			return tlsCert, nil
+		}
+		if supported == nil {
+			supported = certItem
+		}
+	}
+	if supported == nil {
+		// Let the handshake fail with the primary certificate
+		supported = certItems[0]
+	}
+	return GetTLSCertificate(supported), nil
+}
+
+// GetDomainRelation lookup the SNI name by exact name, then wildcard name
+func GetDomainRelation(domain string) (models.DomainRelation, bool) {
+	domain = strings.TrimSuffix(domain, ".")
+	domainRelation, ok := DomainsMap.Load(domain)
+	if !ok && len(domain) > 0 {
+		domainRelation, ok = DomainsMap.Load(GetWildDomainName(domain))
+	}
+	if !ok {
+		return models.DomainRelation{}, false
+	}
+	return domainRelation.(models.DomainRelation), true
+}
+
+// GetCertItemsByDomain return the certificates of exact domain, or wildcard domain, or the default certificate
+func GetCertItemsByDomain(domain string) []*models.CertItem {
+	var certItems []*models.CertItem
+	if relation, ok := GetDomainRelation(domain); ok {
+		if relation.Cert != nil {
+			certItems = append(certItems, relation.Cert)
+		}
+		if relation.AltCert != nil {
+			certItems = append(certItems, relation.AltCert)
+		}
+	}
+	if defaultCertID := data.GetDefaultCertID(); len(certItems) == 0 && defaultCertID > 0 {
+		if certItem, err := SysCallGetCertByID(defaultCertID); err == nil {
+			certItems = append(certItems, certItem)
+		}
+	}
+	return certItems
 }
 
 func GetCertificates(authUser *models.AuthUser) ([]*models.CertItem, error) {
 	if authUser.IsCertAdmin == true {
-		return Certs, nil
+		return GetCertItems(), nil
 	} else {
 		// Remove private key
 		var simpleCerts []*models.CertItem
-		for _, cert := range Certs {
+		for _, cert := range GetCertItems() {
 			simpleCert := &models.CertItem{
 				ID:             cert.ID,
 				CommonName:     cert.CommonName,
@@ -83,6 +181,9 @@ func GetCertificates(authUser *models.AuthUser) ([]*models.CertItem, error) {
 				PrivKeyContent: "You have no privilege to view the private key.",
 				ExpireTime:     cert.ExpireTime,
 				Description:    cert.Description,
+				ACME:           cert.ACME,
+				OCSP:           GetOCSPStatus(cert),
+				DNSNames:       cert.DNSNames,
 			}
 			simpleCerts = append(simpleCerts, simpleCert)
 		}
@@ -92,7 +193,7 @@ func GetCertificates(authUser *models.AuthUser) ([]*models.CertItem, error) {
 
 // SysCallGetCertByID ... Use for internal call, not for UI
 func SysCallGetCertByID(certID int64) (*models.CertItem, error) {
-	for _, cert := range Certs {
+	for _, cert := range GetCertItems() {
 		if cert.ID == certID {
 			return cert, nil
 		}
@@ -101,7 +202,7 @@ func SysCallGetCertByID(certID int64) (*models.CertItem, error) {
 }
 
 func GetCertificateByID(certID int64, authUser *models.AuthUser) (*models.CertItem, error) {
-	for _, cert := range Certs {
+	for _, cert := range GetCertItems() {
 		if cert.ID == certID {
 			if authUser.IsCertAdmin {
 				return cert, nil
@@ -113,6 +214,9 @@ func GetCertificateByID(certID int64, authUser *models.AuthUser) (*models.CertIt
 				PrivKeyContent: "You have no privilege to view the private key.",
 				ExpireTime:     cert.ExpireTime,
 				Description:    cert.Description,
+				ACME:           cert.ACME,
+				OCSP:           GetOCSPStatus(cert),
+				DNSNames:       cert.DNSNames,
 			}
 			return simpleCert, nil
 		}
@@ -121,7 +225,7 @@ func GetCertificateByID(certID int64, authUser *models.AuthUser) (*models.CertIt
 }
 
 func GetCertificateByCommonName(commonName string) *models.CertItem {
-	for _, cert := range Certs {
+	for _, cert := range GetCertItems() {
 		if cert.CommonName == commonName {
 			return cert
 		}
@@ -130,14 +234,21 @@ func GetCertificateByCommonName(commonName string) *models.CertItem {
 	return nil
 }
 
+// UpdateCertificate import certificate, object: {"id": 0, "cert_content": "...", "priv_key_content": "...",
+// "description": "", "skip_chain_check": false, "app_id": 0}, the common name is extracted from the certificate,
+// and the SANs not configured are added as domains of app_id if app_id is not 0.
 func UpdateCertificate(param map[string]interface{}, authUser *models.AuthUser) (*models.CertItem, error) {
 	certificate := param["object"].(map[string]interface{})
 	id := int64(certificate["id"].(float64))
-	commonName := certificate["common_name"].(string)
 	certContent := certificate["cert_content"].(string)
 	privKeyContent := certificate["priv_key_content"].(string)
-	encryptedPrivKey := data.AES256Encrypt([]byte(privKeyContent), false)
-	expireTime := data.GetCertificateExpiryTime(certContent)
+	skipChainCheck, _ := certificate["skip_chain_check"].(bool)
+	chain, err := ParseCertificateChain(certContent, privKeyContent, skipChainCheck)
+	if err != nil {
+		return nil, err
+	}
+	commonName, dnsNames := GetCertificateNames(chain[0])
+	expireTime := chain[0].NotAfter.Unix()
 	var description string
 	var ok bool
 	if description, ok = certificate["description"].(string); !ok {
@@ -151,16 +262,22 @@ func UpdateCertificate(param map[string]interface{}, authUser *models.AuthUser)
 	}
 	if id == 0 {
 		//new certificate
-		newID := data.DAL.InsertCertificate(commonName, certContent, encryptedPrivKey, expireTime, description)
+		var newID int64
+		data.WithInstanceKey(func(encrypt func(plaintext []byte) []byte) error {
+			newID = data.DAL.InsertCertificate(commonName, certContent, encrypt([]byte(privKeyContent)), expireTime, description, false)
+			return nil
+		})
 		certItem = new(models.CertItem)
 		certItem.ID = newID
-		Certs = append(Certs, certItem)
+		addCertItem(certItem)
 	} else {
 		certItem, err = GetCertificateByID(id, authUser)
 		if err != nil {
 			return nil, err
 		}
-		err = data.DAL.UpdateCertificate(commonName, certContent, encryptedPrivKey, expireTime, description, id)
+		err = data.WithInstanceKey(func(encrypt func(plaintext []byte) []byte) error {
+			return data.DAL.UpdateCertificate(commonName, certContent, encrypt([]byte(privKeyContent)), expireTime, description, false, id)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -168,13 +285,29 @@ func UpdateCertificate(param map[string]interface{}, authUser *models.AuthUser)
 	certItem.CommonName = commonName
 	certItem.CertContent = certContent
 	certItem.PrivKeyContent = privKeyContent
-	certItem.TlsCert = tlsCert
+	certsMutex.Lock()
+	setTLSCertificate(certItem, tlsCert)
+	certsMutex.Unlock()
 	certItem.ExpireTime = expireTime
 	certItem.Description = description
+	// Replaced manually, not renewed by ACME any more
+	certItem.ACME = false
+	certItem.DNSNames = dnsNames
+	suggestedDomains := GetSuggestedDomains(dnsNames)
+	if appID, ok := certificate["app_id"].(float64); ok && appID > 0 {
+		err = CreateSuggestedDomains(int64(appID), certItem.ID, suggestedDomains)
+		if err != nil {
+			return nil, err
+		}
+		suggestedDomains = []string{}
+	}
 	data.UpdateBackendLastModified()
-	return certItem, nil
+	importedCert := *certItem
+	importedCert.SuggestedDomains = suggestedDomains
+	return &importedCert, nil
 }
 
+// GetCertificateIndex should be called with certsMutex locked
 func GetCertificateIndex(certID int64) int {
 	for i := 0; i < len(Certs); i++ {
 		if Certs[i].ID == certID {
@@ -185,6 +318,9 @@ func GetCertificateIndex(certID int64) int {
 }
 
 func DeleteCertificateByID(certID int64) error {
+	if certID == data.GetDefaultCertID() {
+		return errors.New("This certificate is the default certificate, please modify Default_Cert_ID at first.")
+	}
 	certDomainsCount := data.DAL.SelectDomainsCountByCertID(certID)
 	if certDomainsCount > 0 {
 		return errors.New("This certificate is in use, please delete relevant applications at first.")
@@ -193,8 +329,11 @@ func DeleteCertificateByID(certID int64) error {
 		if err != nil {
 			return err
 		}
-		i := GetCertificateIndex(certID)
-		Certs = append(Certs[:i], Certs[i+1:]...)
+		certsMutex.Lock()
+		if i := GetCertificateIndex(certID); i >= 0 {
+			Certs = append(Certs[:i], Certs[i+1:]...)
+		}
+		certsMutex.Unlock()
 	}
 	data.UpdateBackendLastModified()
 	return nil
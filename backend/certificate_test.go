@@ -0,0 +1,70 @@
+package backend
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"asec/data"
+	"asec/models"
+)
+
+func TestGetCertificateByHello(t *testing.T) {
+	rsaCert := newSelfSignedCertItem(t, 1, false, "www.asec.test")
+	ecdsaCert := newSelfSignedCertItem(t, 2, true, "www.asec.test")
+	wildCert := newSelfSignedCertItem(t, 3, true, "*.asec.test")
+	defaultCert := newSelfSignedCertItem(t, 4, false, "default.asec.test")
+	oldDefaultCertID := data.GetDefaultCertID()
+	defer func() {
+		Certs = nil
+		data.SetCertSetting("Default_Cert_ID", oldDefaultCertID)
+		DomainsMap.Delete("www.asec.test")
+		DomainsMap.Delete("*.asec.test")
+	}()
+	Certs = []*models.CertItem{rsaCert, ecdsaCert, wildCert, defaultCert}
+	DomainsMap.Store("www.asec.test", models.DomainRelation{Cert: rsaCert, AltCert: ecdsaCert})
+	DomainsMap.Store("*.asec.test", models.DomainRelation{Cert: wildCert})
+
+	tls13Hello := &tls.ClientHelloInfo{
+		SupportedVersions: []uint16{tls.VersionTLS13},
+		SignatureSchemes:  []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256, tls.PSSWithSHA256},
+		SupportedCurves:   []tls.CurveID{tls.X25519, tls.CurveP256},
+	}
+	rsaOnlyHello := &tls.ClientHelloInfo{
+		SupportedVersions: []uint16{tls.VersionTLS12},
+		CipherSuites:      []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		SignatureSchemes:  []tls.SignatureScheme{tls.PKCS1WithSHA256},
+		SupportedCurves:   []tls.CurveID{tls.CurveP256},
+		SupportedPoints:   []uint8{0},
+	}
+	cases := []struct {
+		hello      *tls.ClientHelloInfo
+		serverName string
+		defaultID  int64
+		expected   *models.CertItem
+	}{
+		// ECDSA is preferred by the clients supporting both
+		{tls13Hello, "www.asec.test", 0, ecdsaCert},
+		{tls13Hello, "www.asec.test.", 0, ecdsaCert},
+		{rsaOnlyHello, "www.asec.test", 0, rsaCert},
+		// Wildcard, then the default certificate
+		{tls13Hello, "api.asec.test", 0, wildCert},
+		{tls13Hello, "www.example.com", 4, defaultCert},
+		{tls13Hello, "", 4, defaultCert},
+		{tls13Hello, "www.example.com", 0, nil},
+	}
+	for _, c := range cases {
+		data.SetCertSetting("Default_Cert_ID", c.defaultID)
+		hello := *c.hello
+		hello.ServerName = c.serverName
+		tlsCert, err := GetCertificateByHello(&hello)
+		if c.expected == nil {
+			if err == nil {
+				t.Errorf("%q: certificate returned for unknown host", c.serverName)
+			}
+			continue
+		}
+		if err != nil || tlsCert != &(c.expected.TlsCert) {
+			t.Errorf("%q: unexpected certificate, expected %s, %v", c.serverName, c.expected.CommonName, err)
+		}
+	}
+}
@@ -34,17 +34,21 @@ func LoadDomains() {
 	for _, dbDomain := range dbDomains {
 		pApp, _ := GetApplicationByID(dbDomain.AppID)
 		pCert, _ := SysCallGetCertByID(dbDomain.CertID)
+		pAltCert, _ := SysCallGetCertByID(dbDomain.AltCertID)
 		domain := &models.Domain{
-			ID:       dbDomain.ID,
-			Name:     dbDomain.Name,
-			AppID:    dbDomain.AppID,
-			CertID:   dbDomain.CertID,
-			Redirect: dbDomain.Redirect,
-			Location: dbDomain.Location,
-			App:      pApp,
-			Cert:     pCert}
+			ID:           dbDomain.ID,
+			Name:         dbDomain.Name,
+			AppID:        dbDomain.AppID,
+			CertID:       dbDomain.CertID,
+			Redirect:     dbDomain.Redirect,
+			Location:     dbDomain.Location,
+			AltCertID:    dbDomain.AltCertID,
+			TLSProfileID: dbDomain.TLSProfileID,
+			App:          pApp,
+			Cert:         pCert,
+			AltCert:      pAltCert}
 		Domains = append(Domains, domain)
-		DomainsMap.Store(domain.Name, models.DomainRelation{App: pApp, Cert: pCert, Redirect: dbDomain.Redirect, Location: dbDomain.Location})
+		DomainsMap.Store(domain.Name, models.DomainRelation{App: pApp, Cert: pCert, AltCert: pAltCert, Redirect: dbDomain.Redirect, Location: dbDomain.Location, TLSProfileID: dbDomain.TLSProfileID})
 	}
 }
 
@@ -113,16 +117,25 @@ func UpdateDomain(app *models.Application, domainMapInterface interface{}) *mode
 	certID := int64(domainMap["cert_id"].(float64))
 	redirect := domainMap["redirect"].(bool)
 	location := domainMap["location"].(string)
+	var altCertID int64
+	if altCertIDFloat, ok := domainMap["alt_cert_id"].(float64); ok {
+		altCertID = int64(altCertIDFloat)
+	}
+	var tlsProfileID int64
+	if tlsProfileIDFloat, ok := domainMap["tls_profile_id"].(float64); ok {
+		tlsProfileID = int64(tlsProfileIDFloat)
+	}
 	pCert, _ := SysCallGetCertByID(certID)
+	pAltCert, _ := SysCallGetCertByID(altCertID)
 	domain := GetDomainByID(domainID)
 	if domainID == 0 {
 		// New domain
-		newDomainID := data.DAL.InsertDomain(domainName, app.ID, certID, redirect, location)
+		newDomainID := data.DAL.InsertDomain(domainName, app.ID, certID, redirect, location, altCertID, tlsProfileID)
 		domain = new(models.Domain)
 		domain.ID = newDomainID
 		Domains = append(Domains, domain)
 	} else {
-		data.DAL.UpdateDomain(domainName, app.ID, certID, redirect, location, domain.ID)
+		data.DAL.UpdateDomain(domainName, app.ID, certID, redirect, location, altCertID, tlsProfileID, domain.ID)
 	}
 	domain.Name = domainName
 	domain.AppID = app.ID
@@ -131,7 +144,13 @@ func UpdateDomain(app *models.Application, domainMapInterface interface{}) *mode
 	domain.Location = location
 	domain.App = app
 	domain.Cert = pCert
-	DomainsMap.Store(domainName, models.DomainRelation{App: app, Cert: pCert, Redirect: redirect, Location: location})
+	domain.AltCertID = altCertID
+	domain.AltCert = pAltCert
+	domain.TLSProfileID = tlsProfileID
+	DomainsMap.Store(domainName, models.DomainRelation{App: app, Cert: pCert, AltCert: pAltCert, Redirect: redirect, Location: location, TLSProfileID: tlsProfileID})
+	if domainID == 0 && certID == 0 {
+		go AutoIssueACMECertificate(domain)
+	}
 	return domain
 }
 
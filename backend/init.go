@@ -25,6 +25,9 @@ func InitDatabase() {
 		`afa8bae009c9dbf4135f62e165847227`, ``, true, true, true, true)
 	dal.CreateTableIfNotExistsNodes()
 	dal.CreateTableIfNotExistsTOTP()
+	dal.CreateTableIfNotExistsTLSProfiles()
+	dal.CreateTableIfNotExistsInternalCA()
+	dal.CreateTableIfNotExistsAuditLogs()
 	// Upgrade to latest version
 	if dal.ExistColumnInTable("domains", "redirect") == false {
 		// v0.9.6+ required
@@ -43,9 +46,39 @@ func InitDatabase() {
 		dal.ExecSQL(`ALTER TABLE ccpolicies RENAME COLUMN interval_seconds TO interval_milliseconds`)
 		dal.ExecSQL(`UPDATE ccpolicies SET interval_milliseconds=interval_milliseconds*1000`)
 	}
+	if dal.ExistColumnInTable("certificates", "acme") == false {
+		// v1.0.1+ required
+		dal.ExecSQL(`alter table certificates add column acme boolean default false`)
+	}
+	if dal.ExistColumnInTable("domains", "alt_cert_id") == false {
+		// v1.0.1+ required
+		dal.ExecSQL(`alter table domains add column alt_cert_id bigint default 0`)
+	}
+	if dal.ExistColumnInTable("applications", "tls_profile_id") == false {
+		// v1.0.1+ required
+		dal.ExecSQL(`alter table applications add column tls_profile_id bigint default 0`)
+	}
+	if dal.ExistColumnInTable("domains", "tls_profile_id") == false {
+		// v1.0.1+ required
+		dal.ExecSQL(`alter table domains add column tls_profile_id bigint default 0`)
+	}
+	if dal.ExistColumnInTable("applications", "scoring_enabled") == false {
+		// v1.0.1+ required
+		dal.ExecSQL(`alter table applications add column scoring_enabled boolean default false, add column inbound_threshold bigint default 5, add column outbound_threshold bigint default 4`)
+	}
+	if dal.ExistColumnInTable("applications", "monitor_mode") == false {
+		// v1.0.1+ required
+		dal.ExecSQL(`alter table applications add column monitor_mode boolean default false`)
+	}
+	if dal.ExistColumnInTable("applications", "response_inspect_kb") == false {
+		// v1.0.1+ required
+		dal.ExecSQL(`alter table applications add column response_inspect_kb bigint default 64`)
+	}
+	InitTLSProfiles()
 }
 
 func LoadAppConfiguration() {
+	LoadTLSProfiles()
 	LoadCerts()
 	LoadApps()
 	if data.IsPrimary {
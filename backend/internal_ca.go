@@ -0,0 +1,282 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:13:50
+ * @Last Modified: thonsun, 2026-10-19 14:13:50
+ */
+
+package backend
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+// GetInternalCA return the internal CA and its key pair, the certificate chain of the key pair
+// is the CA certificate followed by its issuers up to the root.
+func GetInternalCA() (*models.InternalCA, *tls.Certificate, error) {
+	if data.IsPrimary == false {
+		return nil, nil, errors.New("Internal CA is only available on the primary node")
+	}
+	dbCA, err := data.DAL.SelectInternalCA()
+	if err != nil {
+		return nil, nil, errors.New("Internal CA is not initialized")
+	}
+	privKey, err := data.AES256Decrypt(dbCA.EncryptedPrivKey, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsCert, err := tls.X509KeyPair([]byte(dbCA.CertContent), privKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsCert.Leaf, err = x509.ParseCertificate(tlsCert.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	internalCA := &models.InternalCA{
+		ID:          dbCA.ID,
+		CommonName:  tlsCert.Leaf.Subject.CommonName,
+		CertContent: dbCA.CertContent,
+		IsRoot:      len(tlsCert.Certificate) == 1,
+		ExpireTime:  tlsCert.Leaf.NotAfter.Unix(),
+		CreateTime:  dbCA.CreateTime,
+	}
+	return internalCA, &tlsCert, nil
+}
+
+// GetInternalCARootContent return the PEM of root certificate for distribution
+func GetInternalCARootContent() ([]byte, error) {
+	_, caCert, err := GetInternalCA()
+	if err != nil {
+		return nil, err
+	}
+	if len(caCert.Certificate) == 0 {
+		return nil, errors.New("Internal CA has no certificate")
+	}
+	rootDER := caCert.Certificate[len(caCert.Certificate)-1]
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rootDER}), nil
+}
+
+// getInternalCARoots used to verify the certificates issued by internal CA, nil if not initialized
+func getInternalCARoots() *x509.CertPool {
+	if data.IsPrimary == false {
+		return nil
+	}
+	_, caCert, err := GetInternalCA()
+	if err != nil || len(caCert.Certificate) == 0 {
+		return nil
+	}
+	root, err := x509.ParseCertificate(caCert.Certificate[len(caCert.Certificate)-1])
+	if err != nil {
+		return nil
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return roots
+}
+
+// GetInternalCAAPI return the internal CA without private key
+func GetInternalCAAPI(authUser *models.AuthUser) (*models.InternalCA, error) {
+	if authUser.IsCertAdmin == false {
+		return nil, errors.New("You have no privilege to view the internal CA.")
+	}
+	internalCA, _, err := GetInternalCA()
+	return internalCA, err
+}
+
+// InitInternalCA generate a root CA, or import a root/intermediate CA with its issuers, object:
+// {"common_name": "asec Internal CA", "key_type": "ecdsa", "validity_days": 3650}
+// or {"cert_content": "CA + issuers", "priv_key_content": "..."}, "replace": true is required if exists.
+func InitInternalCA(param map[string]interface{}, authUser *models.AuthUser) (*models.InternalCA, error) {
+	if authUser.IsCertAdmin == false {
+		return nil, errors.New("You have no privilege to modify the internal CA.")
+	}
+	obj := param["object"].(map[string]interface{})
+	replace, _ := obj["replace"].(bool)
+	if _, _, err := GetInternalCA(); err == nil && replace == false {
+		return nil, errors.New("Internal CA exists, set replace to true to replace it.")
+	}
+	certContent, _ := obj["cert_content"].(string)
+	privKeyContent, _ := obj["priv_key_content"].(string)
+	var err error
+	if len(certContent) > 0 {
+		err = checkImportedCA(certContent, privKeyContent)
+	} else {
+		commonName, _ := obj["common_name"].(string)
+		keyType, _ := obj["key_type"].(string)
+		validityDays, _ := obj["validity_days"].(float64)
+		certContent, privKeyContent, err = generateRootCA(commonName, keyType, int64(validityDays))
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = data.WithInstanceKey(func(encrypt func(plaintext []byte) []byte) error {
+		_, err := data.DAL.InsertInternalCA(certContent, encrypt([]byte(privKeyContent)), time.Now().Unix())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	internalCA, _, err := GetInternalCA()
+	return internalCA, err
+}
+
+func checkImportedCA(certContent string, privKeyContent string) error {
+	chain, err := ParseCertificateChain(certContent, privKeyContent, true)
+	if err != nil {
+		return err
+	}
+	if chain[0].IsCA == false || chain[0].KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.New("The first certificate is not a CA certificate")
+	}
+	if isSelfSigned(chain[len(chain)-1]) == false {
+		return errors.New("Please append the issuers of the intermediate CA up to the root")
+	}
+	return nil
+}
+
+func generateRootCA(commonName string, keyType string, validityDays int64) (certContent string, privKeyContent string, err error) {
+	if len(commonName) == 0 {
+		commonName = "asec Internal CA"
+	}
+	if validityDays <= 0 {
+		validityDays = 3650
+	}
+	priv, privKeyContent, err := utils.GeneratePrivateKey(keyType)
+	if err != nil {
+		return "", "", err
+	}
+	serialNumber, err := utils.NewSerialNumber()
+	if err != nil {
+		return "", "", err
+	}
+	notBefore := time.Now().Add(-5 * time.Minute)
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{"asec"}},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(time.Duration(validityDays) * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
+	if err != nil {
+		return "", "", err
+	}
+	certContent = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes}))
+	return certContent, privKeyContent, nil
+}
+
+// IssueInternalCertificate issue a certificate signed by the internal CA and save it, object:
+// {"domains": ["app.internal", "10.0.0.1"], "key_type": "ecdsa", "validity_days": 365, "usage": "server"}
+// usage: server, client (backend mTLS), both
+func IssueInternalCertificate(param map[string]interface{}, authUser *models.AuthUser) (*models.CertItem, error) {
+	if authUser.IsCertAdmin == false {
+		return nil, errors.New("You have no privilege to issue certificates.")
+	}
+	obj := param["object"].(map[string]interface{})
+	names := InterfaceToStringSlice(obj["domains"])
+	if len(names) == 0 {
+		return nil, errors.New("domains is required")
+	}
+	keyType, _ := obj["key_type"].(string)
+	validityDays, _ := obj["validity_days"].(float64)
+	if validityDays <= 0 {
+		validityDays = 365
+	}
+	usage, _ := obj["usage"].(string)
+	var extKeyUsage []x509.ExtKeyUsage
+	switch usage {
+	case "", "server":
+		extKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	case "client":
+		extKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	case "both":
+		extKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	default:
+		return nil, errors.New("Unknown usage " + usage)
+	}
+	_, caCert, err := GetInternalCA()
+	if err != nil {
+		return nil, err
+	}
+	priv, privKeyContent, err := utils.GeneratePrivateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+	serialNumber, err := utils.NewSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	notBefore := time.Now().Add(-5 * time.Minute)
+	notAfter := notBefore.Add(time.Duration(validityDays) * 24 * time.Hour)
+	if notAfter.After(caCert.Leaf.NotAfter) {
+		notAfter = caCert.Leaf.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: names[0]},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              utils.GetLeafKeyUsage(priv),
+		ExtKeyUsage:           extKeyUsage,
+		BasicConstraintsValid: true,
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, name)
+		}
+	}
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, caCert.Leaf, priv.Public(), caCert.PrivateKey.(crypto.Signer))
+	if err != nil {
+		return nil, err
+	}
+	// leaf + intermediates, the root is distributed separately
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
+	for i := 0; i < len(caCert.Certificate)-1; i++ {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Certificate[i]})...)
+	}
+	certContent := string(certPEM)
+	tlsCert, err := tls.X509KeyPair([]byte(certContent), []byte(privKeyContent))
+	if err != nil {
+		return nil, err
+	}
+	commonName := names[0]
+	description := "Internal CA: " + strings.Join(names, ",")
+	expireTime := notAfter.Unix()
+	var newID int64
+	data.WithInstanceKey(func(encrypt func(plaintext []byte) []byte) error {
+		newID = data.DAL.InsertCertificate(commonName, certContent, encrypt([]byte(privKeyContent)), expireTime, description, false)
+		return nil
+	})
+	certItem := &models.CertItem{
+		ID:             newID,
+		CommonName:     commonName,
+		CertContent:    certContent,
+		PrivKeyContent: privKeyContent,
+		TlsCert:        tlsCert,
+		ExpireTime:     expireTime,
+		Description:    description,
+		DNSNames:       template.DNSNames,
+	}
+	addCertItem(certItem)
+	data.UpdateBackendLastModified()
+	return certItem, nil
+}
@@ -0,0 +1,42 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:18:53
+ * @Last Modified: thonsun, 2026-10-19 14:18:53
+ */
+
+package backend
+
+import (
+	"errors"
+	"strconv"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+// RotateInstanceKey re-encrypt the private keys of certificates, internal CA and other secrets with a new instance key
+func RotateInstanceKey() (int64, error) {
+	count, err := data.DAL.RotateInstanceKey()
+	if err != nil {
+		utils.DebugPrintln("RotateInstanceKey failed, the old instance key is still in use.", err)
+		return 0, err
+	}
+	utils.DebugPrintln("RotateInstanceKey OK, re-encrypted secrets:", count)
+	return count, nil
+}
+
+// RotateInstanceKeyAPI only for super administrators
+func RotateInstanceKeyAPI(authUser *models.AuthUser, srcIP string) (map[string]int64, error) {
+	if authUser.IsSuperAdmin == false {
+		return nil, errors.New("Only super administrators can rotate the instance key.")
+	}
+	count, err := RotateInstanceKey()
+	if err != nil {
+		WriteAuditLog(authUser, srcIP, "rotateinstancekey", "failed: "+err.Error())
+		return nil, err
+	}
+	WriteAuditLog(authUser, srcIP, "rotateinstancekey", "re-encrypted secrets: "+strconv.FormatInt(count, 10))
+	return map[string]int64{"count": count}, nil
+}
@@ -114,7 +114,7 @@ func IsValidAuthKey(r *http.Request, param map[string]interface{}) bool {
 	if err != nil {
 		return false
 	}
-	decryptedAuthBytes, err := data.DecryptWithKey(authBytes, data.RootKey)
+	decryptedAuthBytes, _, err := data.DecryptWithRootKey(authBytes)
 	//decryptedAuthBytes, err := data.AES256Decrypt(authBytes, true)
 	utils.CheckError("IsValidAuthKey DecryptWithKey", err)
 	if err != nil {
@@ -0,0 +1,230 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:08:01
+ * @Last Modified: thonsun, 2026-10-19 14:08:01
+ */
+
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"asec/models"
+	"asec/utils"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// ocspRetrySeconds is the interval of retry after failure
+	ocspRetrySeconds = 600
+	// ocspDefaultRefreshSeconds used when the responder does not provide NextUpdate
+	ocspDefaultRefreshSeconds = 86400
+)
+
+var (
+	// ocspCache (string, *ocspCacheItem), key is the sha256 of the leaf certificate,
+	// so the responses are kept when the certificates are reloaded.
+	ocspCache sync.Map
+	ocspMutex sync.Mutex
+)
+
+type ocspCacheItem struct {
+	status *models.OCSPStatus
+	staple []byte
+}
+
+// RoutineOCSPTick fetch and refresh the OCSP responses of the loaded certificates
+func RoutineOCSPTick() {
+	RefreshOCSPResponses()
+	routineTicker := time.NewTicker(time.Minute)
+	for range routineTicker.C {
+		RefreshOCSPResponses()
+	}
+}
+
+// RefreshOCSPResponses fetch the OCSP responses which are missing or about to expire,
+// and staple them to the certificates.
+func RefreshOCSPResponses() {
+	ocspMutex.Lock()
+	defer ocspMutex.Unlock()
+	now := time.Now().Unix()
+	for _, certItem := range GetCertItems() {
+		tlsCert := GetTLSCertificate(certItem)
+		if len(tlsCert.Certificate) == 0 {
+			continue
+		}
+		key := ocspCacheKey(tlsCert.Certificate[0])
+		var item *ocspCacheItem
+		if value, ok := ocspCache.Load(key); ok {
+			item = value.(*ocspCacheItem)
+		}
+		if item == nil || isOCSPRefreshNeeded(item.status, now) {
+			status, staple := FetchOCSPResponse(certItem)
+			if staple == nil && item != nil && item.staple != nil {
+				// Keep the previous response until it expires
+				staple = item.staple
+				status.ThisUpdate = item.status.ThisUpdate
+				status.NextUpdate = item.status.NextUpdate
+			}
+			item = &ocspCacheItem{status: status, staple: staple}
+			ocspCache.Store(key, item)
+		}
+		applyOCSPStaple(certItem, tlsCert, item, now)
+	}
+}
+
+func ocspCacheKey(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+func isOCSPRefreshNeeded(status *models.OCSPStatus, now int64) bool {
+	switch status.Status {
+	case "none":
+		// No OCSP responder in the certificate
+		return false
+	case "unavailable":
+		return now-status.LastCheck >= ocspRetrySeconds
+	}
+	if status.Error != "" && now-status.LastCheck < ocspRetrySeconds {
+		return false
+	}
+	if status.NextUpdate == 0 {
+		return now-status.LastCheck >= ocspDefaultRefreshSeconds
+	}
+	// Refresh at the half of the validity period
+	return now >= status.ThisUpdate+(status.NextUpdate-status.ThisUpdate)/2
+}
+
+// applyOCSPStaple set the OCSP response to a copy of tlsCert, and swap it for the new handshakes,
+// the certificate is not changed if it has been replaced since tlsCert was read.
+func applyOCSPStaple(certItem *models.CertItem, tlsCert *tls.Certificate, item *ocspCacheItem, now int64) {
+	staple := item.staple
+	if staple != nil && item.status.NextUpdate > 0 && item.status.NextUpdate <= now {
+		// Expired response would be rejected by clients
+		staple = nil
+	}
+	status := *item.status
+	status.Stapled = staple != nil
+	certsMutex.Lock()
+	defer certsMutex.Unlock()
+	if GetTLSCertificate(certItem) != tlsCert {
+		return
+	}
+	if !bytes.Equal(tlsCert.OCSPStaple, staple) {
+		stapledCert := *tlsCert
+		stapledCert.OCSPStaple = staple
+		setTLSCertificate(certItem, stapledCert)
+	}
+	certItem.OCSP = &status
+}
+
+// GetOCSPStatus return the OCSP status of certItem, nil if not checked yet
+func GetOCSPStatus(certItem *models.CertItem) *models.OCSPStatus {
+	certsMutex.RLock()
+	defer certsMutex.RUnlock()
+	return certItem.OCSP
+}
+
+// FetchOCSPResponse query the OCSP responder of the certificate, the response is returned
+// for stapling if its status is good or revoked.
+func FetchOCSPResponse(certItem *models.CertItem) (*models.OCSPStatus, []byte) {
+	status := &models.OCSPStatus{LastCheck: time.Now().Unix()}
+	leaf, err := GetLeafCertificate(certItem)
+	if err != nil {
+		status.Status = "none"
+		status.Error = err.Error()
+		return status, nil
+	}
+	if len(leaf.OCSPServer) == 0 {
+		status.Status = "none"
+		return status, nil
+	}
+	status.Responder = leaf.OCSPServer[0]
+	issuer, err := getIssuerCertificate(certItem, leaf)
+	if err != nil {
+		status.Status = "unavailable"
+		status.Error = err.Error()
+		return status, nil
+	}
+	ocspRequest, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		status.Status = "unavailable"
+		status.Error = err.Error()
+		return status, nil
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(status.Responder, "application/ocsp-request", bytes.NewReader(ocspRequest))
+	if err != nil {
+		utils.DebugPrintln("FetchOCSPResponse", certItem.CommonName, err)
+		status.Status = "unavailable"
+		status.Error = err.Error()
+		return status, nil
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err == nil && resp.StatusCode != http.StatusOK {
+		err = errors.New("OCSP responder " + resp.Status)
+	}
+	var ocspResp *ocsp.Response
+	if err == nil {
+		ocspResp, err = ocsp.ParseResponseForCert(body, leaf, issuer)
+	}
+	if err != nil {
+		utils.DebugPrintln("FetchOCSPResponse", certItem.CommonName, err)
+		status.Status = "unavailable"
+		status.Error = err.Error()
+		return status, nil
+	}
+	status.ThisUpdate = ocspResp.ThisUpdate.Unix()
+	if !ocspResp.NextUpdate.IsZero() {
+		status.NextUpdate = ocspResp.NextUpdate.Unix()
+	}
+	switch ocspResp.Status {
+	case ocsp.Good:
+		status.Status = "good"
+	case ocsp.Revoked:
+		status.Status = "revoked"
+		status.RevokedAt = ocspResp.RevokedAt.Unix()
+	default:
+		status.Status = "unknown"
+		return status, nil
+	}
+	return status, body
+}
+
+// getIssuerCertificate return the issuer from the chain, or download it from the AIA of leaf
+func getIssuerCertificate(certItem *models.CertItem, leaf *x509.Certificate) (*x509.Certificate, error) {
+	if tlsCert := GetTLSCertificate(certItem); len(tlsCert.Certificate) > 1 {
+		return x509.ParseCertificate(tlsCert.Certificate[1])
+	}
+	if len(leaf.IssuingCertificateURL) == 0 {
+		return nil, errors.New("Issuer certificate not found in chain")
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(leaf.IssuingCertificateURL[0])
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(body); block != nil {
+		body = block.Bytes
+	}
+	return x509.ParseCertificate(body)
+}
@@ -0,0 +1,148 @@
+package backend
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"asec/models"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// newOCSPTestCertificate return a certificate chain issued by a test CA with ocspServer
+func newOCSPTestCertificate(t *testing.T, ocspServer string) (*models.CertItem, *x509.Certificate, crypto.Signer) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "asec test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.asec.test"},
+		DNSNames:     []string{"www.asec.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	if ocspServer != "" {
+		leafTemplate.OCSPServer = []string{ocspServer}
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certItem := &models.CertItem{
+		ID:         1,
+		CommonName: "www.asec.test",
+		TlsCert:    tls.Certificate{Certificate: [][]byte{leafDER, caDER}, PrivateKey: leafKey},
+	}
+	return certItem, ca, caKey
+}
+
+func TestRefreshOCSPResponses(t *testing.T) {
+	var ca *x509.Certificate
+	var caKey crypto.Signer
+	ocspStatus := ocsp.Good
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		ocspRequest, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		template := ocsp.Response{
+			Status:       ocspStatus,
+			SerialNumber: ocspRequest.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now().Add(-time.Minute),
+		}
+		resp, err := ocsp.CreateResponse(ca, ca, template, caKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(resp)
+	}))
+	defer responder.Close()
+
+	certItem, issuer, issuerKey := newOCSPTestCertificate(t, responder.URL)
+	ca, caKey = issuer, issuerKey
+	Certs = []*models.CertItem{certItem}
+	defer func() { Certs = nil }()
+
+	handshakeCert := GetTLSCertificate(certItem)
+	RefreshOCSPResponses()
+	if certItem.OCSP == nil || certItem.OCSP.Status != "good" || !certItem.OCSP.Stapled {
+		t.Fatalf("unexpected OCSP status %+v", certItem.OCSP)
+	}
+	// The certificate of the running handshakes is not modified, the new handshakes get the stapled copy
+	stapledCert := GetTLSCertificate(certItem)
+	if len(handshakeCert.OCSPStaple) > 0 || stapledCert == handshakeCert || len(stapledCert.OCSPStaple) == 0 {
+		t.Fatal("OCSP response not stapled to a new certificate")
+	}
+	ocspResp, err := ocsp.ParseResponse(stapledCert.OCSPStaple, ca)
+	if err != nil || ocspResp.Status != ocsp.Good {
+		t.Fatal("invalid stapled response", err)
+	}
+	// Not stapled to the certificate replaced during the refresh
+	replacedCert := *handshakeCert
+	setTLSCertificate(certItem, replacedCert)
+	newCert := GetTLSCertificate(certItem)
+	value, _ := ocspCache.Load(ocspCacheKey(handshakeCert.Certificate[0]))
+	applyOCSPStaple(certItem, stapledCert, value.(*ocspCacheItem), time.Now().Unix())
+	if GetTLSCertificate(certItem) != newCert {
+		t.Fatal("replaced certificate overwritten by the staple")
+	}
+	RefreshOCSPResponses()
+	if len(GetTLSCertificate(certItem).OCSPStaple) == 0 {
+		t.Fatal("OCSP response not stapled to the replaced certificate")
+	}
+
+	// Cached until the half of the validity period
+	ocspStatus = ocsp.Revoked
+	RefreshOCSPResponses()
+	if certItem.OCSP.Status != "good" {
+		t.Fatal("OCSP response not cached")
+	}
+
+	ocspCache = sync.Map{}
+	RefreshOCSPResponses()
+	if certItem.OCSP.Status != "revoked" || certItem.OCSP.RevokedAt == 0 {
+		t.Fatalf("unexpected OCSP status %+v", certItem.OCSP)
+	}
+}
+
+func TestFetchOCSPResponseWithoutResponder(t *testing.T) {
+	certItem, _, _ := newOCSPTestCertificate(t, "")
+	status, staple := FetchOCSPResponse(certItem)
+	if staple != nil || status.Status != "none" {
+		t.Fatalf("unexpected OCSP status %+v", status)
+	}
+	if isOCSPRefreshNeeded(status, time.Now().Unix()+86400) {
+		t.Fatal("certificate without OCSP responder should not be refreshed")
+	}
+}
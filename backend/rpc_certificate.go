@@ -37,3 +37,20 @@ func RPCSelectCertificates() (certs []*models.CertItem) {
 	}
 	return certs
 }
+
+// RPCGetACMEChallenge ...
+func RPCGetACMEChallenge(key string) *models.ACMEChallenge {
+	rpcRequest := &models.RPCRequest{
+		Action: "getacmechallenge", Object: map[string]interface{}{"key": key}}
+	resp, err := data.GetRPCResponse(rpcRequest)
+	if err != nil {
+		utils.CheckError("RPCGetACMEChallenge GetResponse", err)
+		return nil
+	}
+	rpcACMEChallenge := new(models.RPCACMEChallenge)
+	if err = json.Unmarshal(resp, rpcACMEChallenge); err != nil {
+		utils.CheckError("RPCGetACMEChallenge Unmarshal", err)
+		return nil
+	}
+	return rpcACMEChallenge.Object
+}
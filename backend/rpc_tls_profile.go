@@ -0,0 +1,33 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:11:08
+ * @Last Modified: thonsun, 2026-10-19 14:11:08
+ */
+
+package backend
+
+import (
+	"encoding/json"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+func RPCSelectTLSProfiles() (tlsProfiles []*models.TLSProfile) {
+	rpcRequest := &models.RPCRequest{
+		Action: "gettlsprofiles", Object: nil}
+	resp, err := data.GetRPCResponse(rpcRequest)
+	if err != nil {
+		utils.CheckError("RPCSelectTLSProfiles GetResponse", err)
+		return nil
+	}
+	rpcTLSProfiles := new(models.RPCTLSProfiles)
+	err = json.Unmarshal(resp, rpcTLSProfiles)
+	if err != nil {
+		utils.CheckError("RPCSelectTLSProfiles Unmarshal", err)
+		return nil
+	}
+	return rpcTLSProfiles.Object
+}
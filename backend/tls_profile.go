@@ -0,0 +1,312 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:11:08
+ * @Last Modified: thonsun, 2026-10-19 14:11:08
+ */
+
+package backend
+
+import (
+	"crypto/tls"
+	"errors"
+	"strings"
+	"sync"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+var (
+	TLSProfiles []*models.TLSProfile
+
+	// tlsProfileConfigs (int64, *tls.Config) the built config of each profile
+	tlsProfileConfigs sync.Map
+
+	tlsVersions = map[string]uint16{
+		"TLS1.0": tls.VersionTLS10,
+		"TLS1.1": tls.VersionTLS11,
+		"TLS1.2": tls.VersionTLS12,
+		"TLS1.3": tls.VersionTLS13,
+	}
+
+	tlsCurves = map[string]tls.CurveID{
+		"X25519": tls.X25519,
+		"P256":   tls.CurveP256,
+		"P384":   tls.CurveP384,
+		"P521":   tls.CurveP521,
+	}
+)
+
+// InitTLSProfiles add the built-in profile for new installation
+func InitTLSProfiles() {
+	if data.DAL.CountTLSProfiles() > 0 {
+		return
+	}
+	modernProfile := &models.TLSProfile{
+		Name:       "TLS1.2+ AEAD",
+		MinVersion: "TLS1.2",
+		MaxVersion: "TLS1.3",
+		CipherSuites: []string{
+			"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+			"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+			"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+			"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+			"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+			"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"},
+		Curves:      []string{"X25519", "P256", "P384"},
+		NextProtos:  []string{"h2", "http/1.1"},
+		Description: "TLS 1.2 and above with AEAD cipher suites only",
+	}
+	_, err := data.DAL.InsertTLSProfile(modernProfile)
+	utils.CheckError("InitTLSProfiles", err)
+}
+
+// LoadTLSProfiles load profiles from DB, or from the primary node for replicas
+func LoadTLSProfiles() {
+	if data.IsPrimary {
+		TLSProfiles = data.DAL.SelectTLSProfiles()
+	} else {
+		rpcTLSProfiles := RPCSelectTLSProfiles()
+		if rpcTLSProfiles != nil {
+			TLSProfiles = rpcTLSProfiles
+		}
+	}
+	tlsProfileConfigs.Range(func(key, value interface{}) bool {
+		tlsProfileConfigs.Delete(key)
+		return true
+	})
+}
+
+func GetTLSProfiles() ([]*models.TLSProfile, error) {
+	return TLSProfiles, nil
+}
+
+func GetTLSProfileByID(id int64) (*models.TLSProfile, error) {
+	for _, tlsProfile := range TLSProfiles {
+		if tlsProfile.ID == id {
+			return tlsProfile, nil
+		}
+	}
+	return nil, errors.New("TLS profile not found")
+}
+
+// CheckTLSProfileID check the profile referred by applications and domains, 0 is the default TLS config
+func CheckTLSProfileID(id int64) error {
+	if id == 0 {
+		return nil
+	}
+	_, err := GetTLSProfileByID(id)
+	return err
+}
+
+// ValidateTLSProfile check the names of versions, cipher suites and curves
+func ValidateTLSProfile(tlsProfile *models.TLSProfile) error {
+	if len(strings.TrimSpace(tlsProfile.Name)) == 0 {
+		return errors.New("TLS profile name is required")
+	}
+	minVersion, maxVersion := uint16(0), uint16(0)
+	var ok bool
+	if len(tlsProfile.MinVersion) > 0 {
+		if minVersion, ok = tlsVersions[tlsProfile.MinVersion]; !ok {
+			return errors.New("Unknown TLS version " + tlsProfile.MinVersion)
+		}
+	}
+	if len(tlsProfile.MaxVersion) > 0 {
+		if maxVersion, ok = tlsVersions[tlsProfile.MaxVersion]; !ok {
+			return errors.New("Unknown TLS version " + tlsProfile.MaxVersion)
+		}
+	}
+	if minVersion > 0 && maxVersion > 0 && minVersion > maxVersion {
+		return errors.New("min_version is greater than max_version")
+	}
+	if _, err := getCipherSuiteIDs(tlsProfile.CipherSuites); err != nil {
+		return err
+	}
+	for _, curve := range tlsProfile.Curves {
+		if _, ok := tlsCurves[curve]; !ok {
+			return errors.New("Unknown curve " + curve)
+		}
+	}
+	return nil
+}
+
+func getCipherSuiteIDs(names []string) ([]uint16, error) {
+	var ids []uint16
+	for _, name := range names {
+		id, ok := uint16(0), false
+		for _, suite := range tls.CipherSuites() {
+			if suite.Name == name {
+				id, ok = suite.ID, true
+				break
+			}
+		}
+		if !ok {
+			for _, suite := range tls.InsecureCipherSuites() {
+				if suite.Name == name {
+					id, ok = suite.ID, true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, errors.New("Unknown cipher suite " + name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// UpdateTLSProfile add or modify a TLS profile
+func UpdateTLSProfile(param map[string]interface{}, authUser *models.AuthUser) (*models.TLSProfile, error) {
+	if authUser.IsAppAdmin == false {
+		return nil, errors.New("You have no privilege to modify TLS profiles.")
+	}
+	tlsProfileMap, ok := param["object"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("object is required")
+	}
+	id, ok := tlsProfileMap["id"].(float64)
+	if !ok {
+		return nil, errors.New("id is required")
+	}
+	name, ok := tlsProfileMap["name"].(string)
+	if !ok {
+		return nil, errors.New("name is required")
+	}
+	tlsProfile := &models.TLSProfile{
+		ID:           int64(id),
+		Name:         name,
+		CipherSuites: InterfaceToStringSlice(tlsProfileMap["cipher_suites"]),
+		Curves:       InterfaceToStringSlice(tlsProfileMap["curves"]),
+		NextProtos:   InterfaceToStringSlice(tlsProfileMap["next_protos"]),
+	}
+	tlsProfile.MinVersion, _ = tlsProfileMap["min_version"].(string)
+	tlsProfile.MaxVersion, _ = tlsProfileMap["max_version"].(string)
+	tlsProfile.Description, _ = tlsProfileMap["description"].(string)
+	err := ValidateTLSProfile(tlsProfile)
+	if err != nil {
+		return nil, err
+	}
+	if tlsProfile.ID == 0 {
+		tlsProfile.ID, err = data.DAL.InsertTLSProfile(tlsProfile)
+		if err != nil {
+			return nil, err
+		}
+		TLSProfiles = append(TLSProfiles, tlsProfile)
+	} else {
+		oldTLSProfile, err := GetTLSProfileByID(tlsProfile.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err = data.DAL.UpdateTLSProfile(tlsProfile); err != nil {
+			return nil, err
+		}
+		*oldTLSProfile = *tlsProfile
+		tlsProfile = oldTLSProfile
+	}
+	tlsProfileConfigs.Delete(tlsProfile.ID)
+	data.UpdateBackendLastModified()
+	return tlsProfile, nil
+}
+
+// DeleteTLSProfileByID delete the profile not used by applications or domains
+func DeleteTLSProfileByID(id int64, authUser *models.AuthUser) error {
+	if authUser.IsAppAdmin == false {
+		return errors.New("You have no privilege to delete TLS profiles.")
+	}
+	if data.DAL.SelectTLSProfileUsageCount(id) > 0 {
+		return errors.New("This TLS profile is in use, please modify relevant applications and domains at first.")
+	}
+	if err := data.DAL.DeleteTLSProfile(id); err != nil {
+		return err
+	}
+	for i, tlsProfile := range TLSProfiles {
+		if tlsProfile.ID == id {
+			TLSProfiles = append(TLSProfiles[:i], TLSProfiles[i+1:]...)
+			break
+		}
+	}
+	tlsProfileConfigs.Delete(id)
+	data.UpdateBackendLastModified()
+	return nil
+}
+
+// InterfaceToStringSlice convert the JSON array to []string
+func InterfaceToStringSlice(value interface{}) []string {
+	values := []string{}
+	valuesI, _ := value.([]interface{})
+	for _, valueI := range valuesI {
+		if str, ok := valueI.(string); ok && len(strings.TrimSpace(str)) > 0 {
+			values = append(values, strings.TrimSpace(str))
+		}
+	}
+	return values
+}
+
+// BuildTLSConfig return a copy of baseConfig restricted by the profile
+func BuildTLSConfig(baseConfig *tls.Config, tlsProfile *models.TLSProfile) *tls.Config {
+	tlsConfig := baseConfig.Clone()
+	tlsConfig.GetConfigForClient = nil
+	if version, ok := tlsVersions[tlsProfile.MinVersion]; ok {
+		tlsConfig.MinVersion = version
+	}
+	if version, ok := tlsVersions[tlsProfile.MaxVersion]; ok {
+		tlsConfig.MaxVersion = version
+	}
+	if cipherSuites, err := getCipherSuiteIDs(tlsProfile.CipherSuites); err == nil && len(cipherSuites) > 0 {
+		tlsConfig.CipherSuites = cipherSuites
+	}
+	if len(tlsProfile.Curves) > 0 {
+		var curves []tls.CurveID
+		for _, curve := range tlsProfile.Curves {
+			if curveID, ok := tlsCurves[curve]; ok {
+				curves = append(curves, curveID)
+			}
+		}
+		tlsConfig.CurvePreferences = curves
+	}
+	if len(tlsProfile.NextProtos) > 0 {
+		tlsConfig.NextProtos = tlsProfile.NextProtos
+	}
+	return tlsConfig
+}
+
+// GetTLSProfileIDByDomain return the profile of domain, or the profile of its application
+func GetTLSProfileIDByDomain(domain string) int64 {
+	relation, ok := GetDomainRelation(domain)
+	if !ok {
+		return 0
+	}
+	if relation.TLSProfileID > 0 {
+		return relation.TLSProfileID
+	}
+	if relation.App != nil {
+		return relation.App.TLSProfileID
+	}
+	return 0
+}
+
+// GetConfigForClient return the TLS config of the profile attached to the SNI domain,
+// nil for baseConfig.
+func GetConfigForClient(helloInfo *tls.ClientHelloInfo, baseConfig *tls.Config) (*tls.Config, error) {
+	if IsACMETLSALPNHello(helloInfo) {
+		return nil, nil
+	}
+	tlsProfileID := GetTLSProfileIDByDomain(helloInfo.ServerName)
+	if tlsProfileID == 0 {
+		return nil, nil
+	}
+	if tlsConfig, ok := tlsProfileConfigs.Load(tlsProfileID); ok {
+		return tlsConfig.(*tls.Config), nil
+	}
+	tlsProfile, err := GetTLSProfileByID(tlsProfileID)
+	if err != nil {
+		// Profile deleted, use the default config
+		return nil, nil
+	}
+	tlsConfig := BuildTLSConfig(baseConfig, tlsProfile)
+	tlsProfileConfigs.Store(tlsProfileID, tlsConfig)
+	return tlsConfig, nil
+}
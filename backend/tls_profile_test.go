@@ -0,0 +1,118 @@
+package backend
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"asec/models"
+
+	"golang.org/x/crypto/acme"
+)
+
+func TestUpdateTLSProfileInvalidParam(t *testing.T) {
+	authUser := &models.AuthUser{IsAppAdmin: true}
+	for _, invalid := range []map[string]interface{}{
+		{},
+		{"object": "profile"},
+		{"object": map[string]interface{}{"name": "modern"}},
+		{"object": map[string]interface{}{"id": "1", "name": "modern"}},
+		{"object": map[string]interface{}{"id": 0.0}},
+		{"object": map[string]interface{}{"id": 0.0, "name": 1.0}},
+	} {
+		if _, err := UpdateTLSProfile(invalid, authUser); err == nil {
+			t.Error("invalid param accepted", invalid)
+		}
+	}
+}
+
+func TestUpdateApplicationUnknownTLSProfile(t *testing.T) {
+	oldTLSProfiles := TLSProfiles
+	defer func() { TLSProfiles = oldTLSProfiles }()
+	TLSProfiles = []*models.TLSProfile{{ID: 1, Name: "modern"}}
+	for _, id := range []int64{0, 1} {
+		if err := CheckTLSProfileID(id); err != nil {
+			t.Errorf("TLS profile %d rejected, %v", id, err)
+		}
+	}
+	newApplication := func(appTLSProfileID float64, domainTLSProfileID float64) map[string]interface{} {
+		return map[string]interface{}{"object": map[string]interface{}{
+			"id": 0.0, "name": "app", "internal_scheme": "http", "redirect_https": false, "hsts_enabled": false,
+			"waf_enabled": true, "ip_method": 1.0, "oauth_required": false, "session_seconds": 7200.0, "owner": "admin",
+			"tls_profile_id": appTLSProfileID,
+			"domains":        []interface{}{map[string]interface{}{"id": 0.0, "name": "www.example.com", "tls_profile_id": domainTLSProfileID}},
+		}}
+	}
+	// Rejected before any change is saved
+	if _, err := UpdateApplication(newApplication(2, 0)); err == nil {
+		t.Error("application saved with unknown TLS profile")
+	}
+	if _, err := UpdateApplication(newApplication(1, 2)); err == nil {
+		t.Error("domain saved with unknown TLS profile")
+	}
+}
+
+func TestGetConfigForClient(t *testing.T) {
+	oldTLSProfiles := TLSProfiles
+	defer func() {
+		TLSProfiles = oldTLSProfiles
+		for _, domain := range []string{"www.asec.test", "api.asec.test", "old.asec.test"} {
+			DomainsMap.Delete(domain)
+		}
+		for _, id := range []int64{1, 2, 3} {
+			tlsProfileConfigs.Delete(id)
+		}
+	}()
+	TLSProfiles = []*models.TLSProfile{
+		{ID: 1, Name: "modern", MinVersion: "TLS1.3"},
+		{ID: 2, Name: "h1", MinVersion: "TLS1.2", MaxVersion: "TLS1.2", NextProtos: []string{"http/1.1"}},
+	}
+	app := &models.Application{ID: 1, TLSProfileID: 1}
+	DomainsMap.Store("www.asec.test", models.DomainRelation{App: app})
+	DomainsMap.Store("api.asec.test", models.DomainRelation{App: app, TLSProfileID: 2})
+	// Profile 3 has been deleted
+	DomainsMap.Store("old.asec.test", models.DomainRelation{App: app, TLSProfileID: 3})
+	baseConfig := &tls.Config{MinVersion: tls.VersionTLS10, NextProtos: []string{"h2", "http/1.1"}}
+	baseConfig.GetConfigForClient = func(helloInfo *tls.ClientHelloInfo) (*tls.Config, error) {
+		return GetConfigForClient(helloInfo, baseConfig)
+	}
+	cases := []struct {
+		serverName      string
+		supportedProtos []string
+		// expectedMin is 0 if baseConfig is expected
+		expectedMin uint16
+		expectedMax uint16
+		nextProtos  []string
+	}{
+		{"www.asec.test", nil, tls.VersionTLS13, 0, []string{"h2", "http/1.1"}},
+		{"api.asec.test", []string{"h2"}, tls.VersionTLS12, tls.VersionTLS12, []string{"http/1.1"}},
+		{"old.asec.test", nil, 0, 0, nil},
+		{"www.example.com", nil, 0, 0, nil},
+		// The ACME TLS-ALPN-01 challenge is answered with baseConfig
+		{"www.asec.test", []string{acme.ALPNProto}, 0, 0, nil},
+	}
+	for _, c := range cases {
+		tlsConfig, err := GetConfigForClient(&tls.ClientHelloInfo{ServerName: c.serverName, SupportedProtos: c.supportedProtos}, baseConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.expectedMin == 0 {
+			if tlsConfig != nil {
+				t.Errorf("%s %v: expected baseConfig", c.serverName, c.supportedProtos)
+			}
+			continue
+		}
+		if tlsConfig == nil || tlsConfig.MinVersion != c.expectedMin || tlsConfig.MaxVersion != c.expectedMax || tlsConfig.GetConfigForClient != nil {
+			t.Errorf("%s: unexpected config %+v", c.serverName, tlsConfig)
+			continue
+		}
+		if len(tlsConfig.NextProtos) != len(c.nextProtos) || tlsConfig.NextProtos[0] != c.nextProtos[0] {
+			t.Errorf("%s: next protos %v, expected %v", c.serverName, tlsConfig.NextProtos, c.nextProtos)
+		}
+	}
+	// The config is cached until the profile is modified
+	tlsConfig1, _ := GetConfigForClient(&tls.ClientHelloInfo{ServerName: "www.asec.test"}, baseConfig)
+	tlsConfig2, _ := GetConfigForClient(&tls.ClientHelloInfo{ServerName: "www.asec.test"}, baseConfig)
+	if tlsConfig1 != tlsConfig2 {
+		t.Error("TLS config not cached")
+	}
+}
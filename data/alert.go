@@ -0,0 +1,49 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:06:37
+ * @Last Modified: thonsun, 2026-10-19 14:06:37
+ */
+
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"asec/models"
+	"asec/utils"
+)
+
+// SendAlert write the alert to log and post it to the webhooks in config.json
+func SendAlert(source string, title string, content string) {
+	alert := &models.Alert{
+		Source:  source,
+		Title:   title,
+		Content: content,
+		Time:    time.Now().Unix(),
+	}
+	utils.DebugPrintln("Alert", source, title, content)
+	if CFG == nil || len(CFG.PrimaryNode.Alert.Webhooks) == 0 {
+		return
+	}
+	body, err := json.Marshal(alert)
+	if err != nil {
+		utils.DebugPrintln("SendAlert Marshal", err)
+		return
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	for _, webhook := range CFG.PrimaryNode.Alert.Webhooks {
+		resp, err := client.Post(webhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			utils.DebugPrintln("SendAlert", webhook, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			utils.DebugPrintln("SendAlert", webhook, resp.Status)
+		}
+	}
+}
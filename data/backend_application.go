@@ -13,13 +13,13 @@ import (
 )
 
 func (dal *MyDAL) CreateTableIfNotExistsApplications() error {
-	const sqlCreateTableIfNotExistsApplications = `CREATE TABLE IF NOT EXISTS applications(id bigserial PRIMARY KEY,name varchar(128) NOT NULL,internal_scheme varchar(8) NOT NULL,redirect_https boolean,hsts_enabled boolean,waf_enabled boolean,ip_method bigint,description varchar(256),oauth_required boolean,session_seconds bigint default 7200,owner varchar(128))`
+	const sqlCreateTableIfNotExistsApplications = `CREATE TABLE IF NOT EXISTS applications(id bigserial PRIMARY KEY,name varchar(128) NOT NULL,internal_scheme varchar(8) NOT NULL,redirect_https boolean,hsts_enabled boolean,waf_enabled boolean,ip_method bigint,description varchar(256),oauth_required boolean,session_seconds bigint default 7200,owner varchar(128),tls_profile_id bigint default 0,scoring_enabled boolean default false,inbound_threshold bigint default 5,outbound_threshold bigint default 4,monitor_mode boolean default false,response_inspect_kb bigint default 64)`
 	_, err := dal.db.Exec(sqlCreateTableIfNotExistsApplications)
 	return err
 }
 
 func (dal *MyDAL) SelectApplications() []*models.DBApplication {
-	const sqlSelectApplications = `SELECT id,name,internal_scheme,redirect_https,hsts_enabled,waf_enabled,ip_method,description,oauth_required,session_seconds,owner FROM applications`
+	const sqlSelectApplications = `SELECT id,name,internal_scheme,redirect_https,hsts_enabled,waf_enabled,ip_method,description,oauth_required,session_seconds,owner,tls_profile_id,scoring_enabled,inbound_threshold,outbound_threshold,monitor_mode,response_inspect_kb FROM applications`
 	rows, err := dal.db.Query(sqlSelectApplications)
 	utils.CheckError("SelectApplications", err)
 	defer rows.Close()
@@ -37,24 +37,30 @@ func (dal *MyDAL) SelectApplications() []*models.DBApplication {
 			&dbApp.Description,
 			&dbApp.OAuthRequired,
 			&dbApp.SessionSeconds,
-			&dbApp.Owner)
+			&dbApp.Owner,
+			&dbApp.TLSProfileID,
+			&dbApp.ScoringEnabled,
+			&dbApp.InboundThreshold,
+			&dbApp.OutboundThreshold,
+			&dbApp.MonitorMode,
+			&dbApp.ResponseInspectKB)
 		dbApps = append(dbApps, dbApp)
 	}
 	return dbApps
 }
 
-func (dal *MyDAL) InsertApplication(appName string, internalScheme string, redirectHttps bool, hstsEnabled bool, wafEnabled bool, ipMethod models.IPMethod, description string, oauthRequired bool, sessionSeconds int64, owner string) (newID int64) {
-	const sqlInsertApplication = `INSERT INTO applications(name,internal_scheme,redirect_https,hsts_enabled,waf_enabled,ip_method,description,oauth_required,session_seconds,owner) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id`
-	err := dal.db.QueryRow(sqlInsertApplication, appName, internalScheme, redirectHttps, hstsEnabled, wafEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner).Scan(&newID)
+func (dal *MyDAL) InsertApplication(appName string, internalScheme string, redirectHttps bool, hstsEnabled bool, wafEnabled bool, ipMethod models.IPMethod, description string, oauthRequired bool, sessionSeconds int64, owner string, tlsProfileID int64, scoringEnabled bool, inboundThreshold int64, outboundThreshold int64, monitorMode bool, responseInspectKB int64) (newID int64) {
+	const sqlInsertApplication = `INSERT INTO applications(name,internal_scheme,redirect_https,hsts_enabled,waf_enabled,ip_method,description,oauth_required,session_seconds,owner,tls_profile_id,scoring_enabled,inbound_threshold,outbound_threshold,monitor_mode,response_inspect_kb) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16) RETURNING id`
+	err := dal.db.QueryRow(sqlInsertApplication, appName, internalScheme, redirectHttps, hstsEnabled, wafEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner, tlsProfileID, scoringEnabled, inboundThreshold, outboundThreshold, monitorMode, responseInspectKB).Scan(&newID)
 	utils.CheckError("InsertApplication", err)
 	return newID
 }
 
-func (dal *MyDAL) UpdateApplication(appName string, internalScheme string, redirectHttps bool, hstsEnabled bool, wafEnabled bool, ipMethod models.IPMethod, description string, oauthRequired bool, sessionSeconds int64, owner string, appID int64) error {
-	const sqlUpdateApplication = `UPDATE applications SET name=$1,internal_scheme=$2,redirect_https=$3,hsts_enabled=$4,waf_enabled=$5,ip_method=$6,description=$7,oauth_required=$8,session_seconds=$9,owner=$10 WHERE id=$11`
+func (dal *MyDAL) UpdateApplication(appName string, internalScheme string, redirectHttps bool, hstsEnabled bool, wafEnabled bool, ipMethod models.IPMethod, description string, oauthRequired bool, sessionSeconds int64, owner string, tlsProfileID int64, scoringEnabled bool, inboundThreshold int64, outboundThreshold int64, monitorMode bool, responseInspectKB int64, appID int64) error {
+	const sqlUpdateApplication = `UPDATE applications SET name=$1,internal_scheme=$2,redirect_https=$3,hsts_enabled=$4,waf_enabled=$5,ip_method=$6,description=$7,oauth_required=$8,session_seconds=$9,owner=$10,tls_profile_id=$11,scoring_enabled=$12,inbound_threshold=$13,outbound_threshold=$14,monitor_mode=$15,response_inspect_kb=$16 WHERE id=$17`
 	stmt, err := dal.db.Prepare(sqlUpdateApplication)
 	defer stmt.Close()
-	_, err = stmt.Exec(appName, internalScheme, redirectHttps, hstsEnabled, wafEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner, appID)
+	_, err = stmt.Exec(appName, internalScheme, redirectHttps, hstsEnabled, wafEnabled, ipMethod, description, oauthRequired, sessionSeconds, owner, tlsProfileID, scoringEnabled, inboundThreshold, outboundThreshold, monitorMode, responseInspectKB, appID)
 	utils.CheckError("UpdateApplication", err)
 	return err
 }
@@ -0,0 +1,47 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:15:14
+ * @Last Modified: thonsun, 2026-10-19 14:15:14
+ */
+
+package data
+
+import (
+	"asec/models"
+	"asec/utils"
+)
+
+const (
+	sqlCreateTableIfNotExistsAuditLogs = `CREATE TABLE IF NOT EXISTS audit_logs(id bigserial PRIMARY KEY, username varchar(128), action varchar(128), object varchar(1024), src_ip varchar(256), request_time bigint)`
+	sqlInsertAuditLog                  = `INSERT INTO audit_logs(username, action, object, src_ip, request_time) VALUES($1,$2,$3,$4,$5)`
+	sqlSelectAuditLogs                 = `SELECT id, username, action, object, src_ip, request_time FROM audit_logs WHERE request_time between $1 and $2 ORDER BY id DESC LIMIT $3 OFFSET $4`
+)
+
+func (dal *MyDAL) CreateTableIfNotExistsAuditLogs() error {
+	_, err := dal.db.Exec(sqlCreateTableIfNotExistsAuditLogs)
+	return err
+}
+
+func (dal *MyDAL) InsertAuditLog(auditLog *models.AuditLog) error {
+	_, err := dal.db.Exec(sqlInsertAuditLog, auditLog.Username, auditLog.Action, auditLog.Object, auditLog.SrcIP, auditLog.RequestTime)
+	utils.CheckError("InsertAuditLog", err)
+	return err
+}
+
+func (dal *MyDAL) SelectAuditLogs(startTime int64, endTime int64, requestCount int64, offset int64) ([]*models.AuditLog, error) {
+	rows, err := dal.db.Query(sqlSelectAuditLogs, startTime, endTime, requestCount, offset)
+	utils.CheckError("SelectAuditLogs", err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	auditLogs := []*models.AuditLog{}
+	for rows.Next() {
+		auditLog := new(models.AuditLog)
+		err = rows.Scan(&auditLog.ID, &auditLog.Username, &auditLog.Action, &auditLog.Object, &auditLog.SrcIP, &auditLog.RequestTime)
+		utils.CheckError("SelectAuditLogs Scan", err)
+		auditLogs = append(auditLogs, auditLog)
+	}
+	return auditLogs, nil
+}
@@ -16,10 +16,10 @@ import (
 )
 
 const (
-	sqlCreateTableIfNotExistsCertificates = `CREATE TABLE IF NOT EXISTS certificates(id bigserial primary key,common_name varchar(256) not null,pub_cert varchar(16384) not null,priv_key bytea not null,expire_time bigint,description varchar(256))`
-	sqlSelectCertificates                 = `SELECT id,common_name,pub_cert,priv_key,expire_time,description FROM certificates`
-	sqlInsertCertificate                  = `INSERT INTO certificates(common_name,pub_cert,priv_key,expire_time,description) VALUES($1,$2,$3,$4,$5) RETURNING id`
-	sqlUpdateCertificate                  = `UPDATE certificates SET common_name=$1,pub_cert=$2,priv_key=$3,expire_time=$4,description=$5 WHERE id=$6`
+	sqlCreateTableIfNotExistsCertificates = `CREATE TABLE IF NOT EXISTS certificates(id bigserial primary key,common_name varchar(256) not null,pub_cert varchar(16384) not null,priv_key bytea not null,expire_time bigint,description varchar(256),acme boolean default false)`
+	sqlSelectCertificates                 = `SELECT id,common_name,pub_cert,priv_key,expire_time,description,acme FROM certificates`
+	sqlInsertCertificate                  = `INSERT INTO certificates(common_name,pub_cert,priv_key,expire_time,description,acme) VALUES($1,$2,$3,$4,$5,$6) RETURNING id`
+	sqlUpdateCertificate                  = `UPDATE certificates SET common_name=$1,pub_cert=$2,priv_key=$3,expire_time=$4,description=$5,acme=$6 WHERE id=$7`
 	sqlDeleteCertificate                  = `DELETE FROM certificates WHERE id=$1`
 )
 
@@ -37,22 +37,22 @@ func (dal *MyDAL) SelectCertificates() []*models.DBCertItem {
 		dbCert := new(models.DBCertItem)
 		err = rows.Scan(&dbCert.ID, &dbCert.CommonName,
 			&dbCert.CertContent, &dbCert.EncryptedPrivKey,
-			&dbCert.ExpireTime, &dbCert.Description)
+			&dbCert.ExpireTime, &dbCert.Description, &dbCert.ACME)
 		dbCerts = append(dbCerts, dbCert)
 	}
 	return dbCerts
 }
 
-func (dal *MyDAL) InsertCertificate(commonName string, certContent string, encryptedPrivKey []byte, expireTime int64, description string) (new_id int64) {
-	err := dal.db.QueryRow(sqlInsertCertificate, commonName, certContent, encryptedPrivKey, expireTime, description).Scan(&new_id)
+func (dal *MyDAL) InsertCertificate(commonName string, certContent string, encryptedPrivKey []byte, expireTime int64, description string, acme bool) (new_id int64) {
+	err := dal.db.QueryRow(sqlInsertCertificate, commonName, certContent, encryptedPrivKey, expireTime, description, acme).Scan(&new_id)
 	utils.CheckError("InsertCertificate", err)
 	return new_id
 }
 
-func (dal *MyDAL) UpdateCertificate(commonName string, certContent string, encryptedPrivKey []byte, expireTime int64, description string, id int64) error {
+func (dal *MyDAL) UpdateCertificate(commonName string, certContent string, encryptedPrivKey []byte, expireTime int64, description string, acme bool, id int64) error {
 	stmt, err := dal.db.Prepare(sqlUpdateCertificate)
 	defer stmt.Close()
-	_, err = stmt.Exec(commonName, certContent, encryptedPrivKey, expireTime, description, id)
+	_, err = stmt.Exec(commonName, certContent, encryptedPrivKey, expireTime, description, acme, id)
 	utils.CheckError("UpdateCertificate", err)
 	return err
 }
@@ -13,11 +13,11 @@ import (
 )
 
 const (
-	sqlCreateTableIfNotExistsDomains = `CREATE TABLE IF NOT EXISTS domains(id bigserial PRIMARY KEY, name varchar(256) NOT NULL, app_id bigint NOT NULL, cert_id bigint, redirect boolean, location varchar(256))`
-	sqlSelectDomainsCountByCertID    = `SELECT COUNT(1) FROM domains WHERE cert_id=$1`
-	sqlSelectDomains                 = `SELECT id, name, app_id, cert_id, redirect, location FROM domains`
-	sqlInsertDomain                  = `INSERT INTO domains(name, app_id, cert_id, redirect, location) VALUES($1,$2,$3,$4,$5) RETURNING id`
-	sqlUpdateDomain                  = `UPDATE domains SET name=$1,app_id=$2,cert_id=$3,redirect=$4,location=$5 WHERE id=$6`
+	sqlCreateTableIfNotExistsDomains = `CREATE TABLE IF NOT EXISTS domains(id bigserial PRIMARY KEY, name varchar(256) NOT NULL, app_id bigint NOT NULL, cert_id bigint, redirect boolean, location varchar(256), alt_cert_id bigint default 0, tls_profile_id bigint default 0)`
+	sqlSelectDomainsCountByCertID    = `SELECT COUNT(1) FROM domains WHERE cert_id=$1 OR alt_cert_id=$1`
+	sqlSelectDomains                 = `SELECT id, name, app_id, cert_id, redirect, location, alt_cert_id, tls_profile_id FROM domains`
+	sqlInsertDomain                  = `INSERT INTO domains(name, app_id, cert_id, redirect, location, alt_cert_id, tls_profile_id) VALUES($1,$2,$3,$4,$5,$6,$7) RETURNING id`
+	sqlUpdateDomain                  = `UPDATE domains SET name=$1,app_id=$2,cert_id=$3,redirect=$4,location=$5,alt_cert_id=$6,tls_profile_id=$7 WHERE id=$8`
 	sqlDeleteDomainByDomainID        = `DELETE FROM domains WHERE id=$1`
 	sqlDeleteDomainByAppID           = `DELETE FROM domains WHERE app_id=$1`
 )
@@ -33,7 +33,7 @@ func (dal *MyDAL) SelectDomains() (dbDomains []*models.DBDomain) {
 	defer rows.Close()
 	for rows.Next() {
 		dbDomain := new(models.DBDomain)
-		err = rows.Scan(&dbDomain.ID, &dbDomain.Name, &dbDomain.AppID, &dbDomain.CertID, &dbDomain.Redirect, &dbDomain.Location)
+		err = rows.Scan(&dbDomain.ID, &dbDomain.Name, &dbDomain.AppID, &dbDomain.CertID, &dbDomain.Redirect, &dbDomain.Location, &dbDomain.AltCertID, &dbDomain.TLSProfileID)
 		dbDomains = append(dbDomains, dbDomain)
 	}
 	return dbDomains
@@ -46,14 +46,14 @@ func (dal *MyDAL) SelectDomainsCountByCertID(certID int64) int64 {
 	return certDomainsCount
 }
 
-func (dal *MyDAL) InsertDomain(name string, appID int64, certID int64, redirect bool, location string) (newID int64) {
-	err := dal.db.QueryRow(sqlInsertDomain, name, appID, certID, redirect, location).Scan(&newID)
+func (dal *MyDAL) InsertDomain(name string, appID int64, certID int64, redirect bool, location string, altCertID int64, tlsProfileID int64) (newID int64) {
+	err := dal.db.QueryRow(sqlInsertDomain, name, appID, certID, redirect, location, altCertID, tlsProfileID).Scan(&newID)
 	utils.CheckError("InsertDomain", err)
 	return newID
 }
 
-func (dal *MyDAL) UpdateDomain(name string, appID int64, certID int64, redirect bool, location string, domainID int64) error {
-	_, err := dal.db.Exec(sqlUpdateDomain, name, appID, certID, redirect, location, domainID)
+func (dal *MyDAL) UpdateDomain(name string, appID int64, certID int64, redirect bool, location string, altCertID int64, tlsProfileID int64, domainID int64) error {
+	_, err := dal.db.Exec(sqlUpdateDomain, name, appID, certID, redirect, location, altCertID, tlsProfileID, domainID)
 	//stmt, err := dal.db.Prepare(sqlUpdateDomain)
 	//defer stmt.Close()
 	//_, err = stmt.Exec(name, appID, certID, domainID, redirect, location)
@@ -0,0 +1,40 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:13:50
+ * @Last Modified: thonsun, 2026-10-19 14:13:50
+ */
+
+package data
+
+import (
+	"asec/models"
+	"asec/utils"
+)
+
+const (
+	sqlCreateTableIfNotExistsInternalCA = `CREATE TABLE IF NOT EXISTS internal_ca(id bigserial primary key,cert_content varchar(16384) not null,priv_key bytea not null,create_time bigint)`
+	sqlSelectInternalCA                 = `SELECT id,cert_content,priv_key,create_time FROM internal_ca ORDER BY id DESC LIMIT 1`
+	sqlInsertInternalCA                 = `INSERT INTO internal_ca(cert_content,priv_key,create_time) VALUES($1,$2,$3) RETURNING id`
+)
+
+func (dal *MyDAL) CreateTableIfNotExistsInternalCA() error {
+	_, err := dal.db.Exec(sqlCreateTableIfNotExistsInternalCA)
+	return err
+}
+
+// SelectInternalCA return the latest CA, the previous ones are kept for history
+func (dal *MyDAL) SelectInternalCA() (*models.DBInternalCA, error) {
+	dbCA := new(models.DBInternalCA)
+	err := dal.db.QueryRow(sqlSelectInternalCA).Scan(&dbCA.ID, &dbCA.CertContent, &dbCA.EncryptedPrivKey, &dbCA.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+	return dbCA, nil
+}
+
+func (dal *MyDAL) InsertInternalCA(certContent string, encryptedPrivKey []byte, createTime int64) (newID int64, err error) {
+	err = dal.db.QueryRow(sqlInsertInternalCA, certContent, encryptedPrivKey, createTime).Scan(&newID)
+	utils.CheckError("InsertInternalCA", err)
+	return newID, err
+}
@@ -0,0 +1,95 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:11:08
+ * @Last Modified: thonsun, 2026-10-19 14:11:08
+ */
+
+package data
+
+import (
+	"strings"
+
+	"asec/models"
+	"asec/utils"
+)
+
+const (
+	sqlCreateTableIfNotExistsTLSProfiles = `CREATE TABLE IF NOT EXISTS tls_profiles(id bigserial PRIMARY KEY, name varchar(128) NOT NULL, min_version varchar(16), max_version varchar(16), cipher_suites varchar(2048), curves varchar(256), next_protos varchar(256), description varchar(256))`
+	sqlCountTLSProfiles                  = `SELECT COUNT(1) FROM tls_profiles`
+	sqlSelectTLSProfiles                 = `SELECT id, name, min_version, max_version, cipher_suites, curves, next_protos, description FROM tls_profiles`
+	sqlInsertTLSProfile                  = `INSERT INTO tls_profiles(name, min_version, max_version, cipher_suites, curves, next_protos, description) VALUES($1,$2,$3,$4,$5,$6,$7) RETURNING id`
+	sqlUpdateTLSProfile                  = `UPDATE tls_profiles SET name=$1,min_version=$2,max_version=$3,cipher_suites=$4,curves=$5,next_protos=$6,description=$7 WHERE id=$8`
+	sqlDeleteTLSProfile                  = `DELETE FROM tls_profiles WHERE id=$1`
+	sqlSelectTLSProfileUsageCount        = `SELECT (SELECT COUNT(1) FROM applications WHERE tls_profile_id=$1)+(SELECT COUNT(1) FROM domains WHERE tls_profile_id=$1)`
+)
+
+func (dal *MyDAL) CreateTableIfNotExistsTLSProfiles() error {
+	_, err := dal.db.Exec(sqlCreateTableIfNotExistsTLSProfiles)
+	return err
+}
+
+func (dal *MyDAL) CountTLSProfiles() int64 {
+	var count int64
+	err := dal.db.QueryRow(sqlCountTLSProfiles).Scan(&count)
+	utils.CheckError("CountTLSProfiles", err)
+	return count
+}
+
+// splitList convert the comma separated column to slice
+func splitList(value string) []string {
+	if len(value) == 0 {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+func (dal *MyDAL) SelectTLSProfiles() (tlsProfiles []*models.TLSProfile) {
+	rows, err := dal.db.Query(sqlSelectTLSProfiles)
+	utils.CheckError("SelectTLSProfiles", err)
+	if err != nil {
+		return tlsProfiles
+	}
+	defer rows.Close()
+	for rows.Next() {
+		tlsProfile := new(models.TLSProfile)
+		var cipherSuites, curves, nextProtos string
+		err = rows.Scan(&tlsProfile.ID, &tlsProfile.Name, &tlsProfile.MinVersion, &tlsProfile.MaxVersion, &cipherSuites, &curves, &nextProtos, &tlsProfile.Description)
+		utils.CheckError("SelectTLSProfiles Scan", err)
+		tlsProfile.CipherSuites = splitList(cipherSuites)
+		tlsProfile.Curves = splitList(curves)
+		tlsProfile.NextProtos = splitList(nextProtos)
+		tlsProfiles = append(tlsProfiles, tlsProfile)
+	}
+	return tlsProfiles
+}
+
+func (dal *MyDAL) InsertTLSProfile(tlsProfile *models.TLSProfile) (newID int64, err error) {
+	err = dal.db.QueryRow(sqlInsertTLSProfile, tlsProfile.Name, tlsProfile.MinVersion, tlsProfile.MaxVersion,
+		strings.Join(tlsProfile.CipherSuites, ","), strings.Join(tlsProfile.Curves, ","), strings.Join(tlsProfile.NextProtos, ","),
+		tlsProfile.Description).Scan(&newID)
+	utils.CheckError("InsertTLSProfile", err)
+	return newID, err
+}
+
+func (dal *MyDAL) UpdateTLSProfile(tlsProfile *models.TLSProfile) error {
+	_, err := dal.db.Exec(sqlUpdateTLSProfile, tlsProfile.Name, tlsProfile.MinVersion, tlsProfile.MaxVersion,
+		strings.Join(tlsProfile.CipherSuites, ","), strings.Join(tlsProfile.Curves, ","), strings.Join(tlsProfile.NextProtos, ","),
+		tlsProfile.Description, tlsProfile.ID)
+	utils.CheckError("UpdateTLSProfile", err)
+	return err
+}
+
+func (dal *MyDAL) DeleteTLSProfile(id int64) error {
+	_, err := dal.db.Exec(sqlDeleteTLSProfile, id)
+	utils.CheckError("DeleteTLSProfile", err)
+	return err
+}
+
+// SelectTLSProfileUsageCount return the count of applications and domains using the profile
+func (dal *MyDAL) SelectTLSProfileUsageCount(id int64) int64 {
+	var count int64
+	err := dal.db.QueryRow(sqlSelectTLSProfileUsageCount, id).Scan(&count)
+	utils.CheckError("SelectTLSProfileUsageCount", err)
+	return count
+}
@@ -40,7 +40,12 @@ var (
 // InitDAL init Data Access Layer
 func InitDAL() {
 	DAL = new(MyDAL)
-	var err error
+	err := LoadRootKey()
+	utils.CheckError("InitDAL LoadRootKey", err)
+	if err != nil {
+		fmt.Println("Error: load root key failed,", err)
+		os.Exit(1)
+	}
 	CFG, err = NewConfig("./config.json")
 	utils.CheckError("InitDAL", err)
 	if err != nil {
@@ -96,3 +101,12 @@ func (dal *MyDAL) ExistColumnInTable(tableName string, columnName string) bool {
 	}
 	return false
 }
+
+// SelectColumnMaxLength return the max length of varchar column, 0 for unlimited or not exists
+func (dal *MyDAL) SelectColumnMaxLength(tableName string, columnName string) int64 {
+	var maxLength int64
+	const sql = `select coalesce(character_maximum_length,0) from information_schema.columns where table_name=$1 and column_name=$2`
+	err := dal.db.QueryRow(sql, tableName, columnName).Scan(&maxLength)
+	utils.CheckError("SelectColumnMaxLength QueryRow", err)
+	return maxLength
+}
@@ -40,8 +40,33 @@ func NewConfig(filename string) (*models.Config, error) {
 			if err != nil {
 				return nil, err
 			}
-			passwordBytes, _ := AES256Decrypt(encryptedPassword, true)
+			passwordBytes, legacy, _ := DecryptWithRootKey(encryptedPassword)
 			config.PrimaryNode.Database.Password = string(passwordBytes)
+			if legacy {
+				// Migrate to the supplied root key
+				encryptedConfig := models.EncryptedConfig(*config)
+				encryptedConfig.PrimaryNode.Database.Password = hex.EncodeToString(AES256Encrypt(passwordBytes, true))
+				encryptedConfigBytes, _ := json.MarshalIndent(encryptedConfig, "", "\t")
+				err = ioutil.WriteFile(filename, encryptedConfigBytes, 0644)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	} else if len(config.ReplicaNode.NodeKey) > 0 {
+		encryptedNodeKey, err := hex.DecodeString(config.ReplicaNode.NodeKey)
+		if err != nil {
+			return nil, err
+		}
+		nodeKey, legacy, err := DecryptWithRootKey(encryptedNodeKey)
+		if err == nil && legacy {
+			// Migrate to the supplied root key
+			config.ReplicaNode.NodeKey = hex.EncodeToString(AES256Encrypt(nodeKey, true))
+			encryptedConfigBytes, _ := json.MarshalIndent(models.EncryptedConfig(*config), "", "\t")
+			err = ioutil.WriteFile(filename, encryptedConfigBytes, 0644)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 	//fmt.Println("NewConfig config.Database.Password=",config.Database.Password)
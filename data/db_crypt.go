@@ -8,37 +8,100 @@
 package data
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
 
 	"asec/models"
 	"asec/utils"
 )
 
 var (
-	RootKey, _           = hex.DecodeString("58309a83b94a93313a8de8f3ca815f709f4ea52066417b2ae592f2dbfd1c69ab")
+	// RootKey encrypt the instance key, nodes key and config.json, all nodes share the same root key
+	RootKey []byte
+	// legacyRootKey was compiled in before v1.0.1, it is used only if no root key is supplied,
+	// and to migrate the secrets encrypted by it.
+	legacyRootKey, _     = hex.DecodeString("58309a83b94a93313a8de8f3ca815f709f4ea52066417b2ae592f2dbfd1c69ab")
 	instanceKey          []byte
+	instanceKeyMutex     sync.RWMutex
 	NodesKey             []byte
 	HexEncryptedNodesKey string
 )
 
+// LoadRootKey read the root key (64 hex chars) from env ASEC_ROOT_KEY, or the file of env ASEC_ROOT_KEY_FILE,
+// or ./root.key, the legacy built-in key is used if none of them exists.
+func LoadRootKey() error {
+	hexRootKey := os.Getenv("ASEC_ROOT_KEY")
+	if len(hexRootKey) == 0 {
+		rootKeyFile := os.Getenv("ASEC_ROOT_KEY_FILE")
+		if len(rootKeyFile) == 0 {
+			rootKeyFile = "./root.key"
+		}
+		rootKeyBytes, err := ioutil.ReadFile(rootKeyFile)
+		if err != nil && (os.IsNotExist(err) == false || len(os.Getenv("ASEC_ROOT_KEY_FILE")) > 0) {
+			return err
+		}
+		hexRootKey = strings.TrimSpace(string(rootKeyBytes))
+	}
+	if len(hexRootKey) == 0 {
+		utils.DebugPrintln("Warning: root key is not supplied by ASEC_ROOT_KEY, ASEC_ROOT_KEY_FILE or ./root.key, using the legacy built-in key.")
+		RootKey = legacyRootKey
+		return nil
+	}
+	rootKey, err := hex.DecodeString(hexRootKey)
+	if err != nil {
+		return err
+	}
+	if len(rootKey) != 32 {
+		return errors.New("Root key should be 32 bytes (64 hex chars)")
+	}
+	RootKey = rootKey
+	return nil
+}
+
+// DecryptWithRootKey decrypt with the root key, or the legacy root key, legacy is true if the
+// ciphertext need to be encrypted again with the root key.
+func DecryptWithRootKey(ciphertext []byte) (plaintext []byte, legacy bool, err error) {
+	plaintext, err = DecryptWithKey(ciphertext, RootKey)
+	if err != nil && bytes.Equal(RootKey, legacyRootKey) == false {
+		plaintext, err = DecryptWithKey(ciphertext, legacyRootKey)
+		if err == nil {
+			return plaintext, true, nil
+		}
+	}
+	return plaintext, false, err
+}
+
 func (dal *MyDAL) LoadInstanceKey() {
+	instanceKeyMutex.Lock()
+	defer instanceKeyMutex.Unlock()
 	if dal.ExistsSetting("instance_key") == false {
 		instanceKey = GenRandomAES256Key()
-		encryptedInstanceKey := AES256Encrypt(instanceKey, true)
+		encryptedInstanceKey := EncryptWithKey(instanceKey, RootKey)
 		hexInstanceKey := hex.EncodeToString(encryptedInstanceKey)
 		dal.SaveStringSetting("instance_key", hexInstanceKey)
 	} else {
 		hexEncryptedKey, err := dal.SelectStringSetting("instance_key")
 		utils.CheckError("LoadInstanceKey", err)
 		decodeEncryptedKey, _ := hex.DecodeString(hexEncryptedKey)
-		instanceKey, err = AES256Decrypt(decodeEncryptedKey, true)
+		var legacy bool
+		instanceKey, legacy, err = DecryptWithRootKey(decodeEncryptedKey)
 		utils.CheckError("LoadInstanceKey AES256Decrypt", err)
+		if legacy {
+			// Migrate to the supplied root key
+			err = dal.SaveStringSetting("instance_key", hex.EncodeToString(EncryptWithKey(instanceKey, RootKey)))
+			utils.CheckError("LoadInstanceKey SaveStringSetting", err)
+		}
 	}
 }
 
@@ -53,8 +116,15 @@ func (dal *MyDAL) LoadNodesKey() {
 		HexEncryptedNodesKey, err = dal.SelectStringSetting("nodes_key")
 		utils.CheckError("LoadNodesKey", err)
 		decodeEncryptedKey, _ := hex.DecodeString(HexEncryptedNodesKey)
-		NodesKey, err = AES256Decrypt(decodeEncryptedKey, true)
+		var legacy bool
+		NodesKey, legacy, err = DecryptWithRootKey(decodeEncryptedKey)
 		utils.CheckError("LoadNodesKey AES256Decrypt", err)
+		if legacy {
+			// Migrate to the supplied root key, node_key of replica nodes should be updated
+			HexEncryptedNodesKey = hex.EncodeToString(EncryptWithKey(NodesKey, RootKey))
+			err = dal.SaveStringSetting("nodes_key", HexEncryptedNodesKey)
+			utils.CheckError("LoadNodesKey SaveStringSetting", err)
+		}
 	}
 }
 
@@ -84,6 +154,8 @@ func EncryptWithKey(plaintext []byte, key []byte) []byte {
 }
 
 func AES256Encrypt(plaintext []byte, useRootkey bool) []byte {
+	instanceKeyMutex.RLock()
+	defer instanceKeyMutex.RUnlock()
 	key := instanceKey
 	if useRootkey == true {
 		key = RootKey
@@ -92,6 +164,18 @@ func AES256Encrypt(plaintext []byte, useRootkey bool) []byte {
 	return ciphertext
 }
 
+// WithInstanceKey call fn with the encryption by the instance key, and hold the instance key until fn returned,
+// so the secrets encrypted in fn are written to the database before RotateInstanceKey re-encrypts them.
+// fn should not call AES256Encrypt or AES256Decrypt, which wait for a pending rotation.
+func WithInstanceKey(fn func(encrypt func(plaintext []byte) []byte) error) error {
+	instanceKeyMutex.RLock()
+	defer instanceKeyMutex.RUnlock()
+	key := instanceKey
+	return fn(func(plaintext []byte) []byte {
+		return EncryptWithKey(plaintext, key)
+	})
+}
+
 func DecryptWithKey(ciphertext []byte, key []byte) ([]byte, error) {
 	var block cipher.Block
 	var err error
@@ -115,6 +199,8 @@ func DecryptWithKey(ciphertext []byte, key []byte) ([]byte, error) {
 }
 
 func AES256Decrypt(ciphertext []byte, useRootkey bool) ([]byte, error) {
+	instanceKeyMutex.RLock()
+	defer instanceKeyMutex.RUnlock()
 	key := instanceKey
 	if useRootkey == true {
 		key = RootKey
@@ -141,7 +227,7 @@ func SHA256Hash(plaintext string) string {
 func NodeHexKeyToCryptKey(hexKey string) []byte {
 	encrptedKey, err := hex.DecodeString(hexKey)
 	utils.CheckError("NodeHexKeyToCryptKey DecodeString", err)
-	key, err := AES256Decrypt(encrptedKey, true)
+	key, _, err := DecryptWithRootKey(encrptedKey)
 	utils.CheckError("NodeHexKeyToCryptKey AES256Decrypt", err)
 	return key
 }
@@ -0,0 +1,139 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:18:53
+ * @Last Modified: thonsun, 2026-10-19 14:18:53
+ */
+
+package data
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+const (
+	sqlLockInstanceKeyTables         = `LOCK TABLE certificates,internal_ca,settings IN EXCLUSIVE MODE`
+	sqlSelectEncryptedCertPrivKeys   = `SELECT id,priv_key FROM certificates`
+	sqlUpdateEncryptedCertPrivKey    = `UPDATE certificates SET priv_key=$1 WHERE id=$2`
+	sqlSelectEncryptedInternalCAKeys = `SELECT id,priv_key FROM internal_ca`
+	sqlUpdateEncryptedInternalCAKey  = `UPDATE internal_ca SET priv_key=$1 WHERE id=$2`
+	sqlSelectEncryptedStringSetting  = `SELECT string_value FROM settings WHERE name=$1`
+	sqlUpdateEncryptedStringSetting  = `UPDATE settings SET string_value=$1 WHERE name=$2`
+	sqlExistsEncryptedStringSetting  = `SELECT coalesce((SELECT 1 FROM settings WHERE name=$1 limit 1),0)`
+)
+
+// InstanceKeyEncryptedSettings are string settings stored as hex of the ciphertext encrypted by the instance key
+var InstanceKeyEncryptedSettings = []string{"acme_account_key"}
+
+type encryptedSecret struct {
+	id         int64
+	ciphertext []byte
+}
+
+// RotateInstanceKey generate a new instance key, and re-encrypt all secrets encrypted by the old one
+// in a single transaction, the in-memory instance key is replaced only if the transaction committed.
+// It returns the count of re-encrypted secrets.
+func (dal *MyDAL) RotateInstanceKey() (count int64, err error) {
+	// Block the encryption and decryption with the instance key until rotated
+	instanceKeyMutex.Lock()
+	defer instanceKeyMutex.Unlock()
+	oldKey := instanceKey
+	if len(oldKey) == 0 {
+		return 0, errors.New("Instance key is not loaded")
+	}
+	newKey := GenRandomAES256Key()
+	tx, err := dal.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if _, err = tx.Exec(sqlLockInstanceKeyTables); err != nil {
+		return 0, err
+	}
+	tables := []struct {
+		name      string
+		selectSQL string
+		updateSQL string
+	}{
+		{"certificates", sqlSelectEncryptedCertPrivKeys, sqlUpdateEncryptedCertPrivKey},
+		{"internal_ca", sqlSelectEncryptedInternalCAKeys, sqlUpdateEncryptedInternalCAKey},
+	}
+	for _, table := range tables {
+		var secrets []*encryptedSecret
+		secrets, err = selectEncryptedSecrets(tx, table.selectSQL)
+		if err != nil {
+			return 0, err
+		}
+		for _, secret := range secrets {
+			var plaintext []byte
+			plaintext, err = DecryptWithKey(secret.ciphertext, oldKey)
+			if err != nil {
+				return 0, fmt.Errorf("Decrypt %s id=%d failed: %v", table.name, secret.id, err)
+			}
+			if _, err = tx.Exec(table.updateSQL, EncryptWithKey(plaintext, newKey), secret.id); err != nil {
+				return 0, err
+			}
+			count++
+		}
+	}
+	for _, name := range InstanceKeyEncryptedSettings {
+		var exists int64
+		if err = tx.QueryRow(sqlExistsEncryptedStringSetting, name).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if exists == 0 {
+			continue
+		}
+		var hexCiphertext string
+		if err = tx.QueryRow(sqlSelectEncryptedStringSetting, name).Scan(&hexCiphertext); err != nil {
+			return 0, err
+		}
+		var ciphertext, plaintext []byte
+		ciphertext, err = hex.DecodeString(hexCiphertext)
+		if err != nil {
+			return 0, err
+		}
+		plaintext, err = DecryptWithKey(ciphertext, oldKey)
+		if err != nil {
+			return 0, fmt.Errorf("Decrypt setting %s failed: %v", name, err)
+		}
+		if _, err = tx.Exec(sqlUpdateEncryptedStringSetting, hex.EncodeToString(EncryptWithKey(plaintext, newKey)), name); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	hexInstanceKey := hex.EncodeToString(EncryptWithKey(newKey, RootKey))
+	if _, err = tx.Exec(sqlUpdateEncryptedStringSetting, hexInstanceKey, "instance_key"); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	instanceKey = newKey
+	return count, nil
+}
+
+func selectEncryptedSecrets(tx *sql.Tx, selectSQL string) ([]*encryptedSecret, error) {
+	// Collect all rows before updating, the connection can not be shared by rows and updates
+	rows, err := tx.Query(selectSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var secrets []*encryptedSecret
+	for rows.Next() {
+		secret := new(encryptedSecret)
+		if err = rows.Scan(&secret.id, &secret.ciphertext); err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, rows.Err()
+}
@@ -0,0 +1,301 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:18:53
+ * @Last Modified: thonsun, 2026-10-19 14:18:53
+ */
+
+package data
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// fakeKeyStore is an in-memory database of the secrets, it understands the SQL of RotateInstanceKey only
+type fakeKeyStore struct {
+	secrets  map[string]map[int64][]byte
+	settings map[string]string
+	// failSQL makes the statement fail, to break the rotation partway
+	failSQL string
+}
+
+func (store *fakeKeyStore) clone() *fakeKeyStore {
+	newStore := &fakeKeyStore{secrets: map[string]map[int64][]byte{}, settings: map[string]string{}, failSQL: store.failSQL}
+	for table, rows := range store.secrets {
+		newStore.secrets[table] = map[int64][]byte{}
+		for id, ciphertext := range rows {
+			newStore.secrets[table][id] = ciphertext
+		}
+	}
+	for name, value := range store.settings {
+		newStore.settings[name] = value
+	}
+	return newStore
+}
+
+var testKeyStore *fakeKeyStore
+
+func init() {
+	sql.Register("asec-fake-keystore", fakeKeyStoreDriver{})
+}
+
+type fakeKeyStoreDriver struct{}
+
+func (fakeKeyStoreDriver) Open(name string) (driver.Conn, error) {
+	return &fakeKeyStoreConn{}, nil
+}
+
+// fakeKeyStoreConn works on a copy of testKeyStore in transaction, which replaces testKeyStore if committed
+type fakeKeyStoreConn struct {
+	pending *fakeKeyStore
+}
+
+func (conn *fakeKeyStoreConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeKeyStoreStmt{conn: conn, query: query}, nil
+}
+
+func (conn *fakeKeyStoreConn) Close() error {
+	return nil
+}
+
+func (conn *fakeKeyStoreConn) Begin() (driver.Tx, error) {
+	conn.pending = testKeyStore.clone()
+	return conn, nil
+}
+
+func (conn *fakeKeyStoreConn) Commit() error {
+	testKeyStore, conn.pending = conn.pending, nil
+	return nil
+}
+
+func (conn *fakeKeyStoreConn) Rollback() error {
+	conn.pending = nil
+	return nil
+}
+
+type fakeKeyStoreStmt struct {
+	conn  *fakeKeyStoreConn
+	query string
+}
+
+func (stmt *fakeKeyStoreStmt) Close() error {
+	return nil
+}
+
+func (stmt *fakeKeyStoreStmt) NumInput() int {
+	return -1
+}
+
+func (stmt *fakeKeyStoreStmt) store() (*fakeKeyStore, error) {
+	if stmt.conn.pending == nil {
+		return nil, errors.New("transaction required")
+	}
+	if stmt.query == stmt.conn.pending.failSQL {
+		return nil, errors.New("failed: " + stmt.query)
+	}
+	return stmt.conn.pending, nil
+}
+
+func (stmt *fakeKeyStoreStmt) Exec(args []driver.Value) (driver.Result, error) {
+	store, err := stmt.store()
+	if err != nil {
+		return nil, err
+	}
+	switch stmt.query {
+	case sqlLockInstanceKeyTables:
+	case sqlUpdateEncryptedCertPrivKey:
+		store.secrets["certificates"][args[1].(int64)] = args[0].([]byte)
+	case sqlUpdateEncryptedInternalCAKey:
+		store.secrets["internal_ca"][args[1].(int64)] = args[0].([]byte)
+	case sqlUpdateEncryptedStringSetting:
+		store.settings[args[1].(string)] = args[0].(string)
+	default:
+		return nil, errors.New("unexpected exec: " + stmt.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (stmt *fakeKeyStoreStmt) Query(args []driver.Value) (driver.Rows, error) {
+	store, err := stmt.store()
+	if err != nil {
+		return nil, err
+	}
+	rows := &fakeKeyStoreRows{}
+	switch stmt.query {
+	case sqlSelectEncryptedCertPrivKeys, sqlSelectEncryptedInternalCAKeys:
+		table := "certificates"
+		if stmt.query == sqlSelectEncryptedInternalCAKeys {
+			table = "internal_ca"
+		}
+		rows.columns = []string{"id", "priv_key"}
+		for id, ciphertext := range store.secrets[table] {
+			rows.values = append(rows.values, []driver.Value{id, ciphertext})
+		}
+	case sqlExistsEncryptedStringSetting:
+		rows.columns = []string{"coalesce"}
+		_, exists := store.settings[args[0].(string)]
+		if exists {
+			rows.values = [][]driver.Value{{int64(1)}}
+		} else {
+			rows.values = [][]driver.Value{{int64(0)}}
+		}
+	case sqlSelectEncryptedStringSetting:
+		rows.columns = []string{"string_value"}
+		rows.values = [][]driver.Value{{store.settings[args[0].(string)]}}
+	default:
+		return nil, errors.New("unexpected query: " + stmt.query)
+	}
+	return rows, nil
+}
+
+type fakeKeyStoreRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (rows *fakeKeyStoreRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *fakeKeyStoreRows) Close() error {
+	return nil
+}
+
+func (rows *fakeKeyStoreRows) Next(dest []driver.Value) error {
+	if len(rows.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, rows.values[0])
+	rows.values = rows.values[1:]
+	return nil
+}
+
+// newTestKeyStoreDAL generate the root and instance keys, and store the secrets encrypted by the instance key,
+// the returned function restores the keys.
+func newTestKeyStoreDAL(t *testing.T, plaintexts map[string]map[int64][]byte, acmeAccountKey []byte) (*MyDAL, func()) {
+	oldRootKey, oldInstanceKey := RootKey, instanceKey
+	RootKey = GenRandomAES256Key()
+	instanceKey = GenRandomAES256Key()
+	testKeyStore = &fakeKeyStore{secrets: map[string]map[int64][]byte{}, settings: map[string]string{
+		"instance_key":     hex.EncodeToString(EncryptWithKey(instanceKey, RootKey)),
+		"acme_account_key": hex.EncodeToString(AES256Encrypt(acmeAccountKey, false)),
+	}}
+	for _, table := range []string{"certificates", "internal_ca"} {
+		testKeyStore.secrets[table] = map[int64][]byte{}
+		for id, plaintext := range plaintexts[table] {
+			testKeyStore.secrets[table][id] = AES256Encrypt(plaintext, false)
+		}
+	}
+	db, err := sql.Open("asec-fake-keystore", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &MyDAL{db: db}, func() {
+		db.Close()
+		RootKey, instanceKey, testKeyStore = oldRootKey, oldInstanceKey, nil
+	}
+}
+
+func TestRotateInstanceKey(t *testing.T) {
+	plaintexts := map[string]map[int64][]byte{
+		"certificates": {1: []byte("private key of certificate 1"), 2: []byte("private key of certificate 2")},
+		"internal_ca":  {1: []byte("private key of internal CA")},
+	}
+	acmeAccountKey := []byte("ACME account key")
+	dal, restore := newTestKeyStoreDAL(t, plaintexts, acmeAccountKey)
+	defer restore()
+
+	// checkSecrets decrypt all stored secrets with the in-memory instance key
+	checkSecrets := func(stage string) {
+		for table, rows := range plaintexts {
+			for id, plaintext := range rows {
+				decrypted, err := AES256Decrypt(testKeyStore.secrets[table][id], false)
+				if err != nil || !bytes.Equal(decrypted, plaintext) {
+					t.Fatalf("%s: %s id=%d is not readable, %v", stage, table, id, err)
+				}
+			}
+		}
+		ciphertext, _ := hex.DecodeString(testKeyStore.settings["acme_account_key"])
+		if decrypted, err := AES256Decrypt(ciphertext, false); err != nil || !bytes.Equal(decrypted, acmeAccountKey) {
+			t.Fatalf("%s: acme_account_key is not readable, %v", stage, err)
+		}
+		ciphertext, _ = hex.DecodeString(testKeyStore.settings["instance_key"])
+		if storedKey, _, err := DecryptWithRootKey(ciphertext); err != nil || !bytes.Equal(storedKey, instanceKey) {
+			t.Fatalf("%s: stored instance key is not the one in use, %v", stage, err)
+		}
+	}
+
+	// A failure after the certificates are re-encrypted leaves the old ciphertexts and key
+	keyBeforeRotation := instanceKey
+	for _, failSQL := range []string{sqlUpdateEncryptedInternalCAKey, sqlSelectEncryptedStringSetting, sqlUpdateEncryptedStringSetting} {
+		testKeyStore.failSQL = failSQL
+		if _, err := dal.RotateInstanceKey(); err == nil {
+			t.Fatalf("rotation should fail at %s", failSQL)
+		}
+		if !bytes.Equal(instanceKey, keyBeforeRotation) {
+			t.Fatal("instance key replaced by failed rotation")
+		}
+		checkSecrets("failed at " + failSQL)
+	}
+	testKeyStore.failSQL = ""
+
+	count, err := dal.RotateInstanceKey()
+	if err != nil || count != 4 {
+		t.Fatalf("rotated %d secrets, %v", count, err)
+	}
+	if bytes.Equal(instanceKey, keyBeforeRotation) {
+		t.Fatal("instance key not replaced")
+	}
+	checkSecrets("rotated")
+	if _, err := DecryptWithKey(testKeyStore.secrets["certificates"][1], keyBeforeRotation); err == nil {
+		t.Fatal("secret still encrypted by the old instance key")
+	}
+}
+
+func TestRotateInstanceKeyWithWriter(t *testing.T) {
+	plaintext := []byte("private key of certificate 2")
+	dal, restore := newTestKeyStoreDAL(t, map[string]map[int64][]byte{"certificates": {1: []byte("private key of certificate 1")}}, []byte("ACME account key"))
+	defer restore()
+
+	// The writer encrypts a new certificate, and inserts it after the rotation is requested
+	encrypted, release := make(chan struct{}), make(chan struct{})
+	written := make(chan error)
+	go func() {
+		written <- WithInstanceKey(func(encrypt func(plaintext []byte) []byte) error {
+			ciphertext := encrypt(plaintext)
+			close(encrypted)
+			<-release
+			testKeyStore.secrets["certificates"][2] = ciphertext
+			return nil
+		})
+	}()
+	<-encrypted
+	rotated := make(chan error)
+	go func() {
+		_, err := dal.RotateInstanceKey()
+		rotated <- err
+	}()
+	select {
+	case err := <-rotated:
+		t.Fatalf("rotated before the writer inserted, %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-rotated; err != nil {
+		t.Fatal(err)
+	}
+	// The inserted secret is re-encrypted by the rotation
+	if decrypted, err := AES256Decrypt(testKeyStore.secrets["certificates"][2], false); err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("secret written during the rotation is not readable, %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:47:15
+ * @Last Modified: thonsun, 2026-10-19 14:47:15
+ */
+
+package data
+
+import (
+	"asec/models"
+	"asec/utils"
+)
+
+const (
+	sqlCreateTableIfNotExistsACLHitLog = `CREATE TABLE IF NOT EXISTS acl_hit_logs(id bigserial primary key,request_time bigint,client_ip varchar(256),host varchar(256),method varchar(16),url_path varchar(2048),url_query varchar(2048),content_type varchar(128),user_agent varchar(1024),cookies varchar(1024),raw_request varchar(16384),action bigint,acl_id bigint,app_id bigint,country varchar(8),asn bigint,monitor boolean default false)`
+	sqlInsertACLHitLog                 = `INSERT INTO acl_hit_logs(request_time,client_ip,host,method,url_path,url_query,content_type,user_agent,cookies,raw_request,action,acl_id,app_id,country,asn,monitor) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)`
+	sqlSelectACLHitLogByID             = `SELECT id,request_time,client_ip,host,method,url_path,url_query,content_type,user_agent,cookies,raw_request,action,acl_id,app_id,country,asn,monitor FROM acl_hit_logs WHERE id=$1`
+	sqlSelectSimpleACLHitLogs          = `SELECT id,request_time,client_ip,host,method,url_path,action,acl_id,app_id,country,asn,monitor FROM acl_hit_logs WHERE app_id=$1 and request_time between $2 and $3 LIMIT $4 OFFSET $5`
+	sqlSelectACLHitLogsCount           = `SELECT COUNT(1) FROM acl_hit_logs WHERE app_id=$1 and request_time between $2 and $3`
+	sqlDeleteACLHitLogsBeforeTime      = `DELETE FROM acl_hit_logs WHERE request_time<$1`
+)
+
+func (dal *MyDAL) DeleteACLHitLogsBeforeTime(expiredTime int64) error {
+	_, err := dal.db.Exec(sqlDeleteACLHitLogsBeforeTime, expiredTime)
+	utils.CheckError("DeleteACLHitLogsBeforeTime", err)
+	return err
+}
+
+func (dal *MyDAL) CreateTableIfNotExistsACLHitLog() error {
+	_, err := dal.db.Exec(sqlCreateTableIfNotExistsACLHitLog)
+	utils.CheckError("CreateTableIfNotExistsACLHitLog", err)
+	return err
+}
+
+func (dal *MyDAL) InsertACLHitLog(aclLog *models.ACLHitLog) error {
+	_, err := dal.db.Exec(sqlInsertACLHitLog, aclLog.RequestTime, aclLog.ClientIP, aclLog.Host, aclLog.Method, aclLog.UrlPath, aclLog.UrlQuery, aclLog.ContentType, aclLog.UserAgent, aclLog.Cookies, aclLog.RawRequest, int64(aclLog.Action), aclLog.ACLID, aclLog.AppID, aclLog.Country, aclLog.ASN, aclLog.Monitor)
+	utils.CheckError("InsertACLHitLog Exec", err)
+	return err
+}
+
+func (dal *MyDAL) SelectACLHitLogsCount(appID int64, startTime int64, endTime int64) (int64, error) {
+	var count int64
+	err := dal.db.QueryRow(sqlSelectACLHitLogsCount, appID, startTime, endTime).Scan(&count)
+	utils.CheckError("SelectACLHitLogsCount QueryRow", err)
+	return count, err
+}
+
+func (dal *MyDAL) SelectACLHitLogByID(id int64) (*models.ACLHitLog, error) {
+	aclLog := new(models.ACLHitLog)
+	err := dal.db.QueryRow(sqlSelectACLHitLogByID, id).Scan(&aclLog.ID,
+		&aclLog.RequestTime,
+		&aclLog.ClientIP,
+		&aclLog.Host,
+		&aclLog.Method,
+		&aclLog.UrlPath,
+		&aclLog.UrlQuery,
+		&aclLog.ContentType,
+		&aclLog.UserAgent,
+		&aclLog.Cookies,
+		&aclLog.RawRequest,
+		&aclLog.Action,
+		&aclLog.ACLID,
+		&aclLog.AppID,
+		&aclLog.Country,
+		&aclLog.ASN,
+		&aclLog.Monitor)
+	utils.CheckError("SelectACLHitLogByID QueryRow", err)
+	return aclLog, err
+}
+
+func (dal *MyDAL) SelectACLHitLogs(appID int64, startTime int64, endTime int64, requestCount int64, offset int64) (simpleACLHitLogs []*models.SimpleACLHitLog) {
+	rows, err := dal.db.Query(sqlSelectSimpleACLHitLogs, appID, startTime, endTime, requestCount, offset)
+	utils.CheckError("SelectACLHitLogs Query", err)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		simpleACLHitLog := new(models.SimpleACLHitLog)
+		rows.Scan(&simpleACLHitLog.ID, &simpleACLHitLog.RequestTime, &simpleACLHitLog.ClientIP, &simpleACLHitLog.Host, &simpleACLHitLog.Method, &simpleACLHitLog.UrlPath, &simpleACLHitLog.Action, &simpleACLHitLog.ACLID, &simpleACLHitLog.AppID, &simpleACLHitLog.Country, &simpleACLHitLog.ASN, &simpleACLHitLog.Monitor)
+		simpleACLHitLogs = append(simpleACLHitLogs, simpleACLHitLog)
+	}
+	return simpleACLHitLogs
+}
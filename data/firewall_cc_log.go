@@ -13,10 +13,10 @@ import (
 )
 
 const (
-	sqlCreateTableIfNotExistsCCLog = `CREATE TABLE IF NOT EXISTS cc_logs(id bigserial primary key,request_time bigint,client_ip varchar(256),host varchar(256),method varchar(16),url_path varchar(2048),url_query varchar(2048),content_type varchar(128),user_agent varchar(1024),cookies varchar(1024),raw_request varchar(16384),action bigint,app_id bigint)`
-	sqlInsertCCLog                 = `INSERT INTO cc_logs(request_time,client_ip,host,method,url_path,url_query,content_type,user_agent,cookies,raw_request,action,app_id) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`
-	sqlSelectCCLogByID             = `SELECT id,request_time,client_ip,host,method,url_path,url_query,content_type,user_agent,cookies,raw_request,action,app_id FROM cc_logs WHERE id=$1`
-	sqlSelectSimpleCCLogs          = `SELECT id,request_time,client_ip,host,method,url_path,action,app_id FROM cc_logs WHERE app_id=$1 and request_time between $2 and $3 LIMIT $4 OFFSET $5`
+	sqlCreateTableIfNotExistsCCLog = `CREATE TABLE IF NOT EXISTS cc_logs(id bigserial primary key,request_time bigint,client_ip varchar(256),host varchar(256),method varchar(16),url_path varchar(2048),url_query varchar(2048),content_type varchar(128),user_agent varchar(1024),cookies varchar(1024),raw_request varchar(16384),action bigint,app_id bigint,monitor boolean default false)`
+	sqlInsertCCLog                 = `INSERT INTO cc_logs(request_time,client_ip,host,method,url_path,url_query,content_type,user_agent,cookies,raw_request,action,app_id,monitor) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)`
+	sqlSelectCCLogByID             = `SELECT id,request_time,client_ip,host,method,url_path,url_query,content_type,user_agent,cookies,raw_request,action,app_id,monitor FROM cc_logs WHERE id=$1`
+	sqlSelectSimpleCCLogs          = `SELECT id,request_time,client_ip,host,method,url_path,action,app_id,monitor FROM cc_logs WHERE app_id=$1 and request_time between $2 and $3 LIMIT $4 OFFSET $5`
 	sqlSelectCCLogsCount           = `SELECT COUNT(1) FROM cc_logs WHERE app_id=$1 and request_time between $2 and $3`
 	sqlSelectAllCCLogsCount        = `SELECT COUNT(1) FROM cc_logs WHERE request_time between $1 and $2`
 	sqlDeleteCCLogsBeforeTime      = `DELETE FROM cc_logs WHERE request_time<$1`
@@ -34,8 +34,8 @@ func (dal *MyDAL) CreateTableIfNotExistsCCLog() error {
 	return err
 }
 
-func (dal *MyDAL) InsertCCLog(requestTime int64, clientIP string, host string, method string, urlPath string, urlQuery string, contentType string, userAgent string, cookies string, rawRequest string, action int64, appID int64) error {
-	_, err := dal.db.Exec(sqlInsertCCLog, requestTime, clientIP, host, method, urlPath, urlQuery, contentType, userAgent, cookies, rawRequest, action, appID)
+func (dal *MyDAL) InsertCCLog(requestTime int64, clientIP string, host string, method string, urlPath string, urlQuery string, contentType string, userAgent string, cookies string, rawRequest string, action int64, appID int64, monitor bool) error {
+	_, err := dal.db.Exec(sqlInsertCCLog, requestTime, clientIP, host, method, urlPath, urlQuery, contentType, userAgent, cookies, rawRequest, action, appID, monitor)
 	utils.CheckError("InsertCCLog Exec", err)
 	return err
 }
@@ -74,7 +74,8 @@ func (dal *MyDAL) SelectCCLogByID(id int64) (*models.CCLog, error) {
 		&cc_log.Cookies,
 		&cc_log.RawRequest,
 		&cc_log.Action,
-		&cc_log.AppID)
+		&cc_log.AppID,
+		&cc_log.Monitor)
 	utils.CheckError("SelectCCLogByID QueryRow", err)
 	return cc_log, err
 }
@@ -88,7 +89,7 @@ func (dal *MyDAL) SelectCCLogs(appID int64, startTime int64, endTime int64, requ
 	defer rows.Close()
 	for rows.Next() {
 		simpleCCLog := new(models.SimpleCCLog)
-		rows.Scan(&simpleCCLog.ID, &simpleCCLog.RequestTime, &simpleCCLog.ClientIP, &simpleCCLog.Host, &simpleCCLog.Method, &simpleCCLog.UrlPath, &simpleCCLog.Action, &simpleCCLog.AppID)
+		rows.Scan(&simpleCCLog.ID, &simpleCCLog.RequestTime, &simpleCCLog.ClientIP, &simpleCCLog.Host, &simpleCCLog.Method, &simpleCCLog.UrlPath, &simpleCCLog.Action, &simpleCCLog.AppID, &simpleCCLog.Monitor)
 		simpleCCLogs = append(simpleCCLogs, simpleCCLog)
 	}
 	return simpleCCLogs
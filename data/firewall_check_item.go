@@ -8,16 +8,18 @@
 package data
 
 import (
+	"strings"
+
 	"asec/models"
 	"asec/utils"
 )
 
 const (
-	sqlCreateTableIfNotExistCheckItems = `CREATE TABLE IF NOT EXISTS check_items(id bigserial primary key,check_point bigint,operation bigint,key_name varchar(256),regex_policy varchar(512),group_policy_id bigint)`
-	sqlInsertCheckItem                 = `INSERT INTO check_items(check_point,operation,key_name,regex_policy,group_policy_id) VALUES($1,$2,$3,$4,$5) RETURNING id`
-	sqlSelectCheckItemsByGroupID       = `SELECT id,check_point,operation,key_name,regex_policy FROM check_items WHERE group_policy_id=$1`
+	sqlCreateTableIfNotExistCheckItems = `CREATE TABLE IF NOT EXISTS check_items(id bigserial primary key,check_point bigint,operation bigint,key_name varchar(256),regex_policy varchar(16384),group_policy_id bigint,transforms varchar(256) default '')`
+	sqlInsertCheckItem                 = `INSERT INTO check_items(check_point,operation,key_name,regex_policy,group_policy_id,transforms) VALUES($1,$2,$3,$4,$5,$6) RETURNING id`
+	sqlSelectCheckItemsByGroupID       = `SELECT id,check_point,operation,key_name,regex_policy,transforms FROM check_items WHERE group_policy_id=$1`
 	sqlDeleteCheckItemByID             = `DELETE FROM check_items WHERE id=$1`
-	sqlUpdateCheckItemByID             = `UPDATE check_items SET check_point=$1,operation=$2,key_name=$3,regex_policy=$4,group_policy_id=$5 WHERE id=$6`
+	sqlUpdateCheckItemByID             = `UPDATE check_items SET check_point=$1,operation=$2,key_name=$3,regex_policy=$4,group_policy_id=$5,transforms=$6 WHERE id=$7`
 	//sqlDeleteCheckItemsByGroupID       = `DELETE FROM check_items WHERE group_policy_id=$1`
 )
 
@@ -27,11 +29,11 @@ func (dal *MyDAL) CreateTableIfNotExistCheckItems() error {
 	return err
 }
 
-func (dal *MyDAL) InsertCheckItem(checkPoint models.ChkPoint, operation models.Operation, keyName string, regexPolicy string, groupPolicyID int64) (newID int64, err error) {
+func (dal *MyDAL) InsertCheckItem(checkPoint models.ChkPoint, operation models.Operation, keyName string, regexPolicy string, groupPolicyID int64, transforms []string) (newID int64, err error) {
 	stmt, err := dal.db.Prepare(sqlInsertCheckItem)
 	utils.CheckError("sqlInsertCheckItem Prepare", err)
 	defer stmt.Close()
-	err = stmt.QueryRow(checkPoint, operation, keyName, regexPolicy, groupPolicyID).Scan(&newID)
+	err = stmt.QueryRow(checkPoint, operation, keyName, regexPolicy, groupPolicyID, strings.Join(transforms, ",")).Scan(&newID)
 	utils.CheckError("sqlInsertCheckItem Scan", err)
 	return newID, err
 }
@@ -45,8 +47,13 @@ func (dal *MyDAL) SelectCheckItemsByGroupID(groupPolicyID int64) (checkItems []*
 	defer rows.Close()
 	for rows.Next() {
 		checkItem := new(models.CheckItem)
-		err = rows.Scan(&checkItem.ID, &checkItem.CheckPoint, &checkItem.Operation, &checkItem.KeyName, &checkItem.RegexPolicy)
+		var transforms string
+		err = rows.Scan(&checkItem.ID, &checkItem.CheckPoint, &checkItem.Operation, &checkItem.KeyName, &checkItem.RegexPolicy, &transforms)
 		utils.CheckError("SelectCheckItemsByGroupID Scan", err)
+		checkItem.Transforms = []string{}
+		if len(transforms) > 0 {
+			checkItem.Transforms = strings.Split(transforms, ",")
+		}
 		checkItems = append(checkItems, checkItem)
 	}
 	return checkItems, nil
@@ -66,11 +73,11 @@ func (dal *MyDAL) DeleteCheckItemsByGroupID(group_policy_id int64) error {
 }
 */
 
-func (dal *MyDAL) UpdateCheckItemByID(checkPoint models.ChkPoint, operation models.Operation, keyName string, regexPolicy string, groupPolicyID int64, checkItemID int64) error {
+func (dal *MyDAL) UpdateCheckItemByID(checkPoint models.ChkPoint, operation models.Operation, keyName string, regexPolicy string, groupPolicyID int64, transforms []string, checkItemID int64) error {
 	stmt, err := dal.db.Prepare(sqlUpdateCheckItemByID)
 	utils.CheckError("UpdateCheckItemByID Prepare", err)
 	defer stmt.Close()
-	_, err = stmt.Exec(checkPoint, operation, keyName, regexPolicy, groupPolicyID, checkItemID)
+	_, err = stmt.Exec(checkPoint, operation, keyName, regexPolicy, groupPolicyID, strings.Join(transforms, ","), checkItemID)
 	utils.CheckError("UpdateCheckItemByID Exec", err)
 	return err
 }
@@ -8,17 +8,21 @@
 package data
 
 import (
+	"strings"
+
 	"asec/models"
 	"asec/utils"
 )
 
 const (
-	sqlCreateTableIfNotExistsGroupPolicy = `CREATE TABLE IF NOT EXISTS group_policies(id bigserial primary key,description varchar(256),app_id bigint,vuln_id bigint,hit_value bigint,action bigint,is_enabled boolean,user_id bigint,update_time bigint)`
+	sqlCreateTableIfNotExistsGroupPolicy = `CREATE TABLE IF NOT EXISTS group_policies(id bigserial primary key,description varchar(256),app_id bigint,vuln_id bigint,hit_value bigint,action bigint,is_enabled boolean,user_id bigint,update_time bigint,score bigint default 5,scope_host varchar(256) default '',scope_methods varchar(256) default '',scope_path_prefix varchar(2048) default '',scope_path_regex varchar(2048) default '')`
 	sqlExistsGroupPolicy                 = `SELECT coalesce((SELECT 1 FROM group_policies limit 1),0)`
-	sqlSelectGroupPolicies               = `SELECT id,description,app_id,vuln_id,hit_value,action,is_enabled,user_id,update_time FROM group_policies`
-	sqlSelectGroupPoliciesByAppID        = `SELECT id,description,vuln_id,hit_value,action,is_enabled,user_id,update_time FROM group_policies WHERE app_id=$1`
-	sqlInsertGroupPolicy                 = `INSERT INTO group_policies(description,app_id,vuln_id,hit_value,action,is_enabled,user_id,update_time) values($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id`
-	sqlUpdateGroupPolicy                 = `UPDATE group_policies SET description=$1,app_id=$2,vuln_id=$3,hit_value=$4,action=$5,is_enabled=$6,user_id=$7,update_time=$8 WHERE id=$9`
+	sqlExistsGroupPolicyByVulnID         = `SELECT coalesce((SELECT 1 FROM group_policies WHERE vuln_id=$1 limit 1),0)`
+	sqlExistsGroupPolicyByDescription    = `SELECT coalesce((SELECT 1 FROM group_policies WHERE vuln_id=$1 AND description=$2 AND hit_value=$3 limit 1),0)`
+	sqlSelectGroupPolicies               = `SELECT id,description,app_id,vuln_id,hit_value,action,is_enabled,user_id,update_time,score,scope_host,scope_methods,scope_path_prefix,scope_path_regex FROM group_policies`
+	sqlSelectGroupPoliciesByAppID        = `SELECT id,description,vuln_id,hit_value,action,is_enabled,user_id,update_time,score,scope_host,scope_methods,scope_path_prefix,scope_path_regex FROM group_policies WHERE app_id=$1`
+	sqlInsertGroupPolicy                 = `INSERT INTO group_policies(description,app_id,vuln_id,hit_value,action,is_enabled,user_id,update_time,score,scope_host,scope_methods,scope_path_prefix,scope_path_regex) values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING id`
+	sqlUpdateGroupPolicy                 = `UPDATE group_policies SET description=$1,app_id=$2,vuln_id=$3,hit_value=$4,action=$5,is_enabled=$6,user_id=$7,update_time=$8,score=$9,scope_host=$10,scope_methods=$11,scope_path_prefix=$12,scope_path_regex=$13 WHERE id=$14`
 	sqlDeleteGroupPolicyByID             = `DELETE FROM group_policies WHERE id=$1`
 )
 
@@ -34,10 +38,31 @@ func (dal *MyDAL) DeleteGroupPolicyByID(id int64) error {
 	return err
 }
 
-func (dal *MyDAL) UpdateGroupPolicy(description string, appID int64, vulnID int64, hitValue int64, action models.PolicyAction, isEnabled bool, userID int64, updateTime int64, id int64) error {
+// scopeColumns return the columns of scope, nil scope is saved as empty columns
+func scopeColumns(scope *models.PolicyScope) (host string, methods string, pathPrefix string, pathRegex string) {
+	if scope == nil {
+		return "", "", "", ""
+	}
+	return scope.Host, strings.Join(scope.Methods, ","), scope.PathPrefix, scope.PathRegex
+}
+
+// scopeFromColumns return nil if all columns are empty
+func scopeFromColumns(host string, methods string, pathPrefix string, pathRegex string) *models.PolicyScope {
+	if len(host) == 0 && len(methods) == 0 && len(pathPrefix) == 0 && len(pathRegex) == 0 {
+		return nil
+	}
+	scope := &models.PolicyScope{Host: host, Methods: []string{}, PathPrefix: pathPrefix, PathRegex: pathRegex}
+	if len(methods) > 0 {
+		scope.Methods = strings.Split(methods, ",")
+	}
+	return scope
+}
+
+func (dal *MyDAL) UpdateGroupPolicy(description string, appID int64, vulnID int64, hitValue int64, action models.PolicyAction, isEnabled bool, userID int64, updateTime int64, score int64, scope *models.PolicyScope, id int64) error {
 	stmt, err := dal.db.Prepare(sqlUpdateGroupPolicy)
 	defer stmt.Close()
-	_, err = stmt.Exec(description, appID, vulnID, hitValue, action, isEnabled, userID, updateTime, id)
+	scopeHost, scopeMethods, scopePathPrefix, scopePathRegex := scopeColumns(scope)
+	_, err = stmt.Exec(description, appID, vulnID, hitValue, action, isEnabled, userID, updateTime, score, scopeHost, scopeMethods, scopePathPrefix, scopePathRegex, id)
 	utils.CheckError("UpdateGroupPolicy", err)
 	return err
 }
@@ -48,9 +73,12 @@ func (dal *MyDAL) SelectGroupPolicies() (groupPolicies []*models.GroupPolicy) {
 	defer rows.Close()
 	for rows.Next() {
 		groupPolicy := new(models.GroupPolicy)
+		var scopeHost, scopeMethods, scopePathPrefix, scopePathRegex string
 		err = rows.Scan(&groupPolicy.ID, &groupPolicy.Description, &groupPolicy.AppID, &groupPolicy.VulnID,
-			&groupPolicy.HitValue, &groupPolicy.Action, &groupPolicy.IsEnabled, &groupPolicy.UserID, &groupPolicy.UpdateTime)
+			&groupPolicy.HitValue, &groupPolicy.Action, &groupPolicy.IsEnabled, &groupPolicy.UserID, &groupPolicy.UpdateTime, &groupPolicy.Score,
+			&scopeHost, &scopeMethods, &scopePathPrefix, &scopePathRegex)
 		utils.CheckError("SelectGroupPolicies Scan", err)
+		groupPolicy.Scope = scopeFromColumns(scopeHost, scopeMethods, scopePathPrefix, scopePathRegex)
 		groupPolicies = append(groupPolicies, groupPolicy)
 	}
 	return groupPolicies
@@ -63,22 +91,26 @@ func (dal *MyDAL) SelectGroupPoliciesByAppID(appID int64) (groupPolicies []*mode
 	for rows.Next() {
 		groupPolicy := new(models.GroupPolicy)
 		groupPolicy.AppID = appID
+		var scopeHost, scopeMethods, scopePathPrefix, scopePathRegex string
 		err = rows.Scan(&groupPolicy.ID, &groupPolicy.Description, &groupPolicy.VulnID,
-			&groupPolicy.HitValue, &groupPolicy.Action, &groupPolicy.IsEnabled, &groupPolicy.UserID, &groupPolicy.UpdateTime)
+			&groupPolicy.HitValue, &groupPolicy.Action, &groupPolicy.IsEnabled, &groupPolicy.UserID, &groupPolicy.UpdateTime, &groupPolicy.Score,
+			&scopeHost, &scopeMethods, &scopePathPrefix, &scopePathRegex)
 		utils.CheckError("SelectGroupPoliciesByAppID Scan", err)
 		if err != nil {
 			return groupPolicies, err
 		}
+		groupPolicy.Scope = scopeFromColumns(scopeHost, scopeMethods, scopePathPrefix, scopePathRegex)
 		groupPolicies = append(groupPolicies, groupPolicy)
 	}
 	return groupPolicies, err
 }
 
-func (dal *MyDAL) InsertGroupPolicy(description string, appID int64, vulnID int64, hitValue int64, action models.PolicyAction, isEnabled bool, userID int64, updateTime int64) (newID int64, err error) {
+func (dal *MyDAL) InsertGroupPolicy(description string, appID int64, vulnID int64, hitValue int64, action models.PolicyAction, isEnabled bool, userID int64, updateTime int64, score int64, scope *models.PolicyScope) (newID int64, err error) {
 	stmt, err := dal.db.Prepare(sqlInsertGroupPolicy)
 	utils.CheckError("InsertGroupPolicy Prepare", err)
 	defer stmt.Close()
-	err = stmt.QueryRow(description, appID, vulnID, hitValue, action, isEnabled, userID, updateTime).Scan(&newID)
+	scopeHost, scopeMethods, scopePathPrefix, scopePathRegex := scopeColumns(scope)
+	err = stmt.QueryRow(description, appID, vulnID, hitValue, action, isEnabled, userID, updateTime, score, scopeHost, scopeMethods, scopePathPrefix, scopePathRegex).Scan(&newID)
 	utils.CheckError("InsertGroupPolicy Scan", err)
 	return newID, err
 }
@@ -93,3 +125,19 @@ func (dal *MyDAL) ExistsGroupPolicy() bool {
 		return true
 	}
 }
+
+func (dal *MyDAL) ExistsGroupPolicyByVulnID(vulnID int64) bool {
+	var exist int
+	err := dal.db.QueryRow(sqlExistsGroupPolicyByVulnID, vulnID).Scan(&exist)
+	utils.CheckError("ExistsGroupPolicyByVulnID", err)
+	return exist != 0
+}
+
+// ExistsGroupPolicyByDescription return true if a group policy of the vulnerability type has the description and hit value,
+// used to add the built-in policies sharing the vulnerability type with others
+func (dal *MyDAL) ExistsGroupPolicyByDescription(vulnID int64, description string, hitValue int64) bool {
+	var exist int
+	err := dal.db.QueryRow(sqlExistsGroupPolicyByDescription, vulnID, description, hitValue).Scan(&exist)
+	utils.CheckError("ExistsGroupPolicyByDescription", err)
+	return exist != 0
+}
@@ -8,21 +8,25 @@
 package data
 
 import (
+	"strconv"
+	"strings"
+
 	"asec/models"
 	"asec/utils"
 )
 
 const (
-	sqlCreateTableIfNotExistsGroupHitLog  = `CREATE TABLE IF NOT EXISTS group_hit_logs(id bigserial primary key,request_time bigint,client_ip varchar(256),host varchar(256),method varchar(16),url_path varchar(2048),url_query varchar(2048),content_type varchar(128),user_agent varchar(1024),cookies varchar(1024),raw_request varchar(16384),action bigint,policy_id bigint,vuln_id bigint,app_id bigint)`
-	sqlInsertGroupHitLog                  = `INSERT INTO group_hit_logs(request_time,client_ip,host,method,url_path,url_query,content_type,user_agent,cookies,raw_request,action,policy_id,vuln_id,app_id) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14)`
-	sqlSelectGroupHitLogByID              = `SELECT id,request_time,client_ip,host,method,url_path,url_query,content_type,user_agent,cookies,raw_request,action,policy_id,vuln_id,app_id FROM group_hit_logs WHERE id=$1`
-	sqlSelectSimpleGroupHitLogs           = `SELECT id,request_time,client_ip,host,method,url_path,action,policy_id,app_id FROM group_hit_logs WHERE app_id=$1 and request_time between $2 and $3 LIMIT $4 OFFSET $5`
-	sqlSelectGroupHitLogsCount            = `SELECT COUNT(1) FROM group_hit_logs WHERE app_id=$1 and request_time between $2 and $3`
-	sqlSelectGroupHitLogsCountByVulnID    = `SELECT COUNT(1) FROM group_hit_logs WHERE app_id=$1 and vuln_id=$2 and request_time between $3 and $4`
-	sqlSelectAllGroupHitLogsCount         = `SELECT COUNT(1) FROM group_hit_logs WHERE request_time between $1 and $2`
-	sqlSelectAllGroupHitLogsCountByVulnID = `SELECT COUNT(1) FROM group_hit_logs WHERE vuln_id=$1 and request_time between $2 and $3`
-	sqlSelectVulnStatByAppID              = `SELECT vuln_id,COUNT(vuln_id) FROM group_hit_logs WHERE app_id=$1 and request_time between $2 and $3 GROUP BY vuln_id`
-	sqlSelectAllVulnStat                  = `SELECT vuln_id,COUNT(vuln_id) FROM group_hit_logs WHERE request_time between $1 and $2 GROUP BY vuln_id`
+	sqlCreateTableIfNotExistsGroupHitLog  = `CREATE TABLE IF NOT EXISTS group_hit_logs(id bigserial primary key,request_time bigint,client_ip varchar(256),host varchar(256),method varchar(16),url_path varchar(2048),url_query varchar(2048),content_type varchar(128),user_agent varchar(1024),cookies varchar(1024),raw_request varchar(16384),action bigint,policy_id bigint,vuln_id bigint,app_id bigint,score bigint default 0,policy_ids varchar(1024) default '',monitor boolean default false,scope varchar(4096) default '',excluded boolean default false,exclusion_id bigint default 0)`
+	sqlInsertGroupHitLog                  = `INSERT INTO group_hit_logs(request_time,client_ip,host,method,url_path,url_query,content_type,user_agent,cookies,raw_request,action,policy_id,vuln_id,app_id,score,policy_ids,monitor,scope,excluded,exclusion_id) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20)`
+	sqlSelectGroupHitLogByID              = `SELECT id,request_time,client_ip,host,method,url_path,url_query,content_type,user_agent,cookies,raw_request,action,policy_id,vuln_id,app_id,score,policy_ids,monitor,scope,excluded,exclusion_id FROM group_hit_logs WHERE id=$1`
+	sqlSelectSimpleGroupHitLogs           = `SELECT id,request_time,client_ip,host,method,url_path,action,policy_id,app_id,monitor,scope,excluded FROM group_hit_logs WHERE app_id=$1 and request_time between $2 and $3 LIMIT $4 OFFSET $5`
+	sqlSelectGroupHitLogsCount            = `SELECT COUNT(1) FROM group_hit_logs WHERE app_id=$1 and excluded=false and request_time between $2 and $3`
+	sqlSelectExcludedGroupHitLogsCount    = `SELECT COUNT(1) FROM group_hit_logs WHERE app_id=$1 and excluded=true and request_time between $2 and $3`
+	sqlSelectGroupHitLogsCountByVulnID    = `SELECT COUNT(1) FROM group_hit_logs WHERE app_id=$1 and vuln_id=$2 and excluded=false and request_time between $3 and $4`
+	sqlSelectAllGroupHitLogsCount         = `SELECT COUNT(1) FROM group_hit_logs WHERE excluded=false and request_time between $1 and $2`
+	sqlSelectAllGroupHitLogsCountByVulnID = `SELECT COUNT(1) FROM group_hit_logs WHERE vuln_id=$1 and excluded=false and request_time between $2 and $3`
+	sqlSelectVulnStatByAppID              = `SELECT vuln_id,COUNT(vuln_id) FROM group_hit_logs WHERE app_id=$1 and excluded=false and request_time between $2 and $3 GROUP BY vuln_id`
+	sqlSelectAllVulnStat                  = `SELECT vuln_id,COUNT(vuln_id) FROM group_hit_logs WHERE excluded=false and request_time between $1 and $2 GROUP BY vuln_id`
 	sqlDeleteHitLogsBeforeTime            = `DELETE FROM group_hit_logs where request_time<$1`
 )
 
@@ -38,7 +42,7 @@ func (dal *MyDAL) CreateTableIfNotExistsGroupHitLog() error {
 	return err
 }
 
-func (dal *MyDAL) InsertGroupHitLog(requestTime int64, clientIP string, host string, method string, urlPath string, urlQuery string, contentType string, userAgent string, cookies string, rawRequest string, action int64, policyID int64, vulnID int64, appID int64) error {
+func (dal *MyDAL) InsertGroupHitLog(requestTime int64, clientIP string, host string, method string, urlPath string, urlQuery string, contentType string, userAgent string, cookies string, rawRequest string, action int64, policyID int64, vulnID int64, appID int64, score int64, policyIDs []int64, monitor bool, scope string, exclusionID int64) error {
 	/*
 		stmt, err := dal.db.Prepare(sqlInsertGroupHitLog)
 		utils.CheckError("InsertGroupHitLog Prepare", err)
@@ -46,7 +50,7 @@ func (dal *MyDAL) InsertGroupHitLog(requestTime int64, clientIP string, host str
 
 		_, err = stmt.Exec(requestTime, clientIP, host, method, urlPath, urlQuery, contentType, userAgent, cookies, rawRequest, action, policyID, vulnID, appID)
 	*/
-	_, err := dal.db.Exec(sqlInsertGroupHitLog, requestTime, clientIP, host, method, urlPath, urlQuery, contentType, userAgent, cookies, rawRequest, action, policyID, vulnID, appID)
+	_, err := dal.db.Exec(sqlInsertGroupHitLog, requestTime, clientIP, host, method, urlPath, urlQuery, contentType, userAgent, cookies, rawRequest, action, policyID, vulnID, appID, score, joinInt64s(policyIDs), monitor, scope, exclusionID > 0, exclusionID)
 	utils.CheckError("InsertGroupHitLog Exec", err)
 	return err
 }
@@ -61,6 +65,13 @@ func (dal *MyDAL) SelectGroupHitLogsCount(appID int64, startTime int64, endTime
 	return count, err
 }
 
+func (dal *MyDAL) SelectExcludedGroupHitLogsCount(appID int64, startTime int64, endTime int64) (int64, error) {
+	var count int64
+	err := dal.db.QueryRow(sqlSelectExcludedGroupHitLogsCount, appID, startTime, endTime).Scan(&count)
+	utils.CheckError("SelectExcludedGroupHitLogsCount QueryRow", err)
+	return count, err
+}
+
 func (dal *MyDAL) SelectGroupHitLogsCountByVulnID(appID int64, vulnID int64, startTime int64, endTime int64) (int64, error) {
 	stmt, err := dal.db.Prepare(sqlSelectGroupHitLogsCountByVulnID)
 	utils.CheckError("SelectGroupHitLogsCountByVulnID Prepare", err)
@@ -96,6 +107,7 @@ func (dal *MyDAL) SelectGroupHitLogByID(id int64) (*models.GroupHitLog, error) {
 	utils.CheckError("SelectGroupHitLogByID Prepare", err)
 	defer stmt.Close()
 	group_hit_log := new(models.GroupHitLog)
+	var policyIDs string
 	err = stmt.QueryRow(id).Scan(&group_hit_log.ID,
 		&group_hit_log.RequestTime,
 		&group_hit_log.ClientIP,
@@ -110,8 +122,15 @@ func (dal *MyDAL) SelectGroupHitLogByID(id int64) (*models.GroupHitLog, error) {
 		&group_hit_log.Action,
 		&group_hit_log.PolicyID,
 		&group_hit_log.VulnID,
-		&group_hit_log.AppID)
+		&group_hit_log.AppID,
+		&group_hit_log.Score,
+		&policyIDs,
+		&group_hit_log.Monitor,
+		&group_hit_log.Scope,
+		&group_hit_log.Excluded,
+		&group_hit_log.ExclusionID)
 	utils.CheckError("SelectGroupHitLogByID QueryRow", err)
+	group_hit_log.PolicyIDs = splitInt64s(policyIDs)
 	return group_hit_log, err
 }
 
@@ -124,7 +143,7 @@ func (dal *MyDAL) SelectGroupHitLogs(appID int64, startTime int64, endTime int64
 	defer rows.Close()
 	for rows.Next() {
 		simpleGroupHitLog := new(models.SimpleGroupHitLog)
-		rows.Scan(&simpleGroupHitLog.ID, &simpleGroupHitLog.RequestTime, &simpleGroupHitLog.ClientIP, &simpleGroupHitLog.Host, &simpleGroupHitLog.Method, &simpleGroupHitLog.UrlPath, &simpleGroupHitLog.Action, &simpleGroupHitLog.PolicyID, &simpleGroupHitLog.AppID)
+		rows.Scan(&simpleGroupHitLog.ID, &simpleGroupHitLog.RequestTime, &simpleGroupHitLog.ClientIP, &simpleGroupHitLog.Host, &simpleGroupHitLog.Method, &simpleGroupHitLog.UrlPath, &simpleGroupHitLog.Action, &simpleGroupHitLog.PolicyID, &simpleGroupHitLog.AppID, &simpleGroupHitLog.Monitor, &simpleGroupHitLog.Scope, &simpleGroupHitLog.Excluded)
 		simpleGroupHitLogs = append(simpleGroupHitLogs, simpleGroupHitLog)
 	}
 	return simpleGroupHitLogs
@@ -163,3 +182,22 @@ func (dal *MyDAL) SelectAllVulnStat(startTime int64, endTime int64) (vulnStat []
 	}
 	return vulnStat, err
 }
+
+// joinInt64s convert the IDs to the comma separated column
+func joinInt64s(ids []int64) string {
+	var values []string
+	for _, id := range ids {
+		values = append(values, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(values, ",")
+}
+
+func splitInt64s(value string) []int64 {
+	ids := []int64{}
+	for _, item := range strings.Split(value, ",") {
+		if id, err := strconv.ParseInt(item, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,92 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:47:15
+ * @Last Modified: thonsun, 2026-10-19 14:47:15
+ */
+
+package data
+
+import (
+	"strconv"
+	"strings"
+
+	"asec/models"
+	"asec/utils"
+)
+
+const (
+	sqlCreateTableIfNotExistsIPACLs = `CREATE TABLE IF NOT EXISTS ip_acls(id bigserial primary key,app_id bigint,action bigint,ips text,countries varchar(1024),asns varchar(1024),negated boolean default false,description varchar(256),update_time bigint)`
+	sqlSelectIPACLs                 = `SELECT id,app_id,action,ips,countries,asns,negated,description,update_time FROM ip_acls`
+	sqlInsertIPACL                  = `INSERT INTO ip_acls(app_id,action,ips,countries,asns,negated,description,update_time) VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id`
+	sqlUpdateIPACL                  = `UPDATE ip_acls SET app_id=$1,action=$2,ips=$3,countries=$4,asns=$5,negated=$6,description=$7,update_time=$8 WHERE id=$9`
+	sqlDeleteIPACLByID              = `DELETE FROM ip_acls WHERE id=$1`
+)
+
+func (dal *MyDAL) CreateTableIfNotExistsIPACLs() error {
+	_, err := dal.db.Exec(sqlCreateTableIfNotExistsIPACLs)
+	utils.CheckError("CreateTableIfNotExistsIPACLs", err)
+	return err
+}
+
+func joinASNs(asns []int64) string {
+	items := []string{}
+	for _, asn := range asns {
+		items = append(items, strconv.FormatInt(asn, 10))
+	}
+	return strings.Join(items, ",")
+}
+
+func splitASNs(value string) []int64 {
+	asns := []int64{}
+	for _, item := range strings.Split(value, ",") {
+		if asn, err := strconv.ParseInt(item, 10, 64); err == nil {
+			asns = append(asns, asn)
+		}
+	}
+	return asns
+}
+
+func (dal *MyDAL) SelectIPACLs() (ipACLs []*models.IPACL, err error) {
+	rows, err := dal.db.Query(sqlSelectIPACLs)
+	utils.CheckError("SelectIPACLs", err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		ipACL := new(models.IPACL)
+		var ips, countries, asns string
+		err = rows.Scan(&ipACL.ID, &ipACL.AppID, &ipACL.Action, &ips, &countries, &asns, &ipACL.Negated, &ipACL.Description, &ipACL.UpdateTime)
+		utils.CheckError("SelectIPACLs Scan", err)
+		ipACL.IPs = []string{}
+		if len(ips) > 0 {
+			ipACL.IPs = strings.Split(ips, "\n")
+		}
+		ipACL.Countries = []string{}
+		if len(countries) > 0 {
+			ipACL.Countries = strings.Split(countries, ",")
+		}
+		ipACL.ASNs = splitASNs(asns)
+		ipACLs = append(ipACLs, ipACL)
+	}
+	return ipACLs, nil
+}
+
+func (dal *MyDAL) InsertIPACL(ipACL *models.IPACL) (newID int64, err error) {
+	err = dal.db.QueryRow(sqlInsertIPACL, ipACL.AppID, ipACL.Action, strings.Join(ipACL.IPs, "\n"), strings.Join(ipACL.Countries, ","), joinASNs(ipACL.ASNs), ipACL.Negated, ipACL.Description, ipACL.UpdateTime).Scan(&newID)
+	utils.CheckError("InsertIPACL", err)
+	return newID, err
+}
+
+func (dal *MyDAL) UpdateIPACL(ipACL *models.IPACL) error {
+	_, err := dal.db.Exec(sqlUpdateIPACL, ipACL.AppID, ipACL.Action, strings.Join(ipACL.IPs, "\n"), strings.Join(ipACL.Countries, ","), joinASNs(ipACL.ASNs), ipACL.Negated, ipACL.Description, ipACL.UpdateTime, ipACL.ID)
+	utils.CheckError("UpdateIPACL", err)
+	return err
+}
+
+func (dal *MyDAL) DeleteIPACLByID(id int64) error {
+	_, err := dal.db.Exec(sqlDeleteIPACLByID, id)
+	utils.CheckError("DeleteIPACLByID", err)
+	return err
+}
@@ -0,0 +1,68 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:35:19
+ * @Last Modified: thonsun, 2026-10-19 14:35:19
+ */
+
+package data
+
+import (
+	"strings"
+
+	"asec/models"
+	"asec/utils"
+)
+
+const (
+	sqlCreateTableIfNotExistsNamedLists = `CREATE TABLE IF NOT EXISTS named_lists(id bigserial primary key,name varchar(128) unique,list_type bigint,items text,description varchar(256),update_time bigint)`
+	sqlSelectNamedLists                 = `SELECT id,name,list_type,items,description,update_time FROM named_lists`
+	sqlInsertNamedList                  = `INSERT INTO named_lists(name,list_type,items,description,update_time) VALUES($1,$2,$3,$4,$5) RETURNING id`
+	sqlUpdateNamedList                  = `UPDATE named_lists SET name=$1,list_type=$2,items=$3,description=$4,update_time=$5 WHERE id=$6`
+	sqlDeleteNamedListByID              = `DELETE FROM named_lists WHERE id=$1`
+)
+
+func (dal *MyDAL) CreateTableIfNotExistsNamedLists() error {
+	_, err := dal.db.Exec(sqlCreateTableIfNotExistsNamedLists)
+	utils.CheckError("CreateTableIfNotExistsNamedLists", err)
+	return err
+}
+
+func (dal *MyDAL) SelectNamedLists() (namedLists []*models.NamedList, err error) {
+	rows, err := dal.db.Query(sqlSelectNamedLists)
+	utils.CheckError("SelectNamedLists", err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		namedList := new(models.NamedList)
+		var items string
+		err = rows.Scan(&namedList.ID, &namedList.Name, &namedList.ListType, &items, &namedList.Description, &namedList.UpdateTime)
+		utils.CheckError("SelectNamedLists Scan", err)
+		namedList.Items = []string{}
+		if len(items) > 0 {
+			namedList.Items = strings.Split(items, "\n")
+		}
+		namedLists = append(namedLists, namedList)
+	}
+	return namedLists, nil
+}
+
+func (dal *MyDAL) InsertNamedList(name string, listType models.NamedListType, items []string, description string, updateTime int64) (newID int64, err error) {
+	err = dal.db.QueryRow(sqlInsertNamedList, name, listType, strings.Join(items, "\n"), description, updateTime).Scan(&newID)
+	utils.CheckError("InsertNamedList", err)
+	return newID, err
+}
+
+func (dal *MyDAL) UpdateNamedList(name string, listType models.NamedListType, items []string, description string, updateTime int64, id int64) error {
+	_, err := dal.db.Exec(sqlUpdateNamedList, name, listType, strings.Join(items, "\n"), description, updateTime, id)
+	utils.CheckError("UpdateNamedList", err)
+	return err
+}
+
+func (dal *MyDAL) DeleteNamedListByID(id int64) error {
+	_, err := dal.db.Exec(sqlDeleteNamedListByID, id)
+	utils.CheckError("DeleteNamedListByID", err)
+	return err
+}
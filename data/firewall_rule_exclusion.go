@@ -0,0 +1,61 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 15:04:35
+ * @Last Modified: thonsun, 2026-10-19 15:04:35
+ */
+
+package data
+
+import (
+	"asec/models"
+	"asec/utils"
+)
+
+const (
+	sqlCreateTableIfNotExistsRuleExclusions = `CREATE TABLE IF NOT EXISTS rule_exclusions(id bigserial primary key,app_id bigint,policy_id bigint,vuln_id bigint,target_type bigint,target_name varchar(256),path_prefix varchar(2048),description varchar(256),update_time bigint)`
+	sqlSelectRuleExclusions                 = `SELECT id,app_id,policy_id,vuln_id,target_type,target_name,path_prefix,description,update_time FROM rule_exclusions`
+	sqlInsertRuleExclusion                  = `INSERT INTO rule_exclusions(app_id,policy_id,vuln_id,target_type,target_name,path_prefix,description,update_time) VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id`
+	sqlUpdateRuleExclusion                  = `UPDATE rule_exclusions SET app_id=$1,policy_id=$2,vuln_id=$3,target_type=$4,target_name=$5,path_prefix=$6,description=$7,update_time=$8 WHERE id=$9`
+	sqlDeleteRuleExclusionByID              = `DELETE FROM rule_exclusions WHERE id=$1`
+)
+
+func (dal *MyDAL) CreateTableIfNotExistsRuleExclusions() error {
+	_, err := dal.db.Exec(sqlCreateTableIfNotExistsRuleExclusions)
+	utils.CheckError("CreateTableIfNotExistsRuleExclusions", err)
+	return err
+}
+
+func (dal *MyDAL) SelectRuleExclusions() (exclusions []*models.RuleExclusion, err error) {
+	rows, err := dal.db.Query(sqlSelectRuleExclusions)
+	utils.CheckError("SelectRuleExclusions", err)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		exclusion := new(models.RuleExclusion)
+		err = rows.Scan(&exclusion.ID, &exclusion.AppID, &exclusion.PolicyID, &exclusion.VulnID, &exclusion.TargetType, &exclusion.TargetName, &exclusion.PathPrefix, &exclusion.Description, &exclusion.UpdateTime)
+		utils.CheckError("SelectRuleExclusions Scan", err)
+		exclusions = append(exclusions, exclusion)
+	}
+	return exclusions, nil
+}
+
+func (dal *MyDAL) InsertRuleExclusion(exclusion *models.RuleExclusion) (newID int64, err error) {
+	err = dal.db.QueryRow(sqlInsertRuleExclusion, exclusion.AppID, exclusion.PolicyID, exclusion.VulnID, exclusion.TargetType, exclusion.TargetName, exclusion.PathPrefix, exclusion.Description, exclusion.UpdateTime).Scan(&newID)
+	utils.CheckError("InsertRuleExclusion", err)
+	return newID, err
+}
+
+func (dal *MyDAL) UpdateRuleExclusion(exclusion *models.RuleExclusion) error {
+	_, err := dal.db.Exec(sqlUpdateRuleExclusion, exclusion.AppID, exclusion.PolicyID, exclusion.VulnID, exclusion.TargetType, exclusion.TargetName, exclusion.PathPrefix, exclusion.Description, exclusion.UpdateTime, exclusion.ID)
+	utils.CheckError("UpdateRuleExclusion", err)
+	return err
+}
+
+func (dal *MyDAL) DeleteRuleExclusionByID(id int64) error {
+	_, err := dal.db.Exec(sqlDeleteRuleExclusionByID, id)
+	utils.CheckError("DeleteRuleExclusionByID", err)
+	return err
+}
@@ -8,6 +8,7 @@
 package data
 
 import (
+	"sync/atomic"
 	"time"
 
 	"asec/models"
@@ -18,8 +19,76 @@ var (
 	Backend_Last_Modified  int64         = 0 // seconds since 1970.01.01
 	Firewall_Last_Modified int64         = 0
 	Sync_Seconds           time.Duration = (120 * time.Second)
+
+	// Static cache, a cached file younger than Cache_Revalidate_Seconds is fresh,
+	// within the stale-while-revalidate window it is served while being revalidated
+	// in background, within the stale-if-error window it is served when the backend fails.
+	// They are modified by the settings API at runtime, use GetCacheWindows and SetCacheWindow.
+	Cache_Revalidate_Seconds             int64 = 1800
+	Cache_Stale_While_Revalidate_Seconds int64 = 86400
+	Cache_Stale_If_Error_Seconds         int64 = 7 * 86400
+
+	// Certificates expiring within Cert_Expiry_Alert_Days are reported by the certificate monitor
+	Cert_Expiry_Alert_Days int64 = 30
+
+	// Default_Cert_ID is the certificate for the clients without SNI or with unknown names, 0 for none
+	Default_Cert_ID int64 = 0
+	// Cert_Expiry_Alert_Days and Default_Cert_ID are modified by the settings API at runtime and read by handshakes,
+	// use GetCertExpiryAlertDays, GetDefaultCertID and SetCertSetting.
 )
 
+// GetCacheWindows return the static cache windows in seconds
+func GetCacheWindows() (revalidateSeconds int64, staleWhileRevalidateSeconds int64, staleIfErrorSeconds int64) {
+	return atomic.LoadInt64(&Cache_Revalidate_Seconds), atomic.LoadInt64(&Cache_Stale_While_Revalidate_Seconds), atomic.LoadInt64(&Cache_Stale_If_Error_Seconds)
+}
+
+// GetCachePurgeSeconds return the age after which a cached file is out of all windows and can be removed
+func GetCachePurgeSeconds() int64 {
+	revalidateSeconds, staleWhileRevalidateSeconds, staleIfErrorSeconds := GetCacheWindows()
+	if staleIfErrorSeconds > staleWhileRevalidateSeconds {
+		return revalidateSeconds + staleIfErrorSeconds
+	}
+	return revalidateSeconds + staleWhileRevalidateSeconds
+}
+
+// SetCacheWindow modify a static cache window by setting name, return false if name is not a cache window
+func SetCacheWindow(name string, seconds int64) bool {
+	switch name {
+	case "Cache_Revalidate_Seconds":
+		atomic.StoreInt64(&Cache_Revalidate_Seconds, seconds)
+	case "Cache_Stale_While_Revalidate_Seconds":
+		atomic.StoreInt64(&Cache_Stale_While_Revalidate_Seconds, seconds)
+	case "Cache_Stale_If_Error_Seconds":
+		atomic.StoreInt64(&Cache_Stale_If_Error_Seconds, seconds)
+	default:
+		return false
+	}
+	return true
+}
+
+// GetCertExpiryAlertDays return Cert_Expiry_Alert_Days
+func GetCertExpiryAlertDays() int64 {
+	return atomic.LoadInt64(&Cert_Expiry_Alert_Days)
+}
+
+// GetDefaultCertID return Default_Cert_ID
+func GetDefaultCertID() int64 {
+	return atomic.LoadInt64(&Default_Cert_ID)
+}
+
+// SetCertSetting modify a certificate setting by name, return false if name is not a certificate setting
+func SetCertSetting(name string, value int64) bool {
+	switch name {
+	case "Cert_Expiry_Alert_Days":
+		atomic.StoreInt64(&Cert_Expiry_Alert_Days, value)
+	case "Default_Cert_ID":
+		atomic.StoreInt64(&Default_Cert_ID, value)
+	default:
+		return false
+	}
+	return true
+}
+
 func UpdateBackendLastModified() {
 	Backend_Last_Modified = time.Now().Unix()
 	DAL.SaveIntSetting("Backend_Last_Modified", Backend_Last_Modified)
@@ -0,0 +1,79 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:29:27
+ * @Last Modified: thonsun, 2026-10-19 14:29:27
+ */
+
+package firewall
+
+import (
+	"net/http"
+	"sync"
+
+	"asec/models"
+)
+
+// anomalyScoreKey is the key of anomalyScoreCollector in the groupPolicyHitValue map of request context
+const anomalyScoreKey = "anomalyScore"
+
+// anomalyScoreCollector sum the scores of matched group policies instead of returning the first one
+type anomalyScoreCollector struct {
+	total    int64
+	policies []*models.GroupPolicy
+}
+
+func (collector *anomalyScoreCollector) add(groupPolicy *models.GroupPolicy) {
+	collector.total += groupPolicy.Score
+	collector.policies = append(collector.policies, groupPolicy)
+}
+
+// result return the policy with the highest score as the hit policy if the threshold is reached,
+// its action is replaced by block, all contributing policies are recorded in the anomaly score.
+// Below the threshold, the policy with the highest score and the anomaly score are returned without hit,
+// so that they can be logged in monitor mode to tune the thresholds.
+func (collector *anomalyScoreCollector) result(threshold int64) (bool, *models.GroupPolicy, *models.AnomalyScore) {
+	if len(collector.policies) == 0 {
+		return false, nil, nil
+	}
+	anomalyScore := &models.AnomalyScore{Total: collector.total, Threshold: threshold, PolicyIDs: []int64{}}
+	topPolicy := collector.policies[0]
+	for _, groupPolicy := range collector.policies {
+		anomalyScore.PolicyIDs = append(anomalyScore.PolicyIDs, groupPolicy.ID)
+		if groupPolicy.Score > topPolicy.Score {
+			topPolicy = groupPolicy
+		}
+	}
+	if collector.total < threshold {
+		return false, topPolicy, anomalyScore
+	}
+	hitPolicy := *topPolicy
+	hitPolicy.Action = models.Action_Block_100
+	return true, &hitPolicy, anomalyScore
+}
+
+// IsRequestHitAnomalyScore evaluate all group policies of the request in scoring mode,
+// the policies with Action_Pass_400 are returned at once without score.
+func IsRequestHitAnomalyScore(r *http.Request, app *models.Application, srcIP string) (bool, *models.GroupPolicy, *models.AnomalyScore) {
+	ctxMap := r.Context().Value("groupPolicyHitValue").(*sync.Map)
+	collector := &anomalyScoreCollector{}
+	ctxMap.Store(anomalyScoreKey, collector)
+	defer ctxMap.Delete(anomalyScoreKey)
+	if matched, policy := IsRequestHitPolicy(r, app.ID, srcIP); matched {
+		return matched, policy, nil
+	}
+	return collector.result(app.InboundThreshold)
+}
+
+// IsResponseHitAnomalyScore evaluate all group policies of the response in scoring mode,
+// the policies matched by the request are not counted again.
+func IsResponseHitAnomalyScore(resp *http.Response, app *models.Application) (bool, *models.GroupPolicy, *models.AnomalyScore) {
+	ctxMap := resp.Request.Context().Value("groupPolicyHitValue").(*sync.Map)
+	collector := &anomalyScoreCollector{}
+	ctxMap.Store(anomalyScoreKey, collector)
+	defer ctxMap.Delete(anomalyScoreKey)
+	if matched, policy := IsResponseHitPolicy(resp, app.ID, ResponseInspectBytes(app)); matched {
+		return matched, policy, nil
+	}
+	return collector.result(app.OutboundThreshold)
+}
@@ -0,0 +1,51 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:29:27
+ * @Last Modified: thonsun, 2026-10-19 14:29:27
+ */
+
+package firewall
+
+import (
+	"sync"
+	"testing"
+
+	"asec/models"
+)
+
+func TestAnomalyScoreCollector(t *testing.T) {
+	policies := []*models.GroupPolicy{
+		{ID: 1, Score: 2, Action: models.Action_BypassAndLog_200, IsEnabled: true},
+		{ID: 2, Score: 3, Action: models.Action_BypassAndLog_200, IsEnabled: true},
+	}
+	defer installTestCheckItems(t, newTestCheckItems(policies, models.ChkPointGetPostValue, []string{`(?i)select`, `(?i)select`}))()
+
+	hitValueMap := &sync.Map{}
+	collector := &anomalyScoreCollector{}
+	hitValueMap.Store(anomalyScoreKey, collector)
+	// The second value must not add the scores again
+	for _, value := range []string{"1 union select 2", "select"} {
+		if matched, _ := IsMatchGroupPolicy(hitValueMap, 0, value, models.ChkPointGetPostValue, "", false); matched {
+			t.Fatal("policies in scoring mode should not be returned")
+		}
+	}
+	// Below the threshold, the score is returned for the monitor mode logs
+	isHit, policy, anomalyScore := collector.result(6)
+	if isHit || policy.ID != 2 || policy.Action != models.Action_BypassAndLog_200 || anomalyScore.Total != 5 || anomalyScore.Threshold != 6 {
+		t.Fatalf("total %d should be below threshold 6, %+v", collector.total, anomalyScore)
+	}
+	isHit, policy, anomalyScore = collector.result(5)
+	if !isHit || policy.ID != 2 || policy.Action != models.Action_Block_100 || anomalyScore.Total != 5 {
+		t.Fatalf("unexpected result %+v %+v", policy, anomalyScore)
+	}
+	if len(anomalyScore.PolicyIDs) != 2 || anomalyScore.PolicyIDs[0] != 1 || anomalyScore.PolicyIDs[1] != 2 {
+		t.Fatalf("unexpected policy_ids %v", anomalyScore.PolicyIDs)
+	}
+	if isHit, policy, anomalyScore = (&anomalyScoreCollector{}).result(5); isHit || policy != nil || anomalyScore != nil {
+		t.Fatal("empty collector should return nothing")
+	}
+	if policies[1].Action != models.Action_BypassAndLog_200 {
+		t.Fatal("action of the loaded policy should not be changed")
+	}
+}
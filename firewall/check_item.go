@@ -18,8 +18,25 @@ import (
 
 var (
 	checkPointCheckItemsMap sync.Map //(models.ChkPoint, []*models.CheckItem)
+	checkPointRuleEngineMap sync.Map //(models.ChkPoint, *RuleEngine)
 )
 
+// StoreCheckPointCheckItems save the check items of check point, and rebuild the rule engine of the check point
+func StoreCheckPointCheckItems(checkPoint models.ChkPoint, checkItems []*models.CheckItem) {
+	checkPointCheckItemsMap.Store(checkPoint, checkItems)
+	var compiledItems []*CompiledCheckItem
+	for _, checkItem := range checkItems {
+		compiledItem, err := CompileCheckItem(checkItem)
+		if err != nil {
+			// Invalid patterns saved before the validation of UpdateGroupPolicy are skipped
+			utils.CheckError("StoreCheckPointCheckItems CompileCheckItem", err)
+			continue
+		}
+		compiledItems = append(compiledItems, compiledItem)
+	}
+	checkPointRuleEngineMap.Store(checkPoint, NewRuleEngine(compiledItems))
+}
+
 // GetCheckItemIndex ...
 func GetCheckItemIndex(checkItems []*models.CheckItem, id int64) int {
 	for i := 0; i < len(checkItems); i++ {
@@ -76,7 +93,7 @@ func AddCheckItemToMap(checkItem *models.CheckItem) {
 	value, _ := checkPointCheckItemsMap.LoadOrStore(checkItem.CheckPoint, []*models.CheckItem{})
 	checkpointCheckItems := value.([]*models.CheckItem)
 	checkpointCheckItems = append(checkpointCheckItems, checkItem)
-	checkPointCheckItemsMap.Store(checkItem.CheckPoint, checkpointCheckItems)
+	StoreCheckPointCheckItems(checkItem.CheckPoint, checkpointCheckItems)
 
 }
 
@@ -88,16 +105,16 @@ func UpdateCheckItemToMap(checkItem *models.CheckItem) {
 		// check point not changed
 		//fmt.Println("UpdateCheckItemToMap check point not changed")
 		checkPointCheckItems = append(checkPointCheckItems, checkItem)
-		checkPointCheckItemsMap.Store(hitCheckPoint, checkPointCheckItems)
+		StoreCheckPointCheckItems(hitCheckPoint, checkPointCheckItems)
 	} else {
 		//fmt.Println("UpdateCheckItemToMap check point changed, new check point: ", check_item.CheckPoint)
 		// save old check point
-		checkPointCheckItemsMap.Store(hitCheckPoint, checkPointCheckItems)
+		StoreCheckPointCheckItems(hitCheckPoint, checkPointCheckItems)
 		// add new check point
 		value, _ := checkPointCheckItemsMap.LoadOrStore(checkItem.CheckPoint, []*models.CheckItem{})
 		checkPointCheckItems = value.([]*models.CheckItem)
 		checkPointCheckItems = append(checkPointCheckItems, checkItem)
-		checkPointCheckItemsMap.Store(checkItem.CheckPoint, checkPointCheckItems)
+		StoreCheckPointCheckItems(checkItem.CheckPoint, checkPointCheckItems)
 
 	}
 }
@@ -126,6 +143,11 @@ func LoadCheckItems() {
 			checkPointCheckItemsMap.Store(checkItem.CheckPoint, checkpointCheckItems)
 		}
 	}
+	// Compile once after all check items loaded
+	checkPointCheckItemsMap.Range(func(key, value interface{}) bool {
+		StoreCheckPointCheckItems(key.(models.ChkPoint), value.([]*models.CheckItem))
+		return true
+	})
 }
 
 // ContainsCheckItemID ...
@@ -147,20 +169,20 @@ func UpdateCheckItems(groupPolicy *models.GroupPolicy, checkItems []*models.Chec
 			data.DAL.DeleteCheckItemByID(checkItem.ID)
 			hitCheckPoint, checkPointCheckItems, index := GetCheckPointMapByCheckItemID(checkItem, true)
 			checkPointCheckItems = DeleteCheckItemByIndex(checkPointCheckItems, index)
-			checkPointCheckItemsMap.Store(hitCheckPoint, checkPointCheckItems)
+			StoreCheckPointCheckItems(hitCheckPoint, checkPointCheckItems)
 		}
 	}
 	var newCheckItems []*models.CheckItem
 	for _, checkItem := range checkItems {
 		// add new check_items to DB and group_policy
 		if checkItem.ID == 0 {
-			checkItemID, _ := data.DAL.InsertCheckItem(checkItem.CheckPoint, checkItem.Operation, checkItem.KeyName, checkItem.RegexPolicy, groupPolicy.ID)
+			checkItemID, _ := data.DAL.InsertCheckItem(checkItem.CheckPoint, checkItem.Operation, checkItem.KeyName, checkItem.RegexPolicy, groupPolicy.ID, checkItem.Transforms)
 			checkItem.ID = checkItemID
 			checkItem.GroupPolicyID = groupPolicy.ID
 			checkItem.GroupPolicy = groupPolicy
 			AddCheckItemToMap(checkItem)
 		} else {
-			data.DAL.UpdateCheckItemByID(checkItem.CheckPoint, checkItem.Operation, checkItem.KeyName, checkItem.RegexPolicy, groupPolicy.ID, checkItem.ID)
+			data.DAL.UpdateCheckItemByID(checkItem.CheckPoint, checkItem.Operation, checkItem.KeyName, checkItem.RegexPolicy, groupPolicy.ID, checkItem.Transforms, checkItem.ID)
 			UpdateCheckItemToMap(checkItem)
 		}
 		newCheckItems = append(newCheckItems, checkItem)
@@ -185,7 +207,7 @@ func DeleteCheckItemsByGroupPolicy(groupPolicy *models.GroupPolicy) error {
 			//fmt.Println("DeleteCheckItemsByGroupPolicy", i)
 			checkpointCheckItems = DeleteCheckItemByIndex(checkpointCheckItems, i)
 			//checkpoint_check_items = append(checkpoint_check_items[:i], checkpoint_check_items[i+1:]...)
-			checkPointCheckItemsMap.Store(checkItem.CheckPoint, checkpointCheckItems)
+			StoreCheckPointCheckItems(checkItem.CheckPoint, checkpointCheckItems)
 		}
 		data.DAL.DeleteCheckItemByID(checkItem.ID)
 	}
@@ -0,0 +1,126 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:58:58
+ * @Last Modified: thonsun, 2026-10-19 14:58:58
+ */
+
+package firewall
+
+const (
+	minCreditCardDigits = 13
+	maxCreditCardDigits = 19
+	nationalIDLength    = 18
+)
+
+// nationalIDWeights and nationalIDCheckCodes of ISO 7064 MOD 11-2, GB 11643
+var (
+	nationalIDWeights    = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	nationalIDCheckCodes = "10X98765432"
+)
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isASCIIAlnum(c byte) bool {
+	return isASCIIDigit(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// luhnValid check the digits of card number with the Luhn algorithm
+func luhnValid(digits []byte) bool {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		n := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 1 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
+
+// findCreditCards return the ranges of card numbers: 13-19 digits which may be grouped by single spaces or dashes,
+// starting with 2-6 (Mastercard, Amex, Visa, Discover, UnionPay...) and Luhn validated.
+func findCreditCards(value string) [][2]int {
+	var ranges [][2]int
+	for i := 0; i < len(value); {
+		if !isASCIIDigit(value[i]) || i > 0 && isASCIIAlnum(value[i-1]) {
+			i++
+			continue
+		}
+		digits := make([]byte, 0, maxCreditCardDigits+1)
+		end := i
+		for j := i; j < len(value); {
+			c := value[j]
+			if isASCIIDigit(c) {
+				digits = append(digits, c)
+				j++
+				end = j
+				if len(digits) > maxCreditCardDigits {
+					break
+				}
+			} else if (c == ' ' || c == '-') && j+1 < len(value) && isASCIIDigit(value[j+1]) && isASCIIDigit(value[j-1]) {
+				j++
+			} else {
+				break
+			}
+		}
+		if len(digits) >= minCreditCardDigits && len(digits) <= maxCreditCardDigits &&
+			'2' <= digits[0] && digits[0] <= '6' && (end == len(value) || !isASCIIAlnum(value[end])) && luhnValid(digits) {
+			ranges = append(ranges, [2]int{i, end})
+		}
+		// skip the whole number
+		for end < len(value) && isASCIIAlnum(value[end]) {
+			end++
+		}
+		i = end
+	}
+	return ranges
+}
+
+// findNationalIDs return the ranges of the resident ID numbers of China: 17 digits and the check code,
+// with the birth date and check code validated.
+func findNationalIDs(value string) [][2]int {
+	var ranges [][2]int
+	for i := 0; i+nationalIDLength <= len(value); i++ {
+		if i > 0 && isASCIIAlnum(value[i-1]) {
+			continue
+		}
+		end := i + nationalIDLength
+		if end < len(value) && isASCIIAlnum(value[end]) {
+			continue
+		}
+		id := value[i:end]
+		if isValidNationalID(id) {
+			ranges = append(ranges, [2]int{i, end})
+			i = end - 1
+		}
+	}
+	return ranges
+}
+
+func isValidNationalID(id string) bool {
+	sum := 0
+	for i := 0; i < nationalIDLength-1; i++ {
+		if !isASCIIDigit(id[i]) {
+			return false
+		}
+		sum += int(id[i]-'0') * nationalIDWeights[i]
+	}
+	checkCode := id[nationalIDLength-1]
+	if checkCode == 'x' {
+		checkCode = 'X'
+	}
+	if nationalIDCheckCodes[sum%11] != checkCode {
+		return false
+	}
+	// YYYYMMDD at 6
+	year := (id[6]-'0')*10 + (id[7] - '0')
+	month := int(id[10]-'0')*10 + int(id[11]-'0')
+	day := int(id[12]-'0')*10 + int(id[13]-'0')
+	return (year == 19 || year == 20) && month >= 1 && month <= 12 && day >= 1 && day <= 31
+}
@@ -0,0 +1,277 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:42:35
+ * @Last Modified: thonsun, 2026-10-19 14:42:35
+ */
+
+package firewall
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxSQLiTokens of the fingerprint, injections are found at the beginning of the value
+const maxSQLiTokens = 16
+
+/*
+Token types of the SQL fingerprint, in the style of libinjection:
+1 number or null, s string, v variable, n bareword, f function, F dangerous function,
+E statement (select, drop...), U union, & logic (and, or), o operator, B order/group by,
+k other keywords, W waitfor, c comment (kept only at the end), ( ) , ; punctuation
+*/
+var sqliKeywords = map[string]byte{
+	"select": 'E', "insert": 'E', "update": 'E', "delete": 'E', "drop": 'E', "create": 'E', "alter": 'E',
+	"truncate": 'E', "exec": 'E', "execute": 'E', "declare": 'E', "shutdown": 'E', "grant": 'E', "revoke": 'E',
+	"union": 'U', "intersect": 'U', "except": 'U',
+	"and": '&', "or": '&', "xor": '&',
+	"like": 'o', "rlike": 'o', "regexp": 'o', "is": 'o', "in": 'o', "between": 'o', "div": 'o', "mod": 'o', "sounds": 'o',
+	"null": '1', "true": '1', "false": '1',
+	"order": 'B', "group": 'B',
+	"from": 'k', "where": 'k', "having": 'k', "limit": 'k', "into": 'k', "table": 'k', "case": 'k', "when": 'k',
+	"then": 'k', "else": 'k', "end": 'k', "as": 'k', "join": 'k', "values": 'k', "procedure": 'k', "offset": 'k',
+	"waitfor": 'W',
+	// skipped: not, all/distinct after union, by after order/group
+	"not": 0, "all": 0, "distinct": 0, "by": 0,
+}
+
+// sqliDangerousFunctions are used by time based, error based and out of band injections
+var sqliDangerousFunctions = map[string]bool{
+	"sleep": true, "benchmark": true, "pg_sleep": true, "load_file": true, "extractvalue": true, "updatexml": true,
+	"xp_cmdshell": true, "name_const": true, "sys_eval": true, "sys_exec": true, "dbms_pipe.receive_message": true,
+	"utl_inaddr.get_host_address": true, "utl_http.request": true, "dbms_lock.sleep": true,
+}
+
+// sqliFingerprintPatterns match the fingerprints of injections
+var sqliFingerprintPatterns = []*regexp.Regexp{
+	// Tautology: 1 or 1=1, ' or 'a'='a, 1) or (1=1, 1 and @@version>0
+	regexp.MustCompile(`[1sv)]&\(*[1svn]o[1svnf(]`),
+	regexp.MustCompile(`^&[1sv]o[1sv]`),
+	// String context: ' or 1--, admin'--
+	regexp.MustCompile(`^s&[1sv]c?$`),
+	regexp.MustCompile(`^sc$`),
+	// Union: 1 union select, ') union all select
+	regexp.MustCompile(`(^|[1sv)])U\(*E`),
+	// Stacked queries: 1; drop table
+	regexp.MustCompile(`(^|[1sv)]);[EW]`),
+	// Dangerous functions: 1 and sleep(5), select load_file(
+	regexp.MustCompile(`(^|[&o(,;E])F\(`),
+	regexp.MustCompile(`(^|[1sv);&])Wn[s1]`),
+	// Boolean blind: 1 and ascii(substr(...))
+	regexp.MustCompile(`[1sv)]&f\(`),
+	// Subquery: 1=(select ...), 1 and (select ...)
+	regexp.MustCompile(`[&o(]\(+E`),
+	// Order by probing: 1 order by 3--
+	regexp.MustCompile(`[1sv)]B[1n]`),
+}
+
+type sqlTokenizer struct {
+	input       string
+	pos         int
+	inMySQLCode bool
+}
+
+func isSQLWordByte(c byte) bool {
+	return c == '_' || c == '$' || c == '.' || c >= 0x80 || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isSQLSpace(c byte) bool {
+	return c <= ' ' || c == 0x7f
+}
+
+// next return the type of next token, 0 if the end of input
+func (tokenizer *sqlTokenizer) next() byte {
+	input := tokenizer.input
+	for tokenizer.pos < len(input) {
+		i := tokenizer.pos
+		c := input[i]
+		switch {
+		case isSQLSpace(c):
+			tokenizer.pos++
+		case c == '\'' || c == '"':
+			j := i + 1
+			for j < len(input) {
+				if input[j] == '\\' {
+					j += 2
+					continue
+				}
+				if input[j] == c {
+					if j+1 < len(input) && input[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				j++
+			}
+			tokenizer.pos = j + 1
+			return 's'
+		case c == '`':
+			end := strings.IndexByte(input[i+1:], '`')
+			tokenizer.pos = len(input)
+			if end >= 0 {
+				tokenizer.pos = i + end + 2
+			}
+			return 'n'
+		case c == '/' && i+1 < len(input) && input[i+1] == '*':
+			if i+2 < len(input) && input[i+2] == '!' {
+				// MySQL executable comment /*!50000select*/, the content is code
+				j := i + 3
+				for j < len(input) && '0' <= input[j] && input[j] <= '9' {
+					j++
+				}
+				tokenizer.pos = j
+				tokenizer.inMySQLCode = true
+				continue
+			}
+			end := strings.Index(input[i+2:], "*/")
+			tokenizer.pos = len(input)
+			if end >= 0 {
+				tokenizer.pos = i + end + 4
+			}
+			return 'c'
+		case c == '*' && i+1 < len(input) && input[i+1] == '/' && tokenizer.inMySQLCode:
+			tokenizer.pos += 2
+			tokenizer.inMySQLCode = false
+		case c == '-' && i+1 < len(input) && input[i+1] == '-', c == '#':
+			end := strings.IndexByte(input[i:], '\n')
+			tokenizer.pos = len(input)
+			if end >= 0 {
+				tokenizer.pos = i + end + 1
+			}
+			return 'c'
+		case c == ';' || c == '(' || c == ')' || c == ',':
+			tokenizer.pos++
+			return c
+		case c == '@':
+			j := i + 1
+			for j < len(input) && input[j] == '@' {
+				j++
+			}
+			for j < len(input) && isSQLWordByte(input[j]) {
+				j++
+			}
+			tokenizer.pos = j
+			if j == i+1 {
+				return 'o'
+			}
+			return 'v'
+		case '0' <= c && c <= '9' || c == '.' && i+1 < len(input) && '0' <= input[i+1] && input[i+1] <= '9':
+			j := i + 1
+			if c == '0' && j < len(input) && (input[j] == 'x' || input[j] == 'X' || input[j] == 'b' || input[j] == 'B') {
+				j++
+				for j < len(input) && isHexByte(input[j]) {
+					j++
+				}
+			} else {
+				for j < len(input) && ('0' <= input[j] && input[j] <= '9' || input[j] == '.') {
+					j++
+				}
+				if j+1 < len(input) && (input[j] == 'e' || input[j] == 'E') && ('0' <= input[j+1] && input[j+1] <= '9' || input[j+1] == '-' || input[j+1] == '+') {
+					j += 2
+					for j < len(input) && '0' <= input[j] && input[j] <= '9' {
+						j++
+					}
+				}
+			}
+			tokenizer.pos = j
+			return '1'
+		case isSQLWordByte(c):
+			j := i + 1
+			for j < len(input) && isSQLWordByte(input[j]) {
+				j++
+			}
+			word := strings.ToLower(input[i:j])
+			tokenizer.pos = j
+			if kind, ok := sqliKeywords[word]; ok {
+				if kind == 0 {
+					continue
+				}
+				return kind
+			}
+			k := j
+			for k < len(input) && isSQLSpace(input[k]) {
+				k++
+			}
+			if k < len(input) && input[k] == '(' {
+				if sqliDangerousFunctions[word] {
+					return 'F'
+				}
+				return 'f'
+			}
+			return 'n'
+		case c == '|' && i+1 < len(input) && input[i+1] == '|', c == '&' && i+1 < len(input) && input[i+1] == '&':
+			tokenizer.pos += 2
+			return '&'
+		case strings.IndexByte("=<>!+-*/%|&^~:", c) >= 0:
+			j := i + 1
+			for j < len(input) && strings.IndexByte("=<>!", input[j]) >= 0 {
+				j++
+			}
+			tokenizer.pos = j
+			if input[i:j] == "!" {
+				// not
+				continue
+			}
+			return 'o'
+		default:
+			tokenizer.pos++
+		}
+	}
+	return 0
+}
+
+func isHexByte(c byte) bool {
+	_, ok := unhex(c)
+	return ok
+}
+
+// sqliFingerprint tokenize the input and fold the tokens, delimiter is the quote which the input is supposed in, or 0
+func sqliFingerprint(input string, delimiter byte) string {
+	if delimiter != 0 {
+		input = string(delimiter) + input
+	}
+	tokenizer := &sqlTokenizer{input: input}
+	fingerprint := make([]byte, 0, maxSQLiTokens)
+	lastComment := false
+	for len(fingerprint) < maxSQLiTokens {
+		kind := tokenizer.next()
+		if kind == 0 {
+			break
+		}
+		if kind == 'c' {
+			lastComment = true
+			continue
+		}
+		lastComment = false
+		if kind == 'o' {
+			// unary operator, e.g. or -1=-1
+			if len(fingerprint) == 0 || strings.IndexByte("&o(,;EU", fingerprint[len(fingerprint)-1]) >= 0 {
+				continue
+			}
+		}
+		fingerprint = append(fingerprint, kind)
+	}
+	if lastComment && len(fingerprint) < maxSQLiTokens {
+		fingerprint = append(fingerprint, 'c')
+	}
+	return string(fingerprint)
+}
+
+// DetectSQLi tokenize the value as it is, and as it is in single and double quoted strings,
+// return true and the fingerprint if any fingerprint is an injection.
+func DetectSQLi(value string) (bool, string) {
+	for _, delimiter := range []byte{0, '\'', '"'} {
+		if delimiter != 0 && strings.IndexByte(value, delimiter) < 0 {
+			continue
+		}
+		fingerprint := sqliFingerprint(value, delimiter)
+		for _, pattern := range sqliFingerprintPatterns {
+			if pattern.MatchString(fingerprint) {
+				return true, fingerprint
+			}
+		}
+	}
+	return false, ""
+}
@@ -0,0 +1,82 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:42:35
+ * @Last Modified: thonsun, 2026-10-19 14:42:35
+ */
+
+package firewall
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"asec/models"
+)
+
+// loadCorpus read the payloads of testdata/detect, one per line, # for comments
+func loadCorpus(t *testing.T, name string) []string {
+	content, err := ioutil.ReadFile("testdata/detect/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payloads []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		payloads = append(payloads, line)
+	}
+	return payloads
+}
+
+func TestDetectSQLiCorpus(t *testing.T) {
+	for _, payload := range loadCorpus(t, "sqli_attacks.txt") {
+		if isSQLi, _ := DetectSQLi(payload); !isSQLi {
+			t.Errorf("SQLi not detected: %q, fingerprints %q %q %q", payload,
+				sqliFingerprint(payload, 0), sqliFingerprint(payload, '\''), sqliFingerprint(payload, '"'))
+		}
+	}
+	for _, payload := range loadCorpus(t, "sqli_benign.txt") {
+		if isSQLi, fingerprint := DetectSQLi(payload); isSQLi {
+			t.Errorf("Benign input detected as SQLi: %q, fingerprint %q", payload, fingerprint)
+		}
+	}
+}
+
+func TestDetectXSSCorpus(t *testing.T) {
+	for _, payload := range loadCorpus(t, "xss_attacks.txt") {
+		if !DetectXSS(payload) {
+			t.Errorf("XSS not detected: %q", payload)
+		}
+	}
+	for _, payload := range loadCorpus(t, "xss_benign.txt") {
+		if DetectXSS(payload) {
+			t.Errorf("Benign input detected as XSS: %q", payload)
+		}
+	}
+}
+
+func TestCompiledCheckItemDetectors(t *testing.T) {
+	cases := []struct {
+		operation models.Operation
+		value     string
+		expected  bool
+	}{
+		{models.OperationDetectSQLi, "1 union select 1", true},
+		{models.OperationDetectSQLi, "hello", false},
+		{models.OperationDetectXSS, "<svg onload=alert(1)>", true},
+		{models.OperationDetectXSS, "<b>hello</b>", false},
+		{models.OperationDetectXSS | models.OperationNot, "<b>hello</b>", true},
+	}
+	for _, c := range cases {
+		item, err := CompileCheckItem(&models.CheckItem{CheckPoint: models.ChkPointGetPostValue, Operation: c.operation})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Match(c.value) != c.expected {
+			t.Fatalf("operation %d match %q is %v, expected %v", c.operation, c.value, !c.expected, c.expected)
+		}
+	}
+}
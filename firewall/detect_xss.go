@@ -0,0 +1,250 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:42:35
+ * @Last Modified: thonsun, 2026-10-19 14:42:35
+ */
+
+package firewall
+
+import (
+	"html"
+	"strings"
+)
+
+// The states which the value is supposed to be reflected in
+const (
+	xssStateData = iota
+	xssStateValueNoQuote
+	xssStateValueSingleQuote
+	xssStateValueDoubleQuote
+	// xssStateURL is the unquoted value of href or src
+	xssStateURL
+)
+
+// xssTags can execute scripts or load resources by themselves
+var xssTags = map[string]bool{
+	"script": true, "iframe": true, "frame": true, "frameset": true, "object": true, "embed": true, "applet": true,
+	"base": true, "meta": true, "link": true, "style": true, "xml": true, "import": true, "vmlframe": true, "xss": true,
+	"isindex": true,
+}
+
+// xssURLAttrs are loaded or navigated by the browser
+var xssURLAttrs = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "data": true, "lowsrc": true, "dynsrc": true,
+	"background": true, "poster": true, "xlink:href": true, "codebase": true, "from": true, "to": true, "values": true,
+}
+
+var xssBlackURLPrefixes = []string{"javascript:", "vbscript:", "livescript:", "data:text/html", "data:image/svg", "data:application/"}
+
+var xssBlackStyles = []string{"expression(", "javascript:", "behavior:", "-moz-binding", "@import"}
+
+// xssEventHandlers is a list instead of the on prefix, so the words like online are not flagged
+var xssEventHandlers = map[string]bool{}
+
+func init() {
+	for _, event := range strings.Fields(`abort afterprint animationend animationiteration animationstart auxclick
+		beforecopy beforecut beforeinput beforepaste beforeprint beforeunload begin blur bounce canplay canplaythrough
+		change click close contextmenu copy cuechange cut dblclick drag dragend dragenter dragleave dragover dragstart
+		drop durationchange end ended error finish focus focusin focusout formdata fullscreenchange hashchange input
+		invalid keydown keypress keyup load loadeddata loadedmetadata loadend loadstart message mousedown mouseenter
+		mouseleave mousemove mouseout mouseover mouseup mousewheel offline online pagehide pageshow paste pause play
+		playing pointercancel pointerdown pointerenter pointerleave pointermove pointerout pointerover pointerrawupdate
+		pointerup popstate progress propertychange ratechange readystatechange repeat reset resize scroll scrollend
+		search seeked seeking select selectionchange selectstart show start stalled storage submit suspend timeupdate
+		toggle touchend touchmove touchstart transitioncancel transitionend transitionrun transitionstart unload
+		volumechange waiting webkitanimationend webkittransitionend wheel`) {
+		xssEventHandlers["on"+event] = true
+	}
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isXSSBlackURL(value string) bool {
+	value = html.UnescapeString(value)
+	// Browsers ignore the whitespace and control characters, e.g. java&#09;script:
+	normalized := make([]byte, 0, len(value))
+	for i := 0; i < len(value) && len(normalized) < 32; i++ {
+		if c := value[i]; c > ' ' && c != 0x7f {
+			normalized = append(normalized, c)
+		}
+	}
+	lower := strings.ToLower(string(normalized))
+	for _, prefix := range xssBlackURLPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isXSSAttr(name string, value string) bool {
+	if xssEventHandlers[name] {
+		return true
+	}
+	switch {
+	case xssURLAttrs[name]:
+		return isXSSBlackURL(value)
+	case name == "style":
+		lower := strings.ToLower(html.UnescapeString(value))
+		for _, style := range xssBlackStyles {
+			if strings.Contains(lower, style) {
+				return true
+			}
+		}
+	case name == "srcdoc":
+		return strings.ContainsAny(html.UnescapeString(value), "<")
+	}
+	return false
+}
+
+// readXSSAttrValue read the value from pos, quote is 0 for the unquoted value, return the value and next position
+func readXSSAttrValue(input string, pos int, quote byte) (string, int) {
+	if quote != 0 {
+		end := strings.IndexByte(input[pos:], quote)
+		if end < 0 {
+			return input[pos:], len(input)
+		}
+		return input[pos : pos+end], pos + end + 1
+	}
+	end := pos
+	for end < len(input) && !isHTMLSpace(input[end]) && input[end] != '>' {
+		end++
+	}
+	return input[pos:end], end
+}
+
+// isXSSInState tokenize the input like a browser from the state, return true if a script can be executed
+func isXSSInState(input string, state int) bool {
+	pos := 0
+	inTag := false
+	switch state {
+	case xssStateValueNoQuote, xssStateURL:
+		for pos < len(input) && isHTMLSpace(input[pos]) {
+			pos++
+		}
+		value, next := readXSSAttrValue(input, pos, 0)
+		if state == xssStateURL && isXSSBlackURL(value) {
+			return true
+		}
+		pos, inTag = next, true
+	case xssStateValueSingleQuote:
+		_, pos = readXSSAttrValue(input, pos, '\'')
+		inTag = true
+	case xssStateValueDoubleQuote:
+		_, pos = readXSSAttrValue(input, pos, '"')
+		inTag = true
+	}
+	for pos < len(input) {
+		if !inTag {
+			start := strings.IndexByte(input[pos:], '<')
+			if start < 0 {
+				return false
+			}
+			pos += start + 1
+			if pos >= len(input) {
+				return false
+			}
+			switch c := input[pos]; {
+			case c == '!':
+				if strings.HasPrefix(input[pos:], "!--") {
+					end := strings.Index(input[pos+3:], "-->")
+					comment := input[pos+3:]
+					if end >= 0 {
+						comment = comment[:end]
+					}
+					// IE conditional comments
+					if strings.Contains(strings.ToLower(comment), "[if") {
+						return true
+					}
+					pos += 3 + len(comment)
+					continue
+				}
+				fallthrough
+			case c == '?' || c == '/':
+				// Doctype, processing instruction and end tag
+				end := strings.IndexByte(input[pos:], '>')
+				if end < 0 {
+					return false
+				}
+				pos += end + 1
+			case 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+				end := pos
+				for end < len(input) && !isHTMLSpace(input[end]) && strings.IndexByte("/><", input[end]) < 0 {
+					end++
+				}
+				if end < len(input) && input[end] == '<' {
+					// Not a tag, e.g. <scr<script>ipt>
+					pos = end
+					continue
+				}
+				tagName := strings.ToLower(strings.Replace(input[pos:end], "\x00", "", -1))
+				if xssTags[tagName] {
+					return true
+				}
+				pos, inTag = end, true
+			}
+			continue
+		}
+		// Attributes of the tag
+		for pos < len(input) && (isHTMLSpace(input[pos]) || input[pos] == '/') {
+			pos++
+		}
+		if pos >= len(input) {
+			return false
+		}
+		if input[pos] == '>' {
+			pos++
+			inTag = false
+			continue
+		}
+		// The first character of name can be =
+		end := pos + 1
+		for end < len(input) && !isHTMLSpace(input[end]) && strings.IndexByte("/>=", input[end]) < 0 {
+			end++
+		}
+		name := strings.ToLower(strings.Replace(input[pos:end], "\x00", "", -1))
+		pos = end
+		for pos < len(input) && isHTMLSpace(input[pos]) {
+			pos++
+		}
+		// The attributes without value are harmless, e.g. online
+		if pos < len(input) && input[pos] == '=' {
+			pos++
+			for pos < len(input) && isHTMLSpace(input[pos]) {
+				pos++
+			}
+			var quote byte
+			if pos < len(input) && (input[pos] == '"' || input[pos] == '\'' || input[pos] == '`') {
+				quote = input[pos]
+				pos++
+			}
+			var value string
+			value, pos = readXSSAttrValue(input, pos, quote)
+			if isXSSAttr(name, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DetectXSS tokenize the value as it is reflected in HTML text, in attribute values and in URL attributes,
+// return true if it can execute a script in any of them.
+func DetectXSS(value string) bool {
+	if strings.IndexAny(value, "<>'\"=:") < 0 {
+		return false
+	}
+	for _, state := range []int{xssStateData, xssStateValueNoQuote, xssStateValueSingleQuote, xssStateValueDoubleQuote, xssStateURL} {
+		if state == xssStateValueSingleQuote && strings.IndexByte(value, '\'') < 0 ||
+			state == xssStateValueDoubleQuote && strings.IndexByte(value, '"') < 0 {
+			continue
+		}
+		if isXSSInState(value, state) {
+			return true
+		}
+	}
+	return false
+}
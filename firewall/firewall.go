@@ -71,31 +71,47 @@ func UnEscapeRawValue(rawQuery string) string {
 	return decodeQuery
 }
 
+// GetEnforcedAction return the action taken for the hit of IP ACL, CC or group policy. In monitor mode,
+// the hit is logged with monitor flag (Action_BypassAndLog_200) but never enforced, Action_Pass_400 is kept.
+func GetEnforcedAction(app *models.Application, action models.PolicyAction) (enforcedAction models.PolicyAction, monitor bool) {
+	if app.MonitorMode && action != models.Action_Pass_400 {
+		return models.Action_BypassAndLog_200, true
+	}
+	return action, false
+}
+
 // IsRequestHitPolicy ...
 func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *models.GroupPolicy) {
+	return isRequestHitPolicy(r, appID, srcIP, nil)
+}
+
+// isRequestHitPolicy collect the matches in the trace if not nil, see ReplayRequest
+func isRequestHitPolicy(r *http.Request, appID int64, srcIP string, trace *matchTrace) (bool, *models.GroupPolicy) {
 	//fmt.Println("IsForbiddenRequest")
 	ctxMap := r.Context().Value("groupPolicyHitValue").(*sync.Map)
+	// The scopes of group policies are checked against the request
+	ctxMap.Store(requestKey, r)
 
 	// ChkPoint_Host
-	matched, policy := IsMatchGroupPolicy(ctxMap, appID, r.Host, models.ChkPointHost, "", false)
+	matched, policy := matchGroupPolicy(ctxMap, appID, r.Host, models.ChkPointHost, "", false, trace)
 	if matched == true {
 		return matched, policy
 	}
 
 	// ChkPoint_IPAddress
-	matched, policy = IsMatchGroupPolicy(ctxMap, appID, srcIP, models.ChkPointIPAddress, "", false)
+	matched, policy = matchGroupPolicy(ctxMap, appID, srcIP, models.ChkPointIPAddress, "", false, trace)
 	if matched == true {
 		return matched, policy
 	}
 
 	// ChkPoint_Method
-	matched, policy = IsMatchGroupPolicy(ctxMap, appID, r.Method, models.ChkPointMethod, "", false)
+	matched, policy = matchGroupPolicy(ctxMap, appID, r.Method, models.ChkPointMethod, "", false, trace)
 	if matched == true {
 		return matched, policy
 	}
 
 	// ChkPoint_URLPath
-	matched, policy = IsMatchGroupPolicy(ctxMap, appID, r.URL.Path, models.ChkPointURLPath, "", false)
+	matched, policy = matchGroupPolicy(ctxMap, appID, r.URL.Path, models.ChkPointURLPath, "", false, trace)
 	if matched == true {
 		return matched, policy
 	}
@@ -103,7 +119,7 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 	if len(r.URL.RawQuery) > 0 {
 		//decode_query := UnEscapeRawValue(r.URL.RawQuery)
 		//fmt.Println("decode_query:", decode_query)
-		matched, policy = IsMatchGroupPolicy(ctxMap, appID, r.URL.RawQuery, models.ChkPointURLQuery, "", true)
+		matched, policy = matchGroupPolicy(ctxMap, appID, r.URL.RawQuery, models.ChkPointURLQuery, "", true, trace)
 		if matched == true {
 			return matched, policy
 		}
@@ -123,7 +139,7 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 			for _, filesHeader := range r.MultipartForm.File {
 				for _, fileHeader := range filesHeader {
 					fileExtension := filepath.Ext(fileHeader.Filename) // .php
-					matched, policy = IsMatchGroupPolicy(ctxMap, appID, fileExtension, models.ChkPointUploadFileExt, "", false)
+					matched, policy = matchGroupPolicy(ctxMap, appID, fileExtension, models.ChkPointUploadFileExt, "", false, trace)
 					if matched == true {
 						return matched, policy
 					}
@@ -140,7 +156,9 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 				}
 				partContent, err := ioutil.ReadAll(p)
 				//fmt.Println("part_content=", string(part_content))
-				matched, policy = IsMatchGroupPolicy(ctxMap, appID, string(partContent), models.ChkPointGetPostValue, "", true)
+				setValueTarget(ctxMap, models.ExclusionTargetParameter, p.FormName())
+				matched, policy = matchGroupPolicy(ctxMap, appID, string(partContent), models.ChkPointGetPostValue, "", true, trace)
+				clearValueTarget(ctxMap)
 				if matched == true {
 					return matched, policy
 				}
@@ -151,10 +169,29 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 		var params interface{}
 		err := json.Unmarshal(bodyBuf, &params)
 		utils.CheckError("IsRequestHitPolicy Unmarshal", err)
-		matched, policy := IsJSONValueHitPolicy(ctxMap, appID, params)
+		matched, policy := isJSONValueHitPolicy(ctxMap, appID, params, trace)
+		clearValueTarget(ctxMap)
+		if matched == true {
+			return matched, policy
+		}
+		// GraphQL over JSON
+		matched, policy = isGraphQLRequestHitPolicy(ctxMap, appID, params, trace)
+		if matched == true {
+			return matched, policy
+		}
+	} else if strings.HasPrefix(mediaType, "application/graphql") {
+		matched, policy := isGraphQLDocumentHitPolicy(ctxMap, appID, string(bodyBuf), trace)
+		if matched == true {
+			return matched, policy
+		}
+	} else if IsXMLMediaType(mediaType) {
+		// XML and SOAP
+		matched, policy := isXMLValueHitPolicy(ctxMap, appID, bodyBuf, trace)
 		if matched == true {
 			return matched, policy
 		}
+		// The parameters of the query string
+		r.ParseForm()
 	} else {
 		r.ParseForm()
 	}
@@ -165,8 +202,9 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 	r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBuf))
 	for key, values := range params {
 		//fmt.Println("IsRequestHitPolicy param", key, ":", values)
+		setValueTarget(ctxMap, models.ExclusionTargetParameter, key)
 		// ChkPoint_GetPostKey
-		matched, policy = IsMatchGroupPolicy(ctxMap, appID, key, models.ChkPointGetPostKey, "", false)
+		matched, policy = matchGroupPolicy(ctxMap, appID, key, models.ChkPointGetPostKey, "", false, trace)
 		if matched == true {
 			return matched, policy
 		}
@@ -179,12 +217,12 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 			}
 			// ChkPoint_ValueLength
 			valueLength := strconv.Itoa(len(value))
-			matched, policy = IsMatchGroupPolicy(ctxMap, appID, valueLength, models.ChkPointValueLength, "", false)
+			matched, policy = matchGroupPolicy(ctxMap, appID, valueLength, models.ChkPointValueLength, "", false, trace)
 			if matched == true {
 				return matched, policy
 			}
 			// ChkPoint_GetPostValue
-			matched, policy = IsMatchGroupPolicy(ctxMap, appID, value, models.ChkPointGetPostValue, "", true)
+			matched, policy = matchGroupPolicy(ctxMap, appID, value, models.ChkPointGetPostValue, "", true, trace)
 			//fmt.Println("ChkPoint_GetPostValue:", value2, matched)
 			if matched == true {
 				return matched, policy
@@ -196,36 +234,40 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 	// ChkPoint_Cookie
 	cookies := r.Cookies()
 	for _, cookie := range cookies {
+		setValueTarget(ctxMap, models.ExclusionTargetCookie, cookie.Name)
 		// ChkPoint_CookieKey
-		matched, policy = IsMatchGroupPolicy(ctxMap, appID, cookie.Name, models.ChkPointCookieKey, "", false)
+		matched, policy = matchGroupPolicy(ctxMap, appID, cookie.Name, models.ChkPointCookieKey, "", false, trace)
 		if matched == true {
 			return matched, policy
 		}
 		// ChkPoint_CookieValue
 		//value := UnEscapeRawValue(cookie.Value)
 		//fmt.Println("CookieValue:", value)
-		matched, policy = IsMatchGroupPolicy(ctxMap, appID, cookie.Value, models.ChkPointCookieValue, "", true)
+		matched, policy = matchGroupPolicy(ctxMap, appID, cookie.Value, models.ChkPointCookieValue, "", true, trace)
 		if matched == true {
 			return matched, policy
 		}
 	}
 
 	// ChkPoint_UserAgent
-	matched, policy = IsMatchGroupPolicy(ctxMap, appID, r.UserAgent(), models.ChkPointUserAgent, "", false)
+	setValueTarget(ctxMap, models.ExclusionTargetHeader, "User-Agent")
+	matched, policy = matchGroupPolicy(ctxMap, appID, r.UserAgent(), models.ChkPointUserAgent, "", false, trace)
+	clearValueTarget(ctxMap)
 	if matched == true {
 		return matched, policy
 	}
 
 	// ChkPoint_ContentType media_type
-	matched, policy = IsMatchGroupPolicy(ctxMap, appID, mediaType, models.ChkPointContentType, "", false)
+	matched, policy = matchGroupPolicy(ctxMap, appID, mediaType, models.ChkPointContentType, "", false, trace)
 	if matched == true {
 		return matched, policy
 	}
 
 	// ChkPoint_Header
 	for headerKey, headerValues := range r.Header {
+		setValueTarget(ctxMap, models.ExclusionTargetHeader, headerKey)
 		// ChkPoint_HeaderKey
-		matched, policy = IsMatchGroupPolicy(ctxMap, appID, headerKey, models.ChkPointHeaderKey, "", false)
+		matched, policy = matchGroupPolicy(ctxMap, appID, headerKey, models.ChkPointHeaderKey, "", false, trace)
 		if matched == true {
 			return matched, policy
 		}
@@ -233,7 +275,7 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 		for _, headerValue := range headerValues {
 
 			//headerValue = UnEscapeRawValue(headerValue)
-			matched, policy = IsMatchGroupPolicy(ctxMap, appID, headerValue, models.ChkPointHeaderValue, headerKey, false)
+			matched, policy = matchGroupPolicy(ctxMap, appID, headerValue, models.ChkPointHeaderValue, headerKey, false, trace)
 			//fmt.Println("ChkPoint_HeaderValue", headerKey, headerValue, matched)
 			if matched == true {
 				return matched, policy
@@ -241,8 +283,10 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 		}
 	}
 
+	clearValueTarget(ctxMap)
+
 	// ChkPoint_Proto
-	matched, policy = IsMatchGroupPolicy(ctxMap, appID, r.Proto, models.ChkPointUserAgent, "", false)
+	matched, policy = matchGroupPolicy(ctxMap, appID, r.Proto, models.ChkPointProto, "", false, trace)
 	if matched == true {
 		return matched, policy
 	}
@@ -250,8 +294,8 @@ func IsRequestHitPolicy(r *http.Request, appID int64, srcIP string) (bool, *mode
 	return false, nil
 }
 
-// IsResponseHitPolicy ...
-func IsResponseHitPolicy(resp *http.Response, appID int64) (bool, *models.GroupPolicy) {
+// IsResponseHitPolicy check the response, only the first inspectBytes of the decompressed textual body are inspected
+func IsResponseHitPolicy(resp *http.Response, appID int64, inspectBytes int64) (bool, *models.GroupPolicy) {
 	if resp.StatusCode == http.StatusSwitchingProtocols {
 		return false, nil
 	}
@@ -259,6 +303,7 @@ func IsResponseHitPolicy(resp *http.Response, appID int64) (bool, *models.GroupP
 		return false, nil
 	}
 	ctxMap := resp.Request.Context().Value("groupPolicyHitValue").(*sync.Map)
+	ctxMap.Store(requestKey, resp.Request)
 	// ChkPoint_ResponseStatusCode
 	matched, policy := IsMatchGroupPolicy(ctxMap, appID, strconv.Itoa(resp.StatusCode), models.ChkPointResponseStatusCode, "", false)
 	//fmt.Println("IsResponseHitPolicy ResponseStatusCode", matched)
@@ -289,14 +334,15 @@ func IsResponseHitPolicy(resp *http.Response, appID int64) (bool, *models.GroupP
 		return matched, policy
 	}
 	// ChkPoint_ResponseBody
-	bodyBuf, _ := ioutil.ReadAll(resp.Body)
-	resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBuf))
-	defer resp.Body.Close()
-	body1 := string(bodyBuf)
-	matched, policy = IsMatchGroupPolicy(ctxMap, appID, body1, models.ChkPointResponseBody, "", false)
-	//fmt.Println("IsResponseHitPolicy ChkPoint_ResponseBody", matched)
-	if matched == true {
-		return matched, policy
+	if _, ok := checkPointRuleEngineMap.Load(models.ChkPointResponseBody); !ok || !IsTextualContentType(resp.Header.Get("Content-Type")) {
+		return false, nil
+	}
+	if bodyBuf, ok := peekResponseBody(resp, inspectBytes); ok {
+		matched, policy = IsMatchGroupPolicy(ctxMap, appID, string(bodyBuf), models.ChkPointResponseBody, "", false)
+		//fmt.Println("IsResponseHitPolicy ChkPoint_ResponseBody", matched)
+		if matched == true {
+			return matched, policy
+		}
 	}
 
 	// Not hit any policy
@@ -305,6 +351,11 @@ func IsResponseHitPolicy(resp *http.Response, appID int64) (bool, *models.GroupP
 
 // IsJSONValueHitPolicy ...
 func IsJSONValueHitPolicy(ctxMap *sync.Map, appID int64, value interface{}) (bool, *models.GroupPolicy) {
+	return isJSONValueHitPolicy(ctxMap, appID, value, nil)
+}
+
+// isJSONValueHitPolicy collect the matches in the trace if not nil
+func isJSONValueHitPolicy(ctxMap *sync.Map, appID int64, value interface{}, trace *matchTrace) (bool, *models.GroupPolicy) {
 	if value == nil {
 		return false, nil
 	}
@@ -312,14 +363,16 @@ func IsJSONValueHitPolicy(ctxMap *sync.Map, appID int64, value interface{}) (boo
 	switch valueKind {
 	case reflect.String:
 		value2 := value.(string)
-		matched, policy := IsMatchGroupPolicy(ctxMap, appID, value2, models.ChkPointGetPostValue, "", true)
+		matched, policy := matchGroupPolicy(ctxMap, appID, value2, models.ChkPointGetPostValue, "", true, trace)
 		if matched == true {
 			return matched, policy
 		}
 	case reflect.Map:
 		value2 := value.(map[string]interface{})
-		for _, subValue := range value2 {
-			matched, policy := IsJSONValueHitPolicy(ctxMap, appID, subValue)
+		for key, subValue := range value2 {
+			// The values of nested objects and arrays are targeted by the nearest key
+			setValueTarget(ctxMap, models.ExclusionTargetParameter, key)
+			matched, policy := isJSONValueHitPolicy(ctxMap, appID, subValue, trace)
 			if matched == true {
 				return matched, policy
 			}
@@ -327,7 +380,7 @@ func IsJSONValueHitPolicy(ctxMap *sync.Map, appID int64, value interface{}) (boo
 	case reflect.Slice:
 		value2 := value.([]interface{})
 		for _, subValue := range value2 {
-			matched, policy := IsJSONValueHitPolicy(ctxMap, appID, subValue)
+			matched, policy := isJSONValueHitPolicy(ctxMap, appID, subValue, trace)
 			if matched == true {
 				return matched, policy
 			}
@@ -0,0 +1,79 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:30:39
+ * @Last Modified: thonsun, 2026-10-19 14:30:39
+ */
+
+package firewall
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"asec/models"
+)
+
+func TestRequestProtoCheckPoint(t *testing.T) {
+	// The protocol is checked by the ChkPointProto policies only, not the User-Agent ones
+	policies := []*models.GroupPolicy{
+		{ID: 1, VulnID: 100, Action: models.Action_Block_100, IsEnabled: true},
+		{ID: 2, VulnID: 100, Action: models.Action_Block_100, IsEnabled: true},
+	}
+	checkItems := []*models.CheckItem{
+		{ID: 1, CheckPoint: models.ChkPointProto, Operation: models.OperationRegexMatch, RegexPolicy: `^HTTP/1\.0$`, GroupPolicy: policies[0]},
+		{ID: 2, CheckPoint: models.ChkPointUserAgent, Operation: models.OperationRegexMatch, RegexPolicy: `^HTTP/`, GroupPolicy: policies[1]},
+	}
+	defer installTestCheckItems(t, checkItems)()
+
+	cases := []struct {
+		proto     string
+		userAgent string
+		expected  int64
+	}{
+		{"HTTP/1.0", "Mozilla/5.0", 1},
+		{"HTTP/1.1", "Mozilla/5.0", 0},
+		{"HTTP/1.1", "HTTP/1.1 client", 2},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Proto = c.proto
+		r.Header.Set("User-Agent", c.userAgent)
+		r = r.WithContext(context.WithValue(r.Context(), "groupPolicyHitValue", &sync.Map{}))
+		var id int64
+		if matched, policy := IsRequestHitPolicy(r, 0, "127.0.0.1"); matched {
+			id = policy.ID
+		}
+		if id != c.expected {
+			t.Fatalf("%s %s hits %d, expected %d", c.proto, c.userAgent, id, c.expected)
+		}
+	}
+}
+
+func TestGetEnforcedAction(t *testing.T) {
+	cases := []struct {
+		monitorMode     bool
+		action          models.PolicyAction
+		expectedAction  models.PolicyAction
+		expectedMonitor bool
+	}{
+		{false, models.Action_Block_100, models.Action_Block_100, false},
+		{false, models.Action_BypassAndLog_200, models.Action_BypassAndLog_200, false},
+		{false, models.Action_CAPTCHA_300, models.Action_CAPTCHA_300, false},
+		{false, models.Action_Pass_400, models.Action_Pass_400, false},
+		// Monitor mode logs the hits without enforcing them
+		{true, models.Action_Block_100, models.Action_BypassAndLog_200, true},
+		{true, models.Action_BypassAndLog_200, models.Action_BypassAndLog_200, true},
+		{true, models.Action_CAPTCHA_300, models.Action_BypassAndLog_200, true},
+		{true, models.Action_Pass_400, models.Action_Pass_400, false},
+	}
+	for _, c := range cases {
+		app := &models.Application{ID: 1, MonitorMode: c.monitorMode}
+		action, monitor := GetEnforcedAction(app, c.action)
+		if action != c.expectedAction || monitor != c.expectedMonitor {
+			t.Errorf("monitor mode %v, action %d: got %d %v, expected %d %v", c.monitorMode, c.action, action, monitor, c.expectedAction, c.expectedMonitor)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:47:15
+ * @Last Modified: thonsun, 2026-10-19 14:47:15
+ */
+
+package firewall
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+// geoIPReloadSeconds is the interval of checking the modification of GeoIP databases
+const geoIPReloadSeconds = 60
+
+type geoIPDatabase struct {
+	path    string
+	modTime time.Time
+	reader  *mmdbReader
+	// records (data offset uint, *models.GeoIPRecord), IPs of the same network share one record
+	records sync.Map
+}
+
+var (
+	geoIPMutex     sync.RWMutex
+	geoIPDatabases []*geoIPDatabase
+	geoIPOnce      sync.Once
+)
+
+// InitGeoIP load the databases of config, and reload them when the files are modified
+func InitGeoIP() {
+	geoIPOnce.Do(func() {
+		if data.CFG == nil || len(data.CFG.GeoIP.Databases) == 0 {
+			return
+		}
+		ReloadGeoIPDatabases()
+		go RoutineGeoIPReloadTick()
+	})
+}
+
+// ReloadGeoIPDatabases reload the modified databases, the old one is kept if the new file is invalid
+func ReloadGeoIPDatabases() {
+	geoIPMutex.RLock()
+	oldDatabases := geoIPDatabases
+	geoIPMutex.RUnlock()
+	oldDatabaseMap := map[string]*geoIPDatabase{}
+	for _, oldDatabase := range oldDatabases {
+		oldDatabaseMap[oldDatabase.path] = oldDatabase
+	}
+	var databases []*geoIPDatabase
+	modified := false
+	for _, path := range data.CFG.GeoIP.Databases {
+		oldDatabase := oldDatabaseMap[path]
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			utils.CheckError("ReloadGeoIPDatabases Stat", err)
+			if oldDatabase != nil {
+				databases = append(databases, oldDatabase)
+			}
+			continue
+		}
+		if oldDatabase != nil && oldDatabase.modTime.Equal(fileInfo.ModTime()) {
+			databases = append(databases, oldDatabase)
+			continue
+		}
+		database, err := loadGeoIPDatabase(path, fileInfo.ModTime())
+		if err != nil {
+			utils.CheckError("ReloadGeoIPDatabases "+path, err)
+			if oldDatabase != nil {
+				databases = append(databases, oldDatabase)
+			}
+			continue
+		}
+		utils.DebugPrintln("GeoIP database loaded", path)
+		databases = append(databases, database)
+		modified = true
+	}
+	if modified || len(databases) != len(oldDatabases) {
+		geoIPMutex.Lock()
+		geoIPDatabases = databases
+		geoIPMutex.Unlock()
+	}
+}
+
+func loadGeoIPDatabase(path string, modTime time.Time) (*geoIPDatabase, error) {
+	buffer, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := newMMDBReader(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return &geoIPDatabase{path: path, modTime: modTime, reader: reader}, nil
+}
+
+// RoutineGeoIPReloadTick hot reload the GeoIP databases
+func RoutineGeoIPReloadTick() {
+	routineTicker := time.NewTicker(geoIPReloadSeconds * time.Second)
+	for range routineTicker.C {
+		ReloadGeoIPDatabases()
+	}
+}
+
+// parseGeoIPRecord take the country of GeoLite2-Country/City (or the registered country) and the ASN of GeoLite2-ASN
+func parseGeoIPRecord(value interface{}) *models.GeoIPRecord {
+	record := &models.GeoIPRecord{}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return record
+	}
+	for _, key := range []string{"country", "registered_country"} {
+		if country, ok := fields[key].(map[string]interface{}); ok {
+			if isoCode, ok := country["iso_code"].(string); ok && len(isoCode) > 0 {
+				record.Country = strings.ToUpper(isoCode)
+				break
+			}
+		}
+	}
+	if asn, ok := fields["autonomous_system_number"].(uint64); ok {
+		record.ASN = int64(asn)
+	}
+	record.ASOrg, _ = fields["autonomous_system_organization"].(string)
+	return record
+}
+
+func (database *geoIPDatabase) lookup(ip net.IP) (*models.GeoIPRecord, error) {
+	offset, found, err := database.reader.lookupOffset(ip)
+	if err != nil || !found {
+		return nil, err
+	}
+	if record, ok := database.records.Load(offset); ok {
+		return record.(*models.GeoIPRecord), nil
+	}
+	value, _, err := database.reader.decode(offset, 0)
+	if err != nil {
+		return nil, err
+	}
+	record := parseGeoIPRecord(value)
+	database.records.Store(offset, record)
+	return record, nil
+}
+
+// LookupGeoIP return the country and ASN of IP, the fields are empty if not found
+func LookupGeoIP(ip string) *models.GeoIPRecord {
+	result := &models.GeoIPRecord{}
+	netIP := net.ParseIP(ip)
+	if netIP == nil {
+		return result
+	}
+	geoIPMutex.RLock()
+	databases := geoIPDatabases
+	geoIPMutex.RUnlock()
+	for _, database := range databases {
+		record, err := database.lookup(netIP)
+		if err != nil {
+			utils.CheckError("LookupGeoIP "+database.path, err)
+			continue
+		}
+		if record == nil {
+			continue
+		}
+		if len(result.Country) == 0 {
+			result.Country = record.Country
+		}
+		if result.ASN == 0 {
+			result.ASN, result.ASOrg = record.ASN, record.ASOrg
+		}
+	}
+	return result
+}
+
+// IsGeoIPLoaded return true if any GeoIP database is loaded
+func IsGeoIPLoaded() bool {
+	geoIPMutex.RLock()
+	defer geoIPMutex.RUnlock()
+	return len(geoIPDatabases) > 0
+}
+
+// LookupGeoIPAPI API, param: {"ip": "8.8.8.8"}
+func LookupGeoIPAPI(param map[string]interface{}) (*models.GeoIPRecord, error) {
+	ip, _ := param["ip"].(string)
+	if net.ParseIP(ip) == nil {
+		return nil, errors.New("Invalid IP " + ip)
+	}
+	return LookupGeoIP(ip), nil
+}
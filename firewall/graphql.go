@@ -0,0 +1,690 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:53:10
+ * @Last Modified: thonsun, 2026-10-19 14:53:10
+ */
+
+package firewall
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"asec/models"
+)
+
+const (
+	// maxGraphQLParseDepth of nested selection sets and values, deeper documents are reported with this depth
+	maxGraphQLParseDepth = 128
+	// maxGraphQLValues of argument values to be inspected
+	maxGraphQLValues = 10000
+	// maxGraphQLCount saturate the field and alias counts expanded by fragments
+	maxGraphQLCount = 1 << 30
+)
+
+var errGraphQLTooDeep = errors.New("GraphQL document is nested too deeply")
+
+// graphQLToken kinds: p punctuator, n name, 1 number, s string
+type graphQLToken struct {
+	kind  byte
+	value string
+}
+
+type graphQLSelection struct {
+	alias string
+	name  string
+	// spread is the name of fragment spread
+	spread     string
+	selections []*graphQLSelection
+}
+
+type graphQLDocument struct {
+	operations [][]*graphQLSelection
+	fragments  map[string][]*graphQLSelection
+	// fieldNames are the unique names of fields, e.g. __schema
+	fieldNames []string
+	// values are the string literals of arguments, variable defaults and directives
+	values []string
+}
+
+// graphQLStats of an operation, the fragment spreads are expanded
+type graphQLStats struct {
+	depth   int64
+	fields  int64
+	aliases int64
+}
+
+type graphQLParser struct {
+	input      string
+	pos        int
+	token      graphQLToken
+	depth      int
+	document   *graphQLDocument
+	fieldNames map[string]bool
+}
+
+// parseGraphQL parse the executable document (operations and fragments), the type system definitions are not supported
+func parseGraphQL(input string) (*graphQLDocument, error) {
+	parser := &graphQLParser{
+		input:      input,
+		document:   &graphQLDocument{fragments: map[string][]*graphQLSelection{}},
+		fieldNames: map[string]bool{},
+	}
+	if err := parser.advance(); err != nil {
+		return nil, err
+	}
+	if parser.token.kind == 0 {
+		return nil, errors.New("Empty GraphQL document")
+	}
+	for parser.token.kind != 0 {
+		if err := parser.parseDefinition(); err != nil {
+			return nil, err
+		}
+	}
+	return parser.document, nil
+}
+
+// advance read the next token, the commas, spaces and comments are ignored
+func (parser *graphQLParser) advance() error {
+	input := parser.input
+	for parser.pos < len(input) {
+		c := input[parser.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			parser.pos++
+		} else if c == '#' {
+			for parser.pos < len(input) && input[parser.pos] != '\n' && input[parser.pos] != '\r' {
+				parser.pos++
+			}
+		} else if strings.HasPrefix(input[parser.pos:], "\uFEFF") {
+			parser.pos += len("\uFEFF")
+		} else {
+			break
+		}
+	}
+	if parser.pos >= len(input) {
+		parser.token = graphQLToken{}
+		return nil
+	}
+	start := parser.pos
+	c := input[start]
+	switch {
+	case strings.IndexByte("!$&()=:@[]{}|", c) >= 0:
+		parser.pos++
+		parser.token = graphQLToken{kind: 'p', value: input[start:parser.pos]}
+	case strings.HasPrefix(input[start:], "..."):
+		parser.pos += 3
+		parser.token = graphQLToken{kind: 'p', value: "..."}
+	case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		parser.pos++
+		for parser.pos < len(input) && isGraphQLNameByte(input[parser.pos]) {
+			parser.pos++
+		}
+		parser.token = graphQLToken{kind: 'n', value: input[start:parser.pos]}
+	case c == '-' || '0' <= c && c <= '9':
+		parser.pos++
+		for parser.pos < len(input) && strings.IndexByte("0123456789.eE+-", input[parser.pos]) >= 0 {
+			parser.pos++
+		}
+		parser.token = graphQLToken{kind: '1', value: input[start:parser.pos]}
+	case c == '"':
+		value, err := parser.readString()
+		if err != nil {
+			return err
+		}
+		parser.token = graphQLToken{kind: 's', value: value}
+	default:
+		return errors.New("Unexpected character in GraphQL document at " + strconv.Itoa(start))
+	}
+	return nil
+}
+
+func isGraphQLNameByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// readString read the string or block string at pos, and return the value with the escapes decoded
+func (parser *graphQLParser) readString() (string, error) {
+	input := parser.input
+	if strings.HasPrefix(input[parser.pos:], `"""`) {
+		start := parser.pos + 3
+		for i := start; i < len(input); i++ {
+			if strings.HasPrefix(input[i:], `\"""`) {
+				i += 3
+				continue
+			}
+			if strings.HasPrefix(input[i:], `"""`) {
+				parser.pos = i + 3
+				return strings.Replace(input[start:i], `\"""`, `"""`, -1), nil
+			}
+		}
+		return "", errors.New("Unterminated GraphQL block string")
+	}
+	var value strings.Builder
+	for i := parser.pos + 1; i < len(input); i++ {
+		c := input[i]
+		switch c {
+		case '"':
+			parser.pos = i + 1
+			return value.String(), nil
+		case '\n', '\r':
+			return "", errors.New("Unterminated GraphQL string")
+		case '\\':
+			if i+1 >= len(input) {
+				return "", errors.New("Unterminated GraphQL string")
+			}
+			i++
+			switch input[i] {
+			case 'b':
+				value.WriteByte('\b')
+			case 'f':
+				value.WriteByte('\f')
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 't':
+				value.WriteByte('\t')
+			case 'u':
+				if i+4 >= len(input) {
+					return "", errors.New("Invalid GraphQL unicode escape")
+				}
+				code, err := strconv.ParseUint(input[i+1:i+5], 16, 32)
+				if err != nil {
+					return "", errors.New("Invalid GraphQL unicode escape")
+				}
+				var b [utf8.UTFMax]byte
+				value.Write(b[:utf8.EncodeRune(b[:], rune(code))])
+				i += 4
+			default:
+				// \" \\ \/
+				value.WriteByte(input[i])
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", errors.New("Unterminated GraphQL string")
+}
+
+func (parser *graphQLParser) isPunctuator(value string) bool {
+	return parser.token.kind == 'p' && parser.token.value == value
+}
+
+func (parser *graphQLParser) expectPunctuator(value string) error {
+	if !parser.isPunctuator(value) {
+		return errors.New("Expected " + value + " in GraphQL document at " + strconv.Itoa(parser.pos))
+	}
+	return parser.advance()
+}
+
+func (parser *graphQLParser) expectName() (string, error) {
+	if parser.token.kind != 'n' {
+		return "", errors.New("Expected name in GraphQL document at " + strconv.Itoa(parser.pos))
+	}
+	name := parser.token.value
+	return name, parser.advance()
+}
+
+// enter and leave the nested selection sets and values
+func (parser *graphQLParser) enter() error {
+	parser.depth++
+	if parser.depth > maxGraphQLParseDepth {
+		return errGraphQLTooDeep
+	}
+	return nil
+}
+
+func (parser *graphQLParser) leave() {
+	parser.depth--
+}
+
+func (parser *graphQLParser) parseDefinition() error {
+	if parser.isPunctuator("{") {
+		// query shorthand
+		selections, err := parser.parseSelectionSet()
+		if err != nil {
+			return err
+		}
+		parser.document.operations = append(parser.document.operations, selections)
+		return nil
+	}
+	if parser.token.kind != 'n' {
+		return errors.New("Expected definition in GraphQL document at " + strconv.Itoa(parser.pos))
+	}
+	switch parser.token.value {
+	case "query", "mutation", "subscription":
+		if err := parser.advance(); err != nil {
+			return err
+		}
+		if parser.token.kind == 'n' {
+			// operation name
+			if err := parser.advance(); err != nil {
+				return err
+			}
+		}
+		if parser.isPunctuator("(") {
+			if err := parser.parseVariableDefinitions(); err != nil {
+				return err
+			}
+		}
+		if err := parser.parseDirectives(); err != nil {
+			return err
+		}
+		selections, err := parser.parseSelectionSet()
+		if err != nil {
+			return err
+		}
+		parser.document.operations = append(parser.document.operations, selections)
+	case "fragment":
+		if err := parser.advance(); err != nil {
+			return err
+		}
+		name, err := parser.expectName()
+		if err != nil {
+			return err
+		}
+		if on, err := parser.expectName(); err != nil || on != "on" {
+			return errors.New("Expected on of fragment " + name)
+		}
+		if _, err = parser.expectName(); err != nil {
+			return err
+		}
+		if err = parser.parseDirectives(); err != nil {
+			return err
+		}
+		selections, err := parser.parseSelectionSet()
+		if err != nil {
+			return err
+		}
+		parser.document.fragments[name] = selections
+	default:
+		return errors.New("Unsupported GraphQL definition " + parser.token.value)
+	}
+	return nil
+}
+
+// parseVariableDefinitions ($id: ID! = 1, $names: [String] @directive)
+func (parser *graphQLParser) parseVariableDefinitions() error {
+	if err := parser.expectPunctuator("("); err != nil {
+		return err
+	}
+	for !parser.isPunctuator(")") {
+		if err := parser.expectPunctuator("$"); err != nil {
+			return err
+		}
+		if _, err := parser.expectName(); err != nil {
+			return err
+		}
+		if err := parser.expectPunctuator(":"); err != nil {
+			return err
+		}
+		if err := parser.parseType(); err != nil {
+			return err
+		}
+		if parser.isPunctuator("=") {
+			if err := parser.advance(); err != nil {
+				return err
+			}
+			if err := parser.parseValue(); err != nil {
+				return err
+			}
+		}
+		if err := parser.parseDirectives(); err != nil {
+			return err
+		}
+	}
+	return parser.advance()
+}
+
+func (parser *graphQLParser) parseType() error {
+	if err := parser.enter(); err != nil {
+		return err
+	}
+	defer parser.leave()
+	if parser.isPunctuator("[") {
+		if err := parser.advance(); err != nil {
+			return err
+		}
+		if err := parser.parseType(); err != nil {
+			return err
+		}
+		if err := parser.expectPunctuator("]"); err != nil {
+			return err
+		}
+	} else if _, err := parser.expectName(); err != nil {
+		return err
+	}
+	if parser.isPunctuator("!") {
+		return parser.advance()
+	}
+	return nil
+}
+
+func (parser *graphQLParser) parseDirectives() error {
+	for parser.isPunctuator("@") {
+		if err := parser.advance(); err != nil {
+			return err
+		}
+		if _, err := parser.expectName(); err != nil {
+			return err
+		}
+		if parser.isPunctuator("(") {
+			if err := parser.parseArguments(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseArguments (name: value, ...)
+func (parser *graphQLParser) parseArguments() error {
+	if err := parser.expectPunctuator("("); err != nil {
+		return err
+	}
+	for !parser.isPunctuator(")") {
+		if _, err := parser.expectName(); err != nil {
+			return err
+		}
+		if err := parser.expectPunctuator(":"); err != nil {
+			return err
+		}
+		if err := parser.parseValue(); err != nil {
+			return err
+		}
+	}
+	return parser.advance()
+}
+
+// parseValue collect the string literals of value: $variable, number, string, true/false/null/enum, list or object
+func (parser *graphQLParser) parseValue() error {
+	if err := parser.enter(); err != nil {
+		return err
+	}
+	defer parser.leave()
+	token := parser.token
+	switch {
+	case token.kind == 's':
+		if len(parser.document.values) < maxGraphQLValues {
+			parser.document.values = append(parser.document.values, token.value)
+		}
+		return parser.advance()
+	case token.kind == 'n' || token.kind == '1':
+		return parser.advance()
+	case parser.isPunctuator("$"):
+		if err := parser.advance(); err != nil {
+			return err
+		}
+		_, err := parser.expectName()
+		return err
+	case parser.isPunctuator("["):
+		if err := parser.advance(); err != nil {
+			return err
+		}
+		for !parser.isPunctuator("]") {
+			if err := parser.parseValue(); err != nil {
+				return err
+			}
+		}
+		return parser.advance()
+	case parser.isPunctuator("{"):
+		if err := parser.advance(); err != nil {
+			return err
+		}
+		for !parser.isPunctuator("}") {
+			if _, err := parser.expectName(); err != nil {
+				return err
+			}
+			if err := parser.expectPunctuator(":"); err != nil {
+				return err
+			}
+			if err := parser.parseValue(); err != nil {
+				return err
+			}
+		}
+		return parser.advance()
+	}
+	return errors.New("Expected value in GraphQL document at " + strconv.Itoa(parser.pos))
+}
+
+// parseSelectionSet { alias: field(args) @directive { ... }, ...Fragment, ... on Type { ... } }
+func (parser *graphQLParser) parseSelectionSet() ([]*graphQLSelection, error) {
+	if err := parser.enter(); err != nil {
+		return nil, err
+	}
+	defer parser.leave()
+	if err := parser.expectPunctuator("{"); err != nil {
+		return nil, err
+	}
+	var selections []*graphQLSelection
+	for !parser.isPunctuator("}") {
+		selection, err := parser.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	if len(selections) == 0 {
+		return nil, errors.New("Empty GraphQL selection set")
+	}
+	return selections, parser.advance()
+}
+
+func (parser *graphQLParser) parseSelection() (*graphQLSelection, error) {
+	selection := &graphQLSelection{}
+	var err error
+	if parser.isPunctuator("...") {
+		if err = parser.advance(); err != nil {
+			return nil, err
+		}
+		if parser.token.kind == 'n' && parser.token.value != "on" {
+			selection.spread = parser.token.value
+			if err = parser.advance(); err != nil {
+				return nil, err
+			}
+			return selection, parser.parseDirectives()
+		}
+		// inline fragment, the selections are merged into the parent
+		if parser.token.kind == 'n' {
+			if err = parser.advance(); err != nil {
+				return nil, err
+			}
+			if _, err = parser.expectName(); err != nil {
+				return nil, err
+			}
+		}
+		if err = parser.parseDirectives(); err != nil {
+			return nil, err
+		}
+		selection.selections, err = parser.parseSelectionSet()
+		return selection, err
+	}
+	if selection.name, err = parser.expectName(); err != nil {
+		return nil, err
+	}
+	if parser.isPunctuator(":") {
+		if err = parser.advance(); err != nil {
+			return nil, err
+		}
+		selection.alias = selection.name
+		if selection.name, err = parser.expectName(); err != nil {
+			return nil, err
+		}
+	}
+	if !parser.fieldNames[selection.name] {
+		parser.fieldNames[selection.name] = true
+		parser.document.fieldNames = append(parser.document.fieldNames, selection.name)
+	}
+	if parser.isPunctuator("(") {
+		if err = parser.parseArguments(); err != nil {
+			return nil, err
+		}
+	}
+	if err = parser.parseDirectives(); err != nil {
+		return nil, err
+	}
+	if parser.isPunctuator("{") {
+		selection.selections, err = parser.parseSelectionSet()
+	}
+	return selection, err
+}
+
+func addGraphQLCount(a int64, b int64) int64 {
+	if a+b > maxGraphQLCount {
+		return maxGraphQLCount
+	}
+	return a + b
+}
+
+// selectionsStats return the stats of selections, the stats of fragments are cached in fragmentStats,
+// the spreads of cyclic fragments (invalid) are ignored.
+func (document *graphQLDocument) selectionsStats(selections []*graphQLSelection, fragmentStats map[string]*graphQLStats) *graphQLStats {
+	stats := &graphQLStats{}
+	for _, selection := range selections {
+		var subStats *graphQLStats
+		var subDepth int64
+		if len(selection.spread) > 0 {
+			var ok bool
+			if subStats, ok = fragmentStats[selection.spread]; !ok {
+				fragment, exists := document.fragments[selection.spread]
+				if !exists {
+					continue
+				}
+				// placeholder for the cycles
+				fragmentStats[selection.spread] = &graphQLStats{}
+				subStats = document.selectionsStats(fragment, fragmentStats)
+				fragmentStats[selection.spread] = subStats
+			}
+			subDepth = subStats.depth
+		} else if len(selection.name) == 0 {
+			// inline fragment
+			subStats = document.selectionsStats(selection.selections, fragmentStats)
+			subDepth = subStats.depth
+		} else {
+			subStats = document.selectionsStats(selection.selections, fragmentStats)
+			subStats.fields = addGraphQLCount(subStats.fields, 1)
+			if len(selection.alias) > 0 {
+				subStats.aliases = addGraphQLCount(subStats.aliases, 1)
+			}
+			subDepth = subStats.depth + 1
+		}
+		if subDepth > stats.depth {
+			stats.depth = subDepth
+		}
+		stats.fields = addGraphQLCount(stats.fields, subStats.fields)
+		stats.aliases = addGraphQLCount(stats.aliases, subStats.aliases)
+	}
+	return stats
+}
+
+// stats of the document, the max depth and the total fields and aliases of all operations
+func (document *graphQLDocument) stats() *graphQLStats {
+	stats := &graphQLStats{}
+	fragmentStats := map[string]*graphQLStats{}
+	for _, operation := range document.operations {
+		operationStats := document.selectionsStats(operation, fragmentStats)
+		if operationStats.depth > stats.depth {
+			stats.depth = operationStats.depth
+		}
+		stats.fields = addGraphQLCount(stats.fields, operationStats.fields)
+		stats.aliases = addGraphQLCount(stats.aliases, operationStats.aliases)
+	}
+	return stats
+}
+
+// IsGraphQLRequestHitPolicy check the JSON body of GraphQL request {"query": "..."} or the batch [{"query": "..."}, ...],
+// other JSON bodies are ignored.
+func IsGraphQLRequestHitPolicy(ctxMap *sync.Map, appID int64, params interface{}) (bool, *models.GroupPolicy) {
+	return isGraphQLRequestHitPolicy(ctxMap, appID, params, nil)
+}
+
+// isGraphQLRequestHitPolicy collect the matches in the trace if not nil
+func isGraphQLRequestHitPolicy(ctxMap *sync.Map, appID int64, params interface{}, trace *matchTrace) (bool, *models.GroupPolicy) {
+	var queries []string
+	switch params := params.(type) {
+	case map[string]interface{}:
+		if query, ok := params["query"].(string); ok {
+			queries = append(queries, query)
+		}
+	case []interface{}:
+		for _, item := range params {
+			request, ok := item.(map[string]interface{})
+			if !ok {
+				return false, nil
+			}
+			query, ok := request["query"].(string)
+			if !ok {
+				return false, nil
+			}
+			queries = append(queries, query)
+		}
+	}
+	if len(queries) == 0 {
+		return false, nil
+	}
+	// ChkPoint_GraphQLBatch
+	matched, policy := matchGroupPolicy(ctxMap, appID, strconv.Itoa(len(queries)), models.ChkPointGraphQLBatch, "", false, trace)
+	if matched == true {
+		return matched, policy
+	}
+	for _, query := range queries {
+		matched, policy = isGraphQLDocumentHitPolicy(ctxMap, appID, query, trace)
+		if matched == true {
+			return matched, policy
+		}
+	}
+	return false, nil
+}
+
+// IsGraphQLDocumentHitPolicy check the stats, field names and argument values of GraphQL document,
+// the document which is not GraphQL is ignored.
+func IsGraphQLDocumentHitPolicy(ctxMap *sync.Map, appID int64, query string) (bool, *models.GroupPolicy) {
+	return isGraphQLDocumentHitPolicy(ctxMap, appID, query, nil)
+}
+
+// isGraphQLDocumentHitPolicy collect the matches in the trace if not nil
+func isGraphQLDocumentHitPolicy(ctxMap *sync.Map, appID int64, query string, trace *matchTrace) (bool, *models.GroupPolicy) {
+	document, err := parseGraphQL(query)
+	if err == errGraphQLTooDeep {
+		// ChkPoint_GraphQLDepth
+		return matchGroupPolicy(ctxMap, appID, strconv.Itoa(maxGraphQLParseDepth+1), models.ChkPointGraphQLDepth, "", false, trace)
+	}
+	if err != nil {
+		return false, nil
+	}
+	stats := document.stats()
+	// ChkPoint_GraphQLDepth
+	matched, policy := matchGroupPolicy(ctxMap, appID, strconv.FormatInt(stats.depth, 10), models.ChkPointGraphQLDepth, "", false, trace)
+	if matched == true {
+		return matched, policy
+	}
+	// ChkPoint_GraphQLAliases
+	matched, policy = matchGroupPolicy(ctxMap, appID, strconv.FormatInt(stats.aliases, 10), models.ChkPointGraphQLAliases, "", false, trace)
+	if matched == true {
+		return matched, policy
+	}
+	// ChkPoint_GraphQLFields
+	matched, policy = matchGroupPolicy(ctxMap, appID, strconv.FormatInt(stats.fields, 10), models.ChkPointGraphQLFields, "", false, trace)
+	if matched == true {
+		return matched, policy
+	}
+	// ChkPoint_GraphQLFieldName, e.g. __schema and __type of introspection
+	for _, fieldName := range document.fieldNames {
+		matched, policy = matchGroupPolicy(ctxMap, appID, fieldName, models.ChkPointGraphQLFieldName, "", false, trace)
+		if matched == true {
+			return matched, policy
+		}
+	}
+	// ChkPoint_GetPostValue
+	for _, value := range document.values {
+		matched, policy = matchGroupPolicy(ctxMap, appID, value, models.ChkPointGetPostValue, "", true, trace)
+		if matched == true {
+			return matched, policy
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,130 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:53:10
+ * @Last Modified: thonsun, 2026-10-19 14:53:10
+ */
+
+package firewall
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"asec/models"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected graphQLStats
+	}{
+		{`{ hero { name } }`, graphQLStats{depth: 2, fields: 2}},
+		{`query Hero($episode: Episode = JEDI, $withFriends: Boolean!) {
+			hero(episode: $episode) { name, friends @include(if: $withFriends) { name } }
+		}`, graphQLStats{depth: 3, fields: 4}},
+		// aliases and fragments are expanded
+		{`{ a: user(id: "1") { ...F } b: user(id: "2") { ...F } }
+		fragment F on User { id friends { ... on User { id } } }`, graphQLStats{depth: 3, fields: 8, aliases: 2}},
+		// cyclic fragments are ignored
+		{`{ user { ...A } } fragment A on User { id ...B } fragment B on User { name ...A }`, graphQLStats{depth: 2, fields: 3}},
+		{`mutation { login(input: {name: "admin", tags: ["a", """block "quoted" text"""]}) { token } }`, graphQLStats{depth: 2, fields: 2}},
+	}
+	for _, c := range cases {
+		document, err := parseGraphQL(c.query)
+		if err != nil {
+			t.Fatalf("%s: %v", c.query, err)
+		}
+		if stats := document.stats(); *stats != c.expected {
+			t.Fatalf("%s: stats %+v, expected %+v", c.query, *stats, c.expected)
+		}
+	}
+	document, _ := parseGraphQL(`mutation { login(input: {name: "admin", tags: ["a", """block "quoted" text"""]}) { token } }`)
+	if strings.Join(document.values, "|") != `admin|a|block "quoted" text` {
+		t.Fatalf("unexpected values %q", document.values)
+	}
+	// Fragment bombs are saturated
+	bomb := `{ ...F0 }`
+	for i := 0; i < 40; i++ {
+		bomb += " fragment F" + strconv.Itoa(i) + " on T { a: x ...F" + strconv.Itoa(i+1) + " b: x ...F" + strconv.Itoa(i+1) + " }"
+	}
+	document, err := parseGraphQL(bomb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := document.stats(); stats.fields != maxGraphQLCount || stats.aliases != maxGraphQLCount {
+		t.Fatalf("unexpected stats of fragment bomb %+v", *stats)
+	}
+	for _, query := range []string{"", "shoes", "select * from users", `{ a `, `{ }`, `{ a(x: "unterminated) }`} {
+		if _, err := parseGraphQL(query); err == nil {
+			t.Fatalf("%q should be rejected", query)
+		}
+	}
+	if _, err := parseGraphQL(strings.Repeat("{ a ", maxGraphQLParseDepth+1) + strings.Repeat("}", maxGraphQLParseDepth+1)); err != errGraphQLTooDeep {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestGraphQLRequestHitPolicy(t *testing.T) {
+	type policyItem struct {
+		groupPolicy *models.GroupPolicy
+		checkPoint  models.ChkPoint
+		operation   models.Operation
+		pattern     string
+	}
+	// Application 1 limits the depth, aliases, fields and batch size, and blocks the introspection
+	policyItems := []policyItem{
+		{&models.GroupPolicy{ID: 1, AppID: 1}, models.ChkPointGraphQLDepth, models.OperationGreaterThanInteger, "3"},
+		{&models.GroupPolicy{ID: 2, AppID: 1}, models.ChkPointGraphQLAliases, models.OperationGreaterThanInteger, "2"},
+		{&models.GroupPolicy{ID: 3, AppID: 1}, models.ChkPointGraphQLFields, models.OperationGreaterThanInteger, "10"},
+		{&models.GroupPolicy{ID: 4, AppID: 1}, models.ChkPointGraphQLBatch, models.OperationGreaterThanInteger, "2"},
+		{&models.GroupPolicy{ID: 5, AppID: 1}, models.ChkPointGraphQLFieldName, models.OperationRegexMatch, `^__(schema|type)$`},
+		{&models.GroupPolicy{ID: 6}, models.ChkPointGetPostValue, models.OperationRegexMatch, `(?i)union[\s/\*]+select`},
+	}
+	var checkItems []*models.CheckItem
+	for i, policyItem := range policyItems {
+		groupPolicy := policyItem.groupPolicy
+		groupPolicy.Action, groupPolicy.IsEnabled = models.Action_Block_100, true
+		checkItems = append(checkItems, &models.CheckItem{ID: int64(i + 1), CheckPoint: policyItem.checkPoint, Operation: policyItem.operation, RegexPolicy: policyItem.pattern, GroupPolicy: groupPolicy})
+	}
+	defer installTestCheckItems(t, checkItems)()
+
+	request := func(query string) map[string]interface{} {
+		return map[string]interface{}{"query": query, "variables": map[string]interface{}{}}
+	}
+	cases := []struct {
+		appID    int64
+		params   interface{}
+		expected int64
+	}{
+		{1, request(`{ a { b { c } } }`), 0},
+		{1, request(`{ a { b { c { d } } } }`), 1},
+		{2, request(`{ a { b { c { d } } } }`), 0},
+		{1, request(`{ x: a y: a z: a }`), 2},
+		{1, request(`{ a b c d e f g h i j k }`), 3},
+		{1, []interface{}{request(`{ a }`), request(`{ b }`), request(`{ c }`)}, 4},
+		{1, []interface{}{request(`{ a }`), request(`{ b }`)}, 0},
+		{1, request(`query IntrospectionQuery { __schema { types { name } } }`), 5},
+		{1, request(`{ __typename }`), 0},
+		{2, request(`{ user(name: "1 union select password") { id } }`), 6},
+		// Not GraphQL
+		{1, map[string]interface{}{"query": "shoes"}, 0},
+		{1, []interface{}{"a", "b", "c"}, 0},
+	}
+	for _, c := range cases {
+		var id int64
+		if matched, policy := IsGraphQLRequestHitPolicy(&sync.Map{}, c.appID, c.params); matched {
+			id = policy.ID
+		}
+		if id != c.expected {
+			t.Fatalf("app %d %v hits %d, expected %d", c.appID, c.params, id, c.expected)
+		}
+	}
+	// Too deep documents are reported with the max depth
+	deep := strings.Repeat("{ a ", maxGraphQLParseDepth+1) + strings.Repeat("}", maxGraphQLParseDepth+1)
+	if matched, policy := IsGraphQLDocumentHitPolicy(&sync.Map{}, 1, deep); !matched || policy.ID != 1 {
+		t.Fatal("too deep document should hit the depth limit")
+	}
+}
@@ -12,7 +12,6 @@ import (
 	"errors"
 	"net/http"
 	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -33,105 +32,137 @@ func InitGroupPolicy() {
 	if data.IsPrimary {
 		data.DAL.CreateTableIfNotExistsGroupPolicy()
 		data.DAL.CreateTableIfNotExistCheckItems()
+		if data.DAL.ExistColumnInTable("group_policies", "score") == false {
+			// v1.0.1+ required
+			data.DAL.ExecSQL(`alter table group_policies add column score bigint default 5`)
+		}
+		if data.DAL.SelectColumnMaxLength("check_items", "regex_policy") == 512 {
+			// v1.0.1+ required, for the long patterns imported from ModSecurity rules
+			data.DAL.ExecSQL(`ALTER TABLE check_items ALTER COLUMN regex_policy TYPE varchar(16384)`)
+		}
+		if data.DAL.ExistColumnInTable("check_items", "transforms") == false {
+			// v1.0.1+ required
+			data.DAL.ExecSQL(`alter table check_items add column transforms varchar(256) default ''`)
+		}
+		if data.DAL.ExistColumnInTable("group_policies", "scope_host") == false {
+			// v1.0.1+ required
+			data.DAL.ExecSQL(`alter table group_policies add column scope_host varchar(256) default '', add column scope_methods varchar(256) default '', add column scope_path_prefix varchar(2048) default '', add column scope_path_regex varchar(2048) default ''`)
+		}
 		existRegexPolicy := data.DAL.ExistsGroupPolicy()
 		if existRegexPolicy == false {
 			data.DAL.SetIDSeqStartWith("group_policies", 10101)
 			curTime := time.Now().Unix()
 
-			groupPolicyID, err := data.DAL.InsertGroupPolicy("Code Leakage", 0, 100, int64(models.ChkPointURLPath), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err := data.DAL.InsertGroupPolicy("Code Leakage", 0, 100, int64(models.ChkPointURLPath), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointURLPath, models.OperationRegexMatch, "", `(?i)/\.(git|svn)/`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointURLPath, models.OperationRegexMatch, "", `(?i)/\.(git|svn)/`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
 			// r.Form get nil when query use % instead for %25, so check it in url query
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("SQL Injection with Search", 0, 200, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("SQL Injection with Search", 0, 200, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)%\s+(and|or|procedure)\s+`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)%\s+(and|or|procedure)\s+`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
 			// Multiple Sentences SQL Injection  ;\s*(declare|use|drop|create|exec)\s
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("SQL Injection with Multiple Sentences", 0, 200, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("SQL Injection with Multiple Sentences", 0, 200, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i);\s*(declare|use|drop|create|exec)\s`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i);\s*(declare|use|drop|create|exec)\s`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
 			//  SQL Injection Function
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Functions", 0, 200, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Functions", 0, 200, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)(updatexml|extractvalue|ascii|ord|char|chr|count|concat|rand|floor|substr|length|len|user|database|benchmark|analyse)\s?\(`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)(updatexml|extractvalue|ascii|ord|char|chr|count|concat|rand|floor|substr|length|len|user|database|benchmark|analyse)\s?\(`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
 			//  SQL Injection Case When
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Case When", 0, 200, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Case When", 0, 200, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)\(case\s+when\s+[\w\p{L}]+=[\w\p{L}]+\s+then\s+`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)\(case\s+when\s+[\w\p{L}]+=[\w\p{L}]+\s+then\s+`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Attempt", 0, 200, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Attempt", 0, 200, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)\s+(and|or|procedure)\s+[\w\p{L}]+=[\w\p{L}]+(\s|$|--|#)`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)\s+(and|or|procedure)\s+[\w\p{L}]+=[\w\p{L}]+(\s|$|--|#)`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Attempt 2", 0, 200, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Attempt 2", 0, 200, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)\s+(and|or|rlike)\s+(select|case)\s+`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)\s+(and|or|rlike)\s+(select|case)\s+`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Attempt 3", 0, 200, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Attempt 3", 0, 200, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)\s+(and|or|rlike)\s+(if|updatexml)\(`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)\s+(and|or|rlike)\s+(if|updatexml)\(`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Comment", 0, 200, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic SQL Injection Comment", 0, 200, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)/\*(!|\x00)`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)/\*(!|\x00)`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Union SQL Injection", 0, 200, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Union SQL Injection", 0, 200, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)union[\s/\*]+select`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)union[\s/\*]+select`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Command Injection", 0, 210, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Command Injection", 0, 210, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(^|\&\s*|\|\s*)(pwd|ls|ll|whoami|id|net\s+user)$`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(^|\&\s*|\|\s*)(pwd|ls|ll|whoami|id|net\s+user)$`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Web Shell", 0, 500, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Web Shell", 0, 500, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)(eval|system|exec|execute|passthru|shell_exec|phpinfo)\(`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)(eval|system|exec|execute|passthru|shell_exec|phpinfo)\(`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Upload", 0, 510, int64(models.ChkPointUploadFileExt), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Upload", 0, 510, int64(models.ChkPointUploadFileExt), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointUploadFileExt, models.OperationRegexMatch, "", `(?i)\.(php|jsp|aspx|asp|exe|asa)`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointUploadFileExt, models.OperationRegexMatch, "", `(?i)\.(php|jsp|aspx|asp|exe|asa)`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
 			// XSS Tags
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic XSS Tags", 0, 300, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic XSS Tags", 0, 300, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)<(script|iframe)`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)<(script|iframe)`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
 			// XSS Functions
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic XSS Functions", 0, 300, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic XSS Functions", 0, 300, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)(alert|eval|prompt)\(`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)(alert|eval|prompt)\(`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
 			// XSS Event
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic XSS Event", 0, 300, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic XSS Event", 0, 300, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)(onmouseover|onerror|onload|onclick)\s*=`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `(?i)(onmouseover|onerror|onload|onclick)\s*=`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
 			// Path Traversal
-			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic Path Traversal", 0, 400, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime)
+			groupPolicyID, err = data.DAL.InsertGroupPolicy("Basic Path Traversal", 0, 400, int64(models.ChkPointURLQuery), models.Action_Block_100, true, 0, curTime, models.DefaultPolicyScore, nil)
 			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
-			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `\.\./\.\./|/etc/passwd$`, groupPolicyID)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointURLQuery, models.OperationRegexMatch, "", `\.\./\.\./|/etc/passwd$`, groupPolicyID, nil)
 			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
 
 		}
+		if data.DAL.ExistsGroupPolicyByVulnID(960) == false {
+			// v1.0.1+ required, the XXE policy checks the DOCTYPE/ENTITY declarations of XML bodies,
+			// it is added again if deleted, disable it instead.
+			groupPolicyID, err := data.DAL.InsertGroupPolicy("XML External Entity", 0, 960, int64(models.ChkPointGetPostValue), models.Action_Block_100, true, 0, time.Now().Unix(), models.DefaultPolicyScore, nil)
+			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointGetPostValue, models.OperationRegexMatch, "", `(?i)<!(DOCTYPE|ENTITY)\b`, groupPolicyID, nil)
+			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
+		}
+		if data.DAL.ExistsGroupPolicyByDescription(940, "GraphQL Introspection", int64(models.ChkPointGraphQLFieldName)) == false {
+			// v1.0.1+ required, disabled by default, enable it for the production applications,
+			// it is added again if deleted, disable it instead.
+			groupPolicyID, err := data.DAL.InsertGroupPolicy("GraphQL Introspection", 0, 940, int64(models.ChkPointGraphQLFieldName), models.Action_Block_100, false, 0, time.Now().Unix(), models.DefaultPolicyScore, nil)
+			utils.CheckError("InitGroupPolicy InsertGroupPolicy", err)
+			_, err = data.DAL.InsertCheckItem(models.ChkPointGraphQLFieldName, models.OperationRegexMatch, "", `^__(schema|type)$`, groupPolicyID, nil)
+			utils.CheckError("InitGroupPolicy InsertCheckItem", err)
+		}
 		// Load Policies
 		dbGroupPolicies = data.DAL.SelectGroupPolicies()
 		for _, dbGroupPolicy := range dbGroupPolicies {
@@ -146,7 +177,9 @@ func InitGroupPolicy() {
 				Action:      dbGroupPolicy.Action,
 				IsEnabled:   dbGroupPolicy.IsEnabled,
 				User:        user,
-				UpdateTime:  dbGroupPolicy.UpdateTime}
+				UpdateTime:  dbGroupPolicy.UpdateTime,
+				Score:       dbGroupPolicy.Score,
+				Scope:       dbGroupPolicy.Scope}
 			groupPolicies = append(groupPolicies, groupPolicy)
 		}
 	} else {
@@ -200,20 +233,47 @@ func UpdateGroupPolicy(r *http.Request, userID int64) (*models.GroupPolicy, erro
 	defer r.Body.Close()
 	utils.CheckError("UpdateGroupPolicy Decode", err)
 	curGroupPolicy := setGroupPolicyRequest.Object
-	curGroupPolicy.UpdateTime = time.Now().Unix()
 	if curGroupPolicy == nil {
 		return nil, errors.New("UpdateGroupPolicy parse body null")
 	}
+	return SaveGroupPolicy(curGroupPolicy, userID)
+}
+
+// SaveGroupPolicy insert or update the group policy and its check items
+func SaveGroupPolicy(curGroupPolicy *models.GroupPolicy, userID int64) (*models.GroupPolicy, error) {
+	curGroupPolicy.UpdateTime = time.Now().Unix()
 	checkItems := curGroupPolicy.CheckItems
+	scope, err := NormalizePolicyScope(curGroupPolicy.Scope)
+	if err != nil {
+		return nil, err
+	}
+	curGroupPolicy.Scope = scope
+	if curGroupPolicy.Action == models.Action_Mask_500 {
+		if err := ValidateMaskPolicy(curGroupPolicy); err != nil {
+			return nil, err
+		}
+	}
+	for _, checkItem := range checkItems {
+		// Reject invalid patterns before saving
+		if _, err := CompileCheckItem(checkItem); err != nil {
+			return nil, err
+		}
+		if checkItem.Operation&^models.OperationNot == models.OperationInNamedList && getNamedListByName(checkItem.RegexPolicy) == nil {
+			return nil, errors.New("Named list " + checkItem.RegexPolicy + " not found")
+		}
+	}
 	curGroupPolicy.HitValue = 0
 	for _, checkItem := range checkItems {
 		checkItem.GroupPolicy = curGroupPolicy
 		curGroupPolicy.HitValue += int64(checkItem.CheckPoint)
 	}
 	curGroupPolicy.UserID = userID
+	if curGroupPolicy.Score <= 0 {
+		curGroupPolicy.Score = models.DefaultPolicyScore
+	}
 	curTime := time.Now().Unix()
 	if curGroupPolicy.ID == 0 {
-		newID, err := data.DAL.InsertGroupPolicy(curGroupPolicy.Description, curGroupPolicy.AppID, curGroupPolicy.VulnID, curGroupPolicy.HitValue, curGroupPolicy.Action, curGroupPolicy.IsEnabled, curGroupPolicy.UserID, curTime)
+		newID, err := data.DAL.InsertGroupPolicy(curGroupPolicy.Description, curGroupPolicy.AppID, curGroupPolicy.VulnID, curGroupPolicy.HitValue, curGroupPolicy.Action, curGroupPolicy.IsEnabled, curGroupPolicy.UserID, curTime, curGroupPolicy.Score, curGroupPolicy.Scope)
 		utils.CheckError("UpdateGroupPolicy InsertGroupPolicy", err)
 		curGroupPolicy.ID = newID
 		groupPolicies = append(groupPolicies, curGroupPolicy)
@@ -221,7 +281,10 @@ func UpdateGroupPolicy(r *http.Request, userID int64) (*models.GroupPolicy, erro
 	} else {
 		groupPolicy, err := GetGroupPolicyByID(curGroupPolicy.ID)
 		utils.CheckError("UpdateGroupPolicy GetGroupPolicyByID", err)
-		err = data.DAL.UpdateGroupPolicy(curGroupPolicy.Description, curGroupPolicy.AppID, curGroupPolicy.VulnID, curGroupPolicy.HitValue, curGroupPolicy.Action, curGroupPolicy.IsEnabled, curGroupPolicy.UserID, curTime, groupPolicy.ID)
+		if err != nil {
+			return nil, err
+		}
+		err = data.DAL.UpdateGroupPolicy(curGroupPolicy.Description, curGroupPolicy.AppID, curGroupPolicy.VulnID, curGroupPolicy.HitValue, curGroupPolicy.Action, curGroupPolicy.IsEnabled, curGroupPolicy.UserID, curTime, curGroupPolicy.Score, curGroupPolicy.Scope, groupPolicy.ID)
 		groupPolicy.Description = curGroupPolicy.Description
 		groupPolicy.AppID = curGroupPolicy.AppID
 		groupPolicy.VulnID = curGroupPolicy.VulnID
@@ -230,6 +293,8 @@ func UpdateGroupPolicy(r *http.Request, userID int64) (*models.GroupPolicy, erro
 		groupPolicy.IsEnabled = curGroupPolicy.IsEnabled
 		groupPolicy.UserID = curGroupPolicy.UserID
 		groupPolicy.UpdateTime = curTime
+		groupPolicy.Score = curGroupPolicy.Score
+		groupPolicy.Scope = curGroupPolicy.Scope
 		UpdateCheckItems(groupPolicy, checkItems)
 	}
 	return curGroupPolicy, nil
@@ -237,66 +302,71 @@ func UpdateGroupPolicy(r *http.Request, userID int64) (*models.GroupPolicy, erro
 
 // IsMatchGroupPolicy ...
 func IsMatchGroupPolicy(hitValueMap *sync.Map, appID int64, value string, checkPoint models.ChkPoint, designatedKey string, needDecode bool) (bool, *models.GroupPolicy) {
+	return matchGroupPolicy(hitValueMap, appID, value, checkPoint, designatedKey, needDecode, nil)
+}
+
+// matchGroupPolicy collect the matches in the trace if not nil, the trace is passed by ReplayRequest only
+func matchGroupPolicy(hitValueMap *sync.Map, appID int64, value string, checkPoint models.ChkPoint, designatedKey string, needDecode bool, trace *matchTrace) (bool, *models.GroupPolicy) {
 	if len(value) == 0 {
 		return false, nil
 	}
-	checkItemsMap, ok := checkPointCheckItemsMap.Load(checkPoint)
+	ruleEngine, ok := checkPointRuleEngineMap.Load(checkPoint)
 	if !ok {
 		return false, nil
 	}
 	//fmt.Println("IsMatchGroupPolicy checkpoint:", check_point)
-	checkItems := checkItemsMap.([]*models.CheckItem)
-	if needDecode {
-		value = UnEscapeRawValue(value)
-	}
-	for _, checkItem := range checkItems {
+	var hitPolicy *models.GroupPolicy
+	ruleEngine.(*RuleEngine).Range(value, needDecode, func(item *CompiledCheckItem, value string) bool {
+		checkItem := item.CheckItem
 		groupPolicy := checkItem.GroupPolicy
-		if groupPolicy.IsEnabled == false {
-			continue
+		if groupPolicy.IsEnabled == false || groupPolicy.Action == models.Action_Mask_500 {
+			// The mask policies rewrite the response body, see MaskResponseBody
+			return true
+		}
+		if groupPolicy.AppID != 0 && groupPolicy.AppID != appID {
+			return true
+		}
+		if !isPolicyInScope(hitValueMap, groupPolicy) {
+			return true
 		}
-		if groupPolicy.AppID == 0 || groupPolicy.AppID == appID {
-			if len(designatedKey) > 0 && (checkItem.KeyName != designatedKey) {
-				continue
+		if len(designatedKey) > 0 && (checkItem.KeyName != designatedKey) {
+			return true
+		}
+		if item.Match(value) {
+			if exclusion := findRuleExclusion(hitValueMap, appID, groupPolicy, checkItem.CheckPoint); exclusion != nil {
+				recordExcludedHit(hitValueMap, groupPolicy, exclusion)
+				trace.add(checkItem, value, 0, exclusion.ID)
+				return true
 			}
-			matched := false
-			var err error
-			switch checkItem.Operation {
-			case models.OperationRegexMatch:
-				matched, err = regexp.MatchString(checkItem.RegexPolicy, value)
-				utils.CheckError("IsMatchGroupPolicy MatchString", err)
-			case models.OperationEqualsStringCaseInSensitive:
-				if strings.ToLower(checkItem.RegexPolicy) == strings.ToLower(value) {
-					matched = true
-				}
-			case models.OperationGreaterThanInteger:
-				policyValue, err := strconv.ParseInt(checkItem.RegexPolicy, 10, 64)
-				utils.CheckError("IsMatchGroupPolicy ParseInt", err)
-				checkValue, err := strconv.ParseInt(value, 10, 64)
-				utils.CheckError("IsMatchGroupPolicy ParseInt", err)
-				if checkValue > policyValue {
-					matched = true
-				}
-			case models.OperationEqualsInteger:
-				policyValue, err := strconv.ParseInt(checkItem.RegexPolicy, 10, 64)
-				utils.CheckError("IsMatchGroupPolicy ParseInt", err)
-				checkValue, err := strconv.ParseInt(value, 10, 64)
-				utils.CheckError("IsMatchGroupPolicy ParseInt", err)
-				if checkValue == policyValue {
-					matched = true
-				}
+			hitValueInterface, _ := hitValueMap.LoadOrStore(groupPolicy.ID, int64(0))
+			hitValue := hitValueInterface.(int64)
+			if trace.evaluatingAll() && hitValue == groupPolicy.HitValue {
+				// The policy has been hit in the replay, see ReplayRequest
+				trace.add(checkItem, value, hitValue, 0)
+				return true
 			}
-			if matched == true {
-				hitValueInterface, _ := hitValueMap.LoadOrStore(groupPolicy.ID, int64(0))
-				hitValue := hitValueInterface.(int64)
-				hitValue += int64(checkItem.CheckPoint)
-				if hitValue == groupPolicy.HitValue {
-					return matched, groupPolicy
+			hitValue += int64(checkItem.CheckPoint)
+			trace.add(checkItem, value, hitValue, 0)
+			if hitValue == groupPolicy.HitValue {
+				// In scoring mode, the score is added once and the remaining policies are still checked
+				collector, scoring := hitValueMap.Load(anomalyScoreKey)
+				if scoring && groupPolicy.Action != models.Action_Pass_400 {
+					collector.(*anomalyScoreCollector).add(groupPolicy)
+					hitValueMap.Store(groupPolicy.ID, hitValue)
+					return true
+				}
+				if trace.evaluatingAll() {
+					hitValueMap.Store(groupPolicy.ID, hitValue)
+					return true
 				}
-				hitValueMap.Store(groupPolicy.ID, hitValue)
+				hitPolicy = groupPolicy
+				return false
 			}
+			hitValueMap.Store(groupPolicy.ID, hitValue)
 		}
-	}
-	return false, nil
+		return true
+	})
+	return hitPolicy != nil, hitPolicy
 }
 
 // PreProcessString ...
@@ -313,16 +383,34 @@ func IsMatch(pattern string, str string) (bool, error) {
 	return regexp.MatchString(pattern, str)
 }
 
-// TestRegex ...
+// TestRegex object: {"pattern": "...", "payload": "...", "preprocess": true, "transforms": ["url_decode"]},
+// the transforms replace the default preprocess if not empty, and the value after each step is returned.
 func TestRegex(param map[string]interface{}) (*models.RegexMatch, error) {
 	obj := param["object"].(map[string]interface{})
 	pattern := obj["pattern"].(string)
 	payload := obj["payload"].(string)
 	preprocess := obj["preprocess"].(bool)
-	if preprocess {
+	transforms := []string{}
+	if transformsInterface, ok := obj["transforms"].([]interface{}); ok {
+		for _, transform := range transformsInterface {
+			if transformStr, ok := transform.(string); ok {
+				transforms = append(transforms, transformStr)
+			}
+		}
+	}
+	steps := []*models.TransformStep{}
+	if len(transforms) > 0 {
+		var err error
+		steps, err = ApplyTransforms(payload, transforms)
+		if err != nil {
+			return nil, err
+		}
+		payload = steps[len(steps)-1].Value
+	} else if preprocess {
 		payload = UnEscapeRawValue(payload)
+		steps = append(steps, &models.TransformStep{Transform: "preprocess", Value: payload})
 	}
 	matched, err := IsMatch(pattern, payload)
-	regexMatch := &models.RegexMatch{Pattern: pattern, Payload: payload, Matched: matched, PreProcess: preprocess}
+	regexMatch := &models.RegexMatch{Pattern: pattern, Payload: payload, Matched: matched, PreProcess: preprocess, Transforms: transforms, Steps: steps}
 	return regexMatch, err
 }
@@ -24,7 +24,11 @@ func InitFirewall() {
 	})
 	InitVulnType()
 	InitGroupPolicy()
+	InitNamedLists()
 	LoadCheckItems()
+	InitGeoIP()
+	InitIPACLs()
+	InitRuleExclusions()
 	InitHitLog()
 	InitNFTables()
 	go RoutineCleanLogTick()
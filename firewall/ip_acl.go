@@ -0,0 +1,247 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:47:15
+ * @Last Modified: thonsun, 2026-10-19 14:47:15
+ */
+
+package firewall
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+var (
+	ipACLs []*models.IPACL
+	// ipACLMap (appID int64, []*compiledIPACL), the allow entries are before the deny entries
+	ipACLMap sync.Map
+)
+
+type compiledIPACL struct {
+	ipACL     *models.IPACL
+	ips       *ipSet
+	countries map[string]bool
+	asns      map[int64]bool
+}
+
+func compileIPACL(ipACL *models.IPACL) (*compiledIPACL, error) {
+	if ipACL.Action != models.Action_Block_100 && ipACL.Action != models.Action_Pass_400 {
+		return nil, errors.New("Action of IP ACL should be 100 (deny) or 400 (allow)")
+	}
+	if len(ipACL.IPs) == 0 && len(ipACL.Countries) == 0 && len(ipACL.ASNs) == 0 {
+		return nil, errors.New("IPs, countries or ASNs are required")
+	}
+	acl := &compiledIPACL{ipACL: ipACL, countries: map[string]bool{}, asns: map[int64]bool{}}
+	if len(ipACL.IPs) > 0 {
+		var err error
+		acl.ips, err = parseIPSet(ipACL.IPs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, country := range ipACL.Countries {
+		if len(country) != 2 {
+			return nil, errors.New("Invalid country code " + country)
+		}
+		acl.countries[strings.ToUpper(country)] = true
+	}
+	for _, asn := range ipACL.ASNs {
+		if asn <= 0 {
+			return nil, errors.New("Invalid ASN " + strconv.FormatInt(asn, 10))
+		}
+		acl.asns[asn] = true
+	}
+	return acl, nil
+}
+
+// match the client, geoIP is looked up only if required.
+// A negated entry does not match the client whose country and ASN are both unknown, e.g. not found in GeoIP databases.
+func (acl *compiledIPACL) match(clientIP string, geoIP func() *models.GeoIPRecord) bool {
+	matched := acl.ips != nil && acl.ips.Contains(clientIP)
+	if !matched && (len(acl.countries) > 0 || len(acl.asns) > 0) {
+		record := geoIP()
+		matched = acl.countries[record.Country] || acl.asns[record.ASN]
+		known := (len(acl.countries) > 0 && len(record.Country) > 0) || (len(acl.asns) > 0 && record.ASN > 0)
+		if !known && acl.ipACL.Negated {
+			return false
+		}
+	}
+	return matched != acl.ipACL.Negated
+}
+
+// InitIPACLs ...
+func InitIPACLs() {
+	if data.IsPrimary {
+		data.DAL.CreateTableIfNotExistsIPACLs()
+		ipACLs, _ = data.DAL.SelectIPACLs()
+	} else {
+		ipACLs = RPCSelectIPACLs()
+	}
+	buildIPACLMap()
+}
+
+func buildIPACLMap() {
+	aclsMap := map[int64][]*compiledIPACL{}
+	for _, action := range []models.PolicyAction{models.Action_Pass_400, models.Action_Block_100} {
+		for _, ipACL := range ipACLs {
+			if ipACL.Action != action {
+				continue
+			}
+			acl, err := compileIPACL(ipACL)
+			if err != nil {
+				utils.CheckError("InitIPACLs "+strconv.FormatInt(ipACL.ID, 10), err)
+				continue
+			}
+			aclsMap[ipACL.AppID] = append(aclsMap[ipACL.AppID], acl)
+		}
+	}
+	ipACLMap.Range(func(key, value interface{}) bool {
+		if _, ok := aclsMap[key.(int64)]; !ok {
+			ipACLMap.Delete(key)
+		}
+		return true
+	})
+	for appID, acls := range aclsMap {
+		ipACLMap.Store(appID, acls)
+	}
+}
+
+// IsIPACLHit return the first matched IP ACL of the application and then the global ones, nil if none matched.
+// The allow entries of a scope are evaluated before its deny entries.
+func IsIPACLHit(appID int64, clientIP string) *models.IPACL {
+	var record *models.GeoIPRecord
+	geoIP := func() *models.GeoIPRecord {
+		if record == nil {
+			record = LookupGeoIP(clientIP)
+		}
+		return record
+	}
+	scopes := []int64{appID, 0}
+	if appID == 0 {
+		scopes = scopes[:1]
+	}
+	for _, scope := range scopes {
+		acls, ok := ipACLMap.Load(scope)
+		if !ok {
+			continue
+		}
+		for _, acl := range acls.([]*compiledIPACL) {
+			if acl.match(clientIP, geoIP) {
+				return acl.ipACL
+			}
+		}
+	}
+	return nil
+}
+
+// IsIPACLAllowed return true if the client is allowed by an IP ACL, and skip CC and the request checks of group policies
+func IsIPACLAllowed(appID int64, clientIP string) bool {
+	ipACL := IsIPACLHit(appID, clientIP)
+	return ipACL != nil && ipACL.Action == models.Action_Pass_400
+}
+
+// GetIPACLs ...
+func GetIPACLs() ([]*models.IPACL, error) {
+	return ipACLs, nil
+}
+
+// UpdateIPACL API, object: {"id": 0, "app_id": 0, "action": 100, "ips": ["10.0.0.0/8"], "countries": ["CN"], "asns": [13335], "negated": false, "description": ""}
+func UpdateIPACL(param map[string]interface{}) (*models.IPACL, error) {
+	obj, ok := param["object"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("object is required")
+	}
+	newIPACL := &models.IPACL{IPs: []string{}, Countries: []string{}, ASNs: []int64{}, UpdateTime: time.Now().Unix()}
+	if id, ok := obj["id"].(float64); ok {
+		newIPACL.ID = int64(id)
+	}
+	if appID, ok := obj["app_id"].(float64); ok {
+		newIPACL.AppID = int64(appID)
+	}
+	if action, ok := obj["action"].(float64); ok {
+		newIPACL.Action = models.PolicyAction(action)
+	}
+	if ips, ok := obj["ips"].([]interface{}); ok {
+		for _, ipInterface := range ips {
+			ip, _ := ipInterface.(string)
+			if ip = strings.TrimSpace(ip); len(ip) > 0 {
+				newIPACL.IPs = append(newIPACL.IPs, ip)
+			}
+		}
+	}
+	if countries, ok := obj["countries"].([]interface{}); ok {
+		for _, countryInterface := range countries {
+			country, _ := countryInterface.(string)
+			if country = strings.ToUpper(strings.TrimSpace(country)); len(country) > 0 {
+				newIPACL.Countries = append(newIPACL.Countries, country)
+			}
+		}
+	}
+	if asns, ok := obj["asns"].([]interface{}); ok {
+		for _, asnInterface := range asns {
+			asn, _ := asnInterface.(float64)
+			newIPACL.ASNs = append(newIPACL.ASNs, int64(asn))
+		}
+	}
+	newIPACL.Negated, _ = obj["negated"].(bool)
+	newIPACL.Description, _ = obj["description"].(string)
+	if _, err := compileIPACL(newIPACL); err != nil {
+		return nil, err
+	}
+	if len(strings.Join(newIPACL.Countries, ",")) > 1024 || len(newIPACL.ASNs) > 100 {
+		return nil, errors.New("Too many countries or ASNs")
+	}
+	if (len(newIPACL.Countries) > 0 || len(newIPACL.ASNs) > 0) && !IsGeoIPLoaded() {
+		return nil, errors.New("Countries and ASNs require the GeoIP databases of config")
+	}
+	var err error
+	if newIPACL.ID == 0 {
+		newIPACL.ID, err = data.DAL.InsertIPACL(newIPACL)
+		if err != nil {
+			return nil, err
+		}
+		ipACLs = append(ipACLs, newIPACL)
+	} else {
+		var ipACL *models.IPACL
+		for _, curIPACL := range ipACLs {
+			if curIPACL.ID == newIPACL.ID {
+				ipACL = curIPACL
+			}
+		}
+		if ipACL == nil {
+			return nil, errors.New("Not found")
+		}
+		if err = data.DAL.UpdateIPACL(newIPACL); err != nil {
+			return nil, err
+		}
+		*ipACL = *newIPACL
+		newIPACL = ipACL
+	}
+	buildIPACLMap()
+	data.UpdateFirewallLastModified()
+	return newIPACL, nil
+}
+
+// DeleteIPACLByID ...
+func DeleteIPACLByID(id int64) error {
+	for i, ipACL := range ipACLs {
+		if ipACL.ID == id {
+			if err := data.DAL.DeleteIPACLByID(id); err != nil {
+				return err
+			}
+			ipACLs = append(ipACLs[:i], ipACLs[i+1:]...)
+			buildIPACLMap()
+			data.UpdateFirewallLastModified()
+			return nil
+		}
+	}
+	return errors.New("Not found")
+}
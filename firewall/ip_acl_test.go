@@ -0,0 +1,233 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:47:15
+ * @Last Modified: thonsun, 2026-10-19 14:47:15
+ */
+
+package firewall
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"asec/models"
+)
+
+// mmdbControl encode the control byte, size should be less than 285
+func mmdbControl(dataType int, size int) []byte {
+	var extended []byte
+	if size >= 29 {
+		extended = []byte{byte(size - 29)}
+		size = 29
+	}
+	control := []byte{byte(dataType<<5 | size)}
+	if dataType > 7 {
+		control = []byte{byte(size), byte(dataType - 7)}
+	}
+	return append(control, extended...)
+}
+
+func mmdbString(value string) []byte {
+	return append(mmdbControl(mmdbTypeString, len(value)), value...)
+}
+
+func mmdbUint(dataType int, value uint64) []byte {
+	var b []byte
+	for ; value > 0; value >>= 8 {
+		b = append([]byte{byte(value)}, b...)
+	}
+	return append(mmdbControl(dataType, len(b)), b...)
+}
+
+// mmdbMap keys and values are encoded
+func mmdbMap(pairs ...[]byte) []byte {
+	b := mmdbControl(mmdbTypeMap, len(pairs)/2)
+	for _, pair := range pairs {
+		b = append(b, pair...)
+	}
+	return b
+}
+
+// buildTestMMDB build an IPv4 database with 24 bits records, networks are the CIDRs and the data offsets
+func buildTestMMDB(t *testing.T, networks map[string]int, dataSection []byte) []byte {
+	const empty = -1
+	nodes := [][2]int{{empty, empty}}
+	// leaves (node, bit) to data offset
+	leaves := map[[2]int]int{}
+	for cidr, offset := range networks {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := ipNet.Mask.Size()
+		ip := ipNet.IP.To4()
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+			if i == ones-1 {
+				leaves[[2]int{node, bit}] = offset
+				break
+			}
+			if nodes[node][bit] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+	nodeCount := len(nodes)
+	var buffer []byte
+	for i, node := range nodes {
+		for bit, child := range node {
+			record := nodeCount
+			if offset, ok := leaves[[2]int{i, bit}]; ok {
+				record = nodeCount + mmdbDataSectionSeparator + offset
+			} else if child != empty {
+				record = child
+			}
+			buffer = append(buffer, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+	buffer = append(buffer, make([]byte, mmdbDataSectionSeparator)...)
+	buffer = append(buffer, dataSection...)
+	buffer = append(buffer, mmdbMetadataMarker...)
+	buffer = append(buffer, mmdbMap(
+		mmdbString("node_count"), mmdbUint(mmdbTypeUint32, uint64(nodeCount)),
+		mmdbString("record_size"), mmdbUint(mmdbTypeUint16, 24),
+		mmdbString("ip_version"), mmdbUint(mmdbTypeUint16, 4),
+		mmdbString("database_type"), mmdbString("Test-Country-ASN"))...)
+	return buffer
+}
+
+func newTestGeoIPDatabase(t *testing.T) *geoIPDatabase {
+	countryUS := mmdbMap(mmdbString("iso_code"), mmdbString("us"))
+	// record 1: the country and ASN
+	record1 := mmdbMap(
+		mmdbString("country"), countryUS,
+		mmdbString("autonomous_system_number"), mmdbUint(mmdbTypeUint32, 15169),
+		mmdbString("autonomous_system_organization"), mmdbString("GOOGLE"))
+	// record 2: registered country only, the key of iso_code is a pointer to record 1
+	keyOffset := len(mmdbControl(mmdbTypeMap, 3)) + len(mmdbString("country")) + len(mmdbControl(mmdbTypeMap, 1))
+	pointer := []byte{byte(mmdbTypePointer<<5 | keyOffset>>8), byte(keyOffset)}
+	record2 := mmdbMap(
+		mmdbString("registered_country"), mmdbMap(pointer, mmdbString("CN")),
+		mmdbString("flag"), mmdbControl(mmdbTypeBool, 1))
+	dataSection := append(append([]byte{}, record1...), record2...)
+	buffer := buildTestMMDB(t, map[string]int{"8.8.8.0/24": 0, "1.2.0.0/16": len(record1)}, dataSection)
+	reader, err := newMMDBReader(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &geoIPDatabase{path: "test.mmdb", reader: reader}
+}
+
+func TestMMDBReader(t *testing.T) {
+	database := newTestGeoIPDatabase(t)
+	if database.reader.metadata["database_type"] != "Test-Country-ASN" {
+		t.Fatalf("unexpected metadata %+v", database.reader.metadata)
+	}
+	cases := map[string]models.GeoIPRecord{
+		"8.8.8.8":     {Country: "US", ASN: 15169, ASOrg: "GOOGLE"},
+		"1.2.255.1":   {Country: "CN"},
+		"8.8.9.8":     {},
+		"2001:db8::1": {},
+		"invalid":     {},
+	}
+	geoIPDatabases = []*geoIPDatabase{database}
+	defer func() { geoIPDatabases = nil }()
+	for ip, expected := range cases {
+		if record := LookupGeoIP(ip); *record != expected {
+			t.Fatalf("%s is %+v, expected %+v", ip, *record, expected)
+		}
+	}
+	// Cached by data offset
+	if _, err := database.lookup(net.ParseIP("8.8.8.1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := database.records.Load(uint(0)); !ok {
+		t.Fatal("record not cached")
+	}
+	// Truncated files are rejected
+	buffer := buildTestMMDB(t, map[string]int{"8.8.8.0/24": 0}, mmdbString("x"))
+	if _, err := newMMDBReader(buffer[:len(buffer)-3]); err == nil {
+		t.Fatal("truncated metadata accepted")
+	}
+	if _, err := newMMDBReader(bytes.Repeat([]byte{0}, 64)); err == nil {
+		t.Fatal("file without metadata accepted")
+	}
+}
+
+func TestIPACL(t *testing.T) {
+	geoIPDatabases = []*geoIPDatabase{newTestGeoIPDatabase(t)}
+	oldIPACLs := ipACLs
+	defer func() {
+		geoIPDatabases = nil
+		ipACLs = oldIPACLs
+		buildIPACLMap()
+	}()
+	ipACLs = []*models.IPACL{
+		// Global: deny US, allow the scanner of security team
+		{ID: 1, AppID: 0, Action: models.Action_Block_100, Countries: []string{"US"}},
+		{ID: 2, AppID: 0, Action: models.Action_Pass_400, IPs: []string{"8.8.8.8"}},
+		// Application 1: deny all except the office and ASN 15169
+		{ID: 3, AppID: 1, Action: models.Action_Block_100, IPs: []string{"10.0.0.0/8"}, ASNs: []int64{15169}, Negated: true},
+		// Application 3: deny all except CN
+		{ID: 4, AppID: 3, Action: models.Action_Block_100, Countries: []string{"CN"}, Negated: true},
+	}
+	buildIPACLMap()
+	cases := []struct {
+		appID    int64
+		clientIP string
+		expected int64
+	}{
+		{2, "8.8.8.8", 2},
+		{2, "8.8.8.9", 1},
+		{2, "1.2.3.4", 0},
+		{1, "10.1.1.1", 0},
+		{1, "8.8.8.9", 1},
+		{1, "8.8.8.8", 2},
+		// The ASN of 1.2.3.4 is unknown
+		{1, "1.2.3.4", 0},
+		{1, "8.8.9.8", 0},
+		{3, "1.2.3.4", 0},
+		{3, "8.8.8.9", 4},
+		{3, "8.8.9.8", 0},
+		{3, "10.1.1.1", 0},
+	}
+	for _, c := range cases {
+		var id int64
+		if ipACL := IsIPACLHit(c.appID, c.clientIP); ipACL != nil {
+			id = ipACL.ID
+		}
+		if id != c.expected {
+			t.Fatalf("app %d client %s hits %d, expected %d", c.appID, c.clientIP, id, c.expected)
+		}
+	}
+	if !IsIPACLAllowed(2, "8.8.8.8") || IsIPACLAllowed(2, "8.8.8.9") {
+		t.Fatal("unexpected allowed result")
+	}
+	// Countries and ASNs are rejected without GeoIP databases
+	geoIPDatabases = nil
+	for _, obj := range []map[string]interface{}{
+		{"action": 100.0, "countries": []interface{}{"CN"}},
+		{"action": 100.0, "asns": []interface{}{15169.0}},
+	} {
+		if _, err := UpdateIPACL(map[string]interface{}{"object": obj}); err == nil || err.Error() != "Countries and ASNs require the GeoIP databases of config" {
+			t.Fatalf("unexpected error %v of %v", err, obj)
+		}
+	}
+	invalidACLs := []*models.IPACL{
+		{Action: models.Action_CAPTCHA_300, IPs: []string{"1.1.1.1"}},
+		{Action: models.Action_Block_100},
+		{Action: models.Action_Block_100, IPs: []string{"1.1.1.300"}},
+		{Action: models.Action_Block_100, Countries: []string{"USA"}},
+		{Action: models.Action_Block_100, ASNs: []int64{-1}},
+	}
+	for _, ipACL := range invalidACLs {
+		if _, err := compileIPACL(ipACL); err == nil {
+			t.Fatalf("invalid IP ACL accepted %+v", ipACL)
+		}
+	}
+}
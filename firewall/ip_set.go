@@ -0,0 +1,67 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:35:19
+ * @Last Modified: thonsun, 2026-10-19 14:35:19
+ */
+
+package firewall
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// ipSet is a set of IPv4 and IPv6 networks, a single IP is a /32 or /128 network
+type ipSet struct {
+	nets []*net.IPNet
+}
+
+// splitListItems split the items separated by comma, space or line break
+func splitListItems(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+}
+
+func parseIPSet(items []string) (*ipSet, error) {
+	set := &ipSet{}
+	for _, item := range items {
+		if strings.Contains(item, "/") {
+			_, ipNet, err := net.ParseCIDR(item)
+			if err != nil {
+				return nil, errors.New("Invalid CIDR " + item)
+			}
+			set.nets = append(set.nets, ipNet)
+			continue
+		}
+		ip := net.ParseIP(item)
+		if ip == nil {
+			return nil, errors.New("Invalid IP " + item)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			set.nets = append(set.nets, &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)})
+		} else {
+			set.nets = append(set.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+		}
+	}
+	if len(set.nets) == 0 {
+		return nil, errors.New("No IP or CIDR")
+	}
+	return set, nil
+}
+
+// Contains return false if value is not an IP
+func (set *ipSet) Contains(value string) bool {
+	ip := net.ParseIP(strings.TrimSpace(value))
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range set.nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
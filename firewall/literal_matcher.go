@@ -0,0 +1,187 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:21:29
+ * @Last Modified: thonsun, 2026-10-19 14:21:29
+ */
+
+package firewall
+
+import (
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxPrefilterLiterals limit the alternative literals of one pattern, the prefilter is disabled if exceeded
+const maxPrefilterLiterals = 64
+
+// literalMatcher is an Aho-Corasick automaton, which search all literals in a single pass of the case folded value
+type literalMatcher struct {
+	// classes map byte to the column of next, 0 for the bytes not in any literal
+	classes  [256]uint16
+	numClass int32
+	next     []int32
+	outputs  [][]int32
+	count    int
+}
+
+func newLiteralMatcher(literals []string) *literalMatcher {
+	m := &literalMatcher{numClass: 1, count: len(literals)}
+	for _, literal := range literals {
+		for i := 0; i < len(literal); i++ {
+			if m.classes[literal[i]] == 0 {
+				m.classes[literal[i]] = uint16(m.numClass)
+				m.numClass++
+			}
+		}
+	}
+	newNode := func() int32 {
+		for c := int32(0); c < m.numClass; c++ {
+			m.next = append(m.next, -1)
+		}
+		m.outputs = append(m.outputs, nil)
+		return int32(len(m.outputs) - 1)
+	}
+	root := newNode()
+	for id, literal := range literals {
+		node := root
+		for i := 0; i < len(literal); i++ {
+			c := int32(m.classes[literal[i]])
+			if m.next[node*m.numClass+c] < 0 {
+				child := newNode()
+				m.next[node*m.numClass+c] = child
+			}
+			node = m.next[node*m.numClass+c]
+		}
+		m.outputs[node] = append(m.outputs[node], int32(id))
+	}
+	// Build failure links in BFS order, and convert the trie to a DFA
+	fail := make([]int32, len(m.outputs))
+	var queue []int32
+	for c := int32(0); c < m.numClass; c++ {
+		child := m.next[c]
+		if child < 0 {
+			m.next[c] = root
+		} else {
+			fail[child] = root
+			queue = append(queue, child)
+		}
+	}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		m.outputs[node] = append(m.outputs[node], m.outputs[fail[node]]...)
+		for c := int32(0); c < m.numClass; c++ {
+			child := m.next[node*m.numClass+c]
+			failNext := m.next[fail[node]*m.numClass+c]
+			if child < 0 {
+				m.next[node*m.numClass+c] = failNext
+			} else {
+				fail[child] = failNext
+				queue = append(queue, child)
+			}
+		}
+	}
+	return m
+}
+
+// scan set the bit of each literal contained in the case folded value
+func (m *literalMatcher) scan(value string, hits []uint64) {
+	state := int32(0)
+	var buf [utf8.UTFMax]byte
+	for i := 0; i < len(value); {
+		b := value[i]
+		if b < utf8.RuneSelf {
+			i++
+			if 'A' <= b && b <= 'Z' {
+				b += 'a' - 'A'
+			}
+			state = m.next[state*m.numClass+int32(m.classes[b])]
+			for _, id := range m.outputs[state] {
+				hits[id>>6] |= 1 << (uint(id) & 63)
+			}
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(value[i:])
+		i += size
+		n := utf8.EncodeRune(buf[:], foldRune(r))
+		for _, b := range buf[:n] {
+			state = m.next[state*m.numClass+int32(m.classes[b])]
+			for _, id := range m.outputs[state] {
+				hits[id>>6] |= 1 << (uint(id) & 63)
+			}
+		}
+	}
+}
+
+// foldRune map all runes of a case folding orbit (e.g. k, K and the Kelvin sign) to the same rune
+func foldRune(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	if 'A' <= min && min <= 'Z' {
+		min += 'a' - 'A'
+	}
+	return min
+}
+
+func foldString(value string) string {
+	return strings.Map(foldRune, value)
+}
+
+// requiredLiterals return the case folded literals, one of which is contained by any string matched by re,
+// nil if no such literals can be found, e.g. `\d+` or `a?`.
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if len(re.Rune) == 0 {
+			return nil
+		}
+		return []string{foldString(string(re.Rune))}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		// Every sub expression is required, use the most selective one
+		var best []string
+		for _, sub := range re.Sub {
+			literals := requiredLiterals(sub)
+			if literals != nil && (best == nil || shortestLength(literals) > shortestLength(best)) {
+				best = literals
+			}
+		}
+		return best
+	case syntax.OpAlternate:
+		var union []string
+		for _, sub := range re.Sub {
+			literals := requiredLiterals(sub)
+			if literals == nil {
+				return nil
+			}
+			union = append(union, literals...)
+		}
+		if len(union) > maxPrefilterLiterals {
+			return nil
+		}
+		return union
+	}
+	return nil
+}
+
+func shortestLength(literals []string) int {
+	shortest := len(literals[0])
+	for _, literal := range literals[1:] {
+		if len(literal) < shortest {
+			shortest = len(literal)
+		}
+	}
+	return shortest
+}
@@ -23,12 +23,30 @@ import (
 func InitHitLog() {
 	if data.IsPrimary {
 		data.DAL.CreateTableIfNotExistsGroupHitLog()
+		if data.DAL.ExistColumnInTable("group_hit_logs", "score") == false {
+			// v1.0.1+ required
+			data.DAL.ExecSQL(`alter table group_hit_logs add column score bigint default 0, add column policy_ids varchar(1024) default ''`)
+		}
 		data.DAL.CreateTableIfNotExistsCCLog()
+		if data.DAL.ExistColumnInTable("group_hit_logs", "monitor") == false {
+			// v1.0.1+ required
+			data.DAL.ExecSQL(`alter table group_hit_logs add column monitor boolean default false`)
+			data.DAL.ExecSQL(`alter table cc_logs add column monitor boolean default false`)
+		}
+		if data.DAL.ExistColumnInTable("group_hit_logs", "scope") == false {
+			// v1.0.1+ required
+			data.DAL.ExecSQL(`alter table group_hit_logs add column scope varchar(4096) default ''`)
+		}
+		if data.DAL.ExistColumnInTable("group_hit_logs", "excluded") == false {
+			// v1.0.1+ required
+			data.DAL.ExecSQL(`alter table group_hit_logs add column excluded boolean default false, add column exclusion_id bigint default 0`)
+		}
+		data.DAL.CreateTableIfNotExistsACLHitLog()
 	}
 }
 
-// LogCCRequest ...
-func LogCCRequest(r *http.Request, appID int64, clientIP string, policy *models.CCPolicy) {
+// LogCCRequest monitor is true if the action was not taken
+func LogCCRequest(r *http.Request, appID int64, clientIP string, policy *models.CCPolicy, monitor bool) {
 	requestTime := time.Now().Unix()
 	contentType := r.Header.Get("Content-Type")
 	cookies := r.Header.Get("Cookie")
@@ -40,7 +58,7 @@ func LogCCRequest(r *http.Request, appID int64, clientIP string, policy *models.
 	}
 	rawRequest := string(rawRequestBytes[:maxRawSize])
 	if data.IsPrimary {
-		data.DAL.InsertCCLog(requestTime, clientIP, r.Host, r.Method, r.URL.Path, r.URL.RawQuery, contentType, r.UserAgent(), cookies, rawRequest, int64(policy.Action), appID)
+		data.DAL.InsertCCLog(requestTime, clientIP, r.Host, r.Method, r.URL.Path, r.URL.RawQuery, contentType, r.UserAgent(), cookies, rawRequest, int64(policy.Action), appID, monitor)
 	} else {
 		ccLog := &models.CCLog{
 			RequestTime: requestTime,
@@ -54,13 +72,19 @@ func LogCCRequest(r *http.Request, appID int64, clientIP string, policy *models.
 			Cookies:     cookies,
 			RawRequest:  rawRequest,
 			Action:      policy.Action,
-			AppID:       appID}
+			AppID:       appID,
+			Monitor:     monitor}
 		RPCCCLog(ccLog)
 	}
 }
 
-// LogGroupHitRequest ...
-func LogGroupHitRequest(r *http.Request, appID int64, clientIP string, policy *models.GroupPolicy) {
+// LogGroupHitRequest anomalyScore is nil if the application is not in scoring mode, monitor is true if the action was not taken
+func LogGroupHitRequest(r *http.Request, appID int64, clientIP string, policy *models.GroupPolicy, anomalyScore *models.AnomalyScore, monitor bool) {
+	logGroupHitRequest(r, appID, clientIP, policy, anomalyScore, monitor, 0)
+}
+
+// logGroupHitRequest exclusionID is not 0 if the match was skipped by the rule exclusion
+func logGroupHitRequest(r *http.Request, appID int64, clientIP string, policy *models.GroupPolicy, anomalyScore *models.AnomalyScore, monitor bool, exclusionID int64) {
 	requestTime := time.Now().Unix()
 	contentType := r.Header.Get("Content-Type")
 	cookies := r.Header.Get("Cookie")
@@ -71,8 +95,15 @@ func LogGroupHitRequest(r *http.Request, appID int64, clientIP string, policy *m
 		maxRawSize = 16384
 	}
 	rawRequest := string(rawRequestBytes[:maxRawSize])
+	var score int64
+	policyIDs := []int64{}
+	if anomalyScore != nil {
+		score = anomalyScore.Total
+		policyIDs = anomalyScore.PolicyIDs
+	}
+	scope := FormatPolicyScope(policy.Scope)
 	if data.IsPrimary {
-		data.DAL.InsertGroupHitLog(requestTime, clientIP, r.Host, r.Method, r.URL.Path, r.URL.RawQuery, contentType, r.UserAgent(), cookies, rawRequest, int64(policy.Action), policy.ID, policy.VulnID, appID)
+		data.DAL.InsertGroupHitLog(requestTime, clientIP, r.Host, r.Method, r.URL.Path, r.URL.RawQuery, contentType, r.UserAgent(), cookies, rawRequest, int64(policy.Action), policy.ID, policy.VulnID, appID, score, policyIDs, monitor, scope, exclusionID)
 	} else {
 		regexHitLog := &models.GroupHitLog{
 			RequestTime: requestTime,
@@ -88,11 +119,63 @@ func LogGroupHitRequest(r *http.Request, appID int64, clientIP string, policy *m
 			Action:      policy.Action,
 			PolicyID:    policy.ID,
 			VulnID:      policy.VulnID,
-			AppID:       appID}
+			AppID:       appID,
+			Score:       score,
+			PolicyIDs:   policyIDs,
+			Monitor:     monitor,
+			Scope:       scope,
+			Excluded:    exclusionID > 0,
+			ExclusionID: exclusionID}
 		RPCGroupHitLog(regexHitLog)
 	}
 }
 
+// LogACLHitRequest monitor is true if the action was not taken
+func LogACLHitRequest(r *http.Request, appID int64, clientIP string, ipACL *models.IPACL, monitor bool) {
+	contentType := r.Header.Get("Content-Type")
+	rawRequestBytes, err := httputil.DumpRequest(r, true)
+	utils.CheckError("LogACLHitRequest DumpRequest", err)
+	maxRawSize := len(rawRequestBytes)
+	if maxRawSize > 16384 {
+		maxRawSize = 16384
+	}
+	geoIP := LookupGeoIP(clientIP)
+	aclLog := &models.ACLHitLog{
+		RequestTime: time.Now().Unix(),
+		ClientIP:    clientIP,
+		Host:        r.Host,
+		Method:      r.Method,
+		UrlPath:     r.URL.Path,
+		UrlQuery:    r.URL.RawQuery,
+		ContentType: contentType,
+		UserAgent:   r.UserAgent(),
+		Cookies:     r.Header.Get("Cookie"),
+		RawRequest:  string(rawRequestBytes[:maxRawSize]),
+		Action:      ipACL.Action,
+		ACLID:       ipACL.ID,
+		AppID:       appID,
+		Country:     geoIP.Country,
+		ASN:         geoIP.ASN,
+		Monitor:     monitor}
+	if data.IsPrimary {
+		data.DAL.InsertACLHitLog(aclLog)
+	} else {
+		RPCACLHitLog(aclLog)
+	}
+}
+
+// LogACLHitRequestAPI ...
+func LogACLHitRequestAPI(r *http.Request) error {
+	var aclLogReq models.RPCACLHitLogRequest
+	err := json.NewDecoder(r.Body).Decode(&aclLogReq)
+	defer r.Body.Close()
+	utils.CheckError("LogACLHitRequestAPI Decode", err)
+	if aclLogReq.Object == nil {
+		return errors.New("LogACLHitRequestAPI parse body null")
+	}
+	return data.DAL.InsertACLHitLog(aclLogReq.Object)
+}
+
 // LogCCRequestAPI ...
 func LogCCRequestAPI(r *http.Request) error {
 	var ccLogReq models.RPCCCLogRequest
@@ -103,7 +186,7 @@ func LogCCRequestAPI(r *http.Request) error {
 	if ccLog == nil {
 		return errors.New("LogCCRequestAPI parse body null")
 	}
-	return data.DAL.InsertCCLog(ccLog.RequestTime, ccLog.ClientIP, ccLog.Host, ccLog.Method, ccLog.UrlPath, ccLog.UrlQuery, ccLog.ContentType, ccLog.UserAgent, ccLog.Cookies, ccLog.RawRequest, int64(ccLog.Action), ccLog.AppID)
+	return data.DAL.InsertCCLog(ccLog.RequestTime, ccLog.ClientIP, ccLog.Host, ccLog.Method, ccLog.UrlPath, ccLog.UrlQuery, ccLog.ContentType, ccLog.UserAgent, ccLog.Cookies, ccLog.RawRequest, int64(ccLog.Action), ccLog.AppID, ccLog.Monitor)
 }
 
 // LogGroupHitRequestAPI ...
@@ -116,7 +199,7 @@ func LogGroupHitRequestAPI(r *http.Request) error {
 	if regexHitLog == nil {
 		return errors.New("LogGroupHitRequestAPI parse body null")
 	}
-	return data.DAL.InsertGroupHitLog(regexHitLog.RequestTime, regexHitLog.ClientIP, regexHitLog.Host, regexHitLog.Method, regexHitLog.UrlPath, regexHitLog.UrlQuery, regexHitLog.ContentType, regexHitLog.UserAgent, regexHitLog.Cookies, regexHitLog.RawRequest, int64(regexHitLog.Action), regexHitLog.PolicyID, regexHitLog.VulnID, regexHitLog.AppID)
+	return data.DAL.InsertGroupHitLog(regexHitLog.RequestTime, regexHitLog.ClientIP, regexHitLog.Host, regexHitLog.Method, regexHitLog.UrlPath, regexHitLog.UrlQuery, regexHitLog.ContentType, regexHitLog.UserAgent, regexHitLog.Cookies, regexHitLog.RawRequest, int64(regexHitLog.Action), regexHitLog.PolicyID, regexHitLog.VulnID, regexHitLog.AppID, regexHitLog.Score, regexHitLog.PolicyIDs, regexHitLog.Monitor, regexHitLog.Scope, regexHitLog.ExclusionID)
 }
 
 // GetCCLogCount ...
@@ -135,6 +218,20 @@ func GetGroupLogCount(param map[string]interface{}) (*models.HitLogsCount, error
 	startTime := int64(param["start_time"].(float64))
 	endTime := int64(param["end_time"].(float64))
 	count, err := data.DAL.SelectGroupHitLogsCount(appID, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	excludedCount, err := data.DAL.SelectExcludedGroupHitLogsCount(appID, startTime, endTime)
+	logsCount := &models.HitLogsCount{AppID: appID, StartTime: startTime, EndTime: endTime, Count: count, ExcludedCount: excludedCount}
+	return logsCount, err
+}
+
+// GetACLLogCount ...
+func GetACLLogCount(param map[string]interface{}) (*models.HitLogsCount, error) {
+	appID := int64(param["app_id"].(float64))
+	startTime := int64(param["start_time"].(float64))
+	endTime := int64(param["end_time"].(float64))
+	count, err := data.DAL.SelectACLHitLogsCount(appID, startTime, endTime)
 	logsCount := &models.HitLogsCount{AppID: appID, StartTime: startTime, EndTime: endTime, Count: count}
 	return logsCount, err
 }
@@ -197,6 +294,17 @@ func GetCCLogs(param map[string]interface{}) ([]*models.SimpleCCLog, error) {
 	return simpleCCLogs, nil
 }
 
+// GetACLLogs ...
+func GetACLLogs(param map[string]interface{}) ([]*models.SimpleACLHitLog, error) {
+	appID := int64(param["app_id"].(float64))
+	startTime := int64(param["start_time"].(float64))
+	endTime := int64(param["end_time"].(float64))
+	requestCount := int64(param["request_count"].(float64))
+	offset := int64(param["offset"].(float64))
+	simpleACLHitLogs := data.DAL.SelectACLHitLogs(appID, startTime, endTime, requestCount, offset)
+	return simpleACLHitLogs, nil
+}
+
 // GetGroupLogs ...
 func GetGroupLogs(param map[string]interface{}) ([]*models.SimpleGroupHitLog, error) {
 	appID := int64(param["app_id"].(float64))
@@ -219,3 +327,9 @@ func GetCCLogByID(id int64) (*models.CCLog, error) {
 	ccLog, err := data.DAL.SelectCCLogByID(id)
 	return ccLog, err
 }
+
+// GetACLLogByID ...
+func GetACLLogByID(id int64) (*models.ACLHitLog, error) {
+	aclLog, err := data.DAL.SelectACLHitLogByID(id)
+	return aclLog, err
+}
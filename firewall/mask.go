@@ -0,0 +1,274 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:58:58
+ * @Last Modified: thonsun, 2026-10-19 14:58:58
+ */
+
+package firewall
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"asec/models"
+)
+
+const (
+	// maskChunkSize is the max size of body read at a time
+	maskChunkSize = 32 * 1024
+	// maskOverlap is kept for the next chunk, so the matches across chunks (up to this length) are masked
+	maskOverlap = 256
+	// maskKeepChars of the end are kept if the masked text has at least maskKeepMinChars letters and digits
+	maskKeepChars    = 4
+	maskKeepMinChars = 8
+)
+
+// maskingBody stream the decompressed body with the matched content masked
+type maskingBody struct {
+	source  io.Reader
+	closer  io.Closer
+	items   []*CompiledCheckItem
+	pending []byte
+	output  bytes.Buffer
+	err     error
+	// masked are the policies which masked the body, onDone is called once with them at the end of body
+	masked []*models.GroupPolicy
+	onDone func(policies []*models.GroupPolicy)
+	done   bool
+}
+
+// recordingReader save the bytes read until record is set to nil
+type recordingReader struct {
+	reader io.Reader
+	record *bytes.Buffer
+}
+
+func (reader *recordingReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	if reader.record != nil {
+		reader.record.Write(p[:n])
+	}
+	return n, err
+}
+
+// ValidateMaskPolicy the check items of mask policy should be regex, credit card or national ID of ChkPointResponseBody, without transforms
+func ValidateMaskPolicy(groupPolicy *models.GroupPolicy) error {
+	if len(groupPolicy.CheckItems) == 0 {
+		return errors.New("Check items are required by mask policy")
+	}
+	for _, checkItem := range groupPolicy.CheckItems {
+		if checkItem.CheckPoint != models.ChkPointResponseBody {
+			return errors.New("Mask policy is for the response body only")
+		}
+		switch checkItem.Operation {
+		case models.OperationRegexMatch, models.OperationDetectCreditCard, models.OperationDetectNationalID:
+		default:
+			return errors.New("Mask policy supports regex, credit card and national ID operations only")
+		}
+		if len(checkItem.Transforms) > 0 {
+			return errors.New("Transforms are not supported by mask policy")
+		}
+	}
+	return nil
+}
+
+// getMaskItems return the check items of the enabled mask policies of application and the global ones, in scope of the request
+func getMaskItems(appID int64, r *http.Request) []*CompiledCheckItem {
+	ruleEngine, ok := checkPointRuleEngineMap.Load(models.ChkPointResponseBody)
+	if !ok {
+		return nil
+	}
+	var items []*CompiledCheckItem
+	for _, item := range ruleEngine.(*RuleEngine).items {
+		groupPolicy := item.CheckItem.GroupPolicy
+		if groupPolicy.Action == models.Action_Mask_500 && groupPolicy.IsEnabled && (groupPolicy.AppID == 0 || groupPolicy.AppID == appID) && IsRequestInScope(groupPolicy.Scope, r) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// MaskResponseBody rewrite the textual response body with the mask policies, and log each policy which masked it.
+// In monitor mode, only the inspected prefix of body is checked and logged, and the body is not changed.
+func MaskResponseBody(resp *http.Response, app *models.Application, srcIP string) {
+	if resp.Request.Method == "HEAD" || resp.StatusCode == http.StatusSwitchingProtocols || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return
+	}
+	items := getMaskItems(app.ID, resp.Request)
+	if len(items) == 0 || !IsTextualContentType(resp.Header.Get("Content-Type")) {
+		return
+	}
+	r := resp.Request
+	logMasked := func(policies []*models.GroupPolicy) {
+		for _, policy := range policies {
+			go LogGroupHitRequest(r, app.ID, srcIP, policy, nil, app.MonitorMode)
+		}
+	}
+	if app.MonitorMode {
+		bodyBuf, ok := peekResponseBody(resp, ResponseInspectBytes(app))
+		if ok {
+			body := &maskingBody{items: items}
+			body.findMasks(bodyBuf)
+			logMasked(body.masked)
+		}
+		return
+	}
+	newMaskingBody(resp, items, logMasked)
+}
+
+// newMaskingBody replace the body of response, the compressed body is sent decompressed.
+// False is returned if the body can not be decompressed, and the body is not changed.
+func newMaskingBody(resp *http.Response, items []*CompiledCheckItem, onDone func(policies []*models.GroupPolicy)) bool {
+	encoding := responseEncoding(resp)
+	if !isSupportedEncoding(encoding) {
+		return false
+	}
+	original := resp.Body
+	raw := &recordingReader{reader: original, record: &bytes.Buffer{}}
+	decoder, err := newResponseDecoder(encoding, raw)
+	if err != nil {
+		resp.Body = &prefixedBody{Reader: io.MultiReader(raw.record, original), Closer: original}
+		return false
+	}
+	raw.record = nil
+	resp.Body = &maskingBody{source: decoder, closer: original, items: items, onDone: onDone}
+	if encoding != "" && encoding != "identity" {
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+	}
+	// The masked text has the same length, so the Content-Length of identity body is kept
+	return true
+}
+
+func (body *maskingBody) Read(p []byte) (int, error) {
+	for body.output.Len() == 0 {
+		if body.err != nil {
+			if len(body.pending) > 0 {
+				body.process(true)
+				continue
+			}
+			body.finish()
+			return 0, body.err
+		}
+		chunk := make([]byte, maskChunkSize)
+		n, err := body.source.Read(chunk)
+		body.pending = append(body.pending, chunk[:n]...)
+		if err != nil {
+			body.err = err
+			continue
+		}
+		body.process(false)
+	}
+	return body.output.Read(p)
+}
+
+// process mask the pending bytes, the last maskOverlap bytes are kept for the next chunk unless final
+func (body *maskingBody) process(final bool) {
+	buf := body.pending
+	cut := len(buf)
+	if !final {
+		cut -= maskOverlap
+		if cut <= 0 {
+			return
+		}
+	}
+	ranges := body.findMasks(buf)
+	for _, r := range ranges {
+		if r[0] < cut && r[1] > cut {
+			cut = r[1]
+		}
+	}
+	out := append([]byte{}, buf[:cut]...)
+	for _, r := range ranges {
+		if r[0] < cut {
+			maskBytes(out[r[0]:r[1]])
+		}
+	}
+	body.output.Write(out)
+	body.pending = append([]byte{}, buf[cut:]...)
+}
+
+// findMasks return the sorted and merged ranges of buf to be masked, and record the policies
+func (body *maskingBody) findMasks(buf []byte) [][2]int {
+	var ranges [][2]int
+	value := string(buf)
+	for _, item := range body.items {
+		itemRanges := item.findMasks(value)
+		if len(itemRanges) == 0 {
+			continue
+		}
+		ranges = append(ranges, itemRanges...)
+		policy := item.CheckItem.GroupPolicy
+		found := false
+		for _, masked := range body.masked {
+			if masked == policy {
+				found = true
+				break
+			}
+		}
+		if !found {
+			body.masked = append(body.masked, policy)
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var merged [][2]int
+	for _, r := range ranges {
+		if last := len(merged) - 1; last >= 0 && r[0] <= merged[last][1] {
+			if r[1] > merged[last][1] {
+				merged[last][1] = r[1]
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// maskBytes replace the letters and digits with '*' in place, the length is kept:
+// a multi-byte character becomes the same count of '*', e.g. 4111 1111 1111 1111 => **** **** **** 1111
+func maskBytes(b []byte) {
+	count := 0
+	for i := 0; i < len(b); {
+		c, size := utf8.DecodeRune(b[i:])
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			count++
+		}
+		i += size
+	}
+	masks := count
+	if count >= maskKeepMinChars {
+		masks = count - maskKeepChars
+	}
+	for i := 0; i < len(b) && masks > 0; {
+		c, size := utf8.DecodeRune(b[i:])
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			for j := i; j < i+size; j++ {
+				b[j] = '*'
+			}
+			masks--
+		}
+		i += size
+	}
+}
+
+func (body *maskingBody) finish() {
+	if body.done {
+		return
+	}
+	body.done = true
+	if len(body.masked) > 0 && body.onDone != nil {
+		body.onDone(body.masked)
+	}
+}
+
+func (body *maskingBody) Close() error {
+	body.finish()
+	return body.closer.Close()
+}
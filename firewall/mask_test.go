@@ -0,0 +1,135 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:58:58
+ * @Last Modified: thonsun, 2026-10-19 14:58:58
+ */
+
+package firewall
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"asec/models"
+)
+
+func TestFindSensitiveData(t *testing.T) {
+	cards := map[string]bool{
+		"4111111111111111":           true,
+		"card: 4111 1111 1111 1111.": true,
+		"378282246310005":            true,
+		"5555-5555-5555-4444":        true,
+		"4111111111111112":           false,
+		"0000000000000000":           false,
+		"41111111111111110":          false,
+		"id=A4111111111111111":       false,
+		"4111  1111 1111 1111":       false,
+	}
+	for value, expected := range cards {
+		if found := len(findCreditCards(value)) > 0; found != expected {
+			t.Fatalf("credit card %q found %v", value, found)
+		}
+	}
+	ids := map[string]bool{
+		"11010519491231002X":      true,
+		"id: 11010519491231002x,": true,
+		"110105194912310021":      false,
+		"110105194913310027":      false,
+		"11010519491231002X1":     false,
+	}
+	for value, expected := range ids {
+		if found := len(findNationalIDs(value)) > 0; found != expected {
+			t.Fatalf("national ID %q found %v", value, found)
+		}
+	}
+	masks := map[string]string{
+		"4111 1111 1111 1111": "**** **** **** 1111",
+		"13800138000":         "*******8000",
+		"admin":               "*****",
+		"张三":                  "******",
+	}
+	for value, expected := range masks {
+		b := []byte(value)
+		maskBytes(b)
+		if string(b) != expected {
+			t.Fatalf("%s is masked to %s, expected %s", value, b, expected)
+		}
+	}
+}
+
+func TestMaskingBody(t *testing.T) {
+	policies := []*models.GroupPolicy{
+		{ID: 1, Action: models.Action_Mask_500, IsEnabled: true},
+		{ID: 2, Action: models.Action_Mask_500, IsEnabled: true},
+		{ID: 3, Action: models.Action_Mask_500, IsEnabled: true},
+	}
+	checkItems := []*models.CheckItem{
+		{ID: 1, CheckPoint: models.ChkPointResponseBody, Operation: models.OperationDetectCreditCard, GroupPolicy: policies[0]},
+		{ID: 2, CheckPoint: models.ChkPointResponseBody, Operation: models.OperationRegexMatch, RegexPolicy: `"phone":"1\d{2}(\d{4})\d{4}"`, GroupPolicy: policies[1]},
+		{ID: 3, CheckPoint: models.ChkPointResponseBody, Operation: models.OperationDetectNationalID, GroupPolicy: policies[2]},
+	}
+	var items []*CompiledCheckItem
+	for i, checkItem := range checkItems {
+		policies[i].CheckItems = []*models.CheckItem{checkItem}
+		if err := ValidateMaskPolicy(policies[i]); err != nil {
+			t.Fatal(err)
+		}
+		item, err := CompileCheckItem(checkItem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+	// The card number is across the chunks
+	padding := strings.Repeat("x", maskChunkSize-maskOverlap-8)
+	body := padding + ` 4111111111111111 {"phone":"13800138000"}` + strings.Repeat("y", maskChunkSize)
+	expected := padding + ` ************1111 {"phone":"138****8000"}` + strings.Repeat("y", maskChunkSize)
+	for _, encoding := range []string{"", "gzip", "zlib", "br"} {
+		resp := &http.Response{Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader(compressTestBody(t, encoding, []byte(body)))), ContentLength: 100}
+		if encoding == "gzip" {
+			resp.Header.Set("Content-Encoding", "gzip")
+		} else if encoding == "zlib" {
+			resp.Header.Set("Content-Encoding", "deflate")
+		} else if encoding == "br" {
+			resp.Header.Set("Content-Encoding", "br")
+		}
+		var masked []*models.GroupPolicy
+		if !newMaskingBody(resp, items, func(policies []*models.GroupPolicy) { masked = policies }) {
+			t.Fatalf("%s: body is not masked", encoding)
+		}
+		result, err := ioutil.ReadAll(resp.Body)
+		if err != nil || string(result) != expected {
+			t.Fatalf("%s: unexpected body, err %v", encoding, err)
+		}
+		resp.Body.Close()
+		if len(masked) != 2 || masked[0].ID != 1 || masked[1].ID != 2 {
+			t.Fatalf("%s: unexpected masked policies %v", encoding, masked)
+		}
+		if encoding != "" && (resp.Header.Get("Content-Encoding") != "" || resp.ContentLength != -1) {
+			t.Fatalf("%s: the decompressed body should be sent without Content-Encoding", encoding)
+		}
+	}
+	// Invalid gzip body is not changed
+	resp := &http.Response{Header: http.Header{"Content-Encoding": {"gzip"}}, Body: ioutil.NopCloser(strings.NewReader("not gzip"))}
+	if newMaskingBody(resp, items, nil) {
+		t.Fatal("invalid gzip body should not be masked")
+	}
+	if result, _ := ioutil.ReadAll(resp.Body); string(result) != "not gzip" {
+		t.Fatalf("body changed to %q", result)
+	}
+	invalidPolicies := []*models.GroupPolicy{
+		{Action: models.Action_Mask_500},
+		{Action: models.Action_Mask_500, CheckItems: []*models.CheckItem{{CheckPoint: models.ChkPointGetPostValue, Operation: models.OperationRegexMatch, RegexPolicy: "a"}}},
+		{Action: models.Action_Mask_500, CheckItems: []*models.CheckItem{{CheckPoint: models.ChkPointResponseBody, Operation: models.OperationContains, RegexPolicy: "a"}}},
+		{Action: models.Action_Mask_500, CheckItems: []*models.CheckItem{{CheckPoint: models.ChkPointResponseBody, Operation: models.OperationRegexMatch, RegexPolicy: "a", Transforms: []string{"lowercase"}}}},
+	}
+	for _, policy := range invalidPolicies {
+		if err := ValidateMaskPolicy(policy); err == nil {
+			t.Fatalf("invalid mask policy accepted %+v", policy)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:47:15
+ * @Last Modified: thonsun, 2026-10-19 14:47:15
+ */
+
+package firewall
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+)
+
+// mmdbMetadataMarker is followed by the metadata at the end of a MaxMind DB file
+var mmdbMetadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const (
+	mmdbMetadataMaxSize = 128 * 1024
+	// mmdbDataSectionSeparator is the 16 zero bytes between the search tree and the data section
+	mmdbDataSectionSeparator = 16
+	// mmdbMaxDepth of nested maps, arrays and pointers
+	mmdbMaxDepth = 32
+)
+
+// Data types of MaxMind DB format
+const (
+	mmdbTypeExtended = 0
+	mmdbTypePointer  = 1
+	mmdbTypeString   = 2
+	mmdbTypeDouble   = 3
+	mmdbTypeBytes    = 4
+	mmdbTypeUint16   = 5
+	mmdbTypeUint32   = 6
+	mmdbTypeMap      = 7
+	mmdbTypeInt32    = 8
+	mmdbTypeUint64   = 9
+	mmdbTypeUint128  = 10
+	mmdbTypeArray    = 11
+	mmdbTypeBool     = 14
+	mmdbTypeFloat    = 15
+)
+
+// mmdbReader read the MaxMind DB format (GeoLite2, GeoIP2, DB-IP...) from memory,
+// see https://maxmind.github.io/MaxMind-DB/
+type mmdbReader struct {
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	tree       []byte
+	data       []byte
+	// ipv4Start is the node of ::/96 in an IPv6 tree
+	ipv4Start uint
+	metadata  map[string]interface{}
+}
+
+func newMMDBReader(buffer []byte) (*mmdbReader, error) {
+	start := 0
+	if len(buffer) > mmdbMetadataMaxSize {
+		start = len(buffer) - mmdbMetadataMaxSize
+	}
+	index := bytes.LastIndex(buffer[start:], mmdbMetadataMarker)
+	if index < 0 {
+		return nil, errors.New("Invalid MaxMind DB, metadata not found")
+	}
+	metadataStart := start + index + len(mmdbMetadataMarker)
+	metadataReader := &mmdbReader{data: buffer[metadataStart:]}
+	value, _, err := metadataReader.decode(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	metadata, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("Invalid MaxMind DB metadata")
+	}
+	reader := &mmdbReader{metadata: metadata}
+	nodeCount, ok1 := metadata["node_count"].(uint64)
+	recordSize, ok2 := metadata["record_size"].(uint64)
+	ipVersion, ok3 := metadata["ip_version"].(uint64)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, errors.New("Invalid MaxMind DB metadata, node_count, record_size and ip_version are required")
+	}
+	if recordSize != 24 && recordSize != 28 && recordSize != 32 {
+		return nil, errors.New("Unsupported record size of MaxMind DB")
+	}
+	if ipVersion != 4 && ipVersion != 6 {
+		return nil, errors.New("Unsupported IP version of MaxMind DB")
+	}
+	reader.nodeCount, reader.recordSize, reader.ipVersion = uint(nodeCount), uint(recordSize), uint(ipVersion)
+	treeSize := reader.nodeCount * reader.recordSize / 4
+	if treeSize+mmdbDataSectionSeparator > uint(start+index) {
+		return nil, errors.New("Invalid MaxMind DB, the search tree is truncated")
+	}
+	reader.tree = buffer[:treeSize]
+	reader.data = buffer[treeSize+mmdbDataSectionSeparator : start+index]
+	if reader.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < reader.nodeCount; i++ {
+			node = reader.readNode(node, 0)
+		}
+		reader.ipv4Start = node
+	}
+	return reader, nil
+}
+
+// readNode return the left (bit 0) or right (bit 1) record of node
+func (reader *mmdbReader) readNode(node uint, bit uint) uint {
+	b := reader.tree[node*reader.recordSize/4:]
+	switch reader.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// lookupOffset return the data offset of the record of IP, false if not found
+func (reader *mmdbReader) lookupOffset(ip net.IP) (uint, bool, error) {
+	node := uint(0)
+	bits := 128
+	if ipv4 := ip.To4(); ipv4 != nil {
+		ip, bits = ipv4, 32
+		if reader.ipVersion == 6 {
+			node = reader.ipv4Start
+		}
+	} else if reader.ipVersion == 4 {
+		return 0, false, nil
+	}
+	for i := 0; i < bits && node < reader.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = reader.readNode(node, bit)
+	}
+	if node == reader.nodeCount {
+		return 0, false, nil
+	}
+	if node < reader.nodeCount || node-reader.nodeCount < mmdbDataSectionSeparator {
+		return 0, false, errors.New("Invalid MaxMind DB search tree")
+	}
+	return node - reader.nodeCount - mmdbDataSectionSeparator, true, nil
+}
+
+// lookup return the record of IP, nil if not found
+func (reader *mmdbReader) lookup(ip net.IP) (interface{}, error) {
+	offset, found, err := reader.lookupOffset(ip)
+	if err != nil || !found {
+		return nil, err
+	}
+	value, _, err := reader.decode(offset, 0)
+	return value, err
+}
+
+// decodeControl return the type, size and offset of the payload
+func (reader *mmdbReader) decodeControl(offset uint) (int, uint, uint, error) {
+	if offset >= uint(len(reader.data)) {
+		return 0, 0, 0, errors.New("Invalid MaxMind DB data offset")
+	}
+	control := reader.data[offset]
+	offset++
+	dataType := int(control >> 5)
+	if dataType == mmdbTypeExtended {
+		if offset >= uint(len(reader.data)) {
+			return 0, 0, 0, errors.New("Invalid MaxMind DB extended type")
+		}
+		dataType = 7 + int(reader.data[offset])
+		offset++
+	}
+	size := uint(control & 0x1F)
+	if dataType == mmdbTypePointer || size < 29 {
+		return dataType, size, offset, nil
+	}
+	extra := size - 28
+	if offset+extra > uint(len(reader.data)) {
+		return 0, 0, 0, errors.New("Invalid MaxMind DB data size")
+	}
+	sizeBytes := reader.data[offset : offset+extra]
+	switch extra {
+	case 1:
+		size = 29 + uint(sizeBytes[0])
+	case 2:
+		size = 285 + (uint(sizeBytes[0])<<8 | uint(sizeBytes[1]))
+	default:
+		size = 65821 + (uint(sizeBytes[0])<<16 | uint(sizeBytes[1])<<8 | uint(sizeBytes[2]))
+	}
+	return dataType, size, offset + extra, nil
+}
+
+// decode the value at offset of data section, return the value and the offset after it
+func (reader *mmdbReader) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > mmdbMaxDepth {
+		return nil, 0, errors.New("MaxMind DB data is nested too deeply")
+	}
+	dataType, size, offset, err := reader.decodeControl(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if (dataType == mmdbTypeMap || dataType == mmdbTypeArray) && size > uint(len(reader.data)) {
+		return nil, 0, errors.New("Invalid MaxMind DB container size")
+	}
+	switch dataType {
+	case mmdbTypePointer:
+		pointerSize := (size >> 3) & 0x3
+		if offset+pointerSize+1 > uint(len(reader.data)) {
+			return nil, 0, errors.New("Invalid MaxMind DB pointer")
+		}
+		b := reader.data[offset : offset+pointerSize+1]
+		var pointer uint
+		switch pointerSize {
+		case 0:
+			pointer = (size&0x7)<<8 | uint(b[0])
+		case 1:
+			pointer = ((size&0x7)<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+		case 2:
+			pointer = ((size&0x7)<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+		default:
+			pointer = uint(binary.BigEndian.Uint32(b))
+		}
+		value, _, err := reader.decode(pointer, depth+1)
+		return value, offset + pointerSize + 1, err
+	case mmdbTypeMap:
+		value := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := reader.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("Invalid MaxMind DB map key")
+			}
+			value[keyString], offset, err = reader.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return value, offset, nil
+	case mmdbTypeArray:
+		value := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			var item interface{}
+			item, offset, err = reader.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			value = append(value, item)
+		}
+		return value, offset, nil
+	case mmdbTypeBool:
+		return size != 0, offset, nil
+	}
+	if offset+size > uint(len(reader.data)) {
+		return nil, 0, errors.New("Invalid MaxMind DB data size")
+	}
+	b := reader.data[offset : offset+size]
+	next := offset + size
+	switch dataType {
+	case mmdbTypeString:
+		return string(b), next, nil
+	case mmdbTypeBytes:
+		return append([]byte{}, b...), next, nil
+	case mmdbTypeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("Invalid MaxMind DB double")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case mmdbTypeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("Invalid MaxMind DB float")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), next, nil
+	case mmdbTypeUint16, mmdbTypeUint32, mmdbTypeUint64, mmdbTypeInt32:
+		if size > 8 {
+			return nil, 0, errors.New("Invalid MaxMind DB integer")
+		}
+		var value uint64
+		for _, c := range b {
+			value = value<<8 | uint64(c)
+		}
+		if dataType == mmdbTypeInt32 {
+			return int64(int32(value)), next, nil
+		}
+		return value, next, nil
+	case mmdbTypeUint128:
+		// Not used by country and ASN
+		return append([]byte{}, b...), next, nil
+	}
+	return nil, 0, errors.New("Unknown MaxMind DB data type")
+}
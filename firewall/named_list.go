@@ -0,0 +1,200 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:35:19
+ * @Last Modified: thonsun, 2026-10-19 14:35:19
+ */
+
+package firewall
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+var (
+	namedLists []*models.NamedList
+	// namedListMap (name string, *compiledNamedList), looked up when matching, so the changes of lists
+	// take effect without rebuilding the rule engines
+	namedListMap sync.Map
+)
+
+type compiledNamedList struct {
+	ips     *ipSet
+	strings map[string]bool
+}
+
+func (list *compiledNamedList) contains(value string) bool {
+	if list.ips != nil {
+		return list.ips.Contains(value)
+	}
+	return list.strings[value]
+}
+
+func compileNamedList(namedList *models.NamedList) (*compiledNamedList, error) {
+	switch namedList.ListType {
+	case models.NamedListTypeIP:
+		ips, err := parseIPSet(namedList.Items)
+		if err != nil {
+			return nil, err
+		}
+		return &compiledNamedList{ips: ips}, nil
+	case models.NamedListTypeString:
+		list := &compiledNamedList{strings: map[string]bool{}}
+		for _, item := range namedList.Items {
+			list.strings[item] = true
+		}
+		return list, nil
+	}
+	return nil, errors.New("Unknown list type")
+}
+
+// InitNamedLists should be called before loading check items
+func InitNamedLists() {
+	if data.IsPrimary {
+		data.DAL.CreateTableIfNotExistsNamedLists()
+		namedLists, _ = data.DAL.SelectNamedLists()
+	} else {
+		namedLists = RPCSelectNamedLists()
+	}
+	namedListMap.Range(func(key, value interface{}) bool {
+		namedListMap.Delete(key)
+		return true
+	})
+	for _, namedList := range namedLists {
+		list, err := compileNamedList(namedList)
+		if err != nil {
+			utils.CheckError("InitNamedLists "+namedList.Name, err)
+			continue
+		}
+		namedListMap.Store(namedList.Name, list)
+	}
+}
+
+// IsInNamedList return false if the list does not exist
+func IsInNamedList(name string, value string) bool {
+	list, ok := namedListMap.Load(name)
+	if !ok {
+		return false
+	}
+	return list.(*compiledNamedList).contains(value)
+}
+
+// GetNamedLists ...
+func GetNamedLists() ([]*models.NamedList, error) {
+	return namedLists, nil
+}
+
+func getNamedListByName(name string) *models.NamedList {
+	for _, namedList := range namedLists {
+		if namedList.Name == name {
+			return namedList
+		}
+	}
+	return nil
+}
+
+// UpdateNamedList API, object: {"id": 0, "name": "office", "list_type": 1, "items": ["10.0.0.0/8"], "description": ""}
+func UpdateNamedList(param map[string]interface{}) (*models.NamedList, error) {
+	obj, ok := param["object"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("object is required")
+	}
+	var id int64
+	if idValue, ok := obj["id"].(float64); ok {
+		id = int64(idValue)
+	}
+	name, _ := obj["name"].(string)
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || len(name) > 128 {
+		return nil, errors.New("Invalid name")
+	}
+	var listType models.NamedListType
+	if listTypeValue, ok := obj["list_type"].(float64); ok {
+		listType = models.NamedListType(listTypeValue)
+	}
+	items := []string{}
+	if itemsInterface, ok := obj["items"].([]interface{}); ok {
+		for _, itemInterface := range itemsInterface {
+			item, _ := itemInterface.(string)
+			item = strings.TrimSpace(item)
+			if len(item) > 0 && strings.Contains(item, "\n") == false {
+				items = append(items, item)
+			}
+		}
+	}
+	description, _ := obj["description"].(string)
+	newNamedList := &models.NamedList{ID: id, Name: name, ListType: listType, Items: items, Description: description, UpdateTime: time.Now().Unix()}
+	list, err := compileNamedList(newNamedList)
+	if err != nil {
+		return nil, err
+	}
+	if existList := getNamedListByName(name); existList != nil && existList.ID != id {
+		return nil, errors.New("Duplicated name " + name)
+	}
+	if id == 0 {
+		newNamedList.ID, err = data.DAL.InsertNamedList(name, listType, items, description, newNamedList.UpdateTime)
+		if err != nil {
+			return nil, err
+		}
+		namedLists = append(namedLists, newNamedList)
+	} else {
+		var namedList *models.NamedList
+		for _, curNamedList := range namedLists {
+			if curNamedList.ID == id {
+				namedList = curNamedList
+			}
+		}
+		if namedList == nil {
+			return nil, errors.New("Not found")
+		}
+		if namedList.Name != name && isNamedListReferenced(namedList.Name) {
+			return nil, errors.New("The list " + namedList.Name + " is used by group policies and can not be renamed")
+		}
+		if err = data.DAL.UpdateNamedList(name, listType, items, description, newNamedList.UpdateTime, id); err != nil {
+			return nil, err
+		}
+		namedListMap.Delete(namedList.Name)
+		*namedList = *newNamedList
+		newNamedList = namedList
+	}
+	namedListMap.Store(name, list)
+	data.UpdateFirewallLastModified()
+	return newNamedList, nil
+}
+
+// DeleteNamedListByID the lists used by group policies can not be deleted
+func DeleteNamedListByID(id int64) error {
+	for i, namedList := range namedLists {
+		if namedList.ID == id {
+			if isNamedListReferenced(namedList.Name) {
+				return errors.New("The list " + namedList.Name + " is used by group policies")
+			}
+			if err := data.DAL.DeleteNamedListByID(id); err != nil {
+				return err
+			}
+			namedListMap.Delete(namedList.Name)
+			namedLists = append(namedLists[:i], namedLists[i+1:]...)
+			data.UpdateFirewallLastModified()
+			return nil
+		}
+	}
+	return errors.New("Not found")
+}
+
+func isNamedListReferenced(name string) bool {
+	for _, groupPolicy := range groupPolicies {
+		for _, checkItem := range groupPolicy.CheckItems {
+			if checkItem.Operation&^models.OperationNot == models.OperationInNamedList && checkItem.RegexPolicy == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 15:01:37
+ * @Last Modified: thonsun, 2026-10-19 15:01:37
+ */
+
+package firewall
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"asec/models"
+)
+
+// requestKey is the key of the request in the groupPolicyHitValue map of request context, for the policy scopes
+const requestKey = "request"
+
+var (
+	// scopePathRegexMap cache the compiled path regexes of scopes, pattern => *regexp.Regexp
+	scopePathRegexMap sync.Map
+)
+
+// NormalizePolicyScope trim the fields and upper the methods, nil is returned if all fields are empty
+func NormalizePolicyScope(scope *models.PolicyScope) (*models.PolicyScope, error) {
+	if scope == nil {
+		return nil, nil
+	}
+	normalized := &models.PolicyScope{
+		Host:       strings.ToLower(strings.TrimSpace(scope.Host)),
+		Methods:    []string{},
+		PathPrefix: strings.TrimSpace(scope.PathPrefix),
+		PathRegex:  strings.TrimSpace(scope.PathRegex)}
+	for _, method := range scope.Methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if strings.ContainsAny(method, ", ") {
+			return nil, errors.New("Invalid method " + method)
+		}
+		if len(method) > 0 {
+			normalized.Methods = append(normalized.Methods, method)
+		}
+	}
+	if len(normalized.Host) == 0 && len(normalized.Methods) == 0 && len(normalized.PathPrefix) == 0 && len(normalized.PathRegex) == 0 {
+		return nil, nil
+	}
+	if len(normalized.PathRegex) > 0 {
+		if _, err := getScopePathRegex(normalized.PathRegex); err != nil {
+			return nil, err
+		}
+	}
+	return normalized, nil
+}
+
+func getScopePathRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := scopePathRegexMap.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	scopePathRegexMap.Store(pattern, re)
+	return re, nil
+}
+
+// CleanRequestPath return the URL path without dot segments and duplicate slashes, the trailing slash is kept,
+// e.g. //admin/x, /./admin/x and /a/../admin/x are all /admin/x, as the backends route them.
+func CleanRequestPath(r *http.Request) string {
+	cleanPath := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && cleanPath != "/" {
+		cleanPath += "/"
+	}
+	return cleanPath
+}
+
+// IsRequestInScope return true if the request matches all fields of scope, nil scope matches all requests.
+// The path fields are matched against the cleaned path.
+func IsRequestInScope(scope *models.PolicyScope, r *http.Request) bool {
+	if scope == nil {
+		return true
+	}
+	if r == nil {
+		return false
+	}
+	if len(scope.Host) > 0 {
+		host := strings.ToLower(r.Host)
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			if host != scope.Host && hostname != scope.Host {
+				return false
+			}
+		} else if host != scope.Host {
+			return false
+		}
+	}
+	if len(scope.Methods) > 0 {
+		found := false
+		for _, method := range scope.Methods {
+			if method == r.Method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	urlPath := CleanRequestPath(r)
+	if len(scope.PathPrefix) > 0 && !strings.HasPrefix(urlPath, scope.PathPrefix) {
+		return false
+	}
+	if len(scope.PathRegex) > 0 {
+		re, err := getScopePathRegex(scope.PathRegex)
+		if err != nil || !re.MatchString(urlPath) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPolicyInScope check the scope of policy against the request stored in the context map,
+// the scoped policies are skipped if the request is unknown.
+func isPolicyInScope(hitValueMap *sync.Map, groupPolicy *models.GroupPolicy) bool {
+	if groupPolicy.Scope == nil {
+		return true
+	}
+	r, ok := hitValueMap.Load(requestKey)
+	if !ok {
+		return false
+	}
+	return IsRequestInScope(groupPolicy.Scope, r.(*http.Request))
+}
+
+// FormatPolicyScope return the scope for logs, e.g. host=api.example.com methods=POST,PUT path_prefix=/api/ path_regex=^/api/v\d+/
+func FormatPolicyScope(scope *models.PolicyScope) string {
+	if scope == nil {
+		return ""
+	}
+	var fields []string
+	if len(scope.Host) > 0 {
+		fields = append(fields, "host="+scope.Host)
+	}
+	if len(scope.Methods) > 0 {
+		fields = append(fields, "methods="+strings.Join(scope.Methods, ","))
+	}
+	if len(scope.PathPrefix) > 0 {
+		fields = append(fields, "path_prefix="+scope.PathPrefix)
+	}
+	if len(scope.PathRegex) > 0 {
+		fields = append(fields, "path_regex="+scope.PathRegex)
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,87 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 15:01:37
+ * @Last Modified: thonsun, 2026-10-19 15:01:37
+ */
+
+package firewall
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"asec/models"
+)
+
+func TestPolicyScope(t *testing.T) {
+	scope, err := NormalizePolicyScope(&models.PolicyScope{Host: " API.example.com ", Methods: []string{"post", " put", ""}, PathPrefix: "/api/users/", PathRegex: `^/api/users/\d+/avatar$`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scope.Host != "api.example.com" || len(scope.Methods) != 2 || scope.Methods[0] != "POST" || scope.Methods[1] != "PUT" {
+		t.Fatalf("unexpected scope %+v", scope)
+	}
+	if FormatPolicyScope(scope) != `host=api.example.com methods=POST,PUT path_prefix=/api/users/ path_regex=^/api/users/\d+/avatar$` {
+		t.Fatalf("unexpected scope format %s", FormatPolicyScope(scope))
+	}
+	if empty, err := NormalizePolicyScope(&models.PolicyScope{Methods: []string{" "}}); empty != nil || err != nil {
+		t.Fatal("empty scope should be nil")
+	}
+	for _, invalid := range []*models.PolicyScope{{PathRegex: "(/api"}, {Methods: []string{"GET,POST"}}} {
+		if _, err := NormalizePolicyScope(invalid); err == nil {
+			t.Fatalf("invalid scope accepted %+v", invalid)
+		}
+	}
+
+	// Policy 1 patches the avatar upload only, policy 2 is for all requests
+	policies := []*models.GroupPolicy{
+		{ID: 1, VulnID: 200, Action: models.Action_Block_100, IsEnabled: true, Scope: scope},
+		{ID: 2, VulnID: 300, Action: models.Action_Block_100, IsEnabled: true},
+	}
+	defer installTestCheckItems(t, newTestCheckItems(policies, models.ChkPointURLQuery, []string{`(?i)select`, `(?i)<script`}))()
+
+	cases := []struct {
+		method   string
+		url      string
+		expected int64
+	}{
+		{"POST", "http://api.example.com/api/users/12/avatar?name=select", 1},
+		{"PUT", "http://api.example.com:8443/api/users/12/avatar?name=select", 1},
+		// The paths are cleaned before matching the scope
+		{"POST", "http://api.example.com//api/users/12/avatar?name=select", 1},
+		{"POST", "http://api.example.com/./api/users/12/avatar?name=select", 1},
+		{"POST", "http://api.example.com/a/../api/users/12/avatar?name=select", 1},
+		{"POST", "http://api.example.com/api/users/12/./avatar?name=select", 1},
+		{"GET", "http://api.example.com/api/users/12/avatar?name=select", 0},
+		{"POST", "http://www.example.com/api/users/12/avatar?name=select", 0},
+		{"POST", "http://api.example.com/api/users/12/profile?name=select", 0},
+		{"POST", "http://api.example.com/api/orders/12/avatar?name=select", 0},
+		// Out of scope requests are still checked by other policies
+		{"GET", "http://api.example.com/?name=<script>", 2},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.url, nil)
+		r = r.WithContext(context.WithValue(r.Context(), "groupPolicyHitValue", &sync.Map{}))
+		var id int64
+		if matched, policy := IsRequestHitPolicy(r, 0, "127.0.0.1"); matched {
+			id = policy.ID
+		}
+		if id != c.expected {
+			t.Fatalf("%s %s hits %d, expected %d", c.method, c.url, id, c.expected)
+		}
+	}
+	for rawPath, expected := range map[string]string{"": "/", "/": "/", "//admin/x": "/admin/x", "/./admin/x/": "/admin/x/", "/a/../admin/x": "/admin/x", "/../admin": "/admin"} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.URL.Path = rawPath
+		if cleanPath := CleanRequestPath(r); cleanPath != expected {
+			t.Errorf("path %q cleaned as %q, expected %q", rawPath, cleanPath, expected)
+		}
+	}
+	// The scoped policies are skipped if the request is unknown
+	if matched, _ := IsMatchGroupPolicy(&sync.Map{}, 0, "name=select", models.ChkPointURLQuery, "", true); matched {
+		t.Fatal("scoped policy should be skipped without request")
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 15:08:46
+ * @Last Modified: thonsun, 2026-10-19 15:08:46
+ */
+
+package firewall
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"asec/models"
+)
+
+// replayValueLimit is the max length of the values returned in the replay matches
+const replayValueLimit = 1024
+
+// matchTrace collects the matched check items of a replayed request
+type matchTrace struct {
+	// evaluateAll continues after a policy is hit, so the matches of all policies are collected
+	evaluateAll bool
+	matches     []*models.ReplayMatch
+}
+
+func (trace *matchTrace) evaluatingAll() bool {
+	return trace != nil && trace.evaluateAll
+}
+
+func (trace *matchTrace) add(checkItem *models.CheckItem, value string, hitValue int64, exclusionID int64) {
+	if trace == nil {
+		return
+	}
+	if len(value) > replayValueLimit {
+		value = value[:replayValueLimit]
+	}
+	trace.matches = append(trace.matches, &models.ReplayMatch{
+		CheckItemID:    checkItem.ID,
+		PolicyID:       checkItem.GroupPolicy.ID,
+		CheckPoint:     checkItem.CheckPoint,
+		Value:          value,
+		HitValue:       hitValue,
+		PolicyHitValue: checkItem.GroupPolicy.HitValue,
+		ExclusionID:    exclusionID})
+}
+
+// newReplayRequest return a copy of the request with its own body and groupPolicyHitValue map
+func newReplayRequest(r *http.Request, body []byte) (*http.Request, *sync.Map) {
+	ctxMap := &sync.Map{}
+	replayReq := r.Clone(context.WithValue(r.Context(), "groupPolicyHitValue", ctxMap))
+	replayReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+	replayReq.Form = nil
+	replayReq.PostForm = nil
+	replayReq.MultipartForm = nil
+	return replayReq, ctxMap
+}
+
+// ReplayRequest evaluate the request like the gateway without logs and other side effects, CC policies are skipped.
+// The decision is made by the IP ACLs and group policies (or anomaly score) of the application,
+// then the request is evaluated again to collect the matches of all policies.
+func ReplayRequest(r *http.Request, app *models.Application, srcIP string) *models.ReplayResult {
+	result := &models.ReplayResult{AppID: app.ID, ClientIP: srcIP, Matches: []*models.ReplayMatch{}, HitValues: map[int64]int64{}}
+	if ipACL := IsIPACLHit(app.ID, srcIP); ipACL != nil {
+		result.IPACLID = ipACL.ID
+		result.Action = ipACL.Action
+		if ipACL.Action == models.Action_Pass_400 {
+			result.Reason = "Allowed by IP ACL"
+			return result
+		}
+		result.IsHit = true
+		result.Monitor = app.MonitorMode
+		result.Reason = "Denied by IP ACL"
+		if !app.MonitorMode {
+			return result
+		}
+	}
+	if !app.WAFEnabled {
+		result.Reason = "WAF is disabled"
+		return result
+	}
+	if IsStaticResource(r) {
+		result.Reason = "Static resource"
+		return result
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+
+	decisionReq, _ := newReplayRequest(r, body)
+	var isHit bool
+	var policy *models.GroupPolicy
+	var anomalyScore *models.AnomalyScore
+	if app.ScoringEnabled {
+		isHit, policy, anomalyScore = IsRequestHitAnomalyScore(decisionReq, app, srcIP)
+	} else {
+		isHit, policy = IsRequestHitPolicy(decisionReq, app.ID, srcIP)
+	}
+	if isHit {
+		result.IsHit = true
+		result.Action = policy.Action
+		result.PolicyID = policy.ID
+		result.VulnID = policy.VulnID
+		result.Monitor = app.MonitorMode
+		result.Reason = "Hit group policy"
+		if anomalyScore != nil {
+			result.Reason = "Anomaly score reached the threshold"
+		}
+	} else if anomalyScore != nil {
+		result.Reason = "Anomaly score below the threshold"
+	} else if result.IPACLID == 0 {
+		result.Reason = "No policy hit"
+	}
+	result.AnomalyScore = anomalyScore
+
+	trace := &matchTrace{evaluateAll: true}
+	fullReq, ctxMap := newReplayRequest(r, body)
+	isRequestHitPolicy(fullReq, app.ID, srcIP, trace)
+	if trace.matches != nil {
+		result.Matches = trace.matches
+	}
+	ctxMap.Range(func(key, value interface{}) bool {
+		if policyID, ok := key.(int64); ok {
+			result.HitValues[policyID] = value.(int64)
+		}
+		return true
+	})
+	return result
+}
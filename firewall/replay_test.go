@@ -0,0 +1,80 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 15:08:46
+ * @Last Modified: thonsun, 2026-10-19 15:08:46
+ */
+
+package firewall
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"asec/models"
+)
+
+func TestReplayRequest(t *testing.T) {
+	// Policy 1 requires both the path and the value, policies 2 and 3 match the value
+	policies := []*models.GroupPolicy{
+		{ID: 1, VulnID: 200, Action: models.Action_Block_100, IsEnabled: true, Score: 3},
+		{ID: 2, VulnID: 300, Action: models.Action_BypassAndLog_200, IsEnabled: true, Score: 2},
+		{ID: 3, VulnID: 300, Action: models.Action_Block_100, IsEnabled: true, Score: 2},
+	}
+	checkItems := []*models.CheckItem{
+		{ID: 1, CheckPoint: models.ChkPointURLPath, Operation: models.OperationRegexMatch, RegexPolicy: `^/admin/`, GroupPolicy: policies[0]},
+		{ID: 2, CheckPoint: models.ChkPointGetPostValue, Operation: models.OperationRegexMatch, RegexPolicy: `(?i)select`, GroupPolicy: policies[0]},
+		{ID: 3, CheckPoint: models.ChkPointGetPostValue, Operation: models.OperationRegexMatch, RegexPolicy: `(?i)union`, GroupPolicy: policies[1]},
+		{ID: 4, CheckPoint: models.ChkPointGetPostValue, Operation: models.OperationRegexMatch, RegexPolicy: `(?i)union`, GroupPolicy: policies[2]},
+	}
+	defer installTestCheckItems(t, checkItems)()
+
+	app := &models.Application{ID: 1, WAFEnabled: true, InboundThreshold: 5}
+	r := httptest.NewRequest("GET", "/admin/users?q=union%20select", nil)
+	result := ReplayRequest(r, app, "127.0.0.1")
+	// Policy 1 is hit first, and the matches of policies 2 and 3 are still collected
+	if !result.IsHit || result.PolicyID != 1 || result.Action != models.Action_Block_100 {
+		t.Fatalf("unexpected decision %+v", result)
+	}
+	if len(result.Matches) != 4 {
+		t.Fatalf("%d matches, expected 4", len(result.Matches))
+	}
+	for _, policy := range policies {
+		if result.HitValues[policy.ID] != policy.HitValue {
+			t.Fatalf("policy %d hit value %d, expected %d", policy.ID, result.HitValues[policy.ID], policy.HitValue)
+		}
+	}
+	if result.Matches[1].Value != "union select" {
+		t.Fatalf("unexpected value %q", result.Matches[1].Value)
+	}
+
+	// In scoring mode, the policies 1 and 2 reach the threshold 5
+	app.ScoringEnabled = true
+	r = httptest.NewRequest("POST", "/admin/users", nil)
+	r.URL.RawQuery = "q=union%20select"
+	result = ReplayRequest(r, app, "127.0.0.1")
+	if !result.IsHit || result.AnomalyScore == nil || result.AnomalyScore.Total != 7 || result.Action != models.Action_Block_100 {
+		t.Fatalf("unexpected decision %+v", result)
+	}
+
+	// The partial match of policy 1 is reported without hit
+	app.ScoringEnabled = false
+	r = httptest.NewRequest("GET", "/blog?q=select", nil)
+	result = ReplayRequest(r, app, "127.0.0.1")
+	if result.IsHit || len(result.Matches) != 1 || result.HitValues[1] != int64(models.ChkPointGetPostValue) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// Monitor mode reports the decision it would take
+	app.MonitorMode = true
+	r = httptest.NewRequest("GET", "/admin/users?q=select", nil)
+	if result = ReplayRequest(r, app, "127.0.0.1"); !result.IsHit || !result.Monitor || result.Action != models.Action_Block_100 {
+		t.Fatalf("monitor mode, unexpected result %+v", result)
+	}
+	app.MonitorMode = false
+
+	app.WAFEnabled = false
+	if result = ReplayRequest(r, app, "127.0.0.1"); result.IsHit || len(result.Matches) != 0 {
+		t.Fatalf("WAF disabled, unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:54:42
+ * @Last Modified: thonsun, 2026-10-19 14:54:42
+ */
+
+package firewall
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"asec/models"
+
+	"github.com/andybalholm/brotli"
+)
+
+// prefixedBody replay the bytes read for inspection, then stream the remaining of the original body
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// IsTextualContentType return true for the media types worth inspecting: text/*, JSON, JavaScript, XML and forms
+func IsTextualContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/x-javascript", "application/ecmascript",
+		"application/xml", "application/x-www-form-urlencoded", "application/graphql":
+		return true
+	}
+	return false
+}
+
+// ResponseInspectBytes return the inspection size of the application
+func ResponseInspectBytes(app *models.Application) int64 {
+	inspectKB := app.ResponseInspectKB
+	if inspectKB <= 0 {
+		inspectKB = models.DefaultResponseInspectKB
+	}
+	if inspectKB > models.MaxResponseInspectKB {
+		inspectKB = models.MaxResponseInspectKB
+	}
+	return inspectKB * 1024
+}
+
+// peekResponseBody return the first maxBytes of the decompressed body (identity, gzip, deflate or br), and keep the body
+// unchanged for the client: the raw bytes consumed are replayed before the remaining stream.
+// False is returned for other encodings (e.g. zstd), which are not inspected.
+func peekResponseBody(resp *http.Response, maxBytes int64) ([]byte, bool) {
+	encoding := responseEncoding(resp)
+	if !isSupportedEncoding(encoding) {
+		return nil, false
+	}
+	rawBuf := &bytes.Buffer{}
+	original := resp.Body
+	resp.Body = &prefixedBody{Reader: io.MultiReader(rawBuf, original), Closer: original}
+	// All bytes consumed by the decoders are saved in rawBuf
+	decoder, err := newResponseDecoder(encoding, io.TeeReader(original, rawBuf))
+	if err != nil {
+		return nil, false
+	}
+	// The decompressed data is limited, so the decompression bombs are harmless.
+	// A broken stream is inspected as far as it is decompressed.
+	body := &bytes.Buffer{}
+	io.Copy(body, io.LimitReader(decoder, maxBytes))
+	return body.Bytes(), true
+}
+
+func responseEncoding(resp *http.Response) string {
+	return strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+}
+
+func isSupportedEncoding(encoding string) bool {
+	switch encoding {
+	case "", "identity", "gzip", "x-gzip", "deflate", "br":
+		return true
+	}
+	return false
+}
+
+// newResponseDecoder return the reader of decompressed body, the encoding should be supported
+func newResponseDecoder(encoding string, raw io.Reader) (io.Reader, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(raw)
+	case "deflate":
+		// zlib format in theory, but some servers send the raw deflate
+		bufReader := bufio.NewReader(raw)
+		header, err := bufReader.Peek(2)
+		if err != nil {
+			return nil, err
+		}
+		if header[0]&0x0F == 8 && (uint(header[0])<<8|uint(header[1]))%31 == 0 {
+			return zlib.NewReader(bufReader)
+		}
+		return flate.NewReader(bufReader), nil
+	case "br":
+		return brotli.NewReader(raw), nil
+	}
+	return raw, nil
+}
@@ -0,0 +1,100 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:54:42
+ * @Last Modified: thonsun, 2026-10-19 14:54:42
+ */
+
+package firewall
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func compressTestBody(t *testing.T, encoding string, body []byte) []byte {
+	buffer := &bytes.Buffer{}
+	var writer io.WriteCloser
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(buffer)
+	case "zlib":
+		writer = zlib.NewWriter(buffer)
+	case "flate":
+		writer, _ = flate.NewWriter(buffer, flate.DefaultCompression)
+	case "br":
+		writer = brotli.NewWriter(buffer)
+	default:
+		return body
+	}
+	if _, err := writer.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+	return buffer.Bytes()
+}
+
+func TestPeekResponseBody(t *testing.T) {
+	body := []byte("<html>" + strings.Repeat("hello world ", 10000) + "</html>")
+	cases := []struct {
+		compression string
+		encoding    string
+		inspected   bool
+	}{
+		{"", "", true},
+		{"", "identity", true},
+		{"gzip", "gzip", true},
+		{"zlib", "deflate", true},
+		{"flate", "deflate", true},
+		{"br", "br", true},
+		{"", "zstd", false},
+	}
+	for _, c := range cases {
+		raw := compressTestBody(t, c.compression, body)
+		resp := &http.Response{Header: http.Header{}, Body: ioutil.NopCloser(bytes.NewReader(raw))}
+		if len(c.encoding) > 0 {
+			resp.Header.Set("Content-Encoding", c.encoding)
+		}
+		prefix, inspected := peekResponseBody(resp, 1024)
+		if inspected != c.inspected {
+			t.Fatalf("%s: inspected %v", c.encoding, inspected)
+		}
+		if inspected && !bytes.Equal(prefix, body[:1024]) {
+			t.Fatalf("%s: unexpected prefix %q", c.encoding, prefix[:32])
+		}
+		// The client receives the original body
+		streamed, err := ioutil.ReadAll(resp.Body)
+		if err != nil || !bytes.Equal(streamed, raw) {
+			t.Fatalf("%s: body changed, err %v", c.encoding, err)
+		}
+	}
+	// Broken streams are inspected as far as they are decompressed
+	for _, encoding := range []string{"gzip", "br"} {
+		raw := compressTestBody(t, encoding, body)
+		raw = raw[:len(raw)/2]
+		resp := &http.Response{Header: http.Header{"Content-Encoding": {encoding}}, Body: ioutil.NopCloser(bytes.NewReader(raw))}
+		if prefix, inspected := peekResponseBody(resp, 1024); !inspected || !bytes.HasPrefix(body, prefix) {
+			t.Fatalf("broken %s stream should be inspected", encoding)
+		}
+	}
+}
+
+func TestIsTextualContentType(t *testing.T) {
+	for contentType, expected := range map[string]bool{
+		"text/html; charset=utf-8": true, "application/json": true, "application/problem+json": true,
+		"application/javascript": true, "image/png": false, "application/octet-stream": false, "": false,
+	} {
+		if IsTextualContentType(contentType) != expected {
+			t.Fatalf("IsTextualContentType %s should be %v", contentType, expected)
+		}
+	}
+}
@@ -29,6 +29,7 @@ func RoutineCleanLogTick() {
 			expiredTime := time.Now().Unix() - logExpireSeconds
 			data.DAL.DeleteHitLogsBeforeTime(expiredTime)
 			data.DAL.DeleteCCLogsBeforeTime(expiredTime)
+			data.DAL.DeleteACLHitLogsBeforeTime(expiredTime)
 		}
 	}
 }
@@ -37,26 +38,26 @@ func RoutineCleanLogTick() {
 func RoutineCleanCacheTick() {
 	routineTicker := time.NewTicker(time.Duration(7200) * time.Second)
 	for range routineTicker.C {
-		ClearExpiredFiles("./static/cdncache/", time.Now())
+		ClearExpiredFiles("./static/cdncache/", time.Now(), data.GetCachePurgeSeconds())
 	}
 }
 
-// ClearExpiredFiles clear expired static cdn files
-func ClearExpiredFiles(path string, now time.Time) {
+// ClearExpiredFiles clear the static cdn files not checked for expireSeconds
+func ClearExpiredFiles(path string, now time.Time, expireSeconds int64) {
 	fs, err := ioutil.ReadDir(path)
 	if err != nil {
 		utils.DebugPrintln("ClearExpiredFiles", err)
 	}
 	for _, file := range fs {
 		if file.IsDir() {
-			ClearExpiredFiles(path+file.Name()+"/", now)
+			ClearExpiredFiles(path+file.Name()+"/", now, expireSeconds)
 		} else {
 			targetFile := path + file.Name()
 			if fi, err := os.Stat(targetFile); err == nil {
 				fiStat := fi.Sys().(*syscall.Stat_t)
 				// Use ctime fiStat.Ctim.Sec to mark the last check time
 				pastSeconds := now.Unix() - fiStat.Ctim.Sec
-				if pastSeconds >= 86400*7 {
+				if pastSeconds >= expireSeconds {
 					err = os.Remove(targetFile)
 					if err != nil {
 						utils.DebugPrintln("ClearExpiredFiles Remove", targetFile, err)
@@ -0,0 +1,51 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 15:16:37
+ * @Last Modified: thonsun, 2026-10-19 15:16:37
+ */
+
+package firewall
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"asec/data"
+)
+
+func TestClearExpiredFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cdncache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	targetFile := filepath.Join(dir, "www.example.com", "app.js")
+	if err = os.MkdirAll(filepath.Dir(targetFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(targetFile, []byte("var a = 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The purge age covers the longer one of stale-while-revalidate and stale-if-error
+	defer data.SetCacheWindow("Cache_Stale_If_Error_Seconds", data.Cache_Stale_If_Error_Seconds)
+	data.SetCacheWindow("Cache_Stale_If_Error_Seconds", 30*86400)
+	purgeSeconds := data.GetCachePurgeSeconds()
+	if purgeSeconds != data.Cache_Revalidate_Seconds+30*86400 {
+		t.Fatalf("purge seconds %d", purgeSeconds)
+	}
+
+	now := time.Now()
+	ClearExpiredFiles(dir+"/", now.Add(8*24*time.Hour), purgeSeconds)
+	if _, err = os.Stat(targetFile); err != nil {
+		t.Fatal("file within the stale-if-error window removed")
+	}
+	ClearExpiredFiles(dir+"/", now.Add(time.Duration(purgeSeconds+60)*time.Second), purgeSeconds)
+	if _, err = os.Stat(targetFile); !os.IsNotExist(err) {
+		t.Fatal("expired file not removed")
+	}
+}
@@ -0,0 +1,34 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:47:15
+ * @Last Modified: thonsun, 2026-10-19 14:47:15
+ */
+
+package firewall
+
+import (
+	"encoding/json"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+// RPCSelectIPACLs ...
+func RPCSelectIPACLs() (ipACLs []*models.IPACL) {
+	rpcRequest := &models.RPCRequest{
+		Action: "getipacls", Object: nil}
+	resp, err := data.GetRPCResponse(rpcRequest)
+	if err != nil {
+		utils.CheckError("RPCSelectIPACLs GetResponse", err)
+		return nil
+	}
+	rpcIPACLs := new(models.RPCIPACLs)
+	if err := json.Unmarshal(resp, rpcIPACLs); err != nil {
+		utils.CheckError("RPCSelectIPACLs Unmarshal", err)
+		return nil
+	}
+	ipACLs = rpcIPACLs.Object
+	return ipACLs
+}
@@ -28,3 +28,11 @@ func RPCCCLog(ccLog *models.CCLog) {
 	_, err := data.GetRPCResponse(rpcRequest)
 	utils.CheckError("RPCCCLog", err)
 }
+
+// RPCACLHitLog ...
+func RPCACLHitLog(aclLog *models.ACLHitLog) {
+	rpcRequest := &models.RPCRequest{
+		Action: "log_acl_hit", Object: aclLog}
+	_, err := data.GetRPCResponse(rpcRequest)
+	utils.CheckError("RPCACLHitLog", err)
+}
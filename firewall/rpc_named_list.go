@@ -0,0 +1,34 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:35:19
+ * @Last Modified: thonsun, 2026-10-19 14:35:19
+ */
+
+package firewall
+
+import (
+	"encoding/json"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+// RPCSelectNamedLists ...
+func RPCSelectNamedLists() (namedLists []*models.NamedList) {
+	rpcRequest := &models.RPCRequest{
+		Action: "getnamedlists", Object: nil}
+	resp, err := data.GetRPCResponse(rpcRequest)
+	if err != nil {
+		utils.CheckError("RPCSelectNamedLists GetResponse", err)
+		return nil
+	}
+	rpcNamedLists := new(models.RPCNamedLists)
+	if err := json.Unmarshal(resp, rpcNamedLists); err != nil {
+		utils.CheckError("RPCSelectNamedLists Unmarshal", err)
+		return nil
+	}
+	namedLists = rpcNamedLists.Object
+	return namedLists
+}
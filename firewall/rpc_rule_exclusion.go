@@ -0,0 +1,34 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 15:04:35
+ * @Last Modified: thonsun, 2026-10-19 15:04:35
+ */
+
+package firewall
+
+import (
+	"encoding/json"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+// RPCSelectRuleExclusions ...
+func RPCSelectRuleExclusions() (exclusions []*models.RuleExclusion) {
+	rpcRequest := &models.RPCRequest{
+		Action: "getruleexclusions", Object: nil}
+	resp, err := data.GetRPCResponse(rpcRequest)
+	if err != nil {
+		utils.CheckError("RPCSelectRuleExclusions GetResponse", err)
+		return nil
+	}
+	rpcExclusions := new(models.RPCRuleExclusions)
+	if err := json.Unmarshal(resp, rpcExclusions); err != nil {
+		utils.CheckError("RPCSelectRuleExclusions Unmarshal", err)
+		return nil
+	}
+	exclusions = rpcExclusions.Object
+	return exclusions
+}
@@ -0,0 +1,258 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:21:29
+ * @Last Modified: thonsun, 2026-10-19 14:21:29
+ */
+
+package firewall
+
+import (
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+
+	"asec/models"
+)
+
+// CompiledCheckItem is a check item with the pattern compiled, shared by all requests
+type CompiledCheckItem struct {
+	CheckItem *models.CheckItem
+	// operation without OperationNot
+	operation  models.Operation
+	negated    bool
+	regex      *regexp.Regexp
+	intValue   int64
+	maxValue   int64
+	lowerValue string
+	ips        *ipSet
+	// literals, one of them must be found before matching the regex, nil means no prefilter
+	literals []string
+	// transforms of the raw value, nil means the default decoding of check point
+	transforms []transformFunc
+}
+
+// RuleEngine match the check items of a check point, the literals of all regexes are searched in one pass,
+// and only the regexes whose literal was found are executed.
+type RuleEngine struct {
+	items []*CompiledCheckItem
+	// literalIDs of items in the matcher
+	literalIDs [][]int32
+	matcher    *literalMatcher
+	// pipelines are the distinct transforms of items, the first one is the default decoding,
+	// each pipeline is applied at most once for a value
+	pipelines   [][]transformFunc
+	pipelineIDs []int
+}
+
+// CompileCheckItem compile the pattern of check item, invalid patterns are rejected
+func CompileCheckItem(checkItem *models.CheckItem) (*CompiledCheckItem, error) {
+	item := &CompiledCheckItem{CheckItem: checkItem, operation: checkItem.Operation &^ models.OperationNot, negated: checkItem.Operation&models.OperationNot != 0}
+	var err error
+	switch item.operation {
+	case models.OperationRegexMatch:
+		item.regex, err = regexp.Compile(checkItem.RegexPolicy)
+		if err != nil {
+			return nil, errors.New("Invalid regex " + checkItem.RegexPolicy + ": " + err.Error())
+		}
+		re, err := syntax.Parse(checkItem.RegexPolicy, syntax.Perl)
+		if err == nil && !item.negated {
+			// A negated item matches the values without the literals, so it can not be prefiltered
+			item.literals = requiredLiterals(re)
+		}
+	case models.OperationEqualsStringCaseInSensitive:
+		item.lowerValue = strings.ToLower(checkItem.RegexPolicy)
+	case models.OperationContains, models.OperationStartsWith, models.OperationEndsWith:
+		if len(checkItem.RegexPolicy) == 0 {
+			return nil, errors.New("Empty string to match")
+		}
+	case models.OperationGreaterThanInteger, models.OperationEqualsInteger, models.OperationLessThanInteger:
+		item.intValue, err = strconv.ParseInt(checkItem.RegexPolicy, 10, 64)
+		if err != nil {
+			return nil, errors.New("Invalid integer " + checkItem.RegexPolicy)
+		}
+	case models.OperationIntegerRange:
+		bounds := strings.Split(checkItem.RegexPolicy, ",")
+		if len(bounds) != 2 {
+			return nil, errors.New("Invalid range " + checkItem.RegexPolicy + ", min,max is required")
+		}
+		var err1, err2 error
+		item.intValue, err1 = strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64)
+		item.maxValue, err2 = strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 64)
+		if err1 != nil || err2 != nil || item.intValue > item.maxValue {
+			return nil, errors.New("Invalid range " + checkItem.RegexPolicy)
+		}
+	case models.OperationIPInCIDR:
+		item.ips, err = parseIPSet(splitListItems(checkItem.RegexPolicy))
+		if err != nil {
+			return nil, err
+		}
+	case models.OperationInNamedList:
+		// The list is looked up when matching, see IsInNamedList
+		if len(checkItem.RegexPolicy) == 0 {
+			return nil, errors.New("List name is required")
+		}
+	case models.OperationDetectSQLi, models.OperationDetectXSS, models.OperationDetectCreditCard, models.OperationDetectNationalID:
+	default:
+		return nil, errors.New("Unknown operation " + strconv.FormatInt(int64(checkItem.Operation), 10))
+	}
+	if len(strings.Join(checkItem.Transforms, ",")) > maxTransformsLength {
+		return nil, errors.New("Too many transforms")
+	}
+	item.transforms, err = compileTransforms(checkItem.Transforms)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// Match the value which has been preprocessed
+func (item *CompiledCheckItem) Match(value string) bool {
+	return item.match(value) != item.negated
+}
+
+func (item *CompiledCheckItem) match(value string) bool {
+	switch item.operation {
+	case models.OperationRegexMatch:
+		return item.regex.MatchString(value)
+	case models.OperationEqualsStringCaseInSensitive:
+		return item.lowerValue == strings.ToLower(value)
+	case models.OperationContains:
+		return strings.Contains(value, item.CheckItem.RegexPolicy)
+	case models.OperationStartsWith:
+		return strings.HasPrefix(value, item.CheckItem.RegexPolicy)
+	case models.OperationEndsWith:
+		return strings.HasSuffix(value, item.CheckItem.RegexPolicy)
+	case models.OperationGreaterThanInteger:
+		checkValue, err := strconv.ParseInt(value, 10, 64)
+		return err == nil && checkValue > item.intValue
+	case models.OperationEqualsInteger:
+		checkValue, err := strconv.ParseInt(value, 10, 64)
+		return err == nil && checkValue == item.intValue
+	case models.OperationLessThanInteger:
+		checkValue, err := strconv.ParseInt(value, 10, 64)
+		return err == nil && checkValue < item.intValue
+	case models.OperationIntegerRange:
+		checkValue, err := strconv.ParseInt(value, 10, 64)
+		return err == nil && checkValue >= item.intValue && checkValue <= item.maxValue
+	case models.OperationIPInCIDR:
+		return item.ips.Contains(value)
+	case models.OperationInNamedList:
+		return IsInNamedList(item.CheckItem.RegexPolicy, value)
+	case models.OperationDetectSQLi:
+		isSQLi, _ := DetectSQLi(value)
+		return isSQLi
+	case models.OperationDetectXSS:
+		return DetectXSS(value)
+	case models.OperationDetectCreditCard:
+		return len(findCreditCards(value)) > 0
+	case models.OperationDetectNationalID:
+		return len(findNationalIDs(value)) > 0
+	}
+	return false
+}
+
+// findMasks return the ranges of value to be masked: the capture groups of regex (or the whole match without groups),
+// or the credit card and national ID numbers.
+func (item *CompiledCheckItem) findMasks(value string) [][2]int {
+	var ranges [][2]int
+	switch item.operation {
+	case models.OperationRegexMatch:
+		for _, match := range item.regex.FindAllStringSubmatchIndex(value, -1) {
+			if len(match) == 2 {
+				ranges = append(ranges, [2]int{match[0], match[1]})
+				continue
+			}
+			for i := 2; i+1 < len(match); i += 2 {
+				if match[i] >= 0 && match[i] < match[i+1] {
+					ranges = append(ranges, [2]int{match[i], match[i+1]})
+				}
+			}
+		}
+	case models.OperationDetectCreditCard:
+		ranges = findCreditCards(value)
+	case models.OperationDetectNationalID:
+		ranges = findNationalIDs(value)
+	}
+	return ranges
+}
+
+// NewRuleEngine build the engine with compiled check items, the order of check items is kept
+func NewRuleEngine(items []*CompiledCheckItem) *RuleEngine {
+	engine := &RuleEngine{items: items, literalIDs: make([][]int32, len(items)), pipelines: [][]transformFunc{nil}, pipelineIDs: make([]int, len(items))}
+	var literals []string
+	literalIndex := map[string]int32{}
+	pipelineIndex := map[string]int{"": 0}
+	for i, item := range items {
+		pipelineKey := strings.Join(item.CheckItem.Transforms, ",")
+		pipelineID, ok := pipelineIndex[pipelineKey]
+		if !ok {
+			pipelineID = len(engine.pipelines)
+			pipelineIndex[pipelineKey] = pipelineID
+			engine.pipelines = append(engine.pipelines, item.transforms)
+		}
+		engine.pipelineIDs[i] = pipelineID
+		for _, literal := range item.literals {
+			id, ok := literalIndex[literal]
+			if !ok {
+				id = int32(len(literals))
+				literalIndex[literal] = id
+				literals = append(literals, literal)
+			}
+			engine.literalIDs[i] = append(engine.literalIDs[i], id)
+		}
+	}
+	if len(literals) > 0 {
+		engine.matcher = newLiteralMatcher(literals)
+	}
+	return engine
+}
+
+// Range call fn with the transformed value for each check item which may match the raw value, in order,
+// until fn return false, needDecode is the default decoding of check point for the items without transforms.
+func (engine *RuleEngine) Range(value string, needDecode bool, fn func(item *CompiledCheckItem, value string) bool) {
+	values := make([]string, len(engine.pipelines))
+	hits := make([][]uint64, len(engine.pipelines))
+	done := make([]bool, len(engine.pipelines))
+	for i, item := range engine.items {
+		pipelineID := engine.pipelineIDs[i]
+		if !done[pipelineID] {
+			values[pipelineID] = engine.transform(pipelineID, value, needDecode)
+			if engine.matcher != nil {
+				hits[pipelineID] = make([]uint64, (engine.matcher.count+63)/64)
+				engine.matcher.scan(values[pipelineID], hits[pipelineID])
+			}
+			done[pipelineID] = true
+		}
+		if len(engine.literalIDs[i]) > 0 && !containsAnyLiteral(hits[pipelineID], engine.literalIDs[i]) {
+			continue
+		}
+		if fn(item, values[pipelineID]) == false {
+			return
+		}
+	}
+}
+
+func (engine *RuleEngine) transform(pipelineID int, value string, needDecode bool) string {
+	if pipelineID == 0 {
+		if needDecode {
+			return UnEscapeRawValue(value)
+		}
+		return value
+	}
+	for _, fn := range engine.pipelines[pipelineID] {
+		value = fn(value)
+	}
+	return value
+}
+
+func containsAnyLiteral(hits []uint64, literalIDs []int32) bool {
+	for _, id := range literalIDs {
+		if hits[id>>6]&(1<<(uint(id)&63)) != 0 {
+			return true
+		}
+	}
+	return false
+}
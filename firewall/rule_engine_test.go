@@ -0,0 +1,271 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:21:29
+ * @Last Modified: thonsun, 2026-10-19 14:21:29
+ */
+
+package firewall
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+
+	"asec/models"
+)
+
+// testPatterns are the built-in patterns of InitGroupPolicy
+var testPatterns = []string{
+	`(?i)/\.(git|svn)/`,
+	`(?i)%\s+(and|or|procedure)\s+`,
+	`(?i);\s*(declare|use|drop|create|exec)\s`,
+	`(?i)(updatexml|extractvalue|ascii|ord|char|chr|count|concat|rand|floor|substr|length|len|user|database|benchmark|analyse)\s?\(`,
+	`(?i)\(case\s+when\s+[\w\p{L}]+=[\w\p{L}]+\s+then\s+`,
+	`(?i)\s+(and|or|procedure)\s+[\w\p{L}]+=[\w\p{L}]+(\s|$|--|#)`,
+	`(?i)\s+(and|or|rlike)\s+(select|case)\s+`,
+	`(?i)\s+(and|or|rlike)\s+(if|updatexml)\(`,
+	`(?i)/\*(!|\x00)`,
+	`(?i)union[\s/\*]+select`,
+	`(^|\&\s*|\|\s*)(pwd|ls|ll|whoami|id|net\s+user)$`,
+	`(?i)(eval|system|exec|execute|passthru|shell_exec|phpinfo)\(`,
+	`(?i)\.(php|jsp|aspx|asp|exe|asa)`,
+	`(?i)<(script|iframe)`,
+	`(?i)(alert|eval|prompt)\(`,
+	`(?i)(onmouseover|onerror|onload|onclick)\s*=`,
+	`\.\./\.\./|/etc/passwd$`,
+}
+
+var testPayloads = []string{
+	"id=1 and 1=1",
+	"id=1 AND 1=1--",
+	"1 UNION/**/SELECT password FROM users",
+	"1 union ſelect password",
+	"<ScRiPt>alert(1)</script>",
+	"<img src=x onerror=alert(1)>",
+	"name=<svg onload =prompt(1)>",
+	"../../../etc/passwd",
+	"/.git/config",
+	"a; DROP TABLE users",
+	"x=(CASE WHEN 1=1 THEN 1 ELSE 0 END)",
+	"1 or if(1=1,sleep(5),0)",
+	"cmd=whoami",
+	"shell.PHP",
+	"/*!50000select*/",
+	"eval(base64_decode($x))",
+	"Keyword", // Kelvin sign folds to k
+	"the weather is fine today",
+	"product=apple&category=fruit&page=2",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36",
+	"text with invalid utf8 \xff\xfe and more",
+	"",
+}
+
+func newTestRuleEngine(t testing.TB, patterns []string) *RuleEngine {
+	var checkItems []*models.CheckItem
+	for i, pattern := range patterns {
+		checkItems = append(checkItems, &models.CheckItem{ID: int64(i + 1), CheckPoint: models.ChkPointGetPostValue, Operation: models.OperationRegexMatch, RegexPolicy: pattern})
+	}
+	return NewRuleEngine(compileTestCheckItems(t, checkItems))
+}
+
+func compileTestCheckItems(t testing.TB, checkItems []*models.CheckItem) []*CompiledCheckItem {
+	var items []*CompiledCheckItem
+	for _, checkItem := range checkItems {
+		item, err := CompileCheckItem(checkItem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// newTestCheckItems return a regex check item at checkPoint for each policy, with the pattern of the same index
+func newTestCheckItems(policies []*models.GroupPolicy, checkPoint models.ChkPoint, patterns []string) []*models.CheckItem {
+	var checkItems []*models.CheckItem
+	for i, groupPolicy := range policies {
+		checkItems = append(checkItems, &models.CheckItem{ID: int64(i + 1), CheckPoint: checkPoint, Operation: models.OperationRegexMatch, RegexPolicy: patterns[i], GroupPolicy: groupPolicy})
+	}
+	return checkItems
+}
+
+// installTestCheckItems add the check items to their group policies and hit values,
+// and install the rule engines of their check points, the returned function removes the rule engines.
+func installTestCheckItems(t testing.TB, checkItems []*models.CheckItem) func() {
+	checkPointItems := map[models.ChkPoint][]*models.CheckItem{}
+	for _, checkItem := range checkItems {
+		groupPolicy := checkItem.GroupPolicy
+		groupPolicy.CheckItems = append(groupPolicy.CheckItems, checkItem)
+		groupPolicy.HitValue += int64(checkItem.CheckPoint)
+		checkPointItems[checkItem.CheckPoint] = append(checkPointItems[checkItem.CheckPoint], checkItem)
+	}
+	for checkPoint, checkItems := range checkPointItems {
+		checkPointRuleEngineMap.Store(checkPoint, NewRuleEngine(compileTestCheckItems(t, checkItems)))
+	}
+	return func() {
+		for checkPoint := range checkPointItems {
+			checkPointRuleEngineMap.Delete(checkPoint)
+		}
+	}
+}
+
+func TestRuleEngineSameAsRegexp(t *testing.T) {
+	engine := newTestRuleEngine(t, testPatterns)
+	for _, payload := range testPayloads {
+		var engineHits []int64
+		engine.Range(payload, false, func(item *CompiledCheckItem, value string) bool {
+			if item.Match(value) {
+				engineHits = append(engineHits, item.CheckItem.ID)
+			}
+			return true
+		})
+		var regexHits []int64
+		for i, pattern := range testPatterns {
+			if matched, _ := regexp.MatchString(pattern, payload); matched {
+				regexHits = append(regexHits, int64(i+1))
+			}
+		}
+		if len(engineHits) != len(regexHits) {
+			t.Fatalf("payload %q engine hits %v, regexp hits %v", payload, engineHits, regexHits)
+		}
+		for i := range engineHits {
+			if engineHits[i] != regexHits[i] {
+				t.Fatalf("payload %q engine hits %v, regexp hits %v", payload, engineHits, regexHits)
+			}
+		}
+	}
+}
+
+func TestRuleEngineFoldCase(t *testing.T) {
+	engine := newTestRuleEngine(t, []string{`(?i)keyword`, `(?i)union\s+select`})
+	for _, payload := range []string{"Keyword", "UNION ſELECT"} {
+		candidates := 0
+		engine.Range(payload, false, func(item *CompiledCheckItem, value string) bool {
+			candidates++
+			return true
+		})
+		if candidates != 1 {
+			t.Fatalf("payload %q filtered out by the literal prefilter", payload)
+		}
+	}
+}
+
+func TestCompileCheckItemRejectInvalid(t *testing.T) {
+	invalidItems := []*models.CheckItem{
+		{Operation: models.OperationRegexMatch, RegexPolicy: `(?i)(select`},
+		{Operation: models.OperationGreaterThanInteger, RegexPolicy: `abc`},
+		{Operation: models.OperationIPInCIDR, RegexPolicy: `10.0.0.0/33`},
+		{Operation: models.OperationIntegerRange, RegexPolicy: `10,1`},
+		{Operation: models.OperationNot, RegexPolicy: `abc`},
+	}
+	for _, checkItem := range invalidItems {
+		if _, err := CompileCheckItem(checkItem); err == nil {
+			t.Fatalf("invalid check item %q accepted", checkItem.RegexPolicy)
+		}
+	}
+}
+
+func TestCompiledCheckItemOperations(t *testing.T) {
+	namedListMap.Store("test-paths", &compiledNamedList{strings: map[string]bool{"/admin": true}})
+	defer namedListMap.Delete("test-paths")
+	cases := []struct {
+		operation models.Operation
+		pattern   string
+		value     string
+		expected  bool
+	}{
+		{models.OperationContains, "select", "union select 1", true},
+		{models.OperationContains, "select", "union SELECT 1", false},
+		{models.OperationStartsWith, "/admin/", "/admin/login", true},
+		{models.OperationEndsWith, ".php", "/index.php", true},
+		{models.OperationEndsWith, ".php", "/index.php5", false},
+		{models.OperationIPInCIDR, "10.0.0.0/8, 192.168.1.1", "10.1.2.3", true},
+		{models.OperationIPInCIDR, "10.0.0.0/8, 192.168.1.1", "192.168.1.2", false},
+		{models.OperationIPInCIDR, "2001:db8::/32", "2001:db8::1", true},
+		{models.OperationIPInCIDR, "10.0.0.0/8", "::ffff:10.0.0.1", true},
+		{models.OperationIPInCIDR, "10.0.0.0/8", "not ip", false},
+		{models.OperationLessThanInteger, "100", "99", true},
+		{models.OperationIntegerRange, "1, 10", "10", true},
+		{models.OperationIntegerRange, "1, 10", "11", false},
+		{models.OperationInNamedList, "test-paths", "/admin", true},
+		{models.OperationInNamedList, "not-exist", "/admin", false},
+		{models.OperationNot | models.OperationIPInCIDR, "10.0.0.0/8", "8.8.8.8", true},
+		{models.OperationNot | models.OperationRegexMatch, "(?i)mozilla", "curl/7.0", true},
+		{models.OperationNot | models.OperationEqualsInteger, "1", "1", false},
+	}
+	for _, c := range cases {
+		item, err := CompileCheckItem(&models.CheckItem{Operation: c.operation, RegexPolicy: c.pattern})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Match(c.value) != c.expected {
+			t.Fatalf("operation %d %q on %q, expected %v", c.operation, c.pattern, c.value, c.expected)
+		}
+	}
+	// The negated regex is not filtered out by its literals
+	item, _ := CompileCheckItem(&models.CheckItem{Operation: models.OperationNot | models.OperationRegexMatch, RegexPolicy: "mozilla"})
+	engine := NewRuleEngine([]*CompiledCheckItem{item})
+	candidates := 0
+	engine.Range("curl/7.0", false, func(item *CompiledCheckItem, value string) bool {
+		candidates++
+		return true
+	})
+	if candidates != 1 {
+		t.Fatal("negated regex filtered out by the literal prefilter")
+	}
+}
+
+// benchmarkPatterns are the built-in patterns with 10 times of custom rules
+func benchmarkPatterns() []string {
+	patterns := append([]string{}, testPatterns...)
+	for i := 0; i < 10; i++ {
+		suffix := strconv.Itoa(i)
+		patterns = append(patterns,
+			`(?i)custom_func`+suffix+`\s*\(`,
+			`(?i)/admin`+suffix+`/(login|config)\.php`,
+			`(?i)token`+suffix+`=[a-f0-9]{32}`,
+		)
+	}
+	return patterns
+}
+
+func BenchmarkMatchStringPerRequest(b *testing.B) {
+	patterns := benchmarkPatterns()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, payload := range testPayloads {
+			for _, pattern := range patterns {
+				regexp.MatchString(pattern, payload)
+			}
+		}
+	}
+}
+
+func BenchmarkPrecompiledRegexp(b *testing.B) {
+	var regexes []*regexp.Regexp
+	for _, pattern := range benchmarkPatterns() {
+		regexes = append(regexes, regexp.MustCompile(pattern))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, payload := range testPayloads {
+			for _, regex := range regexes {
+				regex.MatchString(payload)
+			}
+		}
+	}
+}
+
+func BenchmarkRuleEngine(b *testing.B) {
+	engine := newTestRuleEngine(b, benchmarkPatterns())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, payload := range testPayloads {
+			engine.Range(payload, false, func(item *CompiledCheckItem, value string) bool {
+				item.Match(value)
+				return true
+			})
+		}
+	}
+}
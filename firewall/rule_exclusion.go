@@ -0,0 +1,294 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 15:04:35
+ * @Last Modified: thonsun, 2026-10-19 15:04:35
+ */
+
+package firewall
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"asec/data"
+	"asec/models"
+)
+
+const (
+	// targetKey is the key of the parameter, cookie or header being checked in the groupPolicyHitValue map of request context
+	targetKey = "target"
+	// excludedHitsKey is the key of the matches skipped by rule exclusions in the groupPolicyHitValue map of request context
+	excludedHitsKey = "excludedHits"
+)
+
+var (
+	ruleExclusions []*models.RuleExclusion
+	// ruleExclusionMap (appID int64, []*models.RuleExclusion)
+	ruleExclusionMap sync.Map
+	// exclusionTargetCheckPoints the check points of each target type, ExclusionTargetAll is for all check points
+	exclusionTargetCheckPoints = map[models.ExclusionTarget][]models.ChkPoint{
+		models.ExclusionTargetParameter: {models.ChkPointGetPostKey, models.ChkPointGetPostValue, models.ChkPointValueLength},
+		models.ExclusionTargetCookie:    {models.ChkPointCookieKey, models.ChkPointCookieValue},
+		models.ExclusionTargetHeader:    {models.ChkPointHeaderKey, models.ChkPointHeaderValue, models.ChkPointUserAgent},
+	}
+)
+
+// valueTarget is the parameter, cookie or header of the values being checked
+type valueTarget struct {
+	targetType models.ExclusionTarget
+	name       string
+}
+
+// excludedHit is a match of group policy skipped by the rule exclusion
+type excludedHit struct {
+	policy    *models.GroupPolicy
+	exclusion *models.RuleExclusion
+}
+
+// setValueTarget should be called before checking the values of a parameter, cookie or header
+func setValueTarget(ctxMap *sync.Map, targetType models.ExclusionTarget, name string) {
+	ctxMap.Store(targetKey, &valueTarget{targetType: targetType, name: name})
+}
+
+func clearValueTarget(ctxMap *sync.Map) {
+	ctxMap.Delete(targetKey)
+}
+
+// InitRuleExclusions ...
+func InitRuleExclusions() {
+	if data.IsPrimary {
+		data.DAL.CreateTableIfNotExistsRuleExclusions()
+		ruleExclusions, _ = data.DAL.SelectRuleExclusions()
+	} else {
+		ruleExclusions = RPCSelectRuleExclusions()
+	}
+	buildRuleExclusionMap()
+}
+
+func buildRuleExclusionMap() {
+	exclusionsMap := map[int64][]*models.RuleExclusion{}
+	for _, exclusion := range ruleExclusions {
+		exclusionsMap[exclusion.AppID] = append(exclusionsMap[exclusion.AppID], exclusion)
+	}
+	ruleExclusionMap.Range(func(key, value interface{}) bool {
+		if _, ok := exclusionsMap[key.(int64)]; !ok {
+			ruleExclusionMap.Delete(key)
+		}
+		return true
+	})
+	for appID, exclusions := range exclusionsMap {
+		ruleExclusionMap.Store(appID, exclusions)
+	}
+}
+
+func validateRuleExclusion(exclusion *models.RuleExclusion) error {
+	if exclusion.PolicyID == 0 && exclusion.VulnID == 0 {
+		return errors.New("policy_id or vuln_id is required")
+	}
+	if exclusion.TargetType == models.ExclusionTargetAll {
+		if len(exclusion.TargetName) > 0 {
+			return errors.New("target_name requires target_type 1 (parameter), 2 (cookie) or 3 (header)")
+		}
+	} else if _, ok := exclusionTargetCheckPoints[exclusion.TargetType]; !ok {
+		return errors.New("Invalid target_type")
+	}
+	if len(exclusion.TargetName) > 256 || len(exclusion.PathPrefix) > 2048 {
+		return errors.New("target_name or path_prefix is too long")
+	}
+	if exclusion.TargetType != models.ExclusionTargetAll && exclusion.PolicyID != 0 {
+		// The values of other check points (e.g. the whole query string) have no target, so they are never excluded
+		if groupPolicy, err := GetGroupPolicyByID(exclusion.PolicyID); err == nil {
+			var unmapped []string
+			for _, checkItem := range groupPolicy.CheckItems {
+				if !isExclusionTargetCheckPoint(exclusion.TargetType, checkItem.CheckPoint) {
+					unmapped = append(unmapped, strconv.FormatInt(int64(checkItem.CheckPoint), 10))
+				}
+			}
+			if len(unmapped) > 0 {
+				return errors.New("Check points " + strings.Join(unmapped, ",") + " of policy " + strconv.FormatInt(groupPolicy.ID, 10) + " can not be excluded by target_type " + strconv.FormatInt(int64(exclusion.TargetType), 10) + ", use target_type 0 with path_prefix instead")
+			}
+		}
+	}
+	return nil
+}
+
+// isExclusionTargetCheckPoint return true if the values of checkPoint are checked with a target of targetType
+func isExclusionTargetCheckPoint(targetType models.ExclusionTarget, checkPoint models.ChkPoint) bool {
+	for _, targetCheckPoint := range exclusionTargetCheckPoints[targetType] {
+		if targetCheckPoint == checkPoint {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRuleExclusion return true if the match of policy at checkPoint is skipped by the exclusion
+func matchRuleExclusion(exclusion *models.RuleExclusion, hitValueMap *sync.Map, groupPolicy *models.GroupPolicy, checkPoint models.ChkPoint) bool {
+	if exclusion.PolicyID != 0 && exclusion.PolicyID != groupPolicy.ID {
+		return false
+	}
+	if exclusion.VulnID != 0 && exclusion.VulnID != groupPolicy.VulnID {
+		return false
+	}
+	if len(exclusion.PathPrefix) > 0 {
+		r, ok := hitValueMap.Load(requestKey)
+		if !ok || !strings.HasPrefix(CleanRequestPath(r.(*http.Request)), exclusion.PathPrefix) {
+			return false
+		}
+	}
+	if exclusion.TargetType == models.ExclusionTargetAll {
+		return true
+	}
+	targetInterface, ok := hitValueMap.Load(targetKey)
+	if !ok {
+		return false
+	}
+	target := targetInterface.(*valueTarget)
+	if target.targetType != exclusion.TargetType {
+		return false
+	}
+	if !isExclusionTargetCheckPoint(target.targetType, checkPoint) {
+		return false
+	}
+	if len(exclusion.TargetName) == 0 {
+		return true
+	}
+	if exclusion.TargetType == models.ExclusionTargetHeader {
+		return strings.EqualFold(target.name, exclusion.TargetName)
+	}
+	return target.name == exclusion.TargetName
+}
+
+// findRuleExclusion return the first exclusion of the application and then the global ones which skip the match, nil if none
+func findRuleExclusion(hitValueMap *sync.Map, appID int64, groupPolicy *models.GroupPolicy, checkPoint models.ChkPoint) *models.RuleExclusion {
+	scopes := []int64{appID, 0}
+	if appID == 0 {
+		scopes = scopes[:1]
+	}
+	for _, scope := range scopes {
+		exclusions, ok := ruleExclusionMap.Load(scope)
+		if !ok {
+			continue
+		}
+		for _, exclusion := range exclusions.([]*models.RuleExclusion) {
+			if matchRuleExclusion(exclusion, hitValueMap, groupPolicy, checkPoint) {
+				return exclusion
+			}
+		}
+	}
+	return nil
+}
+
+// recordExcludedHit save the skipped match for LogExcludedHits, once for each policy
+func recordExcludedHit(hitValueMap *sync.Map, groupPolicy *models.GroupPolicy, exclusion *models.RuleExclusion) {
+	hitsInterface, _ := hitValueMap.LoadOrStore(excludedHitsKey, &[]*excludedHit{})
+	hits := hitsInterface.(*[]*excludedHit)
+	for _, hit := range *hits {
+		if hit.policy == groupPolicy {
+			return
+		}
+	}
+	*hits = append(*hits, &excludedHit{policy: groupPolicy, exclusion: exclusion})
+}
+
+// LogExcludedHits log the matches skipped by rule exclusions since the last call as excluded (monitor) hits
+func LogExcludedHits(r *http.Request, appID int64, clientIP string) {
+	ctxMap := r.Context().Value("groupPolicyHitValue").(*sync.Map)
+	hitsInterface, ok := ctxMap.Load(excludedHitsKey)
+	if !ok {
+		return
+	}
+	ctxMap.Delete(excludedHitsKey)
+	for _, hit := range *hitsInterface.(*[]*excludedHit) {
+		go logGroupHitRequest(r, appID, clientIP, hit.policy, nil, true, hit.exclusion.ID)
+	}
+}
+
+// GetRuleExclusions ...
+func GetRuleExclusions() ([]*models.RuleExclusion, error) {
+	return ruleExclusions, nil
+}
+
+// UpdateRuleExclusion API, object: {"id": 0, "app_id": 1, "policy_id": 10106, "vuln_id": 0, "target_type": 1, "target_name": "content", "path_prefix": "/admin/article", "description": ""}
+func UpdateRuleExclusion(param map[string]interface{}) (*models.RuleExclusion, error) {
+	obj, ok := param["object"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("object is required")
+	}
+	newExclusion := &models.RuleExclusion{UpdateTime: time.Now().Unix()}
+	if id, ok := obj["id"].(float64); ok {
+		newExclusion.ID = int64(id)
+	}
+	if appID, ok := obj["app_id"].(float64); ok {
+		newExclusion.AppID = int64(appID)
+	}
+	if policyID, ok := obj["policy_id"].(float64); ok {
+		newExclusion.PolicyID = int64(policyID)
+	}
+	if vulnID, ok := obj["vuln_id"].(float64); ok {
+		newExclusion.VulnID = int64(vulnID)
+	}
+	if targetType, ok := obj["target_type"].(float64); ok {
+		newExclusion.TargetType = models.ExclusionTarget(targetType)
+	}
+	targetName, _ := obj["target_name"].(string)
+	newExclusion.TargetName = strings.TrimSpace(targetName)
+	pathPrefix, _ := obj["path_prefix"].(string)
+	newExclusion.PathPrefix = strings.TrimSpace(pathPrefix)
+	newExclusion.Description, _ = obj["description"].(string)
+	if err := validateRuleExclusion(newExclusion); err != nil {
+		return nil, err
+	}
+	if newExclusion.PolicyID != 0 {
+		if _, err := GetGroupPolicyByID(newExclusion.PolicyID); err != nil {
+			return nil, errors.New("Group policy not found")
+		}
+	}
+	var err error
+	if newExclusion.ID == 0 {
+		newExclusion.ID, err = data.DAL.InsertRuleExclusion(newExclusion)
+		if err != nil {
+			return nil, err
+		}
+		ruleExclusions = append(ruleExclusions, newExclusion)
+	} else {
+		var exclusion *models.RuleExclusion
+		for _, curExclusion := range ruleExclusions {
+			if curExclusion.ID == newExclusion.ID {
+				exclusion = curExclusion
+			}
+		}
+		if exclusion == nil {
+			return nil, errors.New("Not found")
+		}
+		if err = data.DAL.UpdateRuleExclusion(newExclusion); err != nil {
+			return nil, err
+		}
+		*exclusion = *newExclusion
+		newExclusion = exclusion
+	}
+	buildRuleExclusionMap()
+	data.UpdateFirewallLastModified()
+	return newExclusion, nil
+}
+
+// DeleteRuleExclusionByID ...
+func DeleteRuleExclusionByID(id int64) error {
+	for i, exclusion := range ruleExclusions {
+		if exclusion.ID == id {
+			if err := data.DAL.DeleteRuleExclusionByID(id); err != nil {
+				return err
+			}
+			ruleExclusions = append(ruleExclusions[:i], ruleExclusions[i+1:]...)
+			buildRuleExclusionMap()
+			data.UpdateFirewallLastModified()
+			return nil
+		}
+	}
+	return errors.New("Not found")
+}
@@ -0,0 +1,112 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 15:04:35
+ * @Last Modified: thonsun, 2026-10-19 15:04:35
+ */
+
+package firewall
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"asec/models"
+)
+
+func TestRuleExclusion(t *testing.T) {
+	policies := []*models.GroupPolicy{
+		{ID: 1, VulnID: 200, Action: models.Action_Block_100, IsEnabled: true},
+		{ID: 2, VulnID: 300, Action: models.Action_Block_100, IsEnabled: true},
+	}
+	defer installTestCheckItems(t, newTestCheckItems(policies, models.ChkPointGetPostValue, []string{`(?i)select`, `(?i)<script`}))()
+	// Policy 3 checks the whole query string, which has no parameter target
+	queryPolicy := &models.GroupPolicy{ID: 3, VulnID: 200, Action: models.Action_Block_100, IsEnabled: true}
+	queryItems := newTestCheckItems([]*models.GroupPolicy{queryPolicy}, models.ChkPointURLQuery, []string{`(?i)union`})
+	queryItems[0].ID = 3
+	defer installTestCheckItems(t, queryItems)()
+	oldGroupPolicies := groupPolicies
+	groupPolicies = append(policies, queryPolicy)
+	defer func() { groupPolicies = oldGroupPolicies }()
+
+	exclusions := []*models.RuleExclusion{
+		{ID: 1, AppID: 1, PolicyID: 1, TargetType: models.ExclusionTargetParameter, TargetName: "content", PathPrefix: "/admin/"},
+		{ID: 2, VulnID: 300, TargetType: models.ExclusionTargetAll, PathPrefix: "/editor/"},
+	}
+	for _, exclusion := range exclusions {
+		if err := validateRuleExclusion(exclusion); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ruleExclusions = exclusions
+	buildRuleExclusionMap()
+	defer func() {
+		ruleExclusions = nil
+		buildRuleExclusionMap()
+	}()
+
+	cases := []struct {
+		appID    int64
+		method   string
+		url      string
+		body     string
+		expected int64
+		excluded int64
+	}{
+		{1, "GET", "/admin/article?content=select", "", 0, 1},
+		{1, "GET", "/admin/article?title=select", "", 1, 0},
+		{1, "GET", "/blog?content=select", "", 1, 0},
+		// The path is cleaned before matching path_prefix
+		{1, "GET", "/public/../admin/article?content=select", "", 0, 1},
+		{1, "GET", "/admin/../blog?content=select", "", 1, 0},
+		{2, "GET", "/admin/article?content=select", "", 1, 0},
+		{2, "GET", "/editor/save?text=<script>", "", 0, 2},
+		// The values of nested JSON objects are targeted by the nearest key
+		{1, "POST", "/admin/article", `{"post": {"content": ["select"]}}`, 0, 1},
+		{1, "POST", "/admin/article", `{"post": {"content": "a", "title": "select"}}`, 1, 0},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, c.url, strings.NewReader(c.body))
+		if len(c.body) > 0 {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		ctxMap := &sync.Map{}
+		r = r.WithContext(context.WithValue(r.Context(), "groupPolicyHitValue", ctxMap))
+		var id int64
+		if matched, policy := IsRequestHitPolicy(r, c.appID, "127.0.0.1"); matched {
+			id = policy.ID
+		}
+		if id != c.expected {
+			t.Fatalf("%s %s hits %d, expected %d", c.method, c.url, id, c.expected)
+		}
+		var excluded int64
+		if hits, ok := ctxMap.Load(excludedHitsKey); ok {
+			excluded = (*hits.(*[]*excludedHit))[0].exclusion.ID
+		}
+		if excluded != c.excluded {
+			t.Fatalf("%s %s excluded by %d, expected %d", c.method, c.url, excluded, c.excluded)
+		}
+	}
+
+	invalidExclusions := []*models.RuleExclusion{
+		{TargetType: models.ExclusionTargetParameter, TargetName: "content"},
+		{PolicyID: 1, TargetType: models.ExclusionTargetAll, TargetName: "content"},
+		{PolicyID: 1, TargetType: 9},
+		{PolicyID: 3, TargetType: models.ExclusionTargetParameter, TargetName: "id"},
+	}
+	for _, exclusion := range invalidExclusions {
+		if err := validateRuleExclusion(exclusion); err == nil {
+			t.Fatalf("invalid exclusion accepted %+v", exclusion)
+		}
+	}
+	err := validateRuleExclusion(&models.RuleExclusion{PolicyID: 3, TargetType: models.ExclusionTargetCookie})
+	if err == nil || !strings.HasPrefix(err.Error(), "Check points 16 of policy 3") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err = validateRuleExclusion(&models.RuleExclusion{PolicyID: 3, TargetType: models.ExclusionTargetAll, PathPrefix: "/search"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,621 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:24:16
+ * @Last Modified: thonsun, 2026-10-19 14:24:16
+ */
+
+package firewall
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"asec/data"
+	"asec/models"
+)
+
+// secRule is a parsed SecRule directive, a chained rule has the following links in Chain
+type secRule struct {
+	Line      int64
+	Variables string
+	Operator  string
+	Actions   map[string][]string
+	Chain     []*secRule
+}
+
+// secRuleTarget is a variable mapped to the check point
+type secRuleTarget struct {
+	CheckPoint models.ChkPoint
+	KeyName    string
+}
+
+// secRuleVulnTags map the tags of OWASP CRS to vulnerability types
+var secRuleVulnTags = map[string]int64{
+	"attack-sqli":               200,
+	"attack-rce":                210,
+	"attack-injection-php":      220,
+	"attack-injection-generic":  220,
+	"attack-xss":                300,
+	"attack-lfi":                420,
+	"attack-rfi":                410,
+	"attack-reputation-scanner": 600,
+	"attack-fixation":           920,
+	"attack-protocol":           940,
+}
+
+// secRuleIgnoredActions are the actions without effect on the mapped group policy
+var secRuleIgnoredActions = map[string]bool{
+	"id": true, "msg": true, "phase": true, "log": true, "nolog": true, "auditlog": true, "noauditlog": true,
+	"status": true, "tag": true, "ver": true, "rev": true, "severity": true, "maturity": true, "accuracy": true,
+	"capture": true, "logdata": true, "t": true, "chain": true,
+	"deny": true, "drop": true, "block": true, "pass": true, "allow": true,
+}
+
+// secRuleTransforms map the transforms to the ones of check item, t:none and t:lowercase (mapped to (?i)) are
+// handled by secTransforms. The URL decoding alone is done by the default decoding of the check point.
+var secRuleTransforms = map[string][]string{
+	"urldecode":          {models.TransformURLDecode},
+	"urldecodeuni":       {models.TransformURLDecode, models.TransformUnicodeDecode},
+	"htmlentitydecode":   {models.TransformHTMLEntityDecode},
+	"removecomments":     {models.TransformRemoveComments},
+	"compresswhitespace": {models.TransformCompressWhitespace},
+	"base64decode":       {models.TransformBase64Decode},
+	"hexdecode":          {models.TransformHexDecode},
+	"normalizepath":      {models.TransformNormalizePath},
+}
+
+// secRuleSeverityScores map severity (name or number) to the score of group policy, as CRS anomaly scores
+var secRuleSeverityScores = map[string]int64{
+	"emergency": 5, "0": 5, "alert": 5, "1": 5, "critical": 5, "2": 5,
+	"error": 4, "3": 4, "warning": 3, "4": 3, "notice": 2, "5": 2,
+}
+
+// ImportSecRules API, object: {"content": "SecRule ...", "app_id": 0, "dry_run": false}
+func ImportSecRules(param map[string]interface{}, authUser *models.AuthUser) (*models.SecRuleImportResult, error) {
+	obj, ok := param["object"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("object is required")
+	}
+	content, _ := obj["content"].(string)
+	if len(strings.TrimSpace(content)) == 0 {
+		return nil, errors.New("content is empty")
+	}
+	var appID int64
+	if appIDValue, ok := obj["app_id"].(float64); ok {
+		appID = int64(appIDValue)
+	}
+	dryRun, _ := obj["dry_run"].(bool)
+	result := ParseSecRules(content, appID)
+	result.DryRun = dryRun
+	if dryRun {
+		return result, nil
+	}
+	for _, groupPolicy := range result.GroupPolicies {
+		if _, err := SaveGroupPolicy(groupPolicy, authUser.UserID); err != nil {
+			return result, err
+		}
+	}
+	if len(result.GroupPolicies) > 0 {
+		data.UpdateFirewallLastModified()
+	}
+	return result, nil
+}
+
+// ParseSecRules map the SecRule directives to group policies, the constructs can not be mapped are reported in Issues
+func ParseSecRules(content string, appID int64) *models.SecRuleImportResult {
+	result := &models.SecRuleImportResult{GroupPolicies: []*models.GroupPolicy{}, Issues: []*models.SecRuleIssue{}}
+	var chainHead *secRule
+	for _, directive := range splitSecDirectives(content) {
+		args := splitSecArgs(directive.Text)
+		if len(args) == 0 {
+			continue
+		}
+		if strings.EqualFold(args[0], "SecRule") == false {
+			result.Issues = append(result.Issues, &models.SecRuleIssue{
+				Line:    directive.Line,
+				Level:   "error",
+				Message: "Directive " + args[0] + " is not supported",
+			})
+			continue
+		}
+		if len(args) < 3 || len(args) > 4 {
+			result.Issues = append(result.Issues, &models.SecRuleIssue{
+				Line:    directive.Line,
+				Level:   "error",
+				Message: "SecRule requires VARIABLES OPERATOR [ACTIONS]",
+			})
+			continue
+		}
+		rule := &secRule{Line: directive.Line, Variables: args[1], Operator: args[2], Actions: map[string][]string{}}
+		if len(args) == 4 {
+			rule.Actions = parseSecActions(args[3])
+		}
+		_, isChained := rule.Actions["chain"]
+		if chainHead == nil {
+			chainHead = rule
+		} else {
+			chainHead.Chain = append(chainHead.Chain, rule)
+		}
+		if isChained {
+			continue
+		}
+		result.RuleCount++
+		groupPolicies, issues := mapSecRule(chainHead, appID)
+		result.GroupPolicies = append(result.GroupPolicies, groupPolicies...)
+		result.Issues = append(result.Issues, issues...)
+		chainHead = nil
+	}
+	if chainHead != nil {
+		result.RuleCount++
+		result.Issues = append(result.Issues, &models.SecRuleIssue{
+			Line:    chainHead.Line,
+			RuleID:  secRuleActionValue(chainHead, "id"),
+			Level:   "error",
+			Message: "The chain is not completed",
+		})
+	}
+	return result
+}
+
+type secDirective struct {
+	Line int64
+	Text string
+}
+
+// splitSecDirectives join the continued lines and remove the comments
+func splitSecDirectives(content string) []*secDirective {
+	var directives []*secDirective
+	var current *secDirective
+	lines := strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n")
+	for i, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+		if current == nil {
+			if len(trimmedLine) == 0 || strings.HasPrefix(trimmedLine, "#") {
+				continue
+			}
+			current = &secDirective{Line: int64(i + 1)}
+		}
+		if strings.HasSuffix(trimmedLine, `\`) {
+			current.Text += strings.TrimSuffix(trimmedLine, `\`) + " "
+			continue
+		}
+		current.Text += trimmedLine
+		directives = append(directives, current)
+		current = nil
+	}
+	if current != nil {
+		directives = append(directives, current)
+	}
+	return directives
+}
+
+// splitSecArgs split the directive by spaces, double quoted arguments can contain spaces and \"
+func splitSecArgs(text string) []string {
+	var args []string
+	for i := 0; i < len(text); {
+		if text[i] == ' ' || text[i] == '\t' {
+			i++
+			continue
+		}
+		var arg strings.Builder
+		if text[i] == '"' {
+			i++
+			for i < len(text) && text[i] != '"' {
+				if text[i] == '\\' && i+1 < len(text) && text[i+1] == '"' {
+					i++
+				}
+				arg.WriteByte(text[i])
+				i++
+			}
+			i++
+		} else {
+			for i < len(text) && text[i] != ' ' && text[i] != '\t' {
+				arg.WriteByte(text[i])
+				i++
+			}
+		}
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// parseSecActions parse actions like id:942100,msg:'SQL Injection',t:none,t:urlDecodeUni,block
+func parseSecActions(text string) map[string][]string {
+	actions := map[string][]string{}
+	var items []string
+	var item strings.Builder
+	inQuote := false
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\' && i+1 < len(text) && text[i+1] == '\'':
+			item.WriteByte('\'')
+			i++
+		case text[i] == '\'':
+			inQuote = !inQuote
+		case text[i] == ',' && !inQuote:
+			items = append(items, item.String())
+			item.Reset()
+		default:
+			item.WriteByte(text[i])
+		}
+	}
+	items = append(items, item.String())
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		name, value := item, ""
+		if index := strings.Index(item, ":"); index > 0 {
+			name, value = item[:index], strings.TrimSpace(item[index+1:])
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		actions[name] = append(actions[name], value)
+	}
+	return actions
+}
+
+// isSecRuleScoreSetvar return true if all setvar actions are anomaly score counters
+func isSecRuleScoreSetvar(values []string) bool {
+	for _, value := range values {
+		if !strings.Contains(strings.ToLower(value), "score") {
+			return false
+		}
+	}
+	return true
+}
+
+func secRuleActionValue(rule *secRule, name string) string {
+	if values, ok := rule.Actions[name]; ok && len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// mapSecRule map a rule (with its chain) to group policies, a policy for each variable of a single rule,
+// and a policy with a check item for each link of a chained rule.
+func mapSecRule(rule *secRule, appID int64) ([]*models.GroupPolicy, []*models.SecRuleIssue) {
+	ruleID := secRuleActionValue(rule, "id")
+	var issues []*models.SecRuleIssue
+	addIssue := func(line int64, level string, message string) {
+		issues = append(issues, &models.SecRuleIssue{Line: line, RuleID: ruleID, Level: level, Message: message})
+	}
+	action, vulnID := mapSecRuleDisposition(rule)
+	links := append([]*secRule{rule}, rule.Chain...)
+	for _, link := range links {
+		for name := range link.Actions {
+			if name == "setvar" && isSecRuleScoreSetvar(link.Actions[name]) {
+				// replaced by the score of group policy
+				continue
+			}
+			if secRuleIgnoredActions[name] == false {
+				addIssue(link.Line, "warning", "Action "+name+" is not supported and ignored")
+			}
+		}
+	}
+	// targets and check items of each link
+	var linkItems [][]*models.CheckItem
+	for _, link := range links {
+		targets, targetIssues := mapSecVariables(link.Variables)
+		for _, message := range targetIssues {
+			addIssue(link.Line, "warning", message)
+		}
+		if len(targets) == 0 {
+			addIssue(link.Line, "error", "No supported variable in "+link.Variables)
+			return nil, issues
+		}
+		lowercase, transforms := secTransforms(link, addIssue)
+		operation, pattern, err := mapSecOperator(link.Operator, lowercase)
+		if err != nil {
+			addIssue(link.Line, "error", err.Error())
+			return nil, issues
+		}
+		var items []*models.CheckItem
+		for _, target := range targets {
+			checkItem := &models.CheckItem{CheckPoint: target.CheckPoint, Operation: operation, KeyName: target.KeyName, RegexPolicy: pattern, Transforms: transforms}
+			if _, err := CompileCheckItem(checkItem); err != nil {
+				addIssue(link.Line, "error", err.Error()+" (PCRE only syntax like lookaround or backreference is not supported)")
+				return nil, issues
+			}
+			items = append(items, checkItem)
+		}
+		linkItems = append(linkItems, items)
+	}
+	description := "ModSecurity " + ruleID
+	if msg := secRuleActionValue(rule, "msg"); len(msg) > 0 {
+		description += " " + msg
+	}
+	description = truncateString(description, 256)
+	score := int64(models.DefaultPolicyScore)
+	if severityScore, ok := secRuleSeverityScores[strings.ToLower(secRuleActionValue(rule, "severity"))]; ok {
+		score = severityScore
+	}
+	newGroupPolicy := func(checkItems []*models.CheckItem) *models.GroupPolicy {
+		return &models.GroupPolicy{
+			Description: description,
+			Score:       score,
+			AppID:       appID,
+			VulnID:      vulnID,
+			CheckItems:  checkItems,
+			Action:      action,
+			IsEnabled:   true,
+		}
+	}
+	var groupPolicies []*models.GroupPolicy
+	if len(links) == 1 {
+		// Any variable matched
+		for _, checkItem := range linkItems[0] {
+			groupPolicies = append(groupPolicies, newGroupPolicy([]*models.CheckItem{checkItem}))
+		}
+		return groupPolicies, issues
+	}
+	// All links of the chain matched, the hit value of group policy is the sum of check points
+	var checkItems []*models.CheckItem
+	checkPoints := map[models.ChkPoint]bool{}
+	for i, items := range linkItems {
+		if len(items) > 1 {
+			addIssue(links[i].Line, "error", "Multiple variables in a chained rule are not supported")
+			return nil, issues
+		}
+		if checkPoints[items[0].CheckPoint] {
+			addIssue(links[i].Line, "error", "Chained rules on the same variable are not supported")
+			return nil, issues
+		}
+		checkPoints[items[0].CheckPoint] = true
+		checkItems = append(checkItems, items[0])
+	}
+	return []*models.GroupPolicy{newGroupPolicy(checkItems)}, issues
+}
+
+// mapSecRuleDisposition map the disruptive action and tags, the rules without disruptive action are logged only
+func mapSecRuleDisposition(rule *secRule) (models.PolicyAction, int64) {
+	action := models.Action_BypassAndLog_200
+	for _, name := range []string{"deny", "drop", "block"} {
+		if _, ok := rule.Actions[name]; ok {
+			action = models.Action_Block_100
+		}
+	}
+	if _, ok := rule.Actions["allow"]; ok {
+		action = models.Action_Pass_400
+	}
+	vulnID := int64(999)
+	for _, tag := range rule.Actions["tag"] {
+		if id, ok := secRuleVulnTags[strings.ToLower(tag)]; ok {
+			vulnID = id
+			break
+		}
+	}
+	return action, vulnID
+}
+
+// mapSecVariables map variables like ARGS|REQUEST_COOKIES|!REQUEST_COOKIES:/__utm/ to check points
+func mapSecVariables(variables string) ([]*secRuleTarget, []string) {
+	var targets []*secRuleTarget
+	var issues []string
+	for _, variable := range strings.Split(variables, "|") {
+		variable = strings.TrimSpace(variable)
+		if len(variable) == 0 {
+			continue
+		}
+		if strings.HasPrefix(variable, "!") {
+			issues = append(issues, "Exclusion "+variable+" is not supported and ignored, the matching is broader")
+			continue
+		}
+		if strings.HasPrefix(variable, "&") {
+			issues = append(issues, "Counting variable "+variable+" is not supported")
+			continue
+		}
+		name, selector := variable, ""
+		if index := strings.Index(variable, ":"); index > 0 {
+			name, selector = variable[:index], variable[index+1:]
+		}
+		name = strings.ToUpper(name)
+		target, issue := mapSecVariable(name, selector)
+		if target == nil {
+			issues = append(issues, issue)
+			continue
+		}
+		if len(issue) > 0 {
+			issues = append(issues, issue)
+		}
+		targets = append(targets, target)
+	}
+	return targets, issues
+}
+
+func mapSecVariable(name string, selector string) (*secRuleTarget, string) {
+	if strings.HasPrefix(selector, "/") {
+		return nil, "Regex selector of " + name + " is not supported"
+	}
+	switch name {
+	case "REQUEST_HEADERS":
+		if len(selector) == 0 {
+			return nil, "REQUEST_HEADERS requires a header name"
+		}
+		switch headerKey := http.CanonicalHeaderKey(selector); headerKey {
+		case "User-Agent":
+			return &secRuleTarget{CheckPoint: models.ChkPointUserAgent}, ""
+		case "Host":
+			return &secRuleTarget{CheckPoint: models.ChkPointHost}, ""
+		default:
+			return &secRuleTarget{CheckPoint: models.ChkPointHeaderValue, KeyName: headerKey}, ""
+		}
+	case "RESPONSE_HEADERS":
+		if len(selector) == 0 {
+			return nil, "RESPONSE_HEADERS requires a header name"
+		}
+		return &secRuleTarget{CheckPoint: models.ChkPointResponseHeaderValue, KeyName: http.CanonicalHeaderKey(selector)}, ""
+	}
+	var checkPoint models.ChkPoint
+	issue := ""
+	switch name {
+	case "ARGS", "ARGS_GET", "ARGS_POST":
+		checkPoint = models.ChkPointGetPostValue
+	case "ARGS_NAMES", "ARGS_GET_NAMES", "ARGS_POST_NAMES":
+		checkPoint = models.ChkPointGetPostKey
+	case "REQUEST_COOKIES":
+		checkPoint = models.ChkPointCookieValue
+	case "REQUEST_COOKIES_NAMES":
+		checkPoint = models.ChkPointCookieKey
+	case "REQUEST_HEADERS_NAMES":
+		checkPoint = models.ChkPointHeaderKey
+	case "REQUEST_FILENAME", "REQUEST_BASENAME":
+		checkPoint = models.ChkPointURLPath
+	case "REQUEST_URI", "REQUEST_URI_RAW":
+		checkPoint = models.ChkPointURLPath
+		issue = name + " is mapped to the URL path, the query string is not included"
+	case "QUERY_STRING":
+		checkPoint = models.ChkPointURLQuery
+	case "REQUEST_METHOD":
+		checkPoint = models.ChkPointMethod
+	case "REQUEST_PROTOCOL":
+		checkPoint = models.ChkPointProto
+	case "REMOTE_ADDR":
+		checkPoint = models.ChkPointIPAddress
+	case "SERVER_NAME":
+		checkPoint = models.ChkPointHost
+	case "RESPONSE_STATUS":
+		checkPoint = models.ChkPointResponseStatusCode
+	case "RESPONSE_HEADERS_NAMES":
+		checkPoint = models.ChkPointResponseHeaderKey
+	case "RESPONSE_BODY":
+		checkPoint = models.ChkPointResponseBody
+	default:
+		return nil, "Variable " + name + " is not supported"
+	}
+	if len(selector) > 0 {
+		issue = "Selector " + name + ":" + selector + " is not supported and ignored, the matching is broader"
+	}
+	return &secRuleTarget{CheckPoint: checkPoint}, issue
+}
+
+// secTransforms return whether t:lowercase is applied and the transforms of check items in order,
+// t:none reset the transforms, the unsupported ones are reported.
+// The transforms are nil if only the URL decoding is declared, so the default decoding is kept.
+func secTransforms(link *secRule, addIssue func(line int64, level string, message string)) (lowercase bool, transforms []string) {
+	onlyURLDecode := true
+	for _, transform := range link.Actions["t"] {
+		transform = strings.ToLower(transform)
+		switch transform {
+		case "none":
+			lowercase, transforms, onlyURLDecode = false, nil, true
+		case "lowercase":
+			lowercase = true
+		default:
+			mapped, ok := secRuleTransforms[transform]
+			if !ok {
+				addIssue(link.Line, "warning", "Transform t:"+transform+" is not supported and ignored")
+				continue
+			}
+			if transform != "urldecode" && transform != "urldecodeuni" {
+				onlyURLDecode = false
+			}
+			transforms = append(transforms, mapped...)
+		}
+	}
+	if onlyURLDecode {
+		return lowercase, nil
+	}
+	return lowercase, transforms
+}
+
+// mapSecOperator map the operator to the operation and pattern of check item
+func mapSecOperator(operator string, lowercase bool) (models.Operation, string, error) {
+	if strings.HasPrefix(operator, "!") {
+		operation, pattern, err := mapSecOperator(strings.TrimSpace(operator[1:]), lowercase)
+		return operation | models.OperationNot, pattern, err
+	}
+	name, argument := "rx", operator
+	if strings.HasPrefix(operator, "@") {
+		name, argument = operator[1:], ""
+		if index := strings.IndexAny(name, " \t"); index > 0 {
+			name, argument = name[:index], strings.TrimSpace(name[index+1:])
+		}
+	}
+	if strings.Contains(argument, "%{") {
+		return 0, "", errors.New("Macro expansion in " + operator + " is not supported")
+	}
+	caseInsensitive := ""
+	if lowercase {
+		caseInsensitive = "(?i)"
+	}
+	switch strings.ToLower(name) {
+	case "rx":
+		if lowercase && strings.HasPrefix(argument, "(?i)") == false {
+			argument = caseInsensitive + argument
+		}
+		return models.OperationRegexMatch, argument, nil
+	case "pm":
+		var phrases []string
+		for _, phrase := range strings.Fields(argument) {
+			phrases = append(phrases, regexp.QuoteMeta(phrase))
+		}
+		if len(phrases) == 0 {
+			return 0, "", errors.New("@pm requires phrases")
+		}
+		return models.OperationRegexMatch, "(?i)(?:" + strings.Join(phrases, "|") + ")", nil
+	case "contains":
+		return models.OperationRegexMatch, caseInsensitive + regexp.QuoteMeta(argument), nil
+	case "containsword":
+		return models.OperationRegexMatch, caseInsensitive + `\b` + regexp.QuoteMeta(argument) + `\b`, nil
+	case "beginswith":
+		return models.OperationRegexMatch, caseInsensitive + "^" + regexp.QuoteMeta(argument), nil
+	case "endswith":
+		return models.OperationRegexMatch, caseInsensitive + regexp.QuoteMeta(argument) + "$", nil
+	case "streq":
+		if lowercase {
+			return models.OperationEqualsStringCaseInSensitive, argument, nil
+		}
+		return models.OperationRegexMatch, "^" + regexp.QuoteMeta(argument) + "$", nil
+	case "eq":
+		if _, err := strconv.ParseInt(argument, 10, 64); err != nil {
+			return 0, "", errors.New("@eq requires an integer")
+		}
+		return models.OperationEqualsInteger, argument, nil
+	case "gt", "ge":
+		value, err := strconv.ParseInt(argument, 10, 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("@%s requires an integer", name)
+		}
+		if strings.ToLower(name) == "ge" {
+			value--
+		}
+		return models.OperationGreaterThanInteger, strconv.FormatInt(value, 10), nil
+	case "lt", "le":
+		value, err := strconv.ParseInt(argument, 10, 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("@%s requires an integer", name)
+		}
+		if strings.ToLower(name) == "le" {
+			value++
+		}
+		return models.OperationLessThanInteger, strconv.FormatInt(value, 10), nil
+	case "ipmatch":
+		if _, err := parseIPSet(splitListItems(argument)); err != nil {
+			return 0, "", err
+		}
+		return models.OperationIPInCIDR, argument, nil
+	case "detectsqli":
+		return models.OperationDetectSQLi, "", nil
+	case "detectxss":
+		return models.OperationDetectXSS, "", nil
+	}
+	return 0, "", errors.New("Operator @" + name + " is not supported")
+}
+
+func truncateString(value string, maxLength int) string {
+	if len(value) <= maxLength {
+		return value
+	}
+	value = value[:maxLength]
+	for len(value) > 0 && utf8.ValidString(value) == false {
+		value = value[:len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,113 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:24:16
+ * @Last Modified: thonsun, 2026-10-19 14:24:16
+ */
+
+package firewall
+
+import (
+	"strings"
+	"testing"
+
+	"asec/models"
+)
+
+const testSecRules = `
+# Subset of OWASP CRS
+SecRule REQUEST_COOKIES|!REQUEST_COOKIES:/__utm/|ARGS_NAMES|ARGS "@rx (?i)union[\s/\*]+select" \
+    "id:942190,\
+    phase:2,\
+    block,\
+    capture,\
+    t:none,t:urlDecodeUni,\
+    msg:'Detects MSSQL code execution and information gathering attempts',\
+    tag:'attack-sqli',\
+    setvar:'tx.sql_injection_score=+%{tx.critical_anomaly_score}'"
+
+SecRule REQUEST_HEADERS:User-Agent "@pm nikto sqlmap" "id:913100,phase:1,deny,t:lowercase,severity:'WARNING',tag:'attack-reputation-scanner'"
+
+SecRule REQUEST_METHOD "@streq POST" "id:100001,phase:2,deny,chain,msg:'Upload to admin'"
+    SecRule REQUEST_FILENAME "@beginsWith /admin/upload" "t:none"
+
+SecRule ARGS "@detectSQLi" "id:942100,phase:2,block"
+SecRule ARGS "@rx <script" "id:941110,phase:2,block,t:none,t:utf8toUnicode,t:urlDecodeUni,t:htmlEntityDecode,t:removeComments,t:compressWhitespace,t:lowercase"
+SecRule REQUEST_FILENAME "@beginsWith /admin" "id:100003,phase:1,deny,t:urlDecode,t:base64Decode,t:none,t:hexDecode,t:normalizePath"
+SecRule ARGS "@validateByteRange 1-255" "id:920270,phase:2,block"
+SecRule ARGS "@rx (?<=a)b" "id:100002,phase:2,block"
+SecMarker "END-REQUEST-942"
+`
+
+func TestParseSecRules(t *testing.T) {
+	result := ParseSecRules(testSecRules, 0)
+	if result.RuleCount != 8 {
+		t.Fatalf("rule count %d, expected 8", result.RuleCount)
+	}
+	// 942190: a policy for each of REQUEST_COOKIES, ARGS_NAMES and ARGS; 913100; chained 100001; 942100; 941110; 100003
+	if len(result.GroupPolicies) != 8 {
+		t.Fatalf("group policies %d, expected 8", len(result.GroupPolicies))
+	}
+	sqli := result.GroupPolicies[0]
+	if sqli.VulnID != 200 || sqli.Action != models.Action_Block_100 || !strings.HasPrefix(sqli.Description, "ModSecurity 942190 Detects MSSQL") {
+		t.Fatalf("unexpected policy %+v", sqli)
+	}
+	scanner := result.GroupPolicies[3]
+	if sqli.Score != models.DefaultPolicyScore || scanner.Score != 3 {
+		t.Fatalf("unexpected scores %d %d", sqli.Score, scanner.Score)
+	}
+	checkItem := scanner.CheckItems[0]
+	if checkItem.CheckPoint != models.ChkPointUserAgent || checkItem.RegexPolicy != "(?i)(?:nikto|sqlmap)" {
+		t.Fatalf("unexpected check item %+v", checkItem)
+	}
+	chained := result.GroupPolicies[4]
+	if len(chained.CheckItems) != 2 || chained.CheckItems[1].RegexPolicy != "^/admin/upload" {
+		t.Fatalf("unexpected chained policy %+v", chained)
+	}
+	if detector := result.GroupPolicies[5].CheckItems[0]; detector.Operation != models.OperationDetectSQLi {
+		t.Fatalf("unexpected detector check item %+v", detector)
+	}
+	// The URL decoding alone is the default decoding
+	for i := 0; i < 6; i++ {
+		if checkItem := result.GroupPolicies[i].CheckItems[0]; len(checkItem.Transforms) > 0 {
+			t.Fatalf("unexpected transforms %+v", checkItem)
+		}
+	}
+	transformCases := []struct {
+		checkItem  *models.CheckItem
+		pattern    string
+		transforms string
+	}{
+		{result.GroupPolicies[6].CheckItems[0], "(?i)<script", "url_decode,unicode_decode,html_entity_decode,remove_comments,compress_whitespace"},
+		// Reset by t:none
+		{result.GroupPolicies[7].CheckItems[0], "^/admin", "hex_decode,normalize_path"},
+	}
+	for _, c := range transformCases {
+		if c.checkItem.RegexPolicy != c.pattern || strings.Join(c.checkItem.Transforms, ",") != c.transforms {
+			t.Fatalf("unexpected check item %+v", c.checkItem)
+		}
+	}
+	expectedIssues := map[string]string{
+		"942190": "Exclusion !REQUEST_COOKIES:/__utm/",
+		"920270": "Operator @validateByteRange is not supported",
+		"100002": "Invalid regex",
+		"941110": "Transform t:utf8tounicode is not supported",
+		"":       "Directive SecMarker is not supported",
+	}
+	for ruleID, message := range expectedIssues {
+		found := false
+		for _, issue := range result.Issues {
+			if issue.RuleID == ruleID && strings.Contains(issue.Message, message) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("issue %q of rule %q not reported, issues: %+v", message, ruleID, result.Issues)
+		}
+	}
+	for _, issue := range result.Issues {
+		if strings.HasPrefix(issue.Message, "Transform") && !strings.Contains(issue.Message, "utf8tounicode") {
+			t.Fatalf("mapped transform reported %+v", issue)
+		}
+	}
+}
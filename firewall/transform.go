@@ -0,0 +1,273 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:33:10
+ * @Last Modified: thonsun, 2026-10-19 14:33:10
+ */
+
+package firewall
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"asec/models"
+)
+
+const (
+	// maxURLDecodeTimes of TransformURLDecode, for the payloads encoded more than once
+	maxURLDecodeTimes = 3
+	// maxTransformsLength is the size of check_items.transforms
+	maxTransformsLength = 256
+)
+
+type transformFunc func(value string) string
+
+var transformFuncs = map[string]transformFunc{
+	models.TransformURLDecode:          urlDecodeRepeated,
+	models.TransformHTMLEntityDecode:   html.UnescapeString,
+	models.TransformUnicodeDecode:      unicodeDecode,
+	models.TransformBase64Decode:       base64Decode,
+	models.TransformLowercase:          strings.ToLower,
+	models.TransformCompressWhitespace: compressWhitespace,
+	models.TransformRemoveComments:     removeComments,
+	models.TransformNormalizePath:      normalizePath,
+	models.TransformHexDecode:          hexDecode,
+}
+
+// compileTransforms return the functions of transforms in order, unknown transforms are rejected
+func compileTransforms(transforms []string) ([]transformFunc, error) {
+	var funcs []transformFunc
+	for _, transform := range transforms {
+		fn, ok := transformFuncs[transform]
+		if !ok {
+			return nil, errors.New("Unknown transform " + transform)
+		}
+		funcs = append(funcs, fn)
+	}
+	return funcs, nil
+}
+
+// ApplyTransforms apply the transforms in order and return the value after each step
+func ApplyTransforms(value string, transforms []string) ([]*models.TransformStep, error) {
+	funcs, err := compileTransforms(transforms)
+	if err != nil {
+		return nil, err
+	}
+	steps := []*models.TransformStep{}
+	for i, fn := range funcs {
+		value = fn(value)
+		steps = append(steps, &models.TransformStep{Transform: transforms[i], Value: value})
+	}
+	return steps, nil
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// urlDecode decode %XX and + leniently, invalid escapes are kept as they are
+func urlDecode(value string) string {
+	if strings.IndexByte(value, '%') < 0 && strings.IndexByte(value, '+') < 0 {
+		return value
+	}
+	var builder strings.Builder
+	builder.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '+':
+			builder.WriteByte(' ')
+		case '%':
+			if i+2 < len(value) {
+				high, ok1 := unhex(value[i+1])
+				low, ok2 := unhex(value[i+2])
+				if ok1 && ok2 {
+					builder.WriteByte(high<<4 | low)
+					i += 2
+					continue
+				}
+			}
+			builder.WriteByte('%')
+		default:
+			builder.WriteByte(value[i])
+		}
+	}
+	return builder.String()
+}
+
+// urlDecodeRepeated decode until nothing changed, for the payloads like %2527
+func urlDecodeRepeated(value string) string {
+	for i := 0; i < maxURLDecodeTimes; i++ {
+		decoded := urlDecode(value)
+		if decoded == value {
+			break
+		}
+		value = decoded
+	}
+	return value
+}
+
+// unicodeDecode decode %uXXXX and \uXXXX to UTF-8
+func unicodeDecode(value string) string {
+	if strings.Contains(value, "%u") == false && strings.Contains(value, `\u`) == false {
+		return value
+	}
+	var builder strings.Builder
+	builder.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if (value[i] == '%' || value[i] == '\\') && i+5 < len(value) && (value[i+1] == 'u' || value[i+1] == 'U') {
+			if code, err := strconv.ParseUint(value[i+2:i+6], 16, 16); err == nil {
+				builder.WriteRune(rune(code))
+				i += 5
+				continue
+			}
+		}
+		builder.WriteByte(value[i])
+	}
+	return builder.String()
+}
+
+// base64Decode decode the standard or URL encoding with or without padding, the value is kept if it is not base64
+func base64Decode(value string) string {
+	trimmed := strings.TrimSpace(value)
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if decoded, err := encoding.DecodeString(trimmed); err == nil {
+			return string(decoded)
+		}
+	}
+	return value
+}
+
+// compressWhitespace replace each run of whitespace with a single space, invalid UTF-8 bytes are kept
+func compressWhitespace(value string) string {
+	var builder strings.Builder
+	builder.Grow(len(value))
+	inSpace := false
+	for i := 0; i < len(value); {
+		r, size := utf8.DecodeRuneInString(value[i:])
+		if unicode.IsSpace(r) {
+			if !inSpace {
+				builder.WriteByte(' ')
+			}
+			inSpace = true
+		} else {
+			inSpace = false
+			builder.WriteString(value[i : i+size])
+		}
+		i += size
+	}
+	return builder.String()
+}
+
+// removeComments replace /* */ and <!-- --> comments with a space, so UNION/**/SELECT becomes UNION SELECT,
+// an unterminated comment is removed to the end.
+func removeComments(value string) string {
+	var builder strings.Builder
+	builder.Grow(len(value))
+	for len(value) > 0 {
+		start, begin, end := strings.Index(value, "/*"), "/*", "*/"
+		if htmlStart := strings.Index(value, "<!--"); htmlStart >= 0 && (start < 0 || htmlStart < start) {
+			start, begin, end = htmlStart, "<!--", "-->"
+		}
+		if start < 0 {
+			builder.WriteString(value)
+			break
+		}
+		builder.WriteString(value[:start])
+		builder.WriteByte(' ')
+		value = value[start+len(begin):]
+		index := strings.Index(value, end)
+		if index < 0 {
+			break
+		}
+		value = value[index+len(end):]
+	}
+	return builder.String()
+}
+
+// normalizePath convert \ to /, remove empty and . segments and resolve .. segments,
+// a .. without parent is kept, so the traversal is still visible.
+func normalizePath(value string) string {
+	value = strings.Replace(value, `\`, `/`, -1)
+	if value == "" {
+		return value
+	}
+	absolute := strings.HasPrefix(value, "/")
+	trailing := strings.HasSuffix(value, "/") || strings.HasSuffix(value, "/.") || strings.HasSuffix(value, "/..")
+	var segments []string
+	for _, segment := range strings.Split(value, "/") {
+		switch segment {
+		case "", ".":
+		case "..":
+			if len(segments) > 0 && segments[len(segments)-1] != ".." {
+				segments = segments[:len(segments)-1]
+			} else {
+				segments = append(segments, segment)
+			}
+		default:
+			segments = append(segments, segment)
+		}
+	}
+	normalized := strings.Join(segments, "/")
+	if absolute {
+		normalized = "/" + normalized
+	}
+	if trailing && len(segments) > 0 {
+		normalized += "/"
+	}
+	return normalized
+}
+
+// hexDecode decode \xHH escapes and 0x hex literals (e.g. 0x61646d696e), other text is kept
+func hexDecode(value string) string {
+	var builder strings.Builder
+	builder.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+3 < len(value) && value[i+1] == 'x' {
+			high, ok1 := unhex(value[i+2])
+			low, ok2 := unhex(value[i+3])
+			if ok1 && ok2 {
+				builder.WriteByte(high<<4 | low)
+				i += 3
+				continue
+			}
+		}
+		if value[i] == '0' && i+1 < len(value) && (value[i+1] == 'x' || value[i+1] == 'X') && (i == 0 || !isWordByte(value[i-1])) {
+			end := i + 2
+			for end < len(value) {
+				if _, ok := unhex(value[end]); !ok {
+					break
+				}
+				end++
+			}
+			digits := value[i+2 : end]
+			if len(digits) >= 2 && len(digits)%2 == 0 && (end == len(value) || !isWordByte(value[end])) {
+				if decoded, err := hex.DecodeString(digits); err == nil && utf8.Valid(decoded) {
+					builder.Write(decoded)
+					i = end - 1
+					continue
+				}
+			}
+		}
+		builder.WriteByte(value[i])
+	}
+	return builder.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
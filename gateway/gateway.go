@@ -12,13 +12,11 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"os"
 	"strings"
-	"syscall"
 	"time"
 
 	"asec/backend"
@@ -66,7 +64,23 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	// dynamic
 	srcIP := GetClientIP(r, app)
 	isStatic := firewall.IsStaticResource(r)
-	if app.WAFEnabled && !isStatic {
+	// IP ACLs are evaluated before CC and group policies, the allowed clients skip them
+	isAllowed := false
+	if ipACL := firewall.IsIPACLHit(app.ID, srcIP); ipACL != nil {
+		switch action, monitor := firewall.GetEnforcedAction(app, ipACL.Action); action {
+		case models.Action_Pass_400:
+			isAllowed = true
+		case models.Action_BypassAndLog_200:
+			// Monitor mode, log the action it would take but never enforce it
+			go firewall.LogACLHitRequest(r, app.ID, srcIP, ipACL, monitor)
+		default:
+			go firewall.LogACLHitRequest(r, app.ID, srcIP, ipACL, false)
+			hitInfo := &models.HitInfo{TypeID: 3, PolicyID: ipACL.ID, VulnName: "IP Access Control"}
+			GenerateBlockPage(w, hitInfo)
+			return
+		}
+	}
+	if app.WAFEnabled && !isStatic && !isAllowed {
 		if isCC, ccPolicy, clientID, needLog := firewall.IsCCAttack(r, app.ID, srcIP); isCC == true {
 			targetURL := r.URL.Path
 			if len(r.URL.RawQuery) > 0 {
@@ -79,10 +93,11 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 				ClientID:  clientID,
 				TargetURL: targetURL,
 				BlockTime: time.Now().Unix()}
-			switch ccPolicy.Action {
+			action, monitor := firewall.GetEnforcedAction(app, ccPolicy.Action)
+			switch action {
 			case models.Action_Block_100:
 				if needLog {
-					go firewall.LogCCRequest(r, app.ID, srcIP, ccPolicy)
+					go firewall.LogCCRequest(r, app.ID, srcIP, ccPolicy, false)
 				}
 				if app.ClientIPMethod == models.IPMethod_REMOTE_ADDR {
 					go firewall.AddIP2NFTables(srcIP, ccPolicy.BlockSeconds)
@@ -91,11 +106,11 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 				return
 			case models.Action_BypassAndLog_200:
 				if needLog {
-					go firewall.LogCCRequest(r, app.ID, srcIP, ccPolicy)
+					go firewall.LogCCRequest(r, app.ID, srcIP, ccPolicy, monitor)
 				}
 			case models.Action_CAPTCHA_300:
 				if needLog {
-					go firewall.LogCCRequest(r, app.ID, srcIP, ccPolicy)
+					go firewall.LogCCRequest(r, app.ID, srcIP, ccPolicy, false)
 				}
 				captchaHitInfo.Store(hitInfo.ClientID, hitInfo)
 				captchaURL := CaptchaEntrance + "?id=" + hitInfo.ClientID
@@ -104,18 +119,33 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		if isHit, policy := firewall.IsRequestHitPolicy(r, app.ID, srcIP); isHit == true {
-			switch policy.Action {
+		var isHit bool
+		var policy *models.GroupPolicy
+		var anomalyScore *models.AnomalyScore
+		if app.ScoringEnabled {
+			isHit, policy, anomalyScore = firewall.IsRequestHitAnomalyScore(r, app, srcIP)
+		} else {
+			isHit, policy = firewall.IsRequestHitPolicy(r, app.ID, srcIP)
+		}
+		firewall.LogExcludedHits(r, app.ID, srcIP)
+		if !isHit && anomalyScore != nil && app.MonitorMode {
+			// Below the threshold, logged in monitor mode to tune the thresholds
+			go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy, anomalyScore, true)
+		}
+		if isHit == true {
+			action, monitor := firewall.GetEnforcedAction(app, policy.Action)
+			switch action {
 			case models.Action_Block_100:
 				vulnName, _ := firewall.VulnMap.Load(policy.VulnID)
 				hitInfo := &models.HitInfo{TypeID: 2, PolicyID: policy.ID, VulnName: vulnName.(string)}
-				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy)
+				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy, anomalyScore, false)
 				GenerateBlockPage(w, hitInfo)
 				return
 			case models.Action_BypassAndLog_200:
-				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy)
+				// In monitor mode, the hit is logged with the action it would take
+				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy, anomalyScore, monitor)
 			case models.Action_CAPTCHA_300:
-				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy)
+				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy, anomalyScore, false)
 				clientID := GenClientID(r, app.ID, srcIP)
 				targetURL := r.URL.Path
 				if len(r.URL.RawQuery) > 0 {
@@ -264,69 +294,15 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 	}
 	http2.ConfigureTransport(transport)
 
-	// Check static cache
-	if isStatic {
-		// First check Header Range, not cache for range
-		rangeValue := r.Header.Get("Range")
-		if rangeValue == "" {
-			staticRoot := fmt.Sprintf("./static/cdncache/%d", app.ID)
-			targetFile := staticRoot + r.URL.Path
-			// Check Static Cache
-			if fi, err := os.Stat(targetFile); err == nil {
-				// Found targetFile
-				now := time.Now()
-				fiStat := fi.Sys().(*syscall.Stat_t)
-				// Use ctime fiStat.Ctim.Sec to mark the last check time
-				pastSeconds := now.Unix() - fiStat.Ctim.Sec
-				if pastSeconds > 1800 {
-					// check update
-					go func() {
-						backendAddr := fmt.Sprintf("%s://%s%s", app.InternalScheme, dest.Destination, r.RequestURI)
-						req, err := http.NewRequest("GET", backendAddr, nil)
-						if err != nil {
-							utils.DebugPrintln("Check Update NewRequest", err)
-						}
-						if err == nil {
-							req.Header.Set("Host", r.Host)
-							modTimeGMT := fi.ModTime().UTC().Format(http.TimeFormat)
-							//If-Modified-Since: Sun, 14 Jun 2020 13:54:20 GMT
-							req.Header.Set("If-Modified-Since", modTimeGMT)
-							client := http.Client{
-								Transport: transport,
-							}
-							resp, err := client.Do(req)
-							if err != nil {
-								utils.DebugPrintln("Cache update Do", err)
-								return
-							}
-							defer resp.Body.Close()
-							if resp.StatusCode == http.StatusOK {
-								//fmt.Println("200", backendAddr)
-								bodyBuf, _ := ioutil.ReadAll(resp.Body)
-								err = ioutil.WriteFile(targetFile, bodyBuf, 0666)
-								lastModified, err := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
-								if err != nil {
-									utils.DebugPrintln("CDN Parse Last-Modified", targetFile, err)
-								}
-								err = os.Chtimes(targetFile, now, lastModified)
-								if err != nil {
-									utils.DebugPrintln("CDN Chtimes", targetFile, err)
-								}
-							} else if resp.StatusCode == http.StatusNotModified {
-								//fmt.Println("304", backendAddr)
-								err := os.Chtimes(targetFile, now, fi.ModTime())
-								if err != nil {
-									utils.DebugPrintln("Cache update access time", err)
-								}
-							}
-						}
-					}()
-				}
-				http.ServeFile(w, r, targetFile)
-				return
-			}
+	// Check static cache, not cache for Range
+	cacheable := isStatic && r.Header.Get("Range") == ""
+	var targetFile string
+	if cacheable {
+		targetFile = fmt.Sprintf("./static/cdncache/%d", app.ID) + r.URL.Path
+		if serveStaticCache(w, r, app, dest, transport, targetFile) {
+			return
 		}
-		// Has Range Header, or resource Not Found, Continue
+		// Resource Not Found or must be revalidated, Continue
 	}
 
 	// Reverse Proxy
@@ -342,6 +318,20 @@ func ReverseHandlerFunc(w http.ResponseWriter, r *http.Request) {
 		//utils.CheckError("ReverseHandlerFunc DumpRequest", err)
 		//fmt.Println(string(dump))
 	}
+	if cacheable {
+		// Collapsed forwarding, the other requests wait for the cache file
+		shared, _ := staticCacheFlight.Do(targetFile, func() error {
+			proxy.ServeHTTP(w, r)
+			return nil
+		})
+		if !shared {
+			return
+		}
+		if _, err := os.Stat(targetFile); err == nil {
+			http.ServeFile(w, r, targetFile)
+			return
+		}
+	}
 	proxy.ServeHTTP(w, r)
 }
 
@@ -13,8 +13,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
-	"path/filepath"
 	"time"
 
 	//"net/http/httputil"
@@ -54,22 +52,42 @@ func rewriteResponse(resp *http.Response) (err error) {
 	}
 
 	srcIP := GetClientIP(r, app)
+	// The response is inspected and masked for the clients allowed by IP ACLs too,
+	// the allow entries only skip the checks of requests.
 	if app.WAFEnabled {
-		if isHit, policy := firewall.IsResponseHitPolicy(resp, app.ID); isHit {
-			switch policy.Action {
+		var isHit bool
+		var policy *models.GroupPolicy
+		var anomalyScore *models.AnomalyScore
+		if app.ScoringEnabled {
+			isHit, policy, anomalyScore = firewall.IsResponseHitAnomalyScore(resp, app)
+		} else {
+			isHit, policy = firewall.IsResponseHitPolicy(resp, app.ID, firewall.ResponseInspectBytes(app))
+		}
+		firewall.LogExcludedHits(r, app.ID, srcIP)
+		if !isHit && anomalyScore != nil && app.MonitorMode {
+			// Below the threshold, logged in monitor mode to tune the thresholds
+			go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy, anomalyScore, true)
+		}
+		if isHit {
+			action, monitor := firewall.GetEnforcedAction(app, policy.Action)
+			switch action {
 			case models.Action_Block_100:
 				vulnName, _ := firewall.VulnMap.Load(policy.VulnID)
 				hitInfo := &models.HitInfo{TypeID: 2, PolicyID: policy.ID, VulnName: vulnName.(string)}
-				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy)
+				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy, anomalyScore, false)
 				blockContent := GenerateBlockConcent(hitInfo)
 				//fmt.Println("rewriteResponse Action_Block_100 blockContent", string(blockContent))
 				body := ioutil.NopCloser(bytes.NewReader(blockContent))
+				// The remaining of the origin body is not streamed
+				resp.Body.Close()
 				resp.Body = body
+				resp.Header.Del("Content-Encoding")
 				resp.ContentLength = int64(len(blockContent))
 				resp.StatusCode = 403
 				return nil
 			case models.Action_BypassAndLog_200:
-				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy)
+				// In monitor mode, the hit is logged with the action it would take
+				go firewall.LogGroupHitRequest(r, app.ID, srcIP, policy, anomalyScore, monitor)
 			case models.Action_CAPTCHA_300:
 				clientID := GenClientID(r, app.ID, srcIP)
 				targetURL := r.URL.Path
@@ -90,6 +108,7 @@ func rewriteResponse(resp *http.Response) (err error) {
 				// models.Action_Pass_400 do nothing
 			}
 		}
+		firewall.MaskResponseBody(resp, app, srcIP)
 	}
 
 	// HSTS
@@ -101,24 +120,23 @@ func rewriteResponse(resp *http.Response) (err error) {
 	if resp.StatusCode == http.StatusOK && firewall.IsStaticResource(r) {
 		staticRoot := fmt.Sprintf("./static/cdncache/%d", app.ID)
 		targetFile := staticRoot + r.URL.Path
-		cacheFilePath := filepath.Dir(targetFile)
 		bodyBuf, _ := ioutil.ReadAll(resp.Body)
 		resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBuf))
-
-		err := os.MkdirAll(cacheFilePath, 0666)
-		if err != nil {
-			utils.DebugPrintln("Cache Path Error", err)
-		}
 		contentEncoding := resp.Header.Get("Content-Encoding")
 		switch contentEncoding {
 		case "gzip":
 			reader, err := gzip.NewReader(bytes.NewBuffer(bodyBuf))
+			if err != nil {
+				utils.DebugPrintln("Gzip decompress Error", err)
+				return nil
+			}
 			defer reader.Close()
 			decompressedBodyBuf, err := ioutil.ReadAll(reader)
 			if err != nil {
 				utils.DebugPrintln("Gzip decompress Error", err)
+				return nil
 			}
-			err = ioutil.WriteFile(targetFile, decompressedBodyBuf, 0666)
+			err = writeStaticCacheFile(targetFile, decompressedBodyBuf, resp.Header.Get("Last-Modified"))
 		/*
 			case "deflate":
 				reader := flate.NewReader(bytes.NewBuffer(bodyBuf))
@@ -127,20 +145,14 @@ func rewriteResponse(resp *http.Response) (err error) {
 				utils.DebugPrintln("flate decompress Error", err)
 				err = ioutil.WriteFile(targetFile, decompressedBodyBuf, 0666)
 		*/
+		case "", "identity":
+			err = writeStaticCacheFile(targetFile, bodyBuf, resp.Header.Get("Last-Modified"))
 		default:
-			err = ioutil.WriteFile(targetFile, bodyBuf, 0666)
-		}
-		if err != nil {
-			utils.DebugPrintln("Cache File Error", targetFile, err)
-		}
-		lastModified, err := time.Parse(http.TimeFormat, resp.Header.Get("Last-Modified"))
-		if err != nil {
-			utils.DebugPrintln("Cache File Parse Last-Modified", targetFile, err)
+			// Unknown encoding, not cache
 			return nil
 		}
-		err = os.Chtimes(targetFile, time.Now(), lastModified)
 		if err != nil {
-			utils.DebugPrintln("Cache File Chtimes", targetFile, err)
+			utils.DebugPrintln("Cache File Error", targetFile, err)
 		}
 	}
 	//body, err := httputil.DumpResponse(resp, true)
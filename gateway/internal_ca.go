@@ -0,0 +1,39 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:13:50
+ * @Last Modified: thonsun, 2026-10-19 14:13:50
+ */
+
+package gateway
+
+import (
+	"encoding/pem"
+	"net/http"
+
+	"asec/backend"
+)
+
+// InternalCAHandlerFunc download the root certificate of internal CA, /asec-admin/ca.crt?format=der,
+// served by the admin listener only, not by the gateway of applications.
+func InternalCAHandlerFunc(w http.ResponseWriter, r *http.Request) {
+	rootContent, err := backend.GetInternalCARootContent()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("format") == "der" {
+		block, _ := pem.Decode(rootContent)
+		if block == nil {
+			http.Error(w, "Invalid root certificate of internal CA", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-cert")
+		w.Header().Set("Content-Disposition", `attachment; filename="asec-ca.cer"`)
+		w.Write(block.Bytes)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+	w.Header().Set("Content-Disposition", `attachment; filename="asec-ca.crt"`)
+	w.Write(rootContent)
+}
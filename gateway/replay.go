@@ -0,0 +1,64 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 15:08:46
+ * @Last Modified: thonsun, 2026-10-19 15:08:46
+ */
+
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"asec/backend"
+	"asec/firewall"
+	"asec/models"
+)
+
+// ReplayRequestAPI object: {"app_id": 1, "raw_request": "GET /?id=1 HTTP/1.1\r\nHost: www.example.com\r\n\r\n", "client_ip": "1.2.3.4"},
+// client_ip is optional and acquired by the client IP method of the application if empty.
+func ReplayRequestAPI(param map[string]interface{}) (*models.ReplayResult, error) {
+	obj, ok := param["object"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("object is required")
+	}
+	appID, _ := obj["app_id"].(float64)
+	app, err := backend.GetApplicationByID(int64(appID))
+	if err != nil {
+		return nil, err
+	}
+	rawRequest, _ := obj["raw_request"].(string)
+	reader := bufio.NewReader(strings.NewReader(rawRequest))
+	r, err := http.ReadRequest(reader)
+	if err != nil {
+		return nil, errors.New("Invalid raw_request: " + err.Error())
+	}
+	if r.ContentLength <= 0 && len(r.TransferEncoding) == 0 {
+		// The raw requests of hit logs may have a body without Content-Length
+		body, _ := ioutil.ReadAll(reader)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+	}
+	clientIP, _ := obj["client_ip"].(string)
+	clientIP = strings.TrimSpace(clientIP)
+	if len(clientIP) > 0 && net.ParseIP(clientIP) == nil {
+		return nil, errors.New("Invalid client_ip " + clientIP)
+	}
+	r.RemoteAddr = "127.0.0.1:0"
+	if len(clientIP) > 0 {
+		r.RemoteAddr = net.JoinHostPort(clientIP, "0")
+	}
+	r.URL.Scheme = app.InternalScheme
+	r.URL.Host = r.Host
+	srcIP := clientIP
+	if len(srcIP) == 0 {
+		srcIP = GetClientIP(r, app)
+	}
+	return firewall.ReplayRequest(r, app, srcIP), nil
+}
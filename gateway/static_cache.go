@@ -0,0 +1,189 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:01:16
+ * @Last Modified: thonsun, 2026-10-19 14:01:16
+ */
+
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+var (
+	// staticCacheFlight collapse the backend requests of the same cache file
+	staticCacheFlight = &cacheFlight{calls: map[string]*cacheCall{}}
+)
+
+type cacheCall struct {
+	wg  sync.WaitGroup
+	err error
+}
+
+// cacheFlight make sure only one backend fetch is running for each key
+type cacheFlight struct {
+	mu    sync.Mutex
+	calls map[string]*cacheCall
+}
+
+// Do execute fn once for the concurrent callers of the same key,
+// shared is true for the callers which waited for another one.
+func (f *cacheFlight) Do(key string, fn func() error) (shared bool, err error) {
+	f.mu.Lock()
+	if call, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		call.wg.Wait()
+		return true, call.err
+	}
+	call := new(cacheCall)
+	call.wg.Add(1)
+	f.calls[key] = call
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, key)
+		f.mu.Unlock()
+		call.wg.Done()
+	}()
+	call.err = fn()
+	return false, call.err
+}
+
+// DoBackground run fn in a new goroutine unless the key is already in flight
+func (f *cacheFlight) DoBackground(key string, fn func() error) {
+	f.mu.Lock()
+	_, running := f.calls[key]
+	f.mu.Unlock()
+	if running {
+		return
+	}
+	go func() {
+		_, err := f.Do(key, fn)
+		if err != nil {
+			utils.DebugPrintln("Cache revalidate", key, err)
+		}
+	}()
+}
+
+// serveStaticCache serve the cached file, return false if the request should be forwarded to backend
+func serveStaticCache(w http.ResponseWriter, r *http.Request, app *models.Application, dest *models.Destination, transport http.RoundTripper, targetFile string) bool {
+	fi, err := os.Stat(targetFile)
+	if err != nil {
+		return false
+	}
+	// Use ctime fiStat.Ctim.Sec to mark the last check time
+	fiStat := fi.Sys().(*syscall.Stat_t)
+	pastSeconds := time.Now().Unix() - fiStat.Ctim.Sec
+	revalidateSeconds, staleWhileRevalidateSeconds, staleIfErrorSeconds := data.GetCacheWindows()
+	if pastSeconds <= revalidateSeconds {
+		http.ServeFile(w, r, targetFile)
+		return true
+	}
+	backendAddr := fmt.Sprintf("%s://%s%s", app.InternalScheme, dest.Destination, r.RequestURI)
+	host := r.Host
+	revalidate := func() error {
+		return revalidateStaticCache(backendAddr, host, transport, targetFile, fi)
+	}
+	staleSeconds := pastSeconds - revalidateSeconds
+	if staleSeconds <= staleWhileRevalidateSeconds {
+		// stale-while-revalidate
+		staticCacheFlight.DoBackground(targetFile, revalidate)
+		http.ServeFile(w, r, targetFile)
+		return true
+	}
+	_, err = staticCacheFlight.Do(targetFile, revalidate)
+	if err != nil {
+		utils.DebugPrintln("Cache revalidate", targetFile, err)
+		if staleSeconds <= staleIfErrorSeconds {
+			// stale-if-error
+			http.ServeFile(w, r, targetFile)
+			return true
+		}
+		return false
+	}
+	if _, err := os.Stat(targetFile); err != nil {
+		return false
+	}
+	http.ServeFile(w, r, targetFile)
+	return true
+}
+
+// revalidateStaticCache check update of the cached file with If-Modified-Since
+func revalidateStaticCache(backendAddr string, host string, transport http.RoundTripper, targetFile string, fi os.FileInfo) error {
+	req, err := http.NewRequest("GET", backendAddr, nil)
+	if err != nil {
+		return err
+	}
+	req.Host = host
+	//If-Modified-Since: Sun, 14 Jun 2020 13:54:20 GMT
+	req.Header.Set("If-Modified-Since", fi.ModTime().UTC().Format(http.TimeFormat))
+	client := http.Client{
+		Transport: transport,
+		Timeout:   60 * time.Second,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		bodyBuf, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return writeStaticCacheFile(targetFile, bodyBuf, resp.Header.Get("Last-Modified"))
+	case http.StatusNotModified:
+		return os.Chtimes(targetFile, time.Now(), fi.ModTime())
+	case http.StatusNotFound, http.StatusGone:
+		// Removed from backend, forward the following requests
+		return os.Remove(targetFile)
+	}
+	return errors.New("backend response " + resp.Status)
+}
+
+// writeStaticCacheFile replace the cached file atomically, so that it can be served during update
+func writeStaticCacheFile(targetFile string, body []byte, lastModified string) error {
+	cacheFilePath := filepath.Dir(targetFile)
+	err := os.MkdirAll(cacheFilePath, 0755)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(cacheFilePath, ".cache-")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(body)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpFile.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), targetFile)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	now := time.Now()
+	modTime, err := time.Parse(http.TimeFormat, lastModified)
+	if err != nil {
+		modTime = now
+	}
+	return os.Chtimes(targetFile, now, modTime)
+}
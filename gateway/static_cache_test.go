@@ -0,0 +1,180 @@
+/*
+ * @Copyright Reserved By asec (https://www.asec.com/).
+ * @Author: thonsun
+ * @Date: 2026-10-19 14:01:16
+ * @Last Modified: thonsun, 2026-10-19 14:01:16
+ */
+
+package gateway
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"asec/data"
+	"asec/models"
+	"asec/utils"
+)
+
+func init() {
+	// The revalidation errors are printed instead of the log file
+	utils.Debug = true
+}
+
+func TestCacheFlight(t *testing.T) {
+	flight := &cacheFlight{calls: map[string]*cacheCall{}}
+	started, release := make(chan struct{}), make(chan struct{})
+	var calls, sharedCount int64
+	fn := func() error {
+		atomic.AddInt64(&calls, 1)
+		close(started)
+		<-release
+		return errors.New("backend down")
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if shared, err := flight.Do("a.js", fn); shared || err == nil {
+			t.Error("unexpected first call", shared, err)
+		}
+	}()
+	<-started
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The waiting callers get the error of the running one
+			if shared, err := flight.Do("a.js", fn); shared && err != nil {
+				atomic.AddInt64(&sharedCount, 1)
+			}
+		}()
+	}
+	// Not started while a.js is in flight
+	flight.DoBackground("a.js", func() error {
+		t.Error("background call started while in flight")
+		return nil
+	})
+	// Other keys are not blocked
+	if shared, err := flight.Do("b.js", func() error { return nil }); shared || err != nil {
+		t.Error("unexpected call of other key", shared, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 || sharedCount != 5 {
+		t.Fatalf("%d calls, %d shared, expected 1 and 5", calls, sharedCount)
+	}
+
+	done := make(chan struct{})
+	flight.DoBackground("a.js", func() error {
+		close(done)
+		return nil
+	})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("background call not started")
+	}
+}
+
+func TestServeStaticCache(t *testing.T) {
+	var mu sync.Mutex
+	backendRequests := map[string]int{}
+	requestCount := func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return backendRequests[path]
+	}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		backendRequests[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/updated.js":
+			w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+			w.Write([]byte("updated"))
+		case "/removed.js":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer backend.Close()
+	oldRevalidate, oldStaleWhileRevalidate, oldStaleIfError := data.GetCacheWindows()
+	defer func() {
+		data.SetCacheWindow("Cache_Revalidate_Seconds", oldRevalidate)
+		data.SetCacheWindow("Cache_Stale_While_Revalidate_Seconds", oldStaleWhileRevalidate)
+		data.SetCacheWindow("Cache_Stale_If_Error_Seconds", oldStaleIfError)
+	}()
+	cacheDir, err := ioutil.TempDir("", "asec-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	cases := []struct {
+		name                 string
+		path                 string
+		revalidate           int64
+		staleWhileRevalidate int64
+		staleIfError         int64
+		served               bool
+		body                 string
+		// backend is true if the backend is requested before the response,
+		// the stale file is revalidated in background after the response
+		backend bool
+	}{
+		{"fresh", "/fresh.js", 3600, 0, 0, true, "cached", false},
+		{"stale while revalidate", "/stale.js", 0, 3600, 0, true, "cached", false},
+		{"stale if error", "/error.js", 0, 0, 3600, true, "cached", true},
+		{"error out of windows", "/down.js", 0, 0, 0, false, "", true},
+		{"updated", "/updated.js", 0, 0, 3600, true, "updated", true},
+		{"removed", "/removed.js", 0, 0, 3600, false, "", true},
+	}
+	for _, c := range cases {
+		if err := writeStaticCacheFile(filepath.Join(cacheDir, c.path), []byte("cached"), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The cached files are checked by ctime in seconds
+	time.Sleep(1100 * time.Millisecond)
+	app := &models.Application{ID: 1, InternalScheme: "http"}
+	dest := &models.Destination{Destination: strings.TrimPrefix(backend.URL, "http://")}
+	for _, c := range cases {
+		data.SetCacheWindow("Cache_Revalidate_Seconds", c.revalidate)
+		data.SetCacheWindow("Cache_Stale_While_Revalidate_Seconds", c.staleWhileRevalidate)
+		data.SetCacheWindow("Cache_Stale_If_Error_Seconds", c.staleIfError)
+		r := httptest.NewRequest("GET", c.path, nil)
+		w := httptest.NewRecorder()
+		served := serveStaticCache(w, r, app, dest, http.DefaultTransport, filepath.Join(cacheDir, c.path))
+		if served != c.served || (served && w.Body.String() != c.body) {
+			t.Errorf("%s: served %v %q, expected %v %q", c.name, served, w.Body.String(), c.served, c.body)
+		}
+		if c.backend && requestCount(c.path) != 1 {
+			t.Errorf("%s: %d backend requests", c.name, requestCount(c.path))
+		}
+		if !c.backend && c.staleWhileRevalidate == 0 && requestCount(c.path) != 0 {
+			t.Errorf("%s: backend requested", c.name)
+		}
+	}
+	// The stale file is revalidated in background
+	for i := 0; i < 100 && requestCount("/stale.js") == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if requestCount("/stale.js") != 1 {
+		t.Error("stale file not revalidated")
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, "removed.js")); !os.IsNotExist(err) {
+		t.Error("removed file is still cached", err)
+	}
+}
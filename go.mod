@@ -3,6 +3,7 @@ module asec
 go 1.14
 
 require (
+	github.com/andybalholm/brotli v1.0.6
 	github.com/dchest/captcha v0.0.0-20200903113550-03f5f0333e1f
 	github.com/go-ldap/ldap v3.0.3+incompatible
 	github.com/google/nftables v0.0.0-20200802175506-c25e4f69b425
@@ -12,7 +13,8 @@ require (
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/yookoala/gofast v0.4.0
-	golang.org/x/crypto v0.0.0-20200820211705-5c72a883971a
+	golang.org/x/crypto v0.0.0-20210220033148-5ea612d1eb83
 	golang.org/x/net v0.0.0-20200904194848-62affa334b73
 	gopkg.in/asn1-ber.v1 v1.0.0-20181015200546-f715ec2f112d // indirect
+	software.sslmate.com/src/go-pkcs12 v0.0.0-20210415151418-c5206de65a78
 )
@@ -11,6 +11,7 @@ import (
 	"crypto/tls"
 	"database/sql"
 	"sync"
+	"sync/atomic"
 )
 
 type Application struct {
@@ -32,47 +33,74 @@ type Application struct {
 	OAuthRequired  bool      `json:"oauth_required"`
 	SessionSeconds int64     `json:"session_seconds"`
 	Owner          string    `json:"owner"`
+	// TLSProfileID 0 for the default TLS config
+	TLSProfileID int64 `json:"tls_profile_id"`
+	// ScoringEnabled block the request (response) only if the anomaly score reach the inbound (outbound) threshold
+	ScoringEnabled    bool  `json:"scoring_enabled"`
+	InboundThreshold  int64 `json:"inbound_threshold"`
+	OutboundThreshold int64 `json:"outbound_threshold"`
+	// MonitorMode evaluate and log the policies with the action they would take, but never enforce it, WAFEnabled required
+	MonitorMode bool `json:"monitor_mode"`
+	// ResponseInspectKB is the size of decompressed response body to be inspected, the remaining is streamed through
+	ResponseInspectKB int64 `json:"response_inspect_kb"`
 }
 
 type DBApplication struct {
-	ID             int64    `json:"id"`
-	Name           string   `json:"name"`
-	InternalScheme string   `json:"internal_scheme"` // http, https
-	RedirectHTTPS  bool     `json:"redirect_https"`
-	HSTSEnabled    bool     `json:"hsts_enabled"`
-	WAFEnabled     bool     `json:"waf_enabled"`
-	ClientIPMethod IPMethod `json:"ip_method"`
-	Description    string   `json:"description"`
-	OAuthRequired  bool     `json:"oauth_required"`
-	SessionSeconds int64    `json:"session_seconds"`
-	Owner          string   `json:"owner"`
+	ID                int64    `json:"id"`
+	Name              string   `json:"name"`
+	InternalScheme    string   `json:"internal_scheme"` // http, https
+	RedirectHTTPS     bool     `json:"redirect_https"`
+	HSTSEnabled       bool     `json:"hsts_enabled"`
+	WAFEnabled        bool     `json:"waf_enabled"`
+	ClientIPMethod    IPMethod `json:"ip_method"`
+	Description       string   `json:"description"`
+	OAuthRequired     bool     `json:"oauth_required"`
+	SessionSeconds    int64    `json:"session_seconds"`
+	Owner             string   `json:"owner"`
+	TLSProfileID      int64    `json:"tls_profile_id"`
+	ScoringEnabled    bool     `json:"scoring_enabled"`
+	InboundThreshold  int64    `json:"inbound_threshold"`
+	OutboundThreshold int64    `json:"outbound_threshold"`
+	MonitorMode       bool     `json:"monitor_mode"`
+	ResponseInspectKB int64    `json:"response_inspect_kb"`
 }
 
 type DomainRelation struct {
-	App      *Application
-	Cert     *CertItem
+	App  *Application
+	Cert *CertItem
+	// AltCert is the optional second certificate with another key type, e.g. ECDSA + RSA
+	AltCert  *CertItem
 	Redirect bool
 	Location string
+	// TLSProfileID of the domain, use the application one if 0
+	TLSProfileID int64
 }
 
 type Domain struct {
-	ID       int64        `json:"id"`
-	Name     string       `json:"name"`
-	AppID    int64        `json:"app_id"`
-	CertID   int64        `json:"cert_id"`
-	Redirect bool         `json:"redirect"`
-	Location string       `json:"location"`
-	App      *Application `json:"-"`
-	Cert     *CertItem    `json:"-"`
-}
-
-type DBDomain struct {
 	ID       int64  `json:"id"`
 	Name     string `json:"name"`
 	AppID    int64  `json:"app_id"`
 	CertID   int64  `json:"cert_id"`
 	Redirect bool   `json:"redirect"`
 	Location string `json:"location"`
+	// AltCertID is the optional second certificate with another key type, e.g. ECDSA + RSA
+	AltCertID int64 `json:"alt_cert_id"`
+	// TLSProfileID overrides the profile of application if not 0
+	TLSProfileID int64        `json:"tls_profile_id"`
+	App          *Application `json:"-"`
+	Cert         *CertItem    `json:"-"`
+	AltCert      *CertItem    `json:"-"`
+}
+
+type DBDomain struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	AppID        int64  `json:"app_id"`
+	CertID       int64  `json:"cert_id"`
+	Redirect     bool   `json:"redirect"`
+	Location     string `json:"location"`
+	AltCertID    int64  `json:"alt_cert_id"`
+	TLSProfileID int64  `json:"tls_profile_id"`
 }
 
 // RouteType used for backend routing
@@ -117,6 +145,32 @@ type CertItem struct {
 	TlsCert        tls.Certificate `json:"-"`
 	ExpireTime     int64           `json:"expire_time"`
 	Description    string          `json:"description"`
+
+	// ACME is true if the certificate is issued and renewed automatically
+	ACME bool `json:"acme"`
+
+	// OCSP is the status of the stapled OCSP response, nil if not checked yet
+	OCSP *OCSPStatus `json:"ocsp"`
+	// ActiveCert (*tls.Certificate) replaces TlsCert in handshakes once set, e.g. with the stapled OCSP response,
+	// it is swapped as a whole instead of modifying the certificate being used.
+	ActiveCert atomic.Value `json:"-"`
+
+	// DNSNames is the SANs of the certificate
+	DNSNames []string `json:"dns_names"`
+	// SuggestedDomains is the SANs not configured as domains, only returned by import
+	SuggestedDomains []string `json:"suggested_domains,omitempty"`
+}
+
+// OCSPStatus of the certificate, Status: good, revoked, unknown, unavailable, none (no OCSP responder)
+type OCSPStatus struct {
+	Status     string `json:"status"`
+	Responder  string `json:"responder"`
+	ThisUpdate int64  `json:"this_update"`
+	NextUpdate int64  `json:"next_update"`
+	RevokedAt  int64  `json:"revoked_at"`
+	LastCheck  int64  `json:"last_check"`
+	Stapled    bool   `json:"stapled"`
+	Error      string `json:"error"`
 }
 
 type DBCertItem struct {
@@ -126,6 +180,91 @@ type DBCertItem struct {
 	EncryptedPrivKey []byte
 	ExpireTime       int64
 	Description      sql.NullString
+	ACME             bool
+}
+
+// ACMEChallenge is the pending challenge of ACME authorization
+type ACMEChallenge struct {
+	Token  string `json:"token"`
+	Domain string `json:"domain"`
+	// KeyAuth is the response of http-01
+	KeyAuth string `json:"key_auth"`
+	// CertContent and PrivKeyContent are the certificate of tls-alpn-01
+	CertContent    string           `json:"cert_content"`
+	PrivKeyContent string           `json:"priv_key_content"`
+	TlsCert        *tls.Certificate `json:"-"`
+}
+
+// InternalCA is the managed CA used to issue certificates for internal applications and backend mTLS
+type InternalCA struct {
+	ID         int64  `json:"id"`
+	CommonName string `json:"common_name"`
+	// CertContent is the CA certificate followed by its issuers if it is an intermediate
+	CertContent string `json:"cert_content"`
+	IsRoot      bool   `json:"is_root"`
+	ExpireTime  int64  `json:"expire_time"`
+	CreateTime  int64  `json:"create_time"`
+}
+
+type DBInternalCA struct {
+	ID               int64
+	CertContent      string
+	EncryptedPrivKey []byte
+	CreateTime       int64
+}
+
+// CertificateAlert is the result of certificate monitoring
+type CertificateAlert struct {
+	CertID     int64  `json:"cert_id"`
+	CommonName string `json:"common_name"`
+	// Domain is set for name_mismatch
+	Domain     string `json:"domain"`
+	ExpireTime int64  `json:"expire_time"`
+	// Type: expiring, expired, revoked, name_mismatch
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Alert is sent to the alerting channels
+type Alert struct {
+	Source  string `json:"source"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Time    int64  `json:"time"`
+}
+
+// TLSProfile is the named TLS policy attached to applications or domains
+type TLSProfile struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// MinVersion and MaxVersion: TLS1.0, TLS1.1, TLS1.2, TLS1.3
+	MinVersion string `json:"min_version"`
+	MaxVersion string `json:"max_version"`
+	// CipherSuites used by TLS1.2 and below, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	// the TLS1.3 suites are AEAD and not configurable
+	CipherSuites []string `json:"cipher_suites"`
+	// Curves: X25519, P256, P384, P521
+	Curves []string `json:"curves"`
+	// NextProtos is ALPN, e.g. h2, http/1.1
+	NextProtos  []string `json:"next_protos"`
+	Description string   `json:"description"`
+}
+
+// AuditLog record the sensitive operations of administrators, such as exporting private keys
+type AuditLog struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	Action      string `json:"action"`
+	Object      string `json:"object"`
+	SrcIP       string `json:"src_ip"`
+	RequestTime int64  `json:"request_time"`
+}
+
+// CertBundle is the exported certificate, Content is base64 encoded for pkcs12
+type CertBundle struct {
+	Format   string `json:"format"`
+	Filename string `json:"filename"`
+	Content  string `json:"content"`
 }
 
 // AuthUser used for Authentication in Memory
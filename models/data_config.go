@@ -12,6 +12,7 @@ type Config struct {
 	NodeRole    string            `json:"node_role"`
 	PrimaryNode PrimaryNodeConfig `json:"primary_node"`
 	ReplicaNode ReplicaNodeConfig `json:"replica_node"`
+	GeoIP       GeoIPConfig       `json:"geoip"`
 }
 
 type OAuthConfig struct {
@@ -27,6 +28,8 @@ type PrimaryNodeConfig struct {
 	Admin    AdminConfig `json:"admin"`
 	Database DBConfig    `json:"database"`
 	OAuth    OAuthConfig `json:"oauth"`
+	ACME     ACMEConfig  `json:"acme"`
+	Alert    AlertConfig `json:"alert"`
 }
 
 type ReplicaNodeConfig struct {
@@ -55,6 +58,13 @@ type EncryptedConfig struct {
 	NodeRole    string            `json:"node_role"`
 	PrimaryNode PrimaryNodeConfig `json:"primary_node"`
 	ReplicaNode ReplicaNodeConfig `json:"replica_node"`
+	GeoIP       GeoIPConfig       `json:"geoip"`
+}
+
+// GeoIPConfig is the local MaxMind DB files of each node, e.g. GeoLite2-Country.mmdb and GeoLite2-ASN.mmdb,
+// the country and ASN of a client are taken from the first database which has them
+type GeoIPConfig struct {
+	Databases []string `json:"databases"`
 }
 
 type WxworkConfig struct {
@@ -87,3 +97,20 @@ type LDAPConfig struct {
 	UsingTLS             bool   `json:"using_tls"`
 	AuthenticatorEnabled bool   `json:"authenticator_enabled"`
 }
+
+// ACMEConfig used for automatic certificate issuance (RFC 8555)
+type ACMEConfig struct {
+	Enabled      bool   `json:"enabled"`
+	DirectoryURL string `json:"directory_url"`
+	Email        string `json:"email"`
+	// ChallengeType is http-01 or tls-alpn-01
+	ChallengeType string `json:"challenge_type"`
+	// CACertFile is the trusted root of directory_url, e.g. pebble.minica.pem for a local Pebble
+	CACertFile      string `json:"ca_cert_file"`
+	RenewBeforeDays int64  `json:"renew_before_days"`
+}
+
+// AlertConfig is the alerting channels besides the log, each alert is posted to Webhooks in JSON
+type AlertConfig struct {
+	Webhooks []string `json:"webhooks"`
+}
@@ -18,6 +18,8 @@ const (
 	Action_BypassAndLog_200 PolicyAction = 200
 	Action_CAPTCHA_300      PolicyAction = 300
 	Action_Pass_400         PolicyAction = 400
+	// Action_Mask_500 rewrite the matched content of response bodies, ChkPointResponseBody only
+	Action_Mask_500 PolicyAction = 500
 )
 
 type CCPolicy struct {
@@ -35,22 +37,30 @@ type CCPolicy struct {
 type ChkPoint int64
 
 const (
-	ChkPointHost                ChkPoint = 1
-	ChkPointIPAddress           ChkPoint = 1 << 1
-	ChkPointMethod              ChkPoint = 1 << 2
-	ChkPointURLPath             ChkPoint = 1 << 3
-	ChkPointURLQuery            ChkPoint = 1 << 4
-	ChkPointValueLength         ChkPoint = 1 << 6
-	ChkPointGetPostKey          ChkPoint = 1 << 7
-	ChkPointGetPostValue        ChkPoint = 1 << 8
-	ChkPointUploadFileExt       ChkPoint = 1 << 9
-	ChkPointCookieKey           ChkPoint = 1 << 11
-	ChkPointCookieValue         ChkPoint = 1 << 12
-	ChkPointUserAgent           ChkPoint = 1 << 13
-	ChkPointContentType         ChkPoint = 1 << 14
-	ChkPointHeaderKey           ChkPoint = 1 << 15
-	ChkPointHeaderValue         ChkPoint = 1 << 16
-	ChkPointProto               ChkPoint = 1 << 17
+	ChkPointHost          ChkPoint = 1
+	ChkPointIPAddress     ChkPoint = 1 << 1
+	ChkPointMethod        ChkPoint = 1 << 2
+	ChkPointURLPath       ChkPoint = 1 << 3
+	ChkPointURLQuery      ChkPoint = 1 << 4
+	ChkPointValueLength   ChkPoint = 1 << 6
+	ChkPointGetPostKey    ChkPoint = 1 << 7
+	ChkPointGetPostValue  ChkPoint = 1 << 8
+	ChkPointUploadFileExt ChkPoint = 1 << 9
+	ChkPointCookieKey     ChkPoint = 1 << 11
+	ChkPointCookieValue   ChkPoint = 1 << 12
+	ChkPointUserAgent     ChkPoint = 1 << 13
+	ChkPointContentType   ChkPoint = 1 << 14
+	ChkPointHeaderKey     ChkPoint = 1 << 15
+	ChkPointHeaderValue   ChkPoint = 1 << 16
+	ChkPointProto         ChkPoint = 1 << 17
+	// The GraphQL check points get the depth, alias count, field count (fragments expanded), batch size and field names
+	ChkPointGraphQLDepth        ChkPoint = 1 << 18
+	ChkPointGraphQLAliases      ChkPoint = 1 << 19
+	ChkPointGraphQLFields       ChkPoint = 1 << 20
+	ChkPointGraphQLBatch        ChkPoint = 1 << 21
+	ChkPointGraphQLFieldName    ChkPoint = 1 << 22
+	ChkPointXMLDepth            ChkPoint = 1 << 23 // depth of nested XML elements
+	ChkPointXMLValues           ChkPoint = 1 << 24 // count of XML element text and attribute values
 	ChkPointResponseStatusCode  ChkPoint = 1 << 25
 	ChkPointResponseHeaderKey   ChkPoint = 1 << 26
 	ChkPointResponseHeaderValue ChkPoint = 1 << 27
@@ -70,6 +80,38 @@ type GroupPolicy struct {
 	UserID      int64        `json:"user_id"`
 	User        *AppUser     `json:"-"`
 	UpdateTime  int64        `json:"update_time"`
+	// Score is added to the anomaly score of request when matched in scoring mode
+	Score int64 `json:"score"`
+	// Scope limits the policy to some requests of the application, nil for all requests
+	Scope *PolicyScope `json:"scope"`
+}
+
+// PolicyScope the requests out of scope skip the policy, the empty fields match any request
+type PolicyScope struct {
+	// Host is the exact host of request (case insensitive), with or without port
+	Host string `json:"host"`
+	// Methods of request, e.g. ["GET", "POST"]
+	Methods []string `json:"methods"`
+	// PathPrefix and PathRegex are checked against the URL path, both are required to match if set
+	PathPrefix string `json:"path_prefix"`
+	PathRegex  string `json:"path_regex"`
+}
+
+const (
+	// DefaultPolicyScore is the score of critical policies, the same as the default inbound threshold
+	DefaultPolicyScore       = 5
+	DefaultInboundThreshold  = 5
+	DefaultOutboundThreshold = 4
+	// DefaultResponseInspectKB and MaxResponseInspectKB of the response body inspection
+	DefaultResponseInspectKB = 64
+	MaxResponseInspectKB     = 10240
+)
+
+// AnomalyScore is the sum of scores of the matched group policies in scoring mode
+type AnomalyScore struct {
+	Total     int64   `json:"total"`
+	Threshold int64   `json:"threshold"`
+	PolicyIDs []int64 `json:"policy_ids"`
 }
 
 /*
@@ -93,6 +135,107 @@ const (
 	OperationEqualsStringCaseInSensitive Operation = 1 << 1
 	OperationGreaterThanInteger          Operation = 1 << 2
 	OperationEqualsInteger               Operation = 1 << 3
+	OperationContains                    Operation = 1 << 4
+	OperationStartsWith                  Operation = 1 << 5
+	OperationEndsWith                    Operation = 1 << 6
+	// OperationIPInCIDR regex_policy is the IPs or CIDRs separated by comma, IPv4 and IPv6
+	OperationIPInCIDR Operation = 1 << 7
+	// OperationInNamedList regex_policy is the name of list
+	OperationInNamedList     Operation = 1 << 8
+	OperationLessThanInteger Operation = 1 << 9
+	// OperationIntegerRange regex_policy is "min,max", inclusive
+	OperationIntegerRange Operation = 1 << 10
+	// OperationDetectSQLi and OperationDetectXSS use the built-in detectors, regex_policy is not used
+	OperationDetectSQLi Operation = 1 << 11
+	OperationDetectXSS  Operation = 1 << 12
+	// OperationDetectCreditCard (Luhn validated) and OperationDetectNationalID (resident ID of China, checksum validated)
+	OperationDetectCreditCard Operation = 1 << 13
+	OperationDetectNationalID Operation = 1 << 14
+	// OperationNot is combined with other operations to negate the result, e.g. OperationNot|OperationIPInCIDR
+	OperationNot Operation = 1 << 15
+)
+
+type NamedListType int64
+
+const (
+	// NamedListTypeIP items are IPs or CIDRs
+	NamedListTypeIP NamedListType = 1
+	// NamedListTypeString items are exact strings, such as paths and user agents
+	NamedListTypeString NamedListType = 2
+)
+
+// NamedList is referenced by the check items with OperationInNamedList
+type NamedList struct {
+	ID          int64         `json:"id"`
+	Name        string        `json:"name"`
+	ListType    NamedListType `json:"list_type"`
+	Items       []string      `json:"items"`
+	Description string        `json:"description"`
+	UpdateTime  int64         `json:"update_time"`
+}
+
+type ExclusionTarget int64
+
+const (
+	// ExclusionTargetAll excludes the policies for all check points of the request
+	ExclusionTargetAll ExclusionTarget = 0
+	// ExclusionTargetParameter is a parameter of query, form, multipart or JSON (the key of object)
+	ExclusionTargetParameter ExclusionTarget = 1
+	ExclusionTargetCookie    ExclusionTarget = 2
+	ExclusionTargetHeader    ExclusionTarget = 3
+)
+
+// RuleExclusion skip a group policy, or all policies of a vulnerability type, for a target on a path, app_id 0 is global
+type RuleExclusion struct {
+	ID    int64 `json:"id"`
+	AppID int64 `json:"app_id"`
+	// PolicyID or VulnID of the excluded policies, at least one of them is required
+	PolicyID   int64           `json:"policy_id"`
+	VulnID     int64           `json:"vuln_id"`
+	TargetType ExclusionTarget `json:"target_type"`
+	// TargetName is the name of parameter or cookie (case sensitive) or header (case insensitive), empty for any
+	TargetName string `json:"target_name"`
+	// PathPrefix of the URL path, empty for any
+	PathPrefix  string `json:"path_prefix"`
+	Description string `json:"description"`
+	UpdateTime  int64  `json:"update_time"`
+}
+
+// IPACL allow or deny the clients by IPs, countries and ASNs, app_id 0 is global
+type IPACL struct {
+	ID    int64 `json:"id"`
+	AppID int64 `json:"app_id"`
+	// Action is Action_Pass_400 (allow, skip CC and group policies) or Action_Block_100 (deny)
+	Action PolicyAction `json:"action"`
+	// IPs are IPv4/IPv6 IPs or CIDRs
+	IPs []string `json:"ips"`
+	// Countries are ISO 3166-1 alpha-2 codes in upper case, e.g. CN, US
+	Countries []string `json:"countries"`
+	ASNs      []int64  `json:"asns"`
+	// Negated matches the clients not in IPs, countries and ASNs, e.g. deny all except the office
+	Negated     bool   `json:"negated"`
+	Description string `json:"description"`
+	UpdateTime  int64  `json:"update_time"`
+}
+
+// GeoIPRecord is the location of a client IP, empty if the IP is not found in GeoIP databases
+type GeoIPRecord struct {
+	Country string `json:"country"`
+	ASN     int64  `json:"asn"`
+	ASOrg   string `json:"as_org"`
+}
+
+// Transforms of check item, applied in order to the raw value before matching
+const (
+	TransformURLDecode          = "url_decode"
+	TransformHTMLEntityDecode   = "html_entity_decode"
+	TransformUnicodeDecode      = "unicode_decode"
+	TransformBase64Decode       = "base64_decode"
+	TransformLowercase          = "lowercase"
+	TransformCompressWhitespace = "compress_whitespace"
+	TransformRemoveComments     = "remove_comments"
+	TransformNormalizePath      = "normalize_path"
+	TransformHexDecode          = "hex_decode"
 )
 
 type CheckItem struct {
@@ -103,6 +246,8 @@ type CheckItem struct {
 	RegexPolicy   string       `json:"regex_policy"`
 	GroupPolicyID int64        `json:"group_policy_id"`
 	GroupPolicy   *GroupPolicy `json:"-"`
+	// Transforms replace the default decoding of check point if not empty
+	Transforms []string `json:"transforms"`
 }
 
 /*
@@ -127,10 +272,69 @@ type VulnType struct {
 }
 
 type RegexMatch struct {
-	Pattern    string `json:"pattern"`
-	Payload    string `json:"payload"`
-	Matched    bool   `json:"matched"`
-	PreProcess bool   `json:"preprocess"`
+	Pattern    string           `json:"pattern"`
+	Payload    string           `json:"payload"`
+	Matched    bool             `json:"matched"`
+	PreProcess bool             `json:"preprocess"`
+	Transforms []string         `json:"transforms"`
+	Steps      []*TransformStep `json:"steps"`
+}
+
+// TransformStep is the value after a transform
+type TransformStep struct {
+	Transform string `json:"transform"`
+	Value     string `json:"value"`
+}
+
+// ReplayMatch is a check item matched by the replayed request
+type ReplayMatch struct {
+	CheckItemID int64    `json:"check_item_id"`
+	PolicyID    int64    `json:"policy_id"`
+	CheckPoint  ChkPoint `json:"check_point"`
+	// Value is the checked value after transforms, truncated
+	Value string `json:"value"`
+	// HitValue is the hit value of the policy after this match, the policy is hit if it equals PolicyHitValue
+	HitValue       int64 `json:"hit_value"`
+	PolicyHitValue int64 `json:"policy_hit_value"`
+	// ExclusionID is the rule exclusion which skipped this match, 0 if not skipped
+	ExclusionID int64 `json:"exclusion_id"`
+}
+
+// ReplayResult is the result of replaying a raw request against the live policies, CC policies are not evaluated
+type ReplayResult struct {
+	AppID    int64  `json:"app_id"`
+	ClientIP string `json:"client_ip"`
+	// IsHit, Action, PolicyID and VulnID are the decision of the gateway
+	IsHit    bool         `json:"is_hit"`
+	Action   PolicyAction `json:"action"`
+	PolicyID int64        `json:"policy_id"`
+	VulnID   int64        `json:"vuln_id"`
+	IPACLID  int64        `json:"ip_acl_id"`
+	// Monitor is true if the action is only logged in monitor mode
+	Monitor      bool          `json:"monitor"`
+	Reason       string        `json:"reason"`
+	AnomalyScore *AnomalyScore `json:"anomaly_score"`
+	// Matches are all check items matched, including the ones after the hit policy
+	Matches []*ReplayMatch `json:"matches"`
+	// HitValues are the accumulated hit values, map[policyID]hitValue
+	HitValues map[int64]int64 `json:"hit_values"`
+}
+
+// SecRuleIssue is a construct of the ModSecurity rules which can not be imported exactly
+type SecRuleIssue struct {
+	Line   int64  `json:"line"`
+	RuleID string `json:"rule_id"`
+	// Level is error (the rule is skipped) or warning (the rule is imported, the construct is ignored)
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// SecRuleImportResult is the result of importing ModSecurity rules
+type SecRuleImportResult struct {
+	RuleCount     int64           `json:"rule_count"`
+	GroupPolicies []*GroupPolicy  `json:"group_policies"`
+	Issues        []*SecRuleIssue `json:"issues"`
+	DryRun        bool            `json:"dry_run"`
 }
 
 type CCLog struct {
@@ -147,6 +351,8 @@ type CCLog struct {
 	RawRequest  string       `json:"raw_request"`
 	Action      PolicyAction `json:"action"`
 	AppID       int64        `json:"app_id"`
+	// Monitor is true if the action was not taken (monitor mode)
+	Monitor bool `json:"monitor"`
 }
 
 type SimpleCCLog struct {
@@ -158,6 +364,7 @@ type SimpleCCLog struct {
 	UrlPath     string       `json:"url_path"`
 	Action      PolicyAction `json:"action"`
 	AppID       int64        `json:"app_id"`
+	Monitor     bool         `json:"monitor"`
 }
 
 type GroupHitLog struct {
@@ -176,6 +383,16 @@ type GroupHitLog struct {
 	PolicyID    int64        `json:"policy_id"`
 	VulnID      int64        `json:"vuln_id"`
 	AppID       int64        `json:"app_id"`
+	// Score and PolicyIDs are the anomaly score and all contributing policies in scoring mode
+	Score     int64   `json:"score"`
+	PolicyIDs []int64 `json:"policy_ids"`
+	// Monitor is true if the action was not taken (monitor mode)
+	Monitor bool `json:"monitor"`
+	// Scope of the policy when it was hit, empty for all requests
+	Scope string `json:"scope"`
+	// Excluded is true if the match was skipped by the rule exclusion ExclusionID, the action was not taken
+	Excluded    bool  `json:"excluded"`
+	ExclusionID int64 `json:"exclusion_id"`
 }
 
 type SimpleGroupHitLog struct {
@@ -188,6 +405,45 @@ type SimpleGroupHitLog struct {
 	Action      PolicyAction `json:"action"`
 	PolicyID    int64        `json:"policy_id"`
 	AppID       int64        `json:"app_id"`
+	Monitor     bool         `json:"monitor"`
+	Scope       string       `json:"scope"`
+	Excluded    bool         `json:"excluded"`
+}
+
+type ACLHitLog struct {
+	ID          int64        `json:"id"`
+	RequestTime int64        `json:"request_time"`
+	ClientIP    string       `json:"client_ip"`
+	Host        string       `json:"host"`
+	Method      string       `json:"method"`
+	UrlPath     string       `json:"url_path"`
+	UrlQuery    string       `json:"url_query"`
+	ContentType string       `json:"content_type"`
+	UserAgent   string       `json:"user_agent"`
+	Cookies     string       `json:"cookies"`
+	RawRequest  string       `json:"raw_request"`
+	Action      PolicyAction `json:"action"`
+	ACLID       int64        `json:"acl_id"`
+	AppID       int64        `json:"app_id"`
+	Country     string       `json:"country"`
+	ASN         int64        `json:"asn"`
+	// Monitor is true if the action was not taken (monitor mode)
+	Monitor bool `json:"monitor"`
+}
+
+type SimpleACLHitLog struct {
+	ID          int64        `json:"id"`
+	RequestTime int64        `json:"request_time"`
+	ClientIP    string       `json:"client_ip"`
+	Host        string       `json:"host"`
+	Method      string       `json:"method"`
+	UrlPath     string       `json:"url_path"`
+	Action      PolicyAction `json:"action"`
+	ACLID       int64        `json:"acl_id"`
+	AppID       int64        `json:"app_id"`
+	Country     string       `json:"country"`
+	ASN         int64        `json:"asn"`
+	Monitor     bool         `json:"monitor"`
 }
 
 type HitLogsCount struct {
@@ -195,6 +451,8 @@ type HitLogsCount struct {
 	StartTime int64 `json:"start_time"`
 	EndTime   int64 `json:"end_time"`
 	Count     int64 `json:"count"`
+	// ExcludedCount of the group hit logs skipped by rule exclusions, included in Count
+	ExcludedCount int64 `json:"excluded_count"`
 }
 
 type VulnStat struct {
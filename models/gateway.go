@@ -8,7 +8,7 @@
 package models
 
 type HitInfo struct {
-	TypeID    int64 // 1: CCPolicy  2:GroupPolicy
+	TypeID    int64 // 1: CCPolicy  2:GroupPolicy  3:IPACL
 	PolicyID  int64
 	VulnName  string
 	Action    PolicyAction
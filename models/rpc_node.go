@@ -36,6 +36,14 @@ type RPCCCLogRequest struct {
 	Object   *CCLog `json:"object"`
 }
 
+type RPCACLHitLogRequest struct {
+	Action   string     `json:"action"`
+	ObjectID int64      `json:"id"`
+	NodeID   int64      `json:"node_id"`
+	AuthKey  string     `json:"auth_key"`
+	Object   *ACLHitLog `json:"object"`
+}
+
 type RPCCertItems struct {
 	Error  *string     `json:"err"`
 	Object []*CertItem `json:"object"`
@@ -46,6 +54,11 @@ type RPCApplications struct {
 	Object []*Application `json:"object"`
 }
 
+type RPCTLSProfiles struct {
+	Error  *string       `json:"err"`
+	Object []*TLSProfile `json:"object"`
+}
+
 type RPCDBDomains struct {
 	Error  *string     `json:"err"`
 	Object []*DBDomain `json:"object"`
@@ -66,6 +79,21 @@ type RPCVulntypes struct {
 	Object []*VulnType `json:"object"`
 }
 
+type RPCNamedLists struct {
+	Error  *string      `json:"err"`
+	Object []*NamedList `json:"object"`
+}
+
+type RPCIPACLs struct {
+	Error  *string  `json:"err"`
+	Object []*IPACL `json:"object"`
+}
+
+type RPCRuleExclusions struct {
+	Error  *string          `json:"err"`
+	Object []*RuleExclusion `json:"object"`
+}
+
 type RPCSettings struct {
 	Error  *string    `json:"err"`
 	Object []*Setting `json:"object"`
@@ -80,3 +108,8 @@ type RPCTOTP struct {
 	Error  *string `json:"err"`
 	Object *TOTP   `json:"object"`
 }
+
+type RPCACMEChallenge struct {
+	Error  *string        `json:"err"`
+	Object *ACMEChallenge `json:"object"`
+}
@@ -45,6 +45,10 @@ func UpdateTimeTick() {
 					updateTicker.Stop()
 					updateTicker = time.NewTicker(data.Sync_Seconds * time.Second)
 				}
+			default:
+				if value, ok := settingItem.Value.(float64); ok {
+					LoadIntSetting(settingItem.Name, int64(value))
+				}
 			}
 		}
 	}
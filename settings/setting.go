@@ -8,6 +8,7 @@
 package settings
 
 import (
+	"errors"
 	"time"
 
 	"asec/data"
@@ -29,6 +30,21 @@ func InitDefaultSettings() {
 	if data.DAL.ExistsSetting("Log_Expire_Seconds") == false {
 		data.DAL.SaveIntSetting("Log_Expire_Seconds", 7*86400)
 	}
+	if data.DAL.ExistsSetting("Cache_Revalidate_Seconds") == false {
+		data.DAL.SaveIntSetting("Cache_Revalidate_Seconds", data.Cache_Revalidate_Seconds)
+	}
+	if data.DAL.ExistsSetting("Cache_Stale_While_Revalidate_Seconds") == false {
+		data.DAL.SaveIntSetting("Cache_Stale_While_Revalidate_Seconds", data.Cache_Stale_While_Revalidate_Seconds)
+	}
+	if data.DAL.ExistsSetting("Cache_Stale_If_Error_Seconds") == false {
+		data.DAL.SaveIntSetting("Cache_Stale_If_Error_Seconds", data.Cache_Stale_If_Error_Seconds)
+	}
+	if data.DAL.ExistsSetting("Cert_Expiry_Alert_Days") == false {
+		data.DAL.SaveIntSetting("Cert_Expiry_Alert_Days", data.GetCertExpiryAlertDays())
+	}
+	if data.DAL.ExistsSetting("Default_Cert_ID") == false {
+		data.DAL.SaveIntSetting("Default_Cert_ID", data.GetDefaultCertID())
+	}
 }
 
 func LoadSettings() {
@@ -40,6 +56,16 @@ func LoadSettings() {
 		data.Settings = append(data.Settings, &models.Setting{Name: "Backend_Last_Modified", Value: data.Backend_Last_Modified})
 		data.Settings = append(data.Settings, &models.Setting{Name: "Firewall_Last_Modified", Value: data.Firewall_Last_Modified})
 		data.Settings = append(data.Settings, &models.Setting{Name: "Sync_Seconds", Value: data.Sync_Seconds})
+		for _, name := range []string{"Cache_Revalidate_Seconds", "Cache_Stale_While_Revalidate_Seconds", "Cache_Stale_If_Error_Seconds"} {
+			value, _ := data.DAL.SelectIntSetting(name)
+			data.SetCacheWindow(name, value)
+			data.Settings = append(data.Settings, &models.Setting{Name: name, Value: value})
+		}
+		for _, name := range []string{"Cert_Expiry_Alert_Days", "Default_Cert_ID"} {
+			value, _ := data.DAL.SelectIntSetting(name)
+			data.SetCertSetting(name, value)
+			data.Settings = append(data.Settings, &models.Setting{Name: name, Value: value})
+		}
 	} else {
 		// Load OAuth Config
 		data.CFG.PrimaryNode.OAuth = *(data.RPCGetOAuthConfig())
@@ -53,12 +79,61 @@ func LoadSettings() {
 				data.Firewall_Last_Modified = int64(setting_item.Value.(float64))
 			case "Sync_Seconds":
 				data.Sync_Seconds = time.Duration(setting_item.Value.(float64))
+			default:
+				if value, ok := setting_item.Value.(float64); ok {
+					LoadIntSetting(setting_item.Name, int64(value))
+				}
 			}
 		}
 		go UpdateTimeTick()
 	}
 }
 
+// LoadIntSetting apply the modifiable int setting to memory, return false if not supported
+func LoadIntSetting(name string, value int64) bool {
+	return data.SetCacheWindow(name, value) || data.SetCertSetting(name, value)
+}
+
 func GetSettings() ([]*models.Setting, error) {
 	return data.Settings, nil
 }
+
+// UpdateSettings modify settings from administrators, object: [{"name":"xxx","value":123}]
+func UpdateSettings(param map[string]interface{}, authUser *models.AuthUser) ([]*models.Setting, error) {
+	if authUser.IsSuperAdmin == false {
+		return nil, errors.New("Only super administrators can modify settings.")
+	}
+	settingItems, ok := param["object"].([]interface{})
+	if !ok {
+		return nil, errors.New("UpdateSettings parse object error")
+	}
+	for _, settingItem := range settingItems {
+		settingMap, ok := settingItem.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("UpdateSettings parse setting error")
+		}
+		name, ok := settingMap["name"].(string)
+		if !ok {
+			return nil, errors.New("UpdateSettings setting name is required")
+		}
+		valueFloat, ok := settingMap["value"].(float64)
+		if !ok {
+			return nil, errors.New("Setting " + name + " should be a number.")
+		}
+		value := int64(valueFloat)
+		if value < 0 {
+			return nil, errors.New("Setting " + name + " should not be negative.")
+		}
+		if LoadIntSetting(name, value) == false {
+			return nil, errors.New("Setting " + name + " is not modifiable.")
+		}
+		err := data.DAL.SaveIntSetting(name, value)
+		if err != nil {
+			return nil, err
+		}
+		if setting := data.GetSettingByName(name); setting != nil {
+			setting.Value = value
+		}
+	}
+	return data.Settings, nil
+}
@@ -2,17 +2,21 @@
  * @Copyright Reserved By asec (https://www.asec.com/).
  * @Author: thonsun
  * @Date: 2020-10-05 16:20:56
- * @Last Modified: thonsun, 2020-10-05  16:20:56
+ * @Last Modified: thonsun, 2026-10-19 15:48:12
  */
 
 package utils
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"math/big"
 	"strings"
 	"time"
@@ -23,19 +27,65 @@ type SelfSignedCertificate struct {
 	PrivKeyContent string `json:"priv_key_content"`
 }
 
+// GeneratePrivateKey generate RSA 2048 or ECDSA P-256 key, keyType: rsa (default), ecdsa
+func GeneratePrivateKey(keyType string) (priv crypto.Signer, privKeyContent string, err error) {
+	var block *pem.Block
+	switch strings.ToLower(keyType) {
+	case "", "rsa":
+		rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, "", err
+		}
+		priv = rsaKey
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}
+	case "ecdsa":
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, "", err
+		}
+		der, err := x509.MarshalECPrivateKey(ecKey)
+		if err != nil {
+			return nil, "", err
+		}
+		priv = ecKey
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	default:
+		return nil, "", errors.New("Unknown key type " + keyType)
+	}
+	return priv, string(pem.EncodeToMemory(block)), nil
+}
+
+// GetLeafKeyUsage return the key usage of leaf certificate by key algorithm
+func GetLeafKeyUsage(priv crypto.Signer) x509.KeyUsage {
+	if _, isRSA := priv.(*rsa.PrivateKey); isRSA {
+		return x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+	}
+	return x509.KeyUsageDigitalSignature
+}
+
+// NewSerialNumber return a random 128 bits serial number
+func NewSerialNumber() (*big.Int, error) {
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	return rand.Int(rand.Reader, serialNumberLimit)
+}
+
+// GenerateRSACertificate generate self-signed certificate, object: {"common_name": "...", "key_type": "rsa"}
 func GenerateRSACertificate(param map[string]interface{}) (selfSignedCert *SelfSignedCertificate, err error) {
 	reqObj := param["object"].(map[string]interface{})
 	commonName := reqObj["common_name"].(string)
+	keyType, _ := reqObj["key_type"].(string)
 	org := strings.ToUpper(commonName)
 	dotIndex := strings.Index(org, ".")
 	if dotIndex > 0 {
 		org = org[dotIndex+1:]
 	}
-	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	priv, privKeyContent, err := GeneratePrivateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
 	notBefore := time.Now()
 	notAfter := notBefore.Add(3653 * 24 * time.Hour)
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	serialNumber, err := NewSerialNumber()
 	if err != nil {
 		return nil, err
 	}
@@ -46,18 +96,17 @@ func GenerateRSACertificate(param map[string]interface{}) (selfSignedCert *SelfS
 		},
 		NotBefore:             notBefore,
 		NotAfter:              notAfter,
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		KeyUsage:              GetLeafKeyUsage(priv),
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
 		DNSNames:              []string{commonName},
 	}
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, priv.Public(), priv)
 	//fmt.Println("derBytes=", derBytes)
 	if err != nil {
 		return nil, err
 	}
 	pubCertBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
-	privKeyBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
-	selfSignedCert = &SelfSignedCertificate{CertContent: string(pubCertBytes), PrivKeyContent: string(privKeyBytes)}
+	selfSignedCert = &SelfSignedCertificate{CertContent: string(pubCertBytes), PrivKeyContent: privKeyContent}
 	return selfSignedCert, nil
 }
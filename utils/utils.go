@@ -52,9 +52,9 @@ func GetRoutePath(path string) string {
 // DebugPrintln used for log of error
 func DebugPrintln(a ...interface{}) {
 	if Debug {
-		log.Println(a)
+		log.Println(a...)
 	} else {
-		logger.Println(a)
+		logger.Println(a...)
 	}
 }
 
@@ -63,7 +63,7 @@ func AccessLog(domain string, method string, ip string, url string, ua string) {
 	now := time.Now()
 	f, err := os.OpenFile("./log/"+domain+now.Format("20060102")+".log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
-		log.Println("error opening file: %v", err)
+		log.Println("error opening file:", err)
 	}
 	defer f.Close()
 	log.SetOutput(f)